@@ -1,23 +1,52 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
-	"log/slog"
+	"fmt"
+	"io"
 	"os"
-	"slices"
+	"os/exec"
+	"os/signal"
 
 	"github.com/ogzhanolguncu/mimic/internal/config"
 	dryrun "github.com/ogzhanolguncu/mimic/internal/dry_run"
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
 	"github.com/ogzhanolguncu/mimic/internal/flags"
 	"github.com/ogzhanolguncu/mimic/internal/logger"
+	"github.com/ogzhanolguncu/mimic/internal/remote"
+	"github.com/ogzhanolguncu/mimic/internal/report"
 	"github.com/ogzhanolguncu/mimic/internal/syncer"
 )
 
 func main() {
 	cfg := flags.Parse()
-	setupLogging(cfg.Verbose)
+	closeLog, err := setupLogging(cfg)
+	if err != nil {
+		logger.Fatal("Cannot set up logging", "error", err)
+	}
+	defer closeLog()
 
 	args := flag.Args()
+	if cfg.VerifyManifest != "" {
+		if err := runVerifyManifest(args[0], cfg); err != nil {
+			logger.Fatal("Manifest verification failed", "error", err)
+		}
+		return
+	}
+	if cfg.IntegrityScan {
+		if err := runIntegrityScan(args[0], cfg); err != nil {
+			logger.Fatal("Integrity scan failed", "error", err)
+		}
+		return
+	}
+	if cfg.PruneState {
+		if err := runPruneState(args[0], cfg); err != nil {
+			logger.Fatal("Pruning the state failed", "error", err)
+		}
+		return
+	}
 	srcDir, dstDir := args[0], args[1]
 
 	logger.Info("Starting sync process",
@@ -25,62 +54,170 @@ func main() {
 		"destination", dstDir,
 		"config", cfg)
 
-	if err := runSync(srcDir, dstDir, cfg); err != nil {
+	if err := fileops.SetIOPriority(cfg.IOPriority); err != nil {
+		logger.Warn("Cannot lower I/O priority, running at normal priority", "error", err)
+	}
+
+	// Stop between actions on Ctrl-C so checkpoints and progress are saved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := runSync(ctx, srcDir, dstDir, cfg); err != nil {
+		// A failing post-sync hook decides mimic's exit code
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			logger.Error("Sync process failed", "error", err)
+			os.Exit(exitErr.ExitCode())
+		}
 		logger.Fatal("Sync process failed", "error", err)
 	}
 
 	logger.Info("Sync process completed successfully")
 }
 
-// setupLogging configures the logger based on verbose setting
-func setupLogging(verbose bool) {
-	logLevel := slog.LevelInfo
-	if verbose {
-		logLevel = slog.LevelDebug
+// setupLogging configures the logger level and output from the config.
+// The returned func closes the log file, if any. If the log file cannot be
+// opened the logger still falls back to stderr so the error can be reported.
+func setupLogging(cfg *config.Config) (func(), error) {
+	var output io.Writer = os.Stderr
+	closeLog := func() {}
+	level := logger.LevelFor(cfg.Verbose, cfg.Quiet || cfg.SummaryOnly)
+
+	if cfg.LogFile != "" {
+		file, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			logger.Initialize(logger.Config{Level: level, Output: os.Stderr, SummaryOnly: cfg.SummaryOnly})
+			return closeLog, err
+		}
+		output = file
+		closeLog = func() { _ = file.Close() }
 	}
 
 	logger.Initialize(logger.Config{
-		Level:  logLevel,
-		Output: os.Stderr,
+		Level:       level,
+		Output:      output,
+		SummaryOnly: cfg.SummaryOnly,
 	})
+	return closeLog, nil
 }
 
-// runSync performs the actual synchronization process
-func runSync(srcDir string, dstDir string, cfg *config.Config) error {
-	// Load or create state
-	state, err := syncer.LoadState(dstDir)
+// openDestination returns the local directory, or an SFTP session for a remote
+// [user@]host:path target, and a func that releases it.
+func openDestination(dstDir string, cfg *config.Config) (syncer.StateDestination, func(), error) {
+	if cfg.Remote == nil {
+		return syncer.NewLocalDestination(dstDir, cfg), func() {}, nil
+	}
+
+	if cfg.Resume {
+		logger.Warn("Resuming partial copies is not supported for remote destinations, copying whole files")
+	}
+	logger.Info("Connecting to remote destination", "host", cfg.Remote.Host, "path", cfg.Remote.Path)
+	dest, err := remote.Dial(cfg)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	return dest, func() { _ = dest.Close() }, nil
+}
 
-	// Scan source directory
-	sourceEntries, err := syncer.ScanSource(srcDir)
+// runSync performs the actual synchronization process
+func runSync(ctx context.Context, srcDir string, dstDir string, cfg *config.Config) error {
+	dest, closeDest, err := openDestination(dstDir, cfg)
 	if err != nil {
 		return err
 	}
+	defer closeDest()
 
-	// Compare states and determine actions
-	logger.Info("Comparing states")
-	actions := syncer.CompareStates(sourceEntries, state.Entries)
+	summary, err := syncer.SyncTo(ctx, srcDir, dest, cfg)
+	if summary == nil {
+		return err
+	}
 
 	if cfg.DryRun {
-		dryrun.PrintFullReport(actions)
+		if cfg.SummaryOnly {
+			report.Print(summary.Summary)
+			return nil
+		}
+		if cfg.DryRunFormat == config.DryRunFormatDiff {
+			dryrun.PrintDiff(os.Stdout, summary.Actions, cfg.ShowUnchanged)
+			return nil
+		}
+		dryrun.PrintFullReport(summary.Actions, summary.Summary)
 		return nil
 	}
+	if !cfg.Quiet || cfg.SummaryOnly {
+		report.Print(summary.Summary)
+	}
+	if cfg.PostHook != "" {
+		logger.Info("Running post-sync hook", "command", cfg.PostHook)
+		if hookErr := syncer.RunPostHook(ctx, cfg.PostHook, srcDir, dstDir, summary.Summary); hookErr != nil {
+			return errors.Join(err, hookErr)
+		}
+	}
+	// Failed actions of a -continue-on-error run are reported after the summary
+	return err
+}
+
+// runVerifyManifest scans dir and reports every difference from the manifest.
+func runVerifyManifest(dir string, cfg *config.Config) error {
+	file, err := os.Open(cfg.VerifyManifest)
+	if err != nil {
+		return err
+	}
+	expected, err := syncer.ReadManifest(file)
+	_ = file.Close()
+	if err != nil {
+		return err
+	}
+
+	scanned, err := syncer.ScanDestination(dir, cfg)
+	if err != nil {
+		return err
+	}
+
+	mismatches := syncer.VerifyManifest(expected, scanned)
+	for _, mismatch := range mismatches {
+		logger.Warn("Manifest mismatch", "path", mismatch.Path, "problem", mismatch.Problem)
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("found %d manifest mismatches", len(mismatches))
+	}
+
+	logger.Info("Directory matches manifest", "dir", dir, "entries", len(expected))
+	return nil
+}
+
+// runIntegrityScan audits dir against the state recorded in it and reports every drift.
+func runIntegrityScan(dir string, cfg *config.Config) error {
+	mismatches, err := syncer.IntegrityScan(dir, cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, mismatch := range mismatches {
+		logger.Warn("Integrity mismatch", "path", mismatch.Path, "problem", mismatch.Problem)
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("found %d discrepancies with the recorded state", len(mismatches))
+	}
 
-	// Filter out "none" actions for reporting
-	actionCount := len(slices.DeleteFunc(slices.Clone(actions), func(a syncer.SyncAction) bool {
-		return a.Type == syncer.ActionNone
-	}))
-	logger.Info("Found actions to perform", "count", actionCount)
+	logger.Info("Destination matches its recorded state", "dir", dir)
+	return nil
+}
 
-	// Execute actions
-	logger.Info("Executing sync actions")
-	if err := syncer.ExecuteActions(srcDir, dstDir, actions, cfg); err != nil {
+// runPruneState drops the state entries of dir that the current filters no longer track.
+func runPruneState(dir string, cfg *config.Config) error {
+	dropped, err := syncer.PruneStateFile(dir, cfg)
+	if err != nil {
 		return err
 	}
 
-	// Update and save state
-	state.Entries = sourceEntries
-	return syncer.SaveState(dstDir, state)
+	for _, path := range dropped {
+		logger.Info("Dropped state entry", "path", path)
+	}
+	if cfg.DryRun {
+		logger.Info("Dry run, state left unchanged", "dir", dir, "would_drop", len(dropped))
+		return nil
+	}
+	logger.Info("State pruned", "dir", dir, "dropped", len(dropped))
+	return nil
 }
@@ -0,0 +1,13 @@
+//go:build simulate_errors
+
+package main
+
+import (
+	"flag"
+
+	"github.com/ogzhanolguncu/mimic/internal/syncer"
+)
+
+func init() {
+	flag.Func("simulate-errors", "Fail copies on purpose: every=N fails every Nth file, path=GLOB (repeatable) matching files, times=K only their first K attempts", syncer.SimulateErrors)
+}
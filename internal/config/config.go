@@ -1,12 +1,190 @@
 package config
 
+import (
+	"runtime"
+	"time"
+)
+
 // Default configuration constants
 const (
-	DefaultChunkSize      = 32 << 20 // 32MB in bytes
-	DefaultVerbose        = false
-	DefaultDryRun         = false
-	DefaultChecksum       = false
-	DefaultBandwidthLimit = 0 // No limit
+	DefaultChunkSize             = 32 << 20 // 32MB in bytes
+	DefaultVerbose               = false
+	DefaultDryRun                = false
+	DefaultChecksum              = false
+	DefaultChecksumBlockSize     = 0 // Whole-file checksums only
+	DefaultChecksumMin           = 0 // No lower bound on the checksum band
+	DefaultChecksumMax           = 0 // No upper bound on the checksum band
+	DefaultChecksumOnCopy        = false
+	DefaultStreaming             = false
+	DefaultQuickHash             = false
+	DefaultNoTimes               = false
+	DefaultSyncPermsAlways       = false
+	DefaultPreserveSpecialBits   = false
+	DefaultLongPaths             = false
+	DefaultBandwidthLimit        = 0 // No limit
+	DefaultMaxFileSize           = 0 // No limit
+	DefaultCopyOrder             = CopyOrderNone
+	DefaultPhased                = false
+	DefaultStreamState           = false
+	DefaultStateNDJSON           = false
+	DefaultVerifyState           = false
+	DefaultVerifyStateChecksum   = false
+	DefaultAssumeStable          = false
+	DefaultPersistProgress       = false
+	DefaultQuiet                 = false
+	DefaultScanHeartbeat         = 5 * time.Second
+	DefaultSummaryOnly           = false
+	DefaultLogFile               = "" // Log to stderr
+	DefaultAutoTuneScan          = false
+	DefaultHashParallelThreshold = 16 // Files; smaller scans hash serially
+	DefaultReserveSpace          = 0  // No reserve
+	DefaultMinFreeSpace          = 0  // No free space check
+	DefaultSourceChecksums       = "" // Hash every source file
+	DefaultChecksumCache         = "" // No checksum cache
+	DefaultStrictScan            = false
+	DefaultDeleteDelay           = 0 // Delete as soon as a path is missing from the source
+	DefaultMaxDelete             = 0 // No limit on the number of deletes
+	DefaultMaxDeletePercent      = 0 // No limit on the share of recorded entries deleted
+	DefaultForceDelete           = false
+	DefaultCompareMode           = CompareModeStandard
+	DefaultVerifyEqualMtime      = false
+	DefaultAdopt                 = false
+	DefaultStateless             = false
+	DefaultPruneEmptyDirs        = false
+	DefaultVerifyExecution       = false
+	DefaultSSHPort               = 22
+	DefaultSSHKey                = "" // Use ssh-agent and ~/.ssh/id_ed25519, ~/.ssh/id_rsa
+	DefaultSSHKnownHosts         = "" // Use ~/.ssh/known_hosts
+	DefaultManifestOut           = "" // No manifest export
+	DefaultReportDupes           = false
+	DefaultVerifyManifest        = ""
+	DefaultOneFileSystem         = false
+	DefaultSparse                = false
+	DefaultPreallocate           = false
+	DefaultCheckpoint            = 0 // Save state only at the end of a run
+	DefaultStaleTempAge          = time.Hour
+	DefaultMtimeThreshold        = time.Second
+	DefaultResume                = false
+	DefaultAppendGrowth          = false
+	DefaultUpdate                = false
+	DefaultPreserveDirTimes      = false
+	DefaultRootMetadata          = false
+	DefaultIOPriority            = IOPriorityNormal
+	DefaultChunkPause            = 0 // No pause between chunks
+	DefaultProgress              = false
+	DefaultStatsFile             = "" // No stats file
+	DefaultStateDir              = "" // Keep the state in the destination
+	DefaultLock                  = false
+	DefaultForceUnlock           = false
+	DefaultPostHook              = "" // No hook
+	DefaultStrictTypes           = false
+	DefaultIntegrityScan         = false
+	DefaultBatchThreshold        = 0 // Same as ChunkSize
+	DefaultCopyQueueDepth        = 5 // Chunks
+	DefaultRetries               = 5
+	DefaultIORetries             = 3 // Per chunk read or write, before the attempt fails
+	DefaultContinueOnError       = false
+	DefaultWindowsNames          = WindowsNamesError
+	DefaultFlatten               = false
+	DefaultFlattenRename         = false
+	DefaultRelativeTo            = "" // Paths are relative to the source root
+	DefaultSymlinkPolicy         = SymlinkDereference
+	DefaultFutureMtimes          = FutureMtimesWarn
+	DefaultFutureThreshold       = time.Hour
+	DefaultCaseInsensitive       = false
+	DefaultDereferenceRoot       = false
+	DefaultPruneState            = false
+	DefaultDryRunFormat          = DryRunFormatTree
+	DefaultShowUnchanged         = false
+	DefaultEvents                = "" // No event stream
+)
+
+// Defaults that depend on the machine
+var (
+	// DefaultHashWorkers gives every CPU a hasher; hashing is bound by CPU and source reads
+	DefaultHashWorkers = runtime.NumCPU()
+	// DefaultCopyWorkers keeps copies, which are bound by destination writes, to a few at a time
+	DefaultCopyWorkers = min(runtime.NumCPU(), 4)
+)
+
+// Event stream formats
+const (
+	// EventsJSONL writes one JSON object per line to stdout for every action and progress sample.
+	EventsJSONL = "jsonl"
+)
+
+// Dry-run report formats
+const (
+	// DryRunFormatTree prints the summary and an indented tree of planned actions.
+	DryRunFormatTree = "tree"
+	// DryRunFormatDiff prints one sorted line per path, prefixed with +, -, ~ or =.
+	DryRunFormatDiff = "diff"
+)
+
+// Handling of source files with mtimes in the future
+const (
+	// FutureMtimesWarn logs such files and syncs them as they are.
+	FutureMtimesWarn = "warn"
+	// FutureMtimesClamp records the scan time as their mtime and compares them by size only.
+	FutureMtimesClamp = "clamp"
+	// FutureMtimesSkip leaves them out of the sync.
+	FutureMtimesSkip = "skip"
+)
+
+// Comparison presets, which set NoTimes, Checksum, VerifyOnEqualMtime and QuickHash
+const (
+	// CompareModeQuick compares files by size only.
+	CompareModeQuick = "quick"
+	// CompareModeStandard compares files by size and mtime.
+	CompareModeStandard = "standard"
+	// CompareModeThorough compares files by size and mtime, then by full checksum.
+	CompareModeThorough = "thorough"
+)
+
+// Copy order modes
+const (
+	// CopyOrderNone executes actions in the order they were planned.
+	CopyOrderNone = "none"
+	// CopyOrderLocality groups file copies by source directory, then by inode where available.
+	CopyOrderLocality = "locality"
+)
+
+// I/O priority levels
+const (
+	IOPriorityNormal = "normal"
+	// IOPriorityLow uses the lowest best-effort I/O priority (Linux only).
+	IOPriorityLow = "low"
+	// IOPriorityIdle only uses the disk when no other process needs it (Linux only).
+	IOPriorityIdle = "idle"
+)
+
+// Strategies for source names Windows cannot store (reserved device names such as CON,
+// trailing dots or spaces, and characters like ':'). They only apply on Windows.
+const (
+	// WindowsNamesError fails the scan on the first such name.
+	WindowsNamesError = "error"
+	// WindowsNamesSkip leaves such entries, and everything under them, out of the sync.
+	WindowsNamesSkip = "skip"
+	// WindowsNamesReplace stores such entries under a sanitized name.
+	WindowsNamesReplace = "replace"
+)
+
+// Policies for how source symlinks are realized on the destination.
+const (
+	// SymlinkPreserve recreates the link with the same target, whether or not it points
+	// inside the source tree.
+	SymlinkPreserve = "preserve"
+	// SymlinkDereference copies the contents of the file the link points to.
+	SymlinkDereference = "dereference"
+	// SymlinkSkip leaves links off the destination.
+	SymlinkSkip = "skip"
+)
+
+// Action type names accepted by the -only and -skip filters.
+const (
+	ActionKindCreate = "create"
+	ActionKindUpdate = "update"
+	ActionKindDelete = "delete"
 )
 
 // Default empty slice for exclude patterns
@@ -17,27 +195,384 @@ var DefaultExcludePatterns = []string{".DS_Store"}
 type Config struct {
 	// Verbose enables detailed logging of operations (Debug level).
 	Verbose bool
+	// Quiet limits logging to warnings and errors and suppresses the final summary.
+	Quiet bool
+	// ScanHeartbeat is how often a long scan logs how many entries and bytes it has covered
+	// so far (0 disables; never logged with Quiet)
+	ScanHeartbeat time.Duration
+	// SummaryOnly limits logging to warnings and errors, drops per-file warnings as well and
+	// still prints the final summary, whatever the verbosity
+	SummaryOnly bool
+	// LogFile routes log output to this file instead of stderr.
+	LogFile string
 	// DryRun simulates all operations without making actual filesystem changes.
 	DryRun bool
+	// DryRunFormat selects how a dry run reports its plan (tree, diff)
+	DryRunFormat string
+	// ShowUnchanged lists unchanged entries in the diff format of a dry run
+	ShowUnchanged bool
 	// Checksum enables comparing file content hashes instead of just mtime/size.
 	// More accurate but potentially slower as it requires reading files.
 	Checksum bool
+	// ChecksumBlockSize additionally records a checksum per block of this many bytes for
+	// files larger than one block, so integrity scans can tell which blocks diverged
+	// (0 to disable; it grows the state file)
+	ChecksumBlockSize int64
+	// ChecksumMin and ChecksumMax, when either is set, bound the sizes of files that are hashed
+	// and compared by content when their size and mtime match; other files are compared by
+	// size and mtime alone. Both bounds are inclusive and 0 leaves that side open
+	ChecksumMin int64
+	ChecksumMax int64
+	// ChecksumOnCopy hashes files that are copied while copying them instead of in a separate
+	// pass during the scan; unchanged files keep their recorded checksums
+	ChecksumOnCopy bool
+	// Streaming compares and executes entries as the source walk finds them, in batches, instead
+	// of scanning the whole source into memory first
+	Streaming bool
+	// QuickHash fingerprints files by their size and first and last 64 KiB instead of hashing
+	// the whole content; much faster on large files but blind to same-size edits in the middle
+	QuickHash bool
+	// NoTimes ignores mtimes when comparing files with the state, for file systems whose
+	// clocks cannot be trusted: files of equal size are unchanged unless Checksum is also
+	// set and their recorded checksums differ
+	NoTimes bool
+	// SyncPermsAlways applies source permission changes to files and directories that are
+	// otherwise unchanged, with a chmod instead of a copy
+	SyncPermsAlways bool
+	// PreserveSpecialBits keeps the setuid, setgid and sticky bits of copied files and created
+	// directories on local destinations, which creating them would otherwise drop
+	PreserveSpecialBits bool
+	// LongPaths addresses destination entries with \\?\ extended-length paths on Windows,
+	// lifting the 260-character MAX_PATH limit. It has no effect elsewhere
+	LongPaths bool
 	// ChunkSize defines the buffer size in bytes for file copying
 	ChunkSize int64
+	// BatchThreshold is the file size from which copies stream in ChunkSize chunks
+	// instead of reading the whole file; 0 uses ChunkSize
+	BatchThreshold int64
+	// CopyQueueDepth is how many chunks a streamed copy reads ahead of the writer; each
+	// holds up to ChunkSize bytes
+	CopyQueueDepth int
+	// Retries is how many times a copy, mkdir or delete is attempted before the sync fails
+	Retries int
+	// IORetries is how many times in a row a chunk read or write within a copy is retried
+	// when it fails with EINTR, EAGAIN or ETIMEDOUT, as network and FUSE mounts return
+	IORetries int
+	// ContinueOnError keeps going after a failed action, saves the state without the failed
+	// actions and reports every failure at the end
+	ContinueOnError bool
+	// WindowsNames decides what happens to source names Windows cannot store (error, skip, replace)
+	WindowsNames string
+	// Flatten syncs every file straight into the destination root under its base name and
+	// creates no directories; two files with the same base name fail the scan
+	Flatten bool
+	// FlattenRename resolves Flatten collisions by appending ~2, ~3, ... to the later names
+	FlattenRename bool
+	// RelativeTo records source paths relative to this ancestor of the source root instead of
+	// the root itself, so syncing /data/projects/app relative to /data fills dst/projects/app
+	RelativeTo string
+	// SymlinkPolicy decides how source symlinks end up on the destination (preserve, dereference, skip)
+	SymlinkPolicy string
+	// FutureMtimes decides what happens to files modified more than FutureThreshold after the
+	// scan started, e.g. by a bad clock (warn, clamp, skip)
+	FutureMtimes string
+	// FutureThreshold is how far ahead of the scan an mtime must be to count as in the future
+	FutureThreshold time.Duration
+	// CaseInsensitive matches source and state paths regardless of case, for destinations on
+	// case-folding file systems; case-only renames are then applied as renames
+	CaseInsensitive bool
+	// DereferenceRoot scans the directory a symlinked source root points to; without it
+	// such a root is an error
+	DereferenceRoot bool
 	// ExcludePatterns contains glob patterns for files/directories to skip
 	ExcludePatterns []string
 	// BandwidthLimit restricts transfer speed in KB/s
 	BandwidthLimit int
+	// BandwidthRules set the limit per file; the first rule whose pattern matches a file's
+	// path applies instead of BandwidthLimit
+	BandwidthRules []BandwidthRule
+	// MaxFileSize skips files larger than this many bytes during scan (0 for no limit)
+	MaxFileSize int64
+	// SizeExcludeRules skip the files matching a rule's pattern and size condition during scan
+	SizeExcludeRules []SizeExcludeRule
+	// ExcludeFSTypes skips directories mounted with these filesystem types (e.g. nfs, fuse)
+	ExcludeFSTypes []string
+	// ExcludeMounts skips these mount point paths during scan
+	ExcludeMounts []string
+	// CopyOrder controls the order in which file copies are executed (none, locality)
+	CopyOrder string
+	// Phased executes all directory creations first, shallow to deep, then the file copies
+	// and other changes, then the deletes, deep to shallow
+	Phased bool
+	// NewerThan keeps only files modified at or after this time (zero for no bound)
+	NewerThan time.Time
+	// OlderThan keeps only files modified strictly before this time (zero for no bound)
+	OlderThan time.Time
+	// StreamStateLoad decodes the state file incrementally to reduce peak memory on huge states
+	StreamStateLoad bool
+	// StateNDJSON saves the state as a header line followed by one line per entry
+	StateNDJSON bool
+	// VerifyStateWrite reloads the written state file and compares it before replacing the old one
+	VerifyStateWrite bool
+	// VerifyStateChecksum records a checksum of the state when saving it and checks it when
+	// loading, treating a state that was edited or corrupted since like an unreadable one
+	VerifyStateChecksum bool
+	// AssumeStableSource skips the post-read stat that detects files changing while hashed
+	AssumeStableSource bool
+	// PersistProgress keeps cumulative transfer totals across interrupted and resumed runs
+	PersistProgress bool
+	// HashWorkers limits how many files are checksummed concurrently during scan,
+	// independently of the (serial) directory walk
+	HashWorkers int
+	// CopyWorkers limits how many files are copied concurrently; other actions wait for
+	// running copies, so directories exist before their files and deletes run alone
+	CopyWorkers int
+	// AutoTuneScan ramps hashing concurrency up to HashWorkers while throughput improves
+	AutoTuneScan bool
+	// HashParallelThreshold is the number of files to checksum from which HashWorkers are
+	// used; smaller scans hash serially to avoid the worker overhead
+	HashParallelThreshold int
+	// ReserveSpace defers copies that would leave less than this many bytes free on the destination
+	ReserveSpace int64
+	// MinFreeSpace refuses to start, or stops, a sync whose remaining copies would leave
+	// less than this many bytes free on the destination (0 disables the check)
+	MinFreeSpace int64
+	// OnlyActions restricts execution to these action types (create, update, delete); empty runs all
+	OnlyActions []string
+	// SkipActions leaves these action types unexecuted; they are planned again on the next run
+	SkipActions []string
+	// SourceChecksums is a JSON manifest of precomputed source checksums trusted instead of hashing
+	SourceChecksums string
+	// ChecksumCache is a file caching source checksums by path, size and mtime across runs
+	// and destinations
+	ChecksumCache string
+	// StrictScan halts the scan at the first entry that cannot be read instead of skipping
+	// it and keeping its destination copy
+	StrictScan bool
+	// DeleteDelay holds back the delete of a path missing from the source until it has been
+	// missing for this long across runs (0 deletes right away)
+	DeleteDelay time.Duration
+	// MaxDelete refuses a run that would delete more than this many destination entries
+	// (0 for no limit)
+	MaxDelete int
+	// MaxDeletePercent refuses a run that would delete more than this percentage of the
+	// recorded entries (0 for no limit)
+	MaxDeletePercent float64
+	// ForceDelete runs the deletes the mass delete guard refuses: past MaxDelete or
+	// MaxDeletePercent, or of every recorded entry when the source scans empty
+	ForceDelete bool
+	// CompareMode is the comparison preset chosen with -compare-mode; flags.Parse has already
+	// expanded it into NoTimes, Checksum, VerifyOnEqualMtime and QuickHash
+	CompareMode string
+	// VerifyOnEqualMtime compares checksums of files whose size and mtime match the state,
+	// catching edits that coincidentally preserved both
+	VerifyOnEqualMtime bool
+	// Adopt seeds an empty state from identical files already present in the destination
+	Adopt bool
+	// Stateless plans a local destination's actions from a scan of the destination instead of
+	// the state file, and never writes a state file
+	Stateless bool
+	// PruneEmptyDirs removes destination directories left empty after a sync unless they exist in the source
+	PruneEmptyDirs bool
+	// VerifyExecution checks after a run that deleted entries are gone from a local destination
+	// and created or updated ones exist, and fails the run on any discrepancy
+	VerifyExecution bool
+	// Remote is set when the destination is given as [user@]host:path and is synced over SFTP
+	Remote *RemoteTarget
+	// SSHPort is the port used to reach a remote destination
+	SSHPort int
+	// SSHKey is a private key file used to authenticate to a remote destination
+	SSHKey string
+	// SSHKnownHosts is the known_hosts file used to verify the remote host key
+	SSHKnownHosts string
+	// ManifestOut writes the source scan as a portable text manifest to this file
+	ManifestOut string
+	// ReportDupes logs groups of source files with identical content after the scan, and the
+	// space all but one copy of each take up; nothing is changed
+	ReportDupes bool
+	// VerifyManifest switches to verification mode: the single directory argument is
+	// scanned and compared against this manifest instead of syncing
+	VerifyManifest string
+	// OneFileSystem keeps the scan on the source root's device, like rsync -x
+	OneFileSystem bool
+	// Sparse leaves holes in destination files for runs of zero bytes instead of writing them
+	Sparse bool
+	// Preallocate reserves the full size of a streamed destination file before copying it,
+	// reducing fragmentation and failing early when it cannot fit
+	Preallocate bool
+	// CheckpointActions saves the state after this many completed actions (0 to disable)
+	CheckpointActions int
+	// CheckpointInterval saves the state when this much time has passed since the last save (0 to disable)
+	CheckpointInterval time.Duration
+	// StaleTempAge is how old a temp file left in a local destination or StateDir by an
+	// earlier run must be before a run removes it at startup (0 removes them all)
+	StaleTempAge time.Duration
+	// MtimeThreshold is how far apart a source and recorded mtime may be and still count as
+	// unchanged, to absorb coarse timestamps on FAT or network file systems (0 for exact)
+	MtimeThreshold time.Duration
+	// Resume continues an interrupted copy from its partial file when the partial content
+	// matches the source prefix, instead of copying the whole file again
+	Resume bool
+	// AppendGrowth updates a file that grew by appending only its new tail, when the
+	// destination still holds exactly the previous contents (verified by checksum)
+	AppendGrowth bool
+	// Update leaves destination files that are newer than their source untouched instead of
+	// overwriting them, like rsync -u
+	Update bool
+	// PreserveDirTimes sets destination directory mtimes to the source's once their children are synced
+	PreserveDirTimes bool
+	// RootMetadata gives the destination root the source root's permissions and mtime at the end of a run
+	RootMetadata bool
+	// IOPriority lowers the process I/O scheduling priority (normal, low, idle)
+	IOPriority string
+	// ChunkPause sleeps between chunks of batched copies to reduce disk contention
+	ChunkPause time.Duration
+	// Progress shows a live status line with transfer rate and ETA when stderr is a terminal
+	Progress bool
+	// Events streams action and progress events to stdout in this format (jsonl), instead
+	// of the Progress status line
+	Events string
+	// StatsFile receives a JSON record of each run's counts, bytes, per-extension breakdown and
+	// errors, whether or not the run succeeds
+	StatsFile string
+	// StateDir keeps the state file of a local destination in this directory instead of the
+	// destination itself, named after a hash of the destination's absolute path
+	StateDir string
+	// Lock takes a lock file next to the state for the duration of a run, so concurrent runs
+	// on the same local destination fail instead of interleaving
+	Lock bool
+	// ForceUnlock removes a lock left behind by a run that no longer exists before locking
+	ForceUnlock bool
+	// PostHook is a command line run after a sync without fatal errors, with the summary
+	// in MIMIC_* environment variables; its failure fails mimic
+	PostHook string
+	// StrictTypes fails the sync when a destination entry is a file where the source has a
+	// directory or vice versa, instead of replacing it
+	StrictTypes bool
+	// IntegrityScan switches to audit mode: the single directory argument is scanned and
+	// compared against the state recorded in it instead of syncing
+	IntegrityScan bool
+	// PruneState switches to maintenance mode: entries the exclude patterns and size limit
+	// would no longer track are dropped from the state in the single directory argument
+	PruneState bool
+}
+
+// BandwidthRule limits copies of the files matching Pattern, a glob like the exclude
+// patterns (e.g. *.mp4 or media/**), to LimitKBps KB/s (0 for unlimited).
+type BandwidthRule struct {
+	Pattern   string
+	LimitKBps int
+}
+
+// SizeExcludeRule excludes the files matching Pattern, a glob like the exclude patterns,
+// that are larger than Size bytes, or smaller than Size when Smaller is set.
+type SizeExcludeRule struct {
+	Pattern string
+	Smaller bool
+	Size    int64
+}
+
+// RemoteTarget is a destination of the form [user@]host:path.
+type RemoteTarget struct {
+	User string // Empty for the current user
+	Host string
+	Path string
 }
 
 // NewDefaultConfig creates a new Config with default values
 func NewDefaultConfig() *Config {
 	return &Config{
-		Verbose:         DefaultVerbose,
-		DryRun:          DefaultDryRun,
-		Checksum:        DefaultChecksum,
-		ChunkSize:       DefaultChunkSize,
-		ExcludePatterns: DefaultExcludePatterns,
-		BandwidthLimit:  DefaultBandwidthLimit,
+		ScanHeartbeat:         DefaultScanHeartbeat,
+		Verbose:               DefaultVerbose,
+		SummaryOnly:           DefaultSummaryOnly,
+		DryRun:                DefaultDryRun,
+		DryRunFormat:          DefaultDryRunFormat,
+		ShowUnchanged:         DefaultShowUnchanged,
+		Checksum:              DefaultChecksum,
+		ChecksumBlockSize:     DefaultChecksumBlockSize,
+		ChecksumMin:           DefaultChecksumMin,
+		ChecksumMax:           DefaultChecksumMax,
+		ChecksumOnCopy:        DefaultChecksumOnCopy,
+		Streaming:             DefaultStreaming,
+		QuickHash:             DefaultQuickHash,
+		NoTimes:               DefaultNoTimes,
+		SyncPermsAlways:       DefaultSyncPermsAlways,
+		PreserveSpecialBits:   DefaultPreserveSpecialBits,
+		LongPaths:             DefaultLongPaths,
+		ChunkSize:             DefaultChunkSize,
+		BatchThreshold:        DefaultBatchThreshold,
+		CopyQueueDepth:        DefaultCopyQueueDepth,
+		Retries:               DefaultRetries,
+		IORetries:             DefaultIORetries,
+		ContinueOnError:       DefaultContinueOnError,
+		WindowsNames:          DefaultWindowsNames,
+		Flatten:               DefaultFlatten,
+		FlattenRename:         DefaultFlattenRename,
+		RelativeTo:            DefaultRelativeTo,
+		SymlinkPolicy:         DefaultSymlinkPolicy,
+		FutureMtimes:          DefaultFutureMtimes,
+		FutureThreshold:       DefaultFutureThreshold,
+		CaseInsensitive:       DefaultCaseInsensitive,
+		DereferenceRoot:       DefaultDereferenceRoot,
+		ExcludePatterns:       DefaultExcludePatterns,
+		BandwidthLimit:        DefaultBandwidthLimit,
+		MaxFileSize:           DefaultMaxFileSize,
+		CopyOrder:             DefaultCopyOrder,
+		Phased:                DefaultPhased,
+		StreamStateLoad:       DefaultStreamState,
+		StateNDJSON:           DefaultStateNDJSON,
+		VerifyStateWrite:      DefaultVerifyState,
+		VerifyStateChecksum:   DefaultVerifyStateChecksum,
+		AssumeStableSource:    DefaultAssumeStable,
+		PersistProgress:       DefaultPersistProgress,
+		HashWorkers:           DefaultHashWorkers,
+		CopyWorkers:           DefaultCopyWorkers,
+		AutoTuneScan:          DefaultAutoTuneScan,
+		HashParallelThreshold: DefaultHashParallelThreshold,
+		ReserveSpace:          DefaultReserveSpace,
+		MinFreeSpace:          DefaultMinFreeSpace,
+		SourceChecksums:       DefaultSourceChecksums,
+		ChecksumCache:         DefaultChecksumCache,
+		StrictScan:            DefaultStrictScan,
+		DeleteDelay:           DefaultDeleteDelay,
+		MaxDelete:             DefaultMaxDelete,
+		MaxDeletePercent:      DefaultMaxDeletePercent,
+		ForceDelete:           DefaultForceDelete,
+		CompareMode:           DefaultCompareMode,
+		VerifyOnEqualMtime:    DefaultVerifyEqualMtime,
+		Adopt:                 DefaultAdopt,
+		Stateless:             DefaultStateless,
+		PruneEmptyDirs:        DefaultPruneEmptyDirs,
+		VerifyExecution:       DefaultVerifyExecution,
+		SSHPort:               DefaultSSHPort,
+		SSHKey:                DefaultSSHKey,
+		SSHKnownHosts:         DefaultSSHKnownHosts,
+		ManifestOut:           DefaultManifestOut,
+		ReportDupes:           DefaultReportDupes,
+		VerifyManifest:        DefaultVerifyManifest,
+		OneFileSystem:         DefaultOneFileSystem,
+		Sparse:                DefaultSparse,
+		Preallocate:           DefaultPreallocate,
+		CheckpointActions:     DefaultCheckpoint,
+		CheckpointInterval:    DefaultCheckpoint,
+		StaleTempAge:          DefaultStaleTempAge,
+		MtimeThreshold:        DefaultMtimeThreshold,
+		Resume:                DefaultResume,
+		AppendGrowth:          DefaultAppendGrowth,
+		Update:                DefaultUpdate,
+		PreserveDirTimes:      DefaultPreserveDirTimes,
+		RootMetadata:          DefaultRootMetadata,
+		IOPriority:            DefaultIOPriority,
+		ChunkPause:            DefaultChunkPause,
+		Progress:              DefaultProgress,
+		Events:                DefaultEvents,
+		StatsFile:             DefaultStatsFile,
+		StateDir:              DefaultStateDir,
+		Lock:                  DefaultLock,
+		ForceUnlock:           DefaultForceUnlock,
+		PostHook:              DefaultPostHook,
+		StrictTypes:           DefaultStrictTypes,
+		IntegrityScan:         DefaultIntegrityScan,
+		PruneState:            DefaultPruneState,
 	}
 }
@@ -0,0 +1,56 @@
+package dryrun
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+
+	"github.com/ogzhanolguncu/mimic/internal/syncer"
+)
+
+// diffLine is a single row of the diff format.
+type diffLine struct {
+	prefix string
+	path   string
+}
+
+// diffPrefix is the marker of an action in the diff format: + for creates, - for
+// deletes, ~ for updates and = for unchanged entries.
+func diffPrefix(actionType int) string {
+	switch actionType {
+	case syncer.ActionCreate, syncer.ActionMkdir:
+		return "+"
+	case syncer.ActionDelete, syncer.ActionRmdir:
+		return "-"
+	case syncer.ActionUpdate, syncer.ActionChmod:
+		return "~"
+	default:
+		return "="
+	}
+}
+
+// PrintDiff writes actions to w as one "<prefix> <path>" line per entry, sorted by path
+// with forward slashes and a trailing slash on directories, so the output is the same
+// on every run and platform. Unchanged entries are left out unless showUnchanged is set.
+func PrintDiff(w io.Writer, actions []syncer.SyncAction, showUnchanged bool) {
+	lines := make([]diffLine, 0, len(actions))
+	for _, action := range actions {
+		if action.Type == syncer.ActionNone && !showUnchanged {
+			continue
+		}
+		path := filepath.ToSlash(action.RelativePath)
+		if action.SourceInfo.IsDir {
+			path += "/"
+		}
+		lines = append(lines, diffLine{prefix: diffPrefix(action.Type), path: path})
+	}
+	slices.SortFunc(lines, func(a, b diffLine) int {
+		return cmp.Or(cmp.Compare(a.path, b.path), cmp.Compare(a.prefix, b.prefix))
+	})
+
+	for _, line := range lines {
+		fmt.Fprintf(w, "%s %s\n", line.prefix, line.path)
+	}
+}
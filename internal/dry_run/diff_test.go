@@ -0,0 +1,57 @@
+package dryrun
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/syncer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintDiff(t *testing.T) {
+	actions := []syncer.SyncAction{
+		{Type: syncer.ActionUpdate, RelativePath: "main.go", SourceInfo: syncer.EntryInfo{Size: 200}},
+		{Type: syncer.ActionNone, RelativePath: "go.mod", SourceInfo: syncer.EntryInfo{Size: 50}},
+		{Type: syncer.ActionMkdir, RelativePath: "docs", SourceInfo: syncer.EntryInfo{IsDir: true}},
+		{Type: syncer.ActionCreate, RelativePath: filepath.Join("docs", "readme.md"), SourceInfo: syncer.EntryInfo{Size: 2048}},
+		{Type: syncer.ActionDelete, RelativePath: "old.txt"},
+		{Type: syncer.ActionRmdir, RelativePath: "build", SourceInfo: syncer.EntryInfo{IsDir: true}},
+		{Type: syncer.ActionChmod, RelativePath: "run.sh", SourceInfo: syncer.EntryInfo{Size: 10}},
+	}
+
+	tests := []struct {
+		name          string
+		showUnchanged bool
+		want          string
+	}{
+		{
+			name: "HidesUnchanged",
+			want: "- build/\n" +
+				"+ docs/\n" +
+				"+ docs/readme.md\n" +
+				"~ main.go\n" +
+				"- old.txt\n" +
+				"~ run.sh\n",
+		},
+		{
+			name:          "ShowUnchanged",
+			showUnchanged: true,
+			want: "- build/\n" +
+				"+ docs/\n" +
+				"+ docs/readme.md\n" +
+				"= go.mod\n" +
+				"~ main.go\n" +
+				"- old.txt\n" +
+				"~ run.sh\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			PrintDiff(&buf, actions, tt.showUnchanged)
+			require.Equal(t, tt.want, buf.String())
+		})
+	}
+}
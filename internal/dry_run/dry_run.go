@@ -1,10 +1,11 @@
 package dryrun
 
 import (
-	"fmt"
 	"log"
+	"path/filepath"
 	"strings"
 
+	"github.com/ogzhanolguncu/mimic/internal/report"
 	"github.com/ogzhanolguncu/mimic/internal/syncer"
 )
 
@@ -12,92 +13,29 @@ type Node struct {
 	fileName   string
 	fileSize   int
 	actionType int
+	reason     string // Why an update was planned, empty otherwise
 	children   *[]Node
 }
 
-func printSummary(stats map[int]struct {
-	Count int
-	Size  int64
-},
-) {
-	log.Printf("==== DRY RUN MODE: No changes will be made ====\n")
-	log.Printf("SUMMARY OF ACTIONS:\n")
-	log.Printf("* Files to create: %d (total size: %.1f MB)\n",
-		stats[syncer.ActionCreate].Count,
-		float64(stats[syncer.ActionCreate].Size)/(1024*1024))
-	log.Printf("* Files to update: %d (total size: %.1f MB)\n",
-		stats[syncer.ActionUpdate].Count,
-		float64(stats[syncer.ActionUpdate].Size)/(1024*1024))
-	log.Printf("* Files to delete: %d (total size: %.1f MB)\n",
-		stats[syncer.ActionDelete].Count,
-		float64(stats[syncer.ActionDelete].Size)/(1024*1024))
-	log.Printf("* Directories to create: %d\n", stats[syncer.ActionCreate|0x10].Count) // Assuming flag for directories
-	log.Printf("* Directories to delete: %d\n", stats[syncer.ActionDelete|0x10].Count) // Assuming flag for directories
-	log.Printf("* Unchanged: %d\n", stats[syncer.ActionNone].Count)
-}
-
-func printTree(node *Node, indent string) {
-	if node == nil {
-		return
-	}
-
-	var actionStr string
-	switch node.actionType {
-	case syncer.ActionNone:
-		actionStr = "NONE"
-	case syncer.ActionCreate:
-		actionStr = "CREATE"
-	case syncer.ActionUpdate:
-		actionStr = "UPDATE"
-	case syncer.ActionDelete:
-		actionStr = "DELETE"
-	default:
-		actionStr = "UNKNOWN"
-	}
-
-	// Format file size
-	var sizeStr string
-	if node.fileSize < 1024 {
-		sizeStr = fmt.Sprintf("%d B", node.fileSize)
-	} else if node.fileSize < 1024*1024 {
-		sizeStr = fmt.Sprintf("%.1f KB", float64(node.fileSize)/1024)
-	} else if node.fileSize < 1024*1024*1024 {
-		sizeStr = fmt.Sprintf("%.1f MB", float64(node.fileSize)/(1024*1024))
-	} else {
-		sizeStr = fmt.Sprintf("%.1f GB", float64(node.fileSize)/(1024*1024*1024))
-	}
-
-	// Print current node with action type and file size
-	log.Printf("%s- %s [%s] (%s)", indent, node.fileName, actionStr, sizeStr)
-
-	// Print children recursively with increased indentation
-	if node.children != nil {
-		for i := range *node.children {
-			printTree(&(*node.children)[i], indent+"  ")
-		}
-	}
-}
-
-func PrintFullReport(actions []syncer.SyncAction) {
+func PrintFullReport(actions []syncer.SyncAction, summary report.Summary) {
 	rootNode := generateTree(actions)
-	// First gather statistics
-	stats := collectStats(&rootNode)
 
 	// Print summary
-	printSummary(stats)
+	report.Print(summary)
 
 	// Print detailed tree
-	printTree(&rootNode, "")
+	out := log.Writer()
+	renderTree(out, &rootNode, colorEnabled(out))
 }
 
 func generateTree(actions []syncer.SyncAction) Node {
 	rootNode := Node{fileName: "(root)", children: &[]Node{}}
 
 	for _, action := range actions {
-		path := action.RelativePath
+		path := filepath.ToSlash(action.RelativePath)
 		// Trim any leading slash
 		path = strings.TrimPrefix(path, "/")
-		// Split the path into components
+		// Split the path into components, whatever the platform separator
 		components := strings.Split(path, "/")
 
 		currentNode := &rootNode
@@ -132,6 +70,7 @@ func generateTree(actions []syncer.SyncAction) Node {
 					children:   nil,
 					actionType: action.Type,
 					fileSize:   int(action.SourceInfo.Size),
+					reason:     syncer.DescribeReason(action),
 				}
 
 				// Add to children
@@ -148,40 +87,3 @@ func generateTree(actions []syncer.SyncAction) Node {
 	}
 	return rootNode
 }
-
-func collectStats(node *Node) map[int]struct {
-	Count int
-	Size  int64
-} {
-	stats := make(map[int]struct {
-		Count int
-		Size  int64
-	})
-
-	collectStatsRecursive(node, stats)
-
-	return stats
-}
-
-func collectStatsRecursive(node *Node, stats map[int]struct {
-	Count int
-	Size  int64
-},
-) {
-	if node == nil {
-		return
-	}
-
-	// Update stats for current node
-	statEntry := stats[node.actionType]
-	statEntry.Count++
-	statEntry.Size += int64(node.fileSize)
-	stats[node.actionType] = statEntry
-
-	// Process children
-	if node.children != nil {
-		for i := range *node.children {
-			collectStatsRecursive(&(*node.children)[i], stats)
-		}
-	}
-}
@@ -0,0 +1,121 @@
+package dryrun
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ogzhanolguncu/mimic/internal/report"
+	"github.com/ogzhanolguncu/mimic/internal/syncer"
+)
+
+// ANSI escape codes used for the colored tree
+const (
+	ansiReset  = "\033[0m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiRed    = "\033[31m"
+	ansiDim    = "\033[2m"
+)
+
+// treeLine is a single pre-formatted row of the tree before alignment
+type treeLine struct {
+	label      string // Indented name
+	actionType int
+	action     string // Bracketed action, with the update reason when known
+	size       string
+}
+
+// colorEnabled reports whether colored output should be written to w: only for
+// terminals, and never when NO_COLOR is set.
+func colorEnabled(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	return report.IsTerminal(w)
+}
+
+func actionLabel(actionType int) string {
+	switch actionType {
+	case syncer.ActionNone:
+		return "NONE"
+	case syncer.ActionCreate:
+		return "CREATE"
+	case syncer.ActionUpdate:
+		return "UPDATE"
+	case syncer.ActionDelete:
+		return "DELETE"
+	case syncer.ActionMkdir:
+		return "MKDIR"
+	case syncer.ActionRmdir:
+		return "RMDIR"
+	case syncer.ActionChmod:
+		return "CHMOD"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func actionColor(actionType int) string {
+	switch actionType {
+	case syncer.ActionCreate, syncer.ActionMkdir:
+		return ansiGreen
+	case syncer.ActionUpdate, syncer.ActionChmod:
+		return ansiYellow
+	case syncer.ActionDelete, syncer.ActionRmdir:
+		return ansiRed
+	default:
+		return ansiDim
+	}
+}
+
+// renderTree writes the tree to w with names, actions and sizes in aligned columns,
+// colored by action type when color is set.
+func renderTree(w io.Writer, root *Node, color bool) {
+	var lines []treeLine
+	collectLines(root, "", &lines)
+
+	labelWidth, actionWidth, sizeWidth := 0, 0, 0
+	for _, line := range lines {
+		labelWidth = max(labelWidth, len(line.label))
+		actionWidth = max(actionWidth, utf8.RuneCountInString(line.action))
+		sizeWidth = max(sizeWidth, len(line.size))
+	}
+
+	for _, line := range lines {
+		padding := strings.Repeat(" ", actionWidth-utf8.RuneCountInString(line.action))
+		row := fmt.Sprintf("%-*s  %s%s  %*s",
+			labelWidth, line.label, line.action, padding, sizeWidth, line.size)
+		row = strings.TrimRight(row, " ")
+		if color {
+			row = actionColor(line.actionType) + row + ansiReset
+		}
+		fmt.Fprintln(w, row)
+	}
+}
+
+func collectLines(node *Node, indent string, lines *[]treeLine) {
+	if node == nil {
+		return
+	}
+
+	action := actionLabel(node.actionType)
+	if node.reason != "" {
+		action += ": " + node.reason
+	}
+
+	*lines = append(*lines, treeLine{
+		label:      indent + "- " + node.fileName,
+		actionType: node.actionType,
+		action:     "[" + action + "]",
+		size:       report.FormatSize(int64(node.fileSize)),
+	})
+
+	if node.children != nil {
+		for i := range *node.children {
+			collectLines(&(*node.children)[i], indent+"  ", lines)
+		}
+	}
+}
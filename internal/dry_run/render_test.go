@@ -0,0 +1,94 @@
+package dryrun
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ogzhanolguncu/mimic/internal/syncer"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleTree() Node {
+	return generateTree([]syncer.SyncAction{
+		{Type: syncer.ActionMkdir, RelativePath: "docs", SourceInfo: syncer.EntryInfo{IsDir: true}},
+		{Type: syncer.ActionCreate, RelativePath: "docs/readme.md", SourceInfo: syncer.EntryInfo{Size: 2048}},
+		{
+			Type:         syncer.ActionUpdate,
+			RelativePath: "main.go",
+			SourceInfo:   syncer.EntryInfo{Size: 200},
+			PreviousInfo: syncer.EntryInfo{Size: 100},
+			Reason:       syncer.ReasonSizeChanged,
+		},
+		{Type: syncer.ActionDelete, RelativePath: "old.txt"},
+	})
+}
+
+func TestRenderTreeColor(t *testing.T) {
+	root := sampleTree()
+
+	t.Run("ColorOn", func(t *testing.T) {
+		var buf bytes.Buffer
+		renderTree(&buf, &root, true)
+
+		out := buf.String()
+		require.Contains(t, out, ansiGreen, "Expected green for creates")
+		require.Contains(t, out, ansiYellow, "Expected yellow for updates")
+		require.Contains(t, out, ansiRed, "Expected red for deletes")
+		require.Contains(t, out, ansiDim, "Expected dim for unchanged root")
+		require.Contains(t, out, ansiReset)
+	})
+
+	t.Run("ColorOff", func(t *testing.T) {
+		var buf bytes.Buffer
+		renderTree(&buf, &root, false)
+
+		out := buf.String()
+		require.NotContains(t, out, "\033[", "Expected no escape codes in plain output")
+		require.Contains(t, out, "[MKDIR]")
+		require.Contains(t, out, "[CREATE]")
+		require.Contains(t, out, "[DELETE]")
+		require.Contains(t, out, "[UPDATE: size 100 B→200 B]", "Expected update reason in the action column")
+	})
+
+	t.Run("ColumnsAligned", func(t *testing.T) {
+		var buf bytes.Buffer
+		renderTree(&buf, &root, false)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 5)
+		actionColumn := strings.Index(lines[0], "[")
+		for _, line := range lines {
+			require.Equal(t, actionColumn, strings.Index(line, "["), "Expected actions to start in the same column: %q", line)
+		}
+		sizeColumn := utf8.RuneCountInString(lines[0])
+		for _, line := range lines {
+			require.Equal(t, sizeColumn, utf8.RuneCountInString(line), "Expected sizes to end in the same column: %q", line)
+		}
+	})
+}
+
+func TestColorEnabled(t *testing.T) {
+	var buf bytes.Buffer
+	require.False(t, colorEnabled(&buf), "Expected no color for non-terminal writers")
+
+	t.Setenv("NO_COLOR", "1")
+	require.False(t, colorEnabled(&buf), "Expected NO_COLOR to disable color")
+}
+
+func TestGenerateTreeSplitsPlatformPaths(t *testing.T) {
+	root := generateTree([]syncer.SyncAction{
+		{Type: syncer.ActionCreate, RelativePath: filepath.Join("docs", "guide", "intro.md")},
+		{Type: syncer.ActionCreate, RelativePath: filepath.Join("docs", "faq.md")},
+	})
+
+	require.Len(t, *root.children, 1, "Expected both paths under a single docs node")
+	docs := (*root.children)[0]
+	require.Equal(t, "docs", docs.fileName)
+	require.Len(t, *docs.children, 2)
+	guide := (*docs.children)[0]
+	require.Equal(t, "guide", guide.fileName)
+	require.Equal(t, "intro.md", (*guide.children)[0].fileName)
+}
@@ -1,150 +1,706 @@
 package fileops
 
 import (
+	"bytes"
+	"cmp"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/ogzhanolguncu/mimic/internal/logger"
 )
 
 var (
-	ErrRead       = errors.New("file_ops: failed to read a file")
-	ErrWrite      = errors.New("file_ops: failed to write a file")
-	ErrMkDir      = errors.New("file_ops: failed to make a dir")
-	ErrRemoveDir  = errors.New("file_ops: failed to remove a dir")
-	ErrPathExists = errors.New("file_ops: path already exists")
-	ErrStat       = errors.New("file_ops: failed to stat path")
-	ErrBatchRead  = errors.New("file_ops: failed to batch read")
-	ErrBatchWrite = errors.New("file_ops: failed to batch write")
+	ErrRead        = errors.New("file_ops: failed to read a file")
+	ErrWrite       = errors.New("file_ops: failed to write a file")
+	ErrMkDir       = errors.New("file_ops: failed to make a dir")
+	ErrRemoveDir   = errors.New("file_ops: failed to remove a dir")
+	ErrPathExists  = errors.New("file_ops: path already exists")
+	ErrStat        = errors.New("file_ops: failed to stat path")
+	ErrBatchRead   = errors.New("file_ops: failed to batch read")
+	ErrBatchWrite  = errors.New("file_ops: failed to batch write")
+	ErrNotPrefix   = errors.New("file_ops: destination is not a prefix of the source")
+	ErrNoSpace     = errors.New("file_ops: not enough space for the file")
+	ErrPreallocate = errors.New("file_ops: failed to preallocate a file")
+	ErrChmod       = errors.New("file_ops: failed to change a file mode")
+	ErrIncomplete  = errors.New("file_ops: copied size does not match the source")
+
+	ErrFreeSpaceUnsupported  = errors.New("file_ops: free space lookup is not supported on this platform")
+	ErrIOPriority            = errors.New("file_ops: failed to set I/O priority")
+	ErrIOPriorityUnsupported = errors.New("file_ops: I/O priority is not supported on this platform")
 )
 
-// CopyFile copies a file from readPath to writePath, preserving permissions
-func CopyFile(readPath, writePath string, chunkSize int64) (bool, error) {
+// CopyOptions tunes how CopyFileWith copies a file.
+type CopyOptions struct {
+	Sparse     bool          // Leave holes for zero blocks, see CopyFileSparse
+	Resume     bool          // Copy through a partial file and continue a previous attempt, see CopyFileResumable
+	ChunkPause time.Duration // Sleep between chunks to leave disk bandwidth to other processes
+	LimitKBps  int           // Keep the copy under this many KB/s, 0 for unlimited
+	// Preallocate reserves the whole destination size before writing, see Preallocate;
+	// it is ignored for sparse copies, which would lose their holes
+	Preallocate bool
+	// BatchThreshold is the file size from which copies are streamed in chunks instead of
+	// read whole; 0 means the chunk size
+	BatchThreshold int64
+	// QueueDepth is how many chunks the reader may get ahead of the writer; 0 means
+	// defaultQueueDepth
+	QueueDepth int
+	// PreserveSpecialBits reapplies the source mode once the copy is done, since the
+	// setuid, setgid and sticky bits are dropped by the umask or by writing the file
+	PreserveSpecialBits bool
+	// IORetries is how many times in a row a chunk read or write failing with a transient
+	// error (see transientIOError) is retried before the copy fails
+	IORetries int
+}
+
+const defaultQueueDepth = 5
+
+// ioRetryDelay is the pause before the first retry of a transient I/O error; it doubles
+// with every further attempt.
+const ioRetryDelay = 10 * time.Millisecond
+
+// CopyFile copies a file from readPath to writePath, preserving permissions.
+// It returns the number of bytes written to writePath.
+func CopyFile(readPath, writePath string, chunkSize int64) (int64, error) {
+	return CopyFileWith(readPath, writePath, chunkSize, CopyOptions{})
+}
+
+// CopyFileWith copies like CopyFile with the given options. Sparse and resumable copies
+// always go through the batched path; other files do once they reach opts.BatchThreshold.
+func CopyFileWith(readPath, writePath string, chunkSize int64, opts CopyOptions) (int64, error) {
+	return copyFile(readPath, writePath, chunkSize, opts, nil)
+}
+
+// CopyFileChecksum copies like CopyFileWith and also returns the hex xxHash of the source
+// contents, computed from the bytes read for the copy instead of a separate pass.
+func CopyFileChecksum(readPath, writePath string, chunkSize int64, opts CopyOptions) (int64, string, error) {
+	digest := xxhash.New()
+	written, err := copyFile(readPath, writePath, chunkSize, opts, digest)
+	if err != nil {
+		return written, "", err
+	}
+	return written, hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// copyFile does the work of CopyFileWith, feeding every byte of the source into digest
+// when it is not nil.
+func copyFile(readPath, writePath string, chunkSize int64, opts CopyOptions, digest hash.Hash) (int64, error) {
 	// Get source file info to preserve permissions
 	srcInfo, err := os.Stat(readPath)
 	if err != nil {
-		return false, fmt.Errorf("%w: %v", ErrStat, err)
+		return 0, fmt.Errorf("%w: %w", ErrStat, err)
 	}
-	if srcInfo.Size() >= chunkSize {
+	if useBatching(srcInfo.Size(), chunkSize, opts) {
 		logger.Debug("Running batched copy", "file", srcInfo.Name(), "size", srcInfo.Size())
-		return copyFileBatching(readPath, writePath, chunkSize)
+		written, err := copyFileBatching(readPath, writePath, chunkSize, opts, digest)
+		if err == nil && opts.PreserveSpecialBits {
+			err = SetMode(writePath, srcInfo.Mode())
+		}
+		return written, err
 	}
 	// Ensure parent directory exists
 	if err := os.MkdirAll(filepath.Dir(writePath), 0755); err != nil {
-		return false, fmt.Errorf("%w: %v", ErrMkDir, err)
+		return 0, fmt.Errorf("%w: %w", ErrMkDir, err)
 	}
 	// Read source file
 	file, err := os.ReadFile(readPath)
 	if err != nil {
-		return false, fmt.Errorf("%w: %v", ErrRead, err)
+		return 0, fmt.Errorf("%w: %w", ErrRead, err)
+	}
+	if digest != nil {
+		_, _ = digest.Write(file)
 	}
 	// Write to destination with original permissions
 	if err := os.WriteFile(writePath, file, srcInfo.Mode()); err != nil {
-		return false, fmt.Errorf("%w: %v", ErrWrite, err)
+		return 0, fmt.Errorf("%w: %w", ErrWrite, err)
+	}
+	if opts.PreserveSpecialBits {
+		if err := SetMode(writePath, srcInfo.Mode()); err != nil {
+			return 0, err
+		}
 	}
 	logger.Debug("File copied successfully", "source", readPath, "destination", writePath, "size", srcInfo.Size())
-	return true, nil
+	return int64(len(file)), nil
+}
+
+// useBatching reports whether a file of size bytes is streamed in chunks rather than
+// read into memory whole.
+func useBatching(size, chunkSize int64, opts CopyOptions) bool {
+	if opts.Sparse || opts.Resume || opts.LimitKBps > 0 {
+		return true
+	}
+	threshold := opts.BatchThreshold
+	if threshold <= 0 {
+		threshold = chunkSize
+	}
+	return size >= threshold
 }
 
-func copyFileBatching(readPath, writePath string, chunkSize int64) (bool, error) {
+// CopyFileSparse copies like CopyFile but leaves holes in the destination for runs of
+// zero bytes instead of writing them, so sparse files such as VM images stay sparse.
+// Zero runs are detected per sparseBlockSize block; the logical size is preserved.
+func CopyFileSparse(readPath, writePath string, chunkSize int64) (int64, error) {
+	return CopyFileWith(readPath, writePath, chunkSize, CopyOptions{Sparse: true})
+}
+
+// CopyFileResumable copies readPath into PartialPath(writePath) and renames it into place
+// once complete. If a partial file from an interrupted attempt is already there and its
+// content matches the start of the source, the copy continues from its end instead of
+// starting over. It returns the number of bytes written by this attempt.
+func CopyFileResumable(readPath, writePath string, chunkSize int64, sparse bool) (int64, error) {
+	return CopyFileWith(readPath, writePath, chunkSize, CopyOptions{Sparse: sparse, Resume: true})
+}
+
+// PartialPath is where CopyFileResumable keeps an unfinished copy of writePath.
+func PartialPath(writePath string) string {
+	return writePath + partialSuffix
+}
+
+const partialSuffix = ".mimic-partial"
+
+// TempPath is where a file written atomically is kept until it is renamed to path. The
+// suffix lets CleanupTempFiles recognize files a crashed run left behind.
+func TempPath(path string) string {
+	return path + tempSuffix
+}
+
+const tempSuffix = ".mimic.tmp"
+
+// RenamePath is where an entry renamed in two steps, as a case-only rename is, sits in
+// between. The suffix lets CleanupTempFiles finish a rename a crashed run left halfway.
+func RenamePath(path string) string {
+	return path + renameSuffix
+}
+
+const renameSuffix = ".mimic-rename"
+
+// CleanupTempFiles removes the temp files (see TempPath) found under root whose mtime is
+// more than olderThan ago; younger ones may belong to a run still in progress. Partial
+// copies are kept, as a resumed copy continues from them. An entry left halfway through
+// a rename (see RenamePath) is moved on to its new path, or removed when that is taken.
+// Paths for which tracked, if not nil, reports true are left alone, being synced files
+// that merely share a suffix. It returns the removed or moved paths. A missing root has
+// nothing to clean; files that cannot be removed are logged and left.
+func CleanupTempFiles(root string, olderThan time.Duration, tracked func(relPath string) bool) ([]string, error) {
+	cutoff := time.Now().Add(-olderThan)
+	var removed []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			logger.Warn("Cannot scan for temp files", "path", path, "error", err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		isTemp, isRename := strings.HasSuffix(d.Name(), tempSuffix), strings.HasSuffix(d.Name(), renameSuffix)
+		if (isTemp || isRename) && tracked != nil {
+			if relPath, err := filepath.Rel(root, path); err == nil && tracked(relPath) {
+				return nil
+			}
+		}
+		if isRename {
+			if info, err := d.Info(); err == nil && info.ModTime().Before(cutoff) && finishRename(path) {
+				removed = append(removed, path)
+			}
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !isTemp {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			logger.Warn("Cannot remove stale temp file", "path", path, "error", err)
+			return nil
+		}
+		logger.Debug("Removed stale temp file", "path", path, "mtime", info.ModTime())
+		removed = append(removed, path)
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("%w: %w", ErrRemoveDir, err)
+	}
+	return removed, nil
+}
+
+// finishRename moves an entry left at RenamePath(target) to target, or removes it when
+// target exists by now. It reports whether the leftover is gone.
+func finishRename(path string) bool {
+	target := strings.TrimSuffix(path, renameSuffix)
+	if _, err := os.Lstat(target); errors.Is(err, fs.ErrNotExist) {
+		if err := os.Rename(path, target); err != nil {
+			logger.Warn("Cannot finish an interrupted rename", "path", path, "error", err)
+			return false
+		}
+		logger.Debug("Finished an interrupted rename", "path", target)
+		return true
+	}
+	if err := os.RemoveAll(path); err != nil {
+		logger.Warn("Cannot remove a stale rename leftover", "path", path, "error", err)
+		return false
+	}
+	logger.Debug("Removed a stale rename leftover", "path", path)
+	return true
+}
+
+// sleep is swapped out in tests to observe chunk pauses and throttling.
+var sleep = time.Sleep
+
+// batchWriter wraps the destination of a batched copy; tests swap it to misbehave.
+var batchWriter = func(w io.Writer) io.Writer { return w }
+
+// copyFileBatching streams readPath into writePath in chunks. A non-nil digest receives
+// every byte of the source, including the prefix a resumed copy does not read again.
+func copyFileBatching(readPath, writePath string, chunkSize int64, opts CopyOptions, digest hash.Hash) (int64, error) {
 	// Get source file info to preserve permissions
 	srcInfo, err := os.Stat(readPath)
 	if err != nil {
-		return false, fmt.Errorf("%w: %v", ErrStat, err)
+		return 0, fmt.Errorf("%w: %w", ErrStat, err)
 	}
 	// Ensure parent directory exists
 	if err := os.MkdirAll(filepath.Dir(writePath), 0755); err != nil {
-		return false, fmt.Errorf("%w: %v", ErrMkDir, err)
+		return 0, fmt.Errorf("%w: %w", ErrMkDir, err)
 	}
 
 	logger.Debug("Starting batch file copy", "source", readPath, "destination", writePath, "size", srcInfo.Size())
+	if opts.LimitKBps > 0 {
+		// Keep chunks small enough that throttling stays smooth
+		chunkSize = min(chunkSize, int64(opts.LimitKBps)*1024)
+	}
 
-	transport := make(chan []byte, 5)
 	srcFile, err := os.Open(readPath)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.OpenFile(writePath, os.O_CREATE|os.O_WRONLY, srcInfo.Mode())
+	targetPath := writePath
+	offset := int64(0)
+	if opts.Resume {
+		targetPath = PartialPath(writePath)
+		offset = resumeOffset(srcFile, srcInfo.Size(), targetPath)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY
+	}
+	dstFile, err := os.OpenFile(targetPath, flags, srcInfo.Mode())
 	if err != nil {
-		return false, fmt.Errorf("failed to open destination file %w", err)
+		return 0, fmt.Errorf("failed to open destination file %w", err)
 	}
 	defer dstFile.Close()
 
+	if opts.Preallocate && !opts.Sparse {
+		if err := Preallocate(dstFile, srcInfo.Size()); errors.Is(err, ErrNoSpace) {
+			return 0, err
+		} else if err != nil {
+			logger.Warn("Cannot preallocate destination file, copying without", "destination", writePath, "error", err)
+		}
+	}
+
+	if _, err := srcFile.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrRead, err)
+	}
+	if _, err := dstFile.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrBatchWrite, err)
+	}
+	if offset > 0 {
+		logger.Info("Resuming partial copy", "destination", writePath, "offset", offset, "size", srcInfo.Size())
+		if digest != nil {
+			if _, err := io.Copy(digest, io.NewSectionReader(srcFile, 0, offset)); err != nil {
+				return 0, fmt.Errorf("%w: %w", ErrRead, err)
+			}
+		}
+	}
+
+	var src io.Reader = srcFile
+	if digest != nil {
+		src = io.TeeReader(srcFile, digest)
+	}
+	var dst io.Writer = dstFile
+	if opts.Sparse {
+		dst = sparseWriter{dstFile}
+	}
+	totalBytesWritten, err := copyStream(batchWriter(dst), src, chunkSize, opts.QueueDepth, opts.IORetries, newCopyLimiter(opts))
+	if err != nil {
+		logger.Error("Error copying file", "source", readPath, "destination", writePath, "error", err)
+		return totalBytesWritten, err
+	}
+	// Every chunk was written in full, but the source may have changed size while it was read
+	if offset+totalBytesWritten != srcInfo.Size() {
+		logger.Error("Copied size does not match the source", "source", readPath, "destination", writePath,
+			"written", offset+totalBytesWritten, "size", srcInfo.Size())
+		return totalBytesWritten, fmt.Errorf("%w: wrote %d of %d bytes", ErrIncomplete, offset+totalBytesWritten, srcInfo.Size())
+	}
+
+	// A trailing hole was only seeked over, so extend the file to its logical size
+	if opts.Sparse {
+		if err := dstFile.Truncate(offset + totalBytesWritten); err != nil {
+			return totalBytesWritten, fmt.Errorf("%w: %w", ErrBatchWrite, err)
+		}
+	}
+	logger.Debug("Batch file copy completed", "source", readPath, "destination", writePath, "size", totalBytesWritten)
+
+	if opts.Resume {
+		if err := dstFile.Close(); err != nil {
+			return totalBytesWritten, fmt.Errorf("%w: %w", ErrBatchWrite, err)
+		}
+		if err := os.Rename(targetPath, writePath); err != nil {
+			return totalBytesWritten, fmt.Errorf("%w: %w", ErrWrite, err)
+		}
+	}
+
+	return totalBytesWritten, nil
+}
+
+// RateLimiter paces CopyStream. Wait is called with the bytes written so far between
+// chunks and once more after the last one, with last set.
+type RateLimiter interface {
+	Wait(written int64, last bool)
+}
+
+// RateLimiterFunc adapts a function to a RateLimiter, e.g. to report progress.
+type RateLimiterFunc func(written int64, last bool)
+
+func (f RateLimiterFunc) Wait(written int64, last bool) { f(written, last) }
+
+// copyLimiter applies CopyOptions.ChunkPause between chunks and keeps the copy under
+// CopyOptions.LimitKBps.
+type copyLimiter struct {
+	pause     time.Duration
+	limitKBps int
+	start     time.Time
+}
+
+// newCopyLimiter returns the limiter for opts, or nil when it neither pauses nor throttles.
+func newCopyLimiter(opts CopyOptions) RateLimiter {
+	if opts.ChunkPause <= 0 && opts.LimitKBps <= 0 {
+		return nil
+	}
+	return &copyLimiter{pause: opts.ChunkPause, limitKBps: opts.LimitKBps, start: time.Now()}
+}
+
+func (l *copyLimiter) Wait(written int64, last bool) {
+	if l.limitKBps > 0 {
+		expected := time.Duration(float64(written) / float64(l.limitKBps*1024) * float64(time.Second))
+		if ahead := expected - time.Since(l.start); ahead > 0 {
+			sleep(ahead)
+		}
+	}
+	if l.pause > 0 && !last {
+		sleep(l.pause)
+	}
+}
+
+// sparseWriter writes through writeSparse, seeking over zero blocks.
+type sparseWriter struct {
+	file *os.File
+}
+
+func (w sparseWriter) Write(data []byte) (int, error) {
+	return writeSparse(w.file, data)
+}
+
+// CopyStream copies src to dst in chunks of up to chunkSize bytes, reading the next
+// chunks while the current one is written. A non-nil limiter is consulted between chunks
+// and after the last one. It returns the bytes written; read errors wrap ErrBatchRead
+// and write errors ErrBatchWrite.
+func CopyStream(dst io.Writer, src io.Reader, chunkSize int64, limiter RateLimiter) (int64, error) {
+	return copyStream(dst, src, chunkSize, defaultQueueDepth, 0, limiter)
+}
+
+// copyStream is CopyStream with up to depth chunks read ahead of the writer (0 for
+// defaultQueueDepth), retrying reads and writes that fail with a transient error up to
+// retries times in a row.
+func copyStream(dst io.Writer, src io.Reader, chunkSize int64, depth, retries int, limiter RateLimiter) (int64, error) {
+	if depth <= 0 {
+		depth = defaultQueueDepth
+	}
+	transport := make(chan *[]byte, depth)
+	done := make(chan struct{})
+	var readErr error
 	var readerDone sync.WaitGroup
 	readerDone.Add(1)
-	errChan := make(chan error, 1)
 
-	logger.Debug("Starting file reader goroutine", "path", readPath)
 	go func() {
 		defer readerDone.Done()
 		defer close(transport)
-		buf := make([]byte, chunkSize)
-		totalBytesRead := int64(0)
-
+		failures := 0
 		for {
-			n, err := srcFile.Read(buf)
+			// The writer owns each chunk it receives until it puts it back in the pool
+			buf := getChunk(chunkSize)
+			n, err := src.Read(*buf)
 			if n > 0 {
-				totalBytesRead += int64(n)
-				bufCopy := make([]byte, n)
-				copy(bufCopy, buf[:n])
-				transport <- bufCopy
-
-				if totalBytesRead%(chunkSize*10) == 0 {
-					logger.Debug("Reading progress", "path", readPath, "bytesRead", totalBytesRead, "percentage", float64(totalBytesRead)/float64(srcInfo.Size())*100)
+				failures = 0
+				*buf = (*buf)[:n]
+				select {
+				case transport <- buf:
+				case <-done:
+					putChunk(buf)
+					return
 				}
+			} else {
+				putChunk(buf)
 			}
 			if err != nil {
-				if err == io.EOF {
-					logger.Debug("Reached end of file", "path", readPath, "totalBytesRead", totalBytesRead)
-					break
+				if err != io.EOF && retryTransient(err, failures, retries) {
+					failures++
+					continue
 				}
-				logger.Error("Error reading file", "path", readPath, "error", err)
-				select {
-				case errChan <- fmt.Errorf("%w: %v", ErrRead, err):
-				default:
+				if err != io.EOF {
+					readErr = fmt.Errorf("%w: %w: %w", ErrBatchRead, ErrRead, err)
 				}
 				return
 			}
 		}
 	}()
+	// Stop the reader if the writer gives up early
+	defer func() {
+		close(done)
+		readerDone.Wait()
+	}()
 
-	totalBytesWritten := int64(0)
-	for data := range transport {
-		n, err := dstFile.Write(data)
-		if err != nil {
-			logger.Error("Error writing to file", "path", writePath, "error", err)
-			return false, fmt.Errorf("%w: %v", ErrBatchWrite, err)
+	written := int64(0)
+	chunks := 0
+	for chunk := range transport {
+		if chunks > 0 && limiter != nil {
+			limiter.Wait(written, false)
 		}
-		totalBytesWritten += int64(n)
+		chunks++
 
-		if totalBytesWritten%(chunkSize*10) == 0 {
-			logger.Debug("Writing progress", "path", writePath, "bytesWritten", totalBytesWritten, "percentage", float64(totalBytesWritten)/float64(srcInfo.Size())*100)
+		n, err := writeChunk(dst, *chunk, retries)
+		putChunk(chunk)
+		written += int64(n)
+		if err != nil {
+			return written, fmt.Errorf("%w: %w", ErrBatchWrite, err)
 		}
 	}
+	if chunks > 0 && limiter != nil {
+		limiter.Wait(written, true)
+	}
 
+	// The reader has closed transport, so readErr is settled
 	readerDone.Wait()
+	if readErr != nil {
+		return written, readErr
+	}
+	return written, nil
+}
+
+// writeChunk writes the whole chunk to dst, continuing from where a write that failed
+// with a transient error stopped, up to retries times in a row.
+func writeChunk(dst io.Writer, chunk []byte, retries int) (int, error) {
+	written, failures := 0, 0
+	for {
+		n, err := dst.Write(chunk[written:])
+		written += n
+		if n > 0 {
+			failures = 0
+		}
+		if err == nil && written < len(chunk) {
+			err = io.ErrShortWrite
+		}
+		if err == nil || !retryTransient(err, failures, retries) {
+			return written, err
+		}
+		failures++
+	}
+}
+
+// transientIOError reports whether err is an errno that network and FUSE file systems
+// return under load and that a read or write may simply be tried again on.
+func transientIOError(err error) bool {
+	return errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.ETIMEDOUT)
+}
 
-	select {
-	case err := <-errChan:
-		return false, fmt.Errorf("%w: %v", ErrBatchRead, err)
-	default:
-		logger.Debug("Batch file copy completed", "source", readPath, "destination", writePath, "size", totalBytesWritten)
+// retryTransient reports whether an I/O call that failed with err after failures
+// earlier failures in a row gets another try, pausing before it does.
+func retryTransient(err error, failures, retries int) bool {
+	if failures >= retries || !transientIOError(err) {
+		return false
 	}
+	logger.Debug("Retrying transient I/O error", "attempt", failures+1, "error", err)
+	sleep(ioRetryDelay << failures)
+	return true
+}
 
-	return true, nil
+// chunkPools holds a *sync.Pool of *[]byte chunk buffers per chunk size, so batched copies
+// reuse their buffers across chunks and files instead of allocating one per read.
+var chunkPools sync.Map
+
+// getChunk borrows a buffer of length size from the pool for that size.
+func getChunk(size int64) *[]byte {
+	pool, ok := chunkPools.Load(size)
+	if !ok {
+		pool, _ = chunkPools.LoadOrStore(size, &sync.Pool{New: func() any {
+			buf := make([]byte, size)
+			return &buf
+		}})
+	}
+	return pool.(*sync.Pool).Get().(*[]byte)
+}
+
+// putChunk returns a buffer borrowed with getChunk, however much of it was used.
+func putChunk(buf *[]byte) {
+	*buf = (*buf)[:cap(*buf)]
+	if pool, ok := chunkPools.Load(int64(len(*buf))); ok {
+		pool.(*sync.Pool).Put(buf)
+	}
+}
+
+// resumeOffset returns how many bytes of the partial file at partialPath can be kept:
+// its whole length if that content hashes the same as the same-length prefix of src,
+// otherwise 0.
+func resumeOffset(src *os.File, srcSize int64, partialPath string) int64 {
+	info, err := os.Stat(partialPath)
+	if err != nil || info.Size() == 0 || info.Size() > srcSize {
+		return 0
+	}
+
+	partial, err := os.Open(partialPath)
+	if err != nil {
+		return 0
+	}
+	defer partial.Close()
+
+	srcHash, err := hashPrefix(src, info.Size())
+	if err != nil {
+		return 0
+	}
+	partialHash, err := hashPrefix(partial, info.Size())
+	if err != nil {
+		return 0
+	}
+	if srcHash != partialHash {
+		logger.Debug("Partial copy does not match source, restarting", "path", partialPath)
+		return 0
+	}
+	return info.Size()
+}
+
+// AppendCopy brings writePath up to date with readPath by appending the bytes of
+// readPath past fromOffset, for files that only grew since they were last copied.
+// writePath must be exactly fromOffset bytes long and hash the same as that prefix of
+// a longer readPath; this is verified first, and ErrNotPrefix is returned without
+// writing anything when it does not hold. It returns the number of bytes appended.
+func AppendCopy(readPath, writePath string, fromOffset int64) (int64, error) {
+	src, err := os.Open(readPath)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrRead, err)
+	}
+	defer src.Close()
+	srcInfo, err := src.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrStat, err)
+	}
+
+	dst, err := os.OpenFile(writePath, os.O_RDWR, 0)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrWrite, err)
+	}
+	defer dst.Close()
+	dstInfo, err := dst.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrStat, err)
+	}
+	if fromOffset <= 0 || dstInfo.Size() != fromOffset || srcInfo.Size() <= fromOffset {
+		return 0, ErrNotPrefix
+	}
+
+	srcHash, err := hashPrefix(src, fromOffset)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrRead, err)
+	}
+	dstHash, err := hashPrefix(dst, fromOffset)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrRead, err)
+	}
+	if srcHash != dstHash {
+		logger.Debug("Destination content differs from the source prefix", "path", writePath)
+		return 0, ErrNotPrefix
+	}
+
+	// Both files are positioned at fromOffset after hashing their prefixes
+	written, err := io.Copy(dst, src)
+	if err != nil {
+		return written, fmt.Errorf("%w: %w", ErrWrite, err)
+	}
+	if err := dst.Close(); err != nil {
+		return written, fmt.Errorf("%w: %w", ErrWrite, err)
+	}
+	logger.Debug("Appended to file", "source", readPath, "destination", writePath, "offset", fromOffset, "size", written)
+	return written, nil
+}
+
+func hashPrefix(file *os.File, n int64) (uint64, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	hasher := xxhash.New()
+	if _, err := io.CopyN(hasher, file, n); err != nil {
+		return 0, err
+	}
+	return hasher.Sum64(), nil
+}
+
+// sparseBlockSize is the granularity at which zero runs are turned into holes.
+const sparseBlockSize = 4096
+
+var zeroBlock = make([]byte, sparseBlockSize)
+
+// writeSparse writes data at the current offset, seeking over all-zero blocks instead
+// of writing them. It returns the logical number of bytes consumed.
+func writeSparse(file *os.File, data []byte) (int, error) {
+	written := 0
+	for written < len(data) {
+		end := min(written+sparseBlockSize, len(data))
+		block := data[written:end]
+		if bytes.Equal(block, zeroBlock[:len(block)]) {
+			if _, err := file.Seek(int64(len(block)), io.SeekCurrent); err != nil {
+				return written, err
+			}
+		} else if _, err := file.Write(block); err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// SetMode sets the permission bits of name together with its setuid, setgid and sticky
+// bits to those of mode. Setting setuid or setgid may need privileges on some systems.
+func SetMode(name string, mode os.FileMode) error {
+	if err := os.Chmod(name, mode&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+		return fmt.Errorf("%w: %w", ErrChmod, err)
+	}
+	return nil
 }
 
 // CreateDir creates a directory and all necessary parent directories
 func CreateDir(name string) (bool, error) {
 	if err := os.MkdirAll(name, 0755); err != nil {
 		logger.Error("Failed to create directory", "path", name, "error", err)
-		return false, fmt.Errorf("%w: %v", ErrMkDir, err)
+		return false, fmt.Errorf("%w: %w", ErrMkDir, err)
 	}
 	logger.Debug("Directory created", "path", name)
 	return true, nil
@@ -160,7 +716,7 @@ func DeletePath(name string) (bool, error) {
 
 	if err := os.RemoveAll(name); err != nil {
 		logger.Error("Failed to remove path", "path", name, "error", err)
-		return false, fmt.Errorf("%w: %v", ErrRemoveDir, err)
+		return false, fmt.Errorf("%w: %w", ErrRemoveDir, err)
 	}
 
 	logger.Debug("Path removed successfully", "path", name)
@@ -186,5 +742,44 @@ func PathExists(path string) (bool, error) {
 	}
 
 	logger.Error("Error checking if path exists", "path", path, "error", err)
-	return false, fmt.Errorf("%w: %v", ErrStat, err)
+	return false, fmt.Errorf("%w: %w", ErrStat, err)
+}
+
+// PruneEmptyDirs removes the directories among dirs, relative to root, that are empty,
+// deepest first, so a parent listed along with its children is pruned once they are
+// gone. Directories in keep and those already missing are skipped. It returns the
+// relative paths of the removed directories.
+func PruneEmptyDirs(root string, dirs []string, keep map[string]bool) ([]string, error) {
+	dirs = slices.Clone(dirs)
+	slices.SortFunc(dirs, func(a, b string) int {
+		depth := strings.Count(b, string(filepath.Separator)) - strings.Count(a, string(filepath.Separator))
+		return cmp.Or(depth, strings.Compare(a, b))
+	})
+
+	var removed []string
+	for _, relPath := range slices.Compact(dirs) {
+		if keep[relPath] || relPath == "." {
+			continue
+		}
+
+		path := filepath.Join(root, relPath)
+		entries, err := os.ReadDir(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("%w: %w", ErrRead, err)
+		}
+		if len(entries) > 0 {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("%w: %w", ErrRemoveDir, err)
+		}
+		logger.Debug("Pruned empty directory", "path", path)
+		removed = append(removed, relPath)
+	}
+
+	return removed, nil
 }
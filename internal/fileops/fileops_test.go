@@ -1,10 +1,22 @@
 package fileops
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"testing"
+	"testing/iotest"
+	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/ogzhanolguncu/mimic/internal/config"
 	"github.com/stretchr/testify/require"
 )
@@ -25,9 +37,9 @@ func TestCopyFile(t *testing.T) {
 	require.NoError(t, err, "Failed to create source file")
 
 	// Copy file
-	success, err := CopyFile(sourcePath, destPath, config.DefaultChunkSize)
+	written, err := CopyFile(sourcePath, destPath, config.DefaultChunkSize)
 	require.NoError(t, err, "CopyFile should not return error")
-	require.True(t, success, "CopyFile should return success")
+	require.Equal(t, int64(len(testContent)), written, "CopyFile should report bytes written")
 
 	// Verify content is the same
 	destContent, err := os.ReadFile(destPath)
@@ -36,9 +48,9 @@ func TestCopyFile(t *testing.T) {
 
 	// Test case 2: Copy to a destination in a non-existent directory
 	nestedDestPath := filepath.Join(tempDir, "subdir", "nested", "destination.txt")
-	success, err = CopyFile(sourcePath, nestedDestPath, config.DefaultChunkSize)
+	written, err = CopyFile(sourcePath, nestedDestPath, config.DefaultChunkSize)
 	require.NoError(t, err, "CopyFile should create parent directories")
-	require.True(t, success, "CopyFile should return success")
+	require.Equal(t, int64(len(testContent)), written, "CopyFile should report bytes written")
 
 	// Verify content
 	destContent, err = os.ReadFile(nestedDestPath)
@@ -47,9 +59,9 @@ func TestCopyFile(t *testing.T) {
 
 	// Test case 3: Source file doesn't exist
 	nonExistPath := filepath.Join(tempDir, "nonexistent.txt")
-	success, err = CopyFile(nonExistPath, destPath, config.DefaultChunkSize)
+	written, err = CopyFile(nonExistPath, destPath, config.DefaultChunkSize)
 	require.Error(t, err, "CopyFile should return error for non-existent source")
-	require.False(t, success, "CopyFile should not return success")
+	require.Zero(t, written, "CopyFile should not report bytes written")
 }
 
 func TestLargeFileCopy(t *testing.T) {
@@ -93,9 +105,9 @@ func TestLargeFileCopy(t *testing.T) {
 	require.GreaterOrEqual(t, info.Size(), int64(config.DefaultChunkSize), "Test file should be larger than chunk size")
 
 	// Perform the copy
-	success, err := CopyFile(sourcePath, destPath, config.DefaultChunkSize)
+	written, err := CopyFile(sourcePath, destPath, config.DefaultChunkSize)
 	require.NoError(t, err, "Failed to copy large file")
-	require.True(t, success, "CopyFile should return success")
+	require.Equal(t, info.Size(), written, "CopyFile should report bytes written")
 
 	// Verify destination file size matches source
 	destInfo, err := os.Stat(destPath)
@@ -216,3 +228,662 @@ func TestDeletePath(t *testing.T) {
 	require.NoError(t, err, "DeletePath should not error on non-existent path")
 	require.True(t, success, "DeletePath should return success for non-existent path")
 }
+
+func TestPruneEmptyDirs(t *testing.T) {
+	root := t.TempDir()
+
+	for _, dir := range []string{
+		filepath.Join("a", "b", "c"),
+		filepath.Join("a", "keep-empty"),
+		filepath.Join("d", "e"),
+		"f",
+		"untracked",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "d", "file.txt"), []byte("data"), 0644))
+
+	// Simulate leaf files deleted by a sync: a/b/c and f are now empty husks
+	keep := map[string]bool{filepath.Join("a", "keep-empty"): true}
+	dirs := []string{
+		"a", filepath.Join("a", "b"), filepath.Join("a", "b", "c"), filepath.Join("a", "keep-empty"),
+		"d", filepath.Join("d", "e"), "f", "f", "missing",
+	}
+
+	removed, err := PruneEmptyDirs(root, dirs, keep)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		filepath.Join("a", "b", "c"),
+		filepath.Join("a", "b"),
+		filepath.Join("d", "e"),
+		"f",
+	}, removed)
+
+	require.DirExists(t, filepath.Join(root, "a", "keep-empty"), "Expected empty source directory to be kept")
+	require.DirExists(t, filepath.Join(root, "a"), "Expected parent of kept directory to survive")
+	require.FileExists(t, filepath.Join(root, "d", "file.txt"))
+	require.NoDirExists(t, filepath.Join(root, "a", "b"))
+	require.DirExists(t, filepath.Join(root, "untracked"), "Expected directories not listed to be left alone")
+	require.DirExists(t, root, "Expected root to never be removed")
+}
+
+func TestCopyFileResumable(t *testing.T) {
+	tempDir := t.TempDir()
+	sourcePath := filepath.Join(tempDir, "source.bin")
+	destPath := filepath.Join(tempDir, "dest.bin")
+	chunkSize := int64(64 << 10)
+
+	content := make([]byte, 1<<20)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	require.NoError(t, os.WriteFile(sourcePath, content, 0644))
+
+	tests := []struct {
+		name        string
+		partial     []byte
+		wantWritten int64
+	}{
+		{"ResumesMatchingPrefix", content[:300<<10], int64(len(content) - 300<<10)},
+		{"RestartsMismatchedPrefix", append([]byte("garbage"), content[7:300<<10]...), int64(len(content))},
+		{"RestartsOversizedPartial", append(slices.Clone(content), 'x'), int64(len(content))},
+		{"CopiesWithoutPartial", nil, int64(len(content))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Remove(destPath)
+			if tt.partial != nil {
+				// Simulate an interrupted attempt that left a truncated partial file
+				require.NoError(t, os.WriteFile(PartialPath(destPath), tt.partial, 0644))
+			}
+
+			written, err := CopyFileResumable(sourcePath, destPath, chunkSize, false)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantWritten, written, "Expected only the missing bytes to be written")
+
+			got, err := os.ReadFile(destPath)
+			require.NoError(t, err)
+			require.Equal(t, content, got, "Expected the resumed copy to match the source")
+			require.NoFileExists(t, PartialPath(destPath), "Expected the partial file to be renamed into place")
+		})
+	}
+}
+
+func TestAppendCopy(t *testing.T) {
+	tempDir := t.TempDir()
+	sourcePath := filepath.Join(tempDir, "app.log")
+	destPath := filepath.Join(tempDir, "copy.log")
+
+	original := []byte(strings.Repeat("line of log output\n", 1000))
+	grown := append(slices.Clone(original), "one more line\n"...)
+	edited := slices.Clone(grown)
+	edited[100] = 'X'
+
+	tests := []struct {
+		name        string
+		source      []byte
+		fromOffset  int64
+		wantErr     error
+		wantWritten int64
+	}{
+		{name: "PureAppend", source: grown, fromOffset: int64(len(original)), wantWritten: int64(len(grown) - len(original))},
+		{name: "MidFileChange", source: edited, fromOffset: int64(len(original)), wantErr: ErrNotPrefix},
+		{name: "OffsetNotDestinationSize", source: grown, fromOffset: 10, wantErr: ErrNotPrefix},
+		{name: "SourceNotLonger", source: original, fromOffset: int64(len(original)), wantErr: ErrNotPrefix},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(sourcePath, tt.source, 0644))
+			require.NoError(t, os.WriteFile(destPath, original, 0644))
+
+			written, err := AppendCopy(sourcePath, destPath, tt.fromOffset)
+			got, readErr := os.ReadFile(destPath)
+			require.NoError(t, readErr)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				require.Equal(t, original, got, "Expected the destination to be left untouched")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantWritten, written, "Expected only the new tail to be written")
+			require.Equal(t, tt.source, got)
+		})
+	}
+}
+
+func TestCopyFileChunkPause(t *testing.T) {
+	tempDir := t.TempDir()
+	sourcePath := filepath.Join(tempDir, "source.bin")
+	destPath := filepath.Join(tempDir, "dest.bin")
+	chunkSize := int64(4 << 10)
+
+	content := make([]byte, 10*chunkSize)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	require.NoError(t, os.WriteFile(sourcePath, content, 0644))
+
+	var pauses []time.Duration
+	original := sleep
+	sleep = func(d time.Duration) { pauses = append(pauses, d) }
+	t.Cleanup(func() { sleep = original })
+
+	written, err := CopyFileWith(sourcePath, destPath, chunkSize, CopyOptions{ChunkPause: 5 * time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), written)
+
+	require.Len(t, pauses, 9, "Expected a pause between each of the 10 chunks")
+	for _, pause := range pauses {
+		require.Equal(t, 5*time.Millisecond, pause)
+	}
+
+	got, err := os.ReadFile(destPath)
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+}
+
+func TestCopyFileLimit(t *testing.T) {
+	tempDir := t.TempDir()
+	sourcePath := filepath.Join(tempDir, "source.bin")
+	destPath := filepath.Join(tempDir, "dest.bin")
+	content := make([]byte, 8<<10)
+	require.NoError(t, os.WriteFile(sourcePath, content, 0644))
+
+	var pauses []time.Duration
+	original := sleep
+	sleep = func(d time.Duration) { pauses = append(pauses, d) }
+	t.Cleanup(func() { sleep = original })
+
+	// Below the batching threshold, but a limited copy is still streamed in 4KB chunks
+	written, err := CopyFileWith(sourcePath, destPath, 1<<20, CopyOptions{LimitKBps: 4})
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), written)
+	require.Len(t, pauses, 2, "Expected a pause after each chunk")
+	// The fake sleep takes no time, so the last pause covers the whole 8KB at 4KB/s
+	require.InDelta(t, 2*time.Second, pauses[1], float64(100*time.Millisecond), "Expected the copy to be held to the limit")
+}
+
+func TestCopyFileChecksum(t *testing.T) {
+	tempDir := t.TempDir()
+	sourcePath := filepath.Join(tempDir, "source.bin")
+	destPath := filepath.Join(tempDir, "dest.bin")
+	chunkSize := int64(64 << 10)
+
+	content := make([]byte, 1<<20+123)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	require.NoError(t, os.WriteFile(sourcePath, content, 0644))
+	digest := xxhash.New()
+	_, _ = digest.Write(content)
+	want := hex.EncodeToString(digest.Sum(nil))
+
+	tests := []struct {
+		name    string
+		opts    CopyOptions
+		chunk   int64
+		partial []byte
+	}{
+		{"ReadWhole", CopyOptions{}, 4 << 20, nil},
+		{"Batched", CopyOptions{}, chunkSize, nil},
+		{"ResumedPrefix", CopyOptions{Resume: true}, chunkSize, content[:300<<10]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Remove(destPath)
+			if tt.partial != nil {
+				require.NoError(t, os.WriteFile(PartialPath(destPath), tt.partial, 0644))
+			}
+
+			_, checksum, err := CopyFileChecksum(sourcePath, destPath, tt.chunk, tt.opts)
+			require.NoError(t, err)
+			require.Equal(t, want, checksum, "Expected the copy-time checksum to match a separate hash of the source")
+
+			got, err := os.ReadFile(destPath)
+			require.NoError(t, err)
+			require.Equal(t, content, got)
+		})
+	}
+}
+
+func TestCopyFileQueueDepth(t *testing.T) {
+	tempDir := t.TempDir()
+	sourcePath := filepath.Join(tempDir, "source.bin")
+	chunkSize := int64(4 << 10)
+
+	content := make([]byte, 37*chunkSize+17)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	require.NoError(t, os.WriteFile(sourcePath, content, 0644))
+
+	for _, depth := range []int{0, 1, 5, 64} {
+		t.Run(fmt.Sprintf("Depth%d", depth), func(t *testing.T) {
+			destPath := filepath.Join(tempDir, fmt.Sprintf("dest-%d.bin", depth))
+			written, err := CopyFileWith(sourcePath, destPath, chunkSize, CopyOptions{QueueDepth: depth})
+			require.NoError(t, err)
+			require.Equal(t, int64(len(content)), written)
+
+			got, err := os.ReadFile(destPath)
+			require.NoError(t, err)
+			require.Equal(t, content, got, "Expected the copy to match the source at any queue depth")
+		})
+	}
+}
+
+func BenchmarkCopyFileQueueDepth(b *testing.B) {
+	tempDir := b.TempDir()
+	sourcePath := filepath.Join(tempDir, "source.bin")
+	destPath := filepath.Join(tempDir, "dest.bin")
+	chunkSize := int64(256 << 10)
+	require.NoError(b, os.WriteFile(sourcePath, make([]byte, 64<<20), 0644))
+
+	for _, depth := range []int{1, 5, 16} {
+		b.Run(fmt.Sprintf("Depth%d", depth), func(b *testing.B) {
+			b.SetBytes(64 << 20)
+			for range b.N {
+				if _, err := CopyFileWith(sourcePath, destPath, chunkSize, CopyOptions{QueueDepth: depth}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCopyFileReusesChunks(t *testing.T) {
+	tempDir := t.TempDir()
+	chunkSize := int64(4 << 10)
+
+	// Every chunk differs, so a buffer handed back to the pool too early would corrupt the copy
+	for i, seed := range []int{7, 13, 7} {
+		sourcePath := filepath.Join(tempDir, fmt.Sprintf("source-%d.bin", i))
+		destPath := filepath.Join(tempDir, fmt.Sprintf("dest-%d.bin", i))
+		content := make([]byte, 50*chunkSize+1)
+		for j := range content {
+			content[j] = byte(j/int(chunkSize)*seed + j%seed)
+		}
+		require.NoError(t, os.WriteFile(sourcePath, content, 0644))
+
+		_, err := CopyFileWith(sourcePath, destPath, chunkSize, CopyOptions{QueueDepth: 1})
+		require.NoError(t, err)
+		got, err := os.ReadFile(destPath)
+		require.NoError(t, err)
+		require.Equal(t, content, got, "Expected copy %d to match its source with reused buffers", i)
+	}
+
+	buf := getChunk(chunkSize)
+	require.Len(t, *buf, int(chunkSize), "Expected pooled buffers to come back at full length")
+	putChunk(buf)
+}
+
+func BenchmarkCopyFileBatching(b *testing.B) {
+	tempDir := b.TempDir()
+	sourcePath := filepath.Join(tempDir, "source.bin")
+	destPath := filepath.Join(tempDir, "dest.bin")
+	chunkSize := int64(64 << 10)
+	require.NoError(b, os.WriteFile(sourcePath, make([]byte, 16<<20), 0644))
+
+	b.ReportAllocs()
+	b.SetBytes(16 << 20)
+	for range b.N {
+		if _, err := CopyFileWith(sourcePath, destPath, chunkSize, CopyOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// failingWriter accepts limit bytes, then fails every write with err; short makes it
+// report a short write without an error instead.
+type failingWriter struct {
+	buf   bytes.Buffer
+	limit int
+	err   error
+	short bool
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	room := w.limit - w.buf.Len()
+	if len(p) <= room {
+		return w.buf.Write(p)
+	}
+	n, _ := w.buf.Write(p[:max(room, 0)])
+	if w.short {
+		return n, nil
+	}
+	return n, w.err
+}
+
+func TestCopyStream(t *testing.T) {
+	content := make([]byte, 10<<10+3)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	const chunkSize = 1 << 10
+
+	t.Run("ShortReads", func(t *testing.T) {
+		for name, src := range map[string]io.Reader{
+			"OneByte": iotest.OneByteReader(bytes.NewReader(content)),
+			"Half":    iotest.HalfReader(bytes.NewReader(content)),
+			"DataErr": iotest.DataErrReader(bytes.NewReader(content)),
+		} {
+			t.Run(name, func(t *testing.T) {
+				var dst bytes.Buffer
+				written, err := CopyStream(&dst, src, chunkSize, nil)
+				require.NoError(t, err)
+				require.Equal(t, int64(len(content)), written)
+				require.Equal(t, content, dst.Bytes())
+			})
+		}
+	})
+
+	t.Run("ReadError", func(t *testing.T) {
+		var dst bytes.Buffer
+		src := io.MultiReader(bytes.NewReader(content[:3<<10]), iotest.ErrReader(context.Canceled))
+		written, err := CopyStream(&dst, src, chunkSize, nil)
+		require.ErrorIs(t, err, ErrBatchRead)
+		require.ErrorIs(t, err, context.Canceled, "Expected the reader's cancellation to surface")
+		require.Equal(t, int64(3<<10), written, "Expected the chunks read before the error to be written")
+		require.Equal(t, content[:3<<10], dst.Bytes())
+	})
+
+	t.Run("WriteError", func(t *testing.T) {
+		diskFull := errors.New("disk full")
+		dst := &failingWriter{limit: 2<<10 + 100, err: diskFull}
+		written, err := CopyStream(dst, bytes.NewReader(content), chunkSize, nil)
+		require.ErrorIs(t, err, ErrBatchWrite)
+		require.ErrorIs(t, err, diskFull)
+		require.Equal(t, int64(2<<10+100), written)
+		require.Equal(t, content[:written], dst.buf.Bytes())
+	})
+
+	t.Run("ShortWrite", func(t *testing.T) {
+		dst := &failingWriter{limit: 100, short: true}
+		_, err := CopyStream(dst, bytes.NewReader(content), chunkSize, nil)
+		require.ErrorIs(t, err, io.ErrShortWrite)
+	})
+
+	t.Run("Limiter", func(t *testing.T) {
+		var progress []int64
+		var lastCalls int
+		limiter := RateLimiterFunc(func(written int64, last bool) {
+			progress = append(progress, written)
+			if last {
+				lastCalls++
+			}
+		})
+		var dst bytes.Buffer
+		_, err := CopyStream(&dst, bytes.NewReader(content[:3<<10]), chunkSize, limiter)
+		require.NoError(t, err)
+		require.Equal(t, []int64{1 << 10, 2 << 10, 3 << 10}, progress, "Expected a call between chunks and after the last")
+		require.Equal(t, 1, lastCalls)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		var dst bytes.Buffer
+		written, err := CopyStream(&dst, bytes.NewReader(nil), chunkSize, RateLimiterFunc(func(int64, bool) {
+			t.Fatal("Expected no limiter calls for an empty stream")
+		}))
+		require.NoError(t, err)
+		require.Zero(t, written)
+	})
+}
+
+// shortWriter writes only the first half of every chunk through to w and reports that
+// count without an error.
+type shortWriter struct {
+	w io.Writer
+}
+
+func (s shortWriter) Write(p []byte) (int, error) {
+	return s.w.Write(p[:len(p)/2])
+}
+
+// beforeWrite calls fn ahead of the first write through to w.
+type beforeWrite struct {
+	w    io.Writer
+	fn   func()
+	done bool
+}
+
+func (b *beforeWrite) Write(p []byte) (int, error) {
+	if !b.done {
+		b.done = true
+		b.fn()
+	}
+	return b.w.Write(p)
+}
+
+func TestCopyFileIncomplete(t *testing.T) {
+	const chunkSize = 1 << 10
+	content := bytes.Repeat([]byte("0123456789"), 2<<10)
+	useWriter := func(t *testing.T, wrap func(io.Writer) io.Writer) {
+		original := batchWriter
+		batchWriter = wrap
+		t.Cleanup(func() { batchWriter = original })
+	}
+
+	t.Run("ShortWrite", func(t *testing.T) {
+		tempDir := t.TempDir()
+		sourcePath := filepath.Join(tempDir, "source.bin")
+		require.NoError(t, os.WriteFile(sourcePath, content, 0644))
+		useWriter(t, func(w io.Writer) io.Writer { return shortWriter{w} })
+
+		written, err := CopyFileWith(sourcePath, filepath.Join(tempDir, "dest.bin"), chunkSize, CopyOptions{BatchThreshold: 1})
+		require.ErrorIs(t, err, ErrBatchWrite)
+		require.ErrorIs(t, err, io.ErrShortWrite)
+		require.Equal(t, int64(chunkSize/2), written, "Expected only the bytes actually written to be counted")
+	})
+
+	t.Run("SourceShrinks", func(t *testing.T) {
+		tempDir := t.TempDir()
+		sourcePath := filepath.Join(tempDir, "source.bin")
+		require.NoError(t, os.WriteFile(sourcePath, content, 0644))
+		useWriter(t, func(w io.Writer) io.Writer {
+			return &beforeWrite{w: w, fn: func() { require.NoError(t, os.Truncate(sourcePath, chunkSize)) }}
+		})
+
+		written, err := CopyFileWith(sourcePath, filepath.Join(tempDir, "dest.bin"), chunkSize, CopyOptions{BatchThreshold: 1, QueueDepth: 1})
+		require.ErrorIs(t, err, ErrIncomplete)
+		require.Less(t, written, int64(len(content)))
+		require.ErrorContains(t, err, fmt.Sprintf("of %d bytes", len(content)))
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		tempDir := t.TempDir()
+		sourcePath := filepath.Join(tempDir, "source.bin")
+		require.NoError(t, os.WriteFile(sourcePath, content, 0644))
+
+		written, err := CopyFileWith(sourcePath, filepath.Join(tempDir, "dest.bin"), chunkSize, CopyOptions{BatchThreshold: 1})
+		require.NoError(t, err)
+		require.Equal(t, int64(len(content)), written)
+	})
+}
+
+// flakyWriter writes half of the first chunk through to w and then fails with err, and
+// keeps failing until it has failed failures times; later writes pass through.
+type flakyWriter struct {
+	w        io.Writer
+	err      error
+	failures int
+	calls    int
+}
+
+func (f *flakyWriter) Write(p []byte) (int, error) {
+	f.calls++
+	if f.calls > f.failures {
+		return f.w.Write(p)
+	}
+	if f.calls == 1 {
+		n, _ := f.w.Write(p[:len(p)/2])
+		return n, f.err
+	}
+	return 0, f.err
+}
+
+// flakyReader fails its first failures reads with err before reading from r.
+type flakyReader struct {
+	r        io.Reader
+	err      error
+	failures int
+}
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	if f.failures > 0 {
+		f.failures--
+		return 0, f.err
+	}
+	return f.r.Read(p)
+}
+
+func TestCopyFileTransientErrors(t *testing.T) {
+	const chunkSize = 1 << 10
+	content := bytes.Repeat([]byte("0123456789"), 1<<10)
+	var pauses []time.Duration
+	original := sleep
+	sleep = func(d time.Duration) { pauses = append(pauses, d) }
+	t.Cleanup(func() { sleep = original })
+
+	testCases := []struct {
+		name      string
+		err       error
+		failures  int
+		expectErr bool
+	}{
+		{name: "EAGAINRecovers", err: syscall.EAGAIN, failures: 3},
+		{name: "ETIMEDOUTRecovers", err: syscall.ETIMEDOUT, failures: 1},
+		{name: "GivesUp", err: syscall.EAGAIN, failures: 4, expectErr: true},
+		{name: "PermanentNotRetried", err: syscall.EIO, failures: 1, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pauses = nil
+			tempDir := t.TempDir()
+			sourcePath, destPath := filepath.Join(tempDir, "source.bin"), filepath.Join(tempDir, "dest.bin")
+			require.NoError(t, os.WriteFile(sourcePath, content, 0644))
+			original := batchWriter
+			batchWriter = func(w io.Writer) io.Writer { return &flakyWriter{w: w, err: tc.err, failures: tc.failures} }
+			t.Cleanup(func() { batchWriter = original })
+
+			_, err := CopyFileWith(sourcePath, destPath, chunkSize, CopyOptions{BatchThreshold: 1, IORetries: 3})
+			if tc.expectErr {
+				require.ErrorIs(t, err, ErrBatchWrite)
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			got, err := os.ReadFile(destPath)
+			require.NoError(t, err)
+			require.Equal(t, content, got, "Expected a retried write to continue where it stopped")
+			require.Len(t, pauses, tc.failures)
+			require.Equal(t, ioRetryDelay, pauses[0])
+		})
+	}
+
+	t.Run("Read", func(t *testing.T) {
+		var dst bytes.Buffer
+		src := &flakyReader{r: bytes.NewReader(content), err: syscall.EINTR, failures: 2}
+		written, err := copyStream(&dst, src, chunkSize, 0, 2, nil)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(content)), written)
+		require.Equal(t, content, dst.Bytes())
+
+		src = &flakyReader{r: bytes.NewReader(content), err: syscall.EINTR, failures: 3}
+		_, err = copyStream(&bytes.Buffer{}, src, chunkSize, 0, 2, nil)
+		require.ErrorIs(t, err, ErrBatchRead)
+		require.ErrorIs(t, err, syscall.EINTR)
+	})
+}
+
+func TestUseBatching(t *testing.T) {
+	const chunkSize = 1 << 20
+	testCases := []struct {
+		name string
+		size int64
+		opts CopyOptions
+		want bool
+	}{
+		{"DefaultBelowChunkSize", chunkSize - 1, CopyOptions{}, false},
+		{"DefaultAtChunkSize", chunkSize, CopyOptions{}, true},
+		{"LowThresholdStreamsSmallFile", 4 << 10, CopyOptions{BatchThreshold: 1 << 10}, true},
+		{"HighThresholdReadsLargeFileWhole", 8 * chunkSize, CopyOptions{BatchThreshold: 64 * chunkSize}, false},
+		{"SparseAlwaysStreams", 10, CopyOptions{Sparse: true, BatchThreshold: 64 * chunkSize}, true},
+		{"ResumeAlwaysStreams", 10, CopyOptions{Resume: true}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, useBatching(tc.size, chunkSize, tc.opts))
+		})
+	}
+}
+
+func TestCopyFileBatchThreshold(t *testing.T) {
+	tempDir := t.TempDir()
+	sourcePath := filepath.Join(tempDir, "source.bin")
+	chunkSize := int64(4 << 10)
+	content := make([]byte, 4*chunkSize)
+	require.NoError(t, os.WriteFile(sourcePath, content, 0644))
+
+	var pauses int
+	original := sleep
+	sleep = func(time.Duration) { pauses++ }
+	t.Cleanup(func() { sleep = original })
+
+	// Chunk pauses only happen on the batched path, so they reveal which path ran
+	opts := CopyOptions{ChunkPause: time.Millisecond, BatchThreshold: 1 << 20}
+	_, err := CopyFileWith(sourcePath, filepath.Join(tempDir, "whole.bin"), chunkSize, opts)
+	require.NoError(t, err)
+	require.Zero(t, pauses, "Expected a file below the threshold to be read whole despite exceeding the chunk size")
+
+	opts.BatchThreshold = 1
+	_, err = CopyFileWith(sourcePath, filepath.Join(tempDir, "batched.bin"), chunkSize, opts)
+	require.NoError(t, err)
+	require.Equal(t, 3, pauses, "Expected a file above the threshold to be streamed in chunks")
+}
+
+func TestCleanupTempFiles(t *testing.T) {
+	root := t.TempDir()
+	stale := time.Now().Add(-2 * time.Hour)
+	write := func(rel string, mtime time.Time) string {
+		path := filepath.Join(root, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0644))
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+		return path
+	}
+
+	staleState := write(TempPath(".sync_state"), stale)
+	staleCopy := write(TempPath(filepath.Join("nested", "deep", "video.mp4")), stale)
+	freshCopy := write(TempPath("in-progress.bin"), time.Now())
+	partial := write(PartialPath("big.iso"), stale)
+	userTmp := write("notes.tmp", stale)
+	// Renames interrupted halfway: one whose new path is free, one whose new path was taken
+	halfRenamed := write(RenamePath(filepath.Join("docs", "readme.md")), stale)
+	takenDir := filepath.Join(root, RenamePath("photos"))
+	write(filepath.Join(RenamePath("photos"), "a.jpg"), stale)
+	require.NoError(t, os.Chtimes(takenDir, stale, stale))
+	write(filepath.Join("photos", "a.jpg"), stale)
+
+	removed, err := CleanupTempFiles(root, time.Hour, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{staleState, staleCopy, halfRenamed, takenDir}, removed)
+	require.FileExists(t, filepath.Join(root, "docs", "readme.md"), "Expected an interrupted rename to be finished")
+	require.NoDirExists(t, takenDir)
+	require.FileExists(t, filepath.Join(root, "photos", "a.jpg"))
+	require.NoFileExists(t, staleState)
+	require.NoFileExists(t, staleCopy)
+	require.FileExists(t, freshCopy, "Expected a temp file younger than the threshold to be kept")
+	require.FileExists(t, partial, "Expected partial copies to be kept for resuming")
+	require.FileExists(t, userTmp, "Expected files mimic did not write to be kept")
+
+	removed, err = CleanupTempFiles(root, 0, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{freshCopy}, removed)
+
+	removed, err = CleanupTempFiles(filepath.Join(root, "missing"), 0, nil)
+	require.NoError(t, err)
+	require.Empty(t, removed)
+}
@@ -0,0 +1,54 @@
+//go:build linux
+
+package fileops
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+)
+
+// ioprio_set(2) constants from linux/ioprio.h
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+	ioprioLowestBE   = 7
+)
+
+// SetIOPriority lowers the I/O scheduling priority of the running process: low is the
+// lowest best-effort level, idle only gets disk time nobody else wants. I/O priority is
+// per thread on Linux, so it is applied to every existing thread; threads started later
+// inherit it from their creator.
+func SetIOPriority(level string) error {
+	var prio int
+	switch level {
+	case config.IOPriorityNormal:
+		return nil
+	case config.IOPriorityLow:
+		prio = ioprioClassBE<<ioprioClassShift | ioprioLowestBE
+	case config.IOPriorityIdle:
+		prio = ioprioClassIdle << ioprioClassShift
+	default:
+		return fmt.Errorf("%w: unknown level %q", ErrIOPriority, level)
+	}
+
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIOPriority, err)
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio)); errno != 0 {
+			return fmt.Errorf("%w: %v", ErrIOPriority, errno)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package fileops
+
+import "github.com/ogzhanolguncu/mimic/internal/config"
+
+// SetIOPriority is only supported on Linux; elsewhere only the normal level is accepted.
+func SetIOPriority(level string) error {
+	if level == config.IOPriorityNormal {
+		return nil
+	}
+	return ErrIOPriorityUnsupported
+}
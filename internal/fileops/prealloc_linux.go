@@ -0,0 +1,30 @@
+//go:build linux
+
+package fileops
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Preallocate reserves size bytes for f with fallocate(2), extending it to that size.
+// File systems without fallocate support are left alone; running out of space is
+// reported as ErrNoSpace so the copy can stop before writing anything.
+func Preallocate(f *os.File, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	err := syscall.Fallocate(int(f.Fd()), 0, 0, size)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, syscall.ENOSPC):
+		return fmt.Errorf("%w: reserving %d bytes for %s: %w", ErrNoSpace, size, f.Name(), err)
+	case errors.Is(err, syscall.EOPNOTSUPP), errors.Is(err, syscall.ENOSYS):
+		return nil
+	default:
+		return fmt.Errorf("%w: %w", ErrPreallocate, err)
+	}
+}
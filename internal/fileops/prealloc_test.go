@@ -0,0 +1,44 @@
+//go:build linux || darwin
+
+package fileops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreallocate(t *testing.T) {
+	tempDir := t.TempDir()
+
+	t.Run("SizesFile", func(t *testing.T) {
+		file, err := os.Create(filepath.Join(tempDir, "reserved.bin"))
+		require.NoError(t, err)
+		defer file.Close()
+
+		require.NoError(t, Preallocate(file, 3<<20))
+		info, err := file.Stat()
+		require.NoError(t, err)
+		require.Equal(t, int64(3<<20), info.Size(), "Expected the file to be extended to the reserved size")
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		sourcePath := filepath.Join(tempDir, "source.bin")
+		destPath := filepath.Join(tempDir, "dest.bin")
+		content := make([]byte, 5<<20+7)
+		for i := range content {
+			content[i] = byte(i % 251)
+		}
+		require.NoError(t, os.WriteFile(sourcePath, content, 0644))
+
+		written, err := CopyFileWith(sourcePath, destPath, 1<<20, CopyOptions{Preallocate: true})
+		require.NoError(t, err)
+		require.Equal(t, int64(len(content)), written)
+
+		got, err := os.ReadFile(destPath)
+		require.NoError(t, err)
+		require.Equal(t, content, got, "Expected a preallocated copy to match the source exactly")
+	})
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package fileops
+
+// FreeSpace is not supported on this platform.
+func FreeSpace(_ string) (uint64, error) {
+	return 0, ErrFreeSpaceUnsupported
+}
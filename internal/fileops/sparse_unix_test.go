@@ -0,0 +1,71 @@
+//go:build unix
+
+package fileops
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyFileSparse(t *testing.T) {
+	tempDir := t.TempDir()
+	sourcePath := filepath.Join(tempDir, "disk.img")
+	destPath := filepath.Join(tempDir, "copy", "disk.img")
+
+	const holeSize = 8 << 20
+	head := bytes.Repeat([]byte("head"), 1024)
+	tail := bytes.Repeat([]byte("tail"), 1024)
+
+	src, err := os.Create(sourcePath)
+	require.NoError(t, err)
+	_, err = src.Write(head)
+	require.NoError(t, err)
+	_, err = src.Write(make([]byte, holeSize)) // Explicit zeros, fully allocated
+	require.NoError(t, err)
+	_, err = src.Write(tail)
+	require.NoError(t, err)
+	require.NoError(t, src.Close())
+
+	// A stale, larger destination must not leak into the copy
+	require.NoError(t, os.MkdirAll(filepath.Dir(destPath), 0755))
+	require.NoError(t, os.WriteFile(destPath, bytes.Repeat([]byte{0xff}, holeSize*2), 0644))
+
+	written, err := CopyFileSparse(sourcePath, destPath, 1<<20)
+	require.NoError(t, err)
+
+	expectedSize := int64(len(head) + holeSize + len(tail))
+	require.Equal(t, expectedSize, written, "Expected the logical size to be reported")
+
+	srcContent, err := os.ReadFile(sourcePath)
+	require.NoError(t, err)
+	dstContent, err := os.ReadFile(destPath)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(srcContent, dstContent), "Expected identical content")
+
+	info, err := os.Stat(destPath)
+	require.NoError(t, err)
+	require.Equal(t, expectedSize, info.Size(), "Expected logical size to be preserved")
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	require.True(t, ok)
+	allocated := int64(stat.Blocks) * 512
+	require.Less(t, allocated, expectedSize/2, "Expected the zero region to stay a hole (allocated %d bytes)", allocated)
+
+	t.Run("TrailingHole", func(t *testing.T) {
+		trailing := filepath.Join(tempDir, "trailing.img")
+		require.NoError(t, os.WriteFile(trailing, append(bytes.Clone(head), make([]byte, holeSize)...), 0644))
+
+		out := filepath.Join(tempDir, "trailing.copy")
+		_, err := CopyFileSparse(trailing, out, 1<<20)
+		require.NoError(t, err)
+
+		info, err := os.Stat(out)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(head)+holeSize), info.Size(), "Expected a trailing hole to keep the logical size")
+	})
+}
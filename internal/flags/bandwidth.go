@@ -0,0 +1,35 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+)
+
+var ErrInvalidBandwidthRule = errors.New("flags: invalid bandwidth rule")
+
+// ParseBandwidthRule parses a rule of the form "GLOB=RATE", like "*.mp4=5000KB/s" or
+// "*.conf => unlimited". RATE is a size per second as accepted by ParseSize, with an
+// optional "/s"; 0 or "unlimited" lifts the limit for matching files.
+func ParseBandwidthRule(s string) (config.BandwidthRule, error) {
+	pattern, rate, ok := strings.Cut(s, "=")
+	pattern = strings.TrimSpace(pattern)
+	rate = strings.TrimSpace(strings.TrimPrefix(rate, ">"))
+	if !ok || pattern == "" || rate == "" {
+		return config.BandwidthRule{}, fmt.Errorf("%w: %q, expected GLOB=RATE", ErrInvalidBandwidthRule, s)
+	}
+
+	if strings.EqualFold(rate, "unlimited") {
+		return config.BandwidthRule{Pattern: pattern}, nil
+	}
+	bytesPerSecond, err := ParseSize(strings.TrimSuffix(strings.ToLower(rate), "/s"))
+	if err != nil {
+		return config.BandwidthRule{}, fmt.Errorf("%w: %q: %w", ErrInvalidBandwidthRule, s, err)
+	}
+	if bytesPerSecond > 0 && bytesPerSecond < 1024 {
+		return config.BandwidthRule{}, fmt.Errorf("%w: %q, limits start at 1KB/s", ErrInvalidBandwidthRule, s)
+	}
+	return config.BandwidthRule{Pattern: pattern, LimitKBps: int(bytesPerSecond / 1024)}, nil
+}
@@ -0,0 +1,45 @@
+package flags
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+)
+
+var ErrInvalidCompareMode = errors.New("flags: invalid compare mode")
+
+// compareModes maps each -compare-mode preset to the flags it expands into.
+var compareModes = map[string]map[string]string{
+	config.CompareModeQuick: {
+		"no-times": "true", "checksum": "false", "checksum-verify-on-equal-mtime": "false",
+	},
+	config.CompareModeStandard: {
+		"no-times": "false", "checksum": "false", "checksum-verify-on-equal-mtime": "false",
+	},
+	config.CompareModeThorough: {
+		"no-times": "false", "checksum": "true", "checksum-verify-on-equal-mtime": "true", "quick-hash": "false",
+	},
+}
+
+// ApplyCompareMode sets the flags the preset mode expands into on fs, except for those
+// given on the command line, so individual flags override the preset wherever they
+// appear. Call it once fs is parsed.
+func ApplyCompareMode(fs *flag.FlagSet, mode string) error {
+	preset, ok := compareModes[mode]
+	if !ok {
+		return fmt.Errorf("%w: %q (want quick, standard or thorough)", ErrInvalidCompareMode, mode)
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for name, value := range preset {
+		if explicit[name] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%w: -%s: %v", ErrInvalidCompareMode, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+)
+
+var ErrInvalidSizeRule = errors.New("flags: invalid size exclude rule")
+
+// ParseSizeExcludeRule parses a rule of the form "GLOB:>SIZE" or "GLOB:<SIZE", like
+// "*.iso:>1G" or "*.log:<1K". SIZE is anything ParseSize accepts. The condition is taken
+// from after the last colon, so the glob itself may contain colons.
+func ParseSizeExcludeRule(s string) (config.SizeExcludeRule, error) {
+	sep := strings.LastIndex(s, ":")
+	if sep < 0 {
+		return config.SizeExcludeRule{}, fmt.Errorf("%w: %q, expected GLOB:>SIZE or GLOB:<SIZE", ErrInvalidSizeRule, s)
+	}
+	pattern, condition := strings.TrimSpace(s[:sep]), strings.TrimSpace(s[sep+1:])
+
+	rule := config.SizeExcludeRule{Pattern: pattern}
+	switch {
+	case strings.HasPrefix(condition, ">"):
+	case strings.HasPrefix(condition, "<"):
+		rule.Smaller = true
+	default:
+		condition = ""
+	}
+	if pattern == "" || condition == "" {
+		return config.SizeExcludeRule{}, fmt.Errorf("%w: %q, expected GLOB:>SIZE or GLOB:<SIZE", ErrInvalidSizeRule, s)
+	}
+
+	size, err := ParseSize(condition[1:])
+	if err != nil {
+		return config.SizeExcludeRule{}, fmt.Errorf("%w: %q: %w", ErrInvalidSizeRule, s, err)
+	}
+	rule.Size = size
+	return rule, nil
+}
@@ -2,7 +2,11 @@ package flags
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ogzhanolguncu/mimic/internal/config"
 	"github.com/ogzhanolguncu/mimic/internal/logger"
@@ -12,18 +16,398 @@ func Parse() *config.Config {
 	cfg := config.NewDefaultConfig()
 
 	flag.BoolVar(&cfg.Verbose, "verbose", config.DefaultVerbose, "Enable detailed debug logging")
+	flag.BoolVar(&cfg.Quiet, "quiet", config.DefaultQuiet, "Only log warnings and errors (overrides -verbose)")
+	flag.BoolVar(&cfg.SummaryOnly, "summary-only", config.DefaultSummaryOnly, "Print only the final summary and errors, with no per-file output (overrides -verbose)")
+	flag.StringVar(&cfg.LogFile, "log-file", config.DefaultLogFile, "Write logs to this file instead of stderr")
 	flag.BoolVar(&cfg.DryRun, "dry-run", config.DefaultDryRun, "Simulate operations without making changes")
+	flag.Func("format", "Dry-run report format: tree or diff (one +, -, ~ line per path)", func(s string) error {
+		switch s {
+		case config.DryRunFormatTree, config.DryRunFormatDiff:
+			cfg.DryRunFormat = s
+			return nil
+		default:
+			return fmt.Errorf("unknown dry-run format %q", s)
+		}
+	})
+	flag.BoolVar(&cfg.ShowUnchanged, "show-unchanged", config.DefaultShowUnchanged, "List unchanged entries (=) in the diff dry-run format")
 	flag.BoolVar(&cfg.Checksum, "checksum", config.DefaultChecksum, "Use checksum comparison instead of mtime/size")
+	flag.BoolVar(&cfg.QuickHash, "quick-hash", config.DefaultQuickHash, "Fingerprint files by size, head and tail instead of hashing all of their content (faster, misses same-size edits in the middle)")
+	flag.BoolVar(&cfg.ChecksumOnCopy, "checksum-on-copy", config.DefaultChecksumOnCopy, "Hash copied files while copying them instead of during the scan (ignored with -checksum)")
+	flag.BoolVar(&cfg.Streaming, "streaming", config.DefaultStreaming, "Compare and copy entries as the source walk finds them instead of scanning the whole source into memory first")
+	flag.BoolVar(&cfg.NoTimes, "no-times", config.DefaultNoTimes, "Ignore mtimes when comparing files and rely on size, plus checksums with -checksum")
+	flag.Func("compare-mode", "Comparison preset: quick (size only), standard (size and mtime) or thorough (size, mtime and full checksum); individual flags override it", func(s string) error {
+		if _, ok := compareModes[s]; !ok {
+			return fmt.Errorf("%w: %q", ErrInvalidCompareMode, s)
+		}
+		cfg.CompareMode = s
+		return nil
+	})
+	flag.BoolVar(&cfg.PreserveSpecialBits, "preserve-special-bits", config.DefaultPreserveSpecialBits, "Keep setuid, setgid and sticky bits on copied files and created directories (local destinations only)")
+	flag.BoolVar(&cfg.SyncPermsAlways, "sync-perms-always", config.DefaultSyncPermsAlways, "Apply changed source permissions to otherwise unchanged entries without copying them")
+	flag.BoolVar(&cfg.LongPaths, "long-paths", config.DefaultLongPaths, `On Windows, use \\?\ extended-length destination paths to get past the 260-character limit`)
 	flag.Int64Var(&cfg.ChunkSize, "chunk-size", config.DefaultChunkSize, "Buffer size in bytes for file copying")
+	flag.IntVar(&cfg.CopyQueueDepth, "copy-queue-depth", config.DefaultCopyQueueDepth, "Chunks a streamed copy reads ahead of the writer, trading memory for throughput")
+	flag.IntVar(&cfg.Retries, "retries", config.DefaultRetries, "Attempts per copy, mkdir or delete before giving up on transient errors")
+	flag.IntVar(&cfg.IORetries, "io-retries", config.DefaultIORetries, "Retries of a chunk read or write failing with EINTR, EAGAIN or ETIMEDOUT within a copy, e.g. more for network or FUSE mounts")
+	flag.BoolVar(&cfg.ContinueOnError, "continue-on-error", config.DefaultContinueOnError, "Keep syncing after a failed action and report all failures at the end")
+	flag.Func("batch-threshold", "Stream files of at least this size in chunks instead of reading them whole, e.g. 4M (default: chunk size)", func(s string) error {
+		size, err := ParseSize(s)
+		if err != nil {
+			return err
+		}
+		cfg.BatchThreshold = size
+		return nil
+	})
+	flag.Func("exclude-size", "Exclude files matching a glob by size, e.g. '*.iso:>1G' or '*.log:<1K'; may be repeated", func(s string) error {
+		rule, err := ParseSizeExcludeRule(s)
+		if err != nil {
+			return err
+		}
+		cfg.SizeExcludeRules = append(cfg.SizeExcludeRules, rule)
+		return nil
+	})
 	flag.IntVar(&cfg.BandwidthLimit, "bandwidth-limit", config.DefaultBandwidthLimit, "Bandwidth limit in KB/s (0 for unlimited)")
+	flag.Func("bandwidth-rule", "Bandwidth limit for files matching a glob, e.g. '*.mp4=5000KB/s' or '*.conf=unlimited'; may be repeated, the first match wins", func(s string) error {
+		rule, err := ParseBandwidthRule(s)
+		if err != nil {
+			return err
+		}
+		cfg.BandwidthRules = append(cfg.BandwidthRules, rule)
+		return nil
+	})
+	flag.BoolVar(&cfg.StreamStateLoad, "stream-state-load", config.DefaultStreamState, "Decode the state file incrementally to reduce memory for huge states")
+	flag.BoolVar(&cfg.StateNDJSON, "state-ndjson", config.DefaultStateNDJSON, "Save the state as newline-delimited JSON, one entry per line, so -stream-state-load reads it entry by entry")
+	flag.BoolVar(&cfg.VerifyStateWrite, "verify-state-write", config.DefaultVerifyState, "Reload and verify the state file after writing it")
+	flag.BoolVar(&cfg.VerifyStateChecksum, "checksum-verify-state", config.DefaultVerifyStateChecksum, "Checksum the state file when saving it and fall back to the backup or a fresh state if it was altered")
+	flag.BoolVar(&cfg.PersistProgress, "persist-progress", config.DefaultPersistProgress, "Persist transfer totals so a resumed run reports the whole effort")
+	flag.IntVar(&cfg.HashWorkers, "hash-workers", config.DefaultHashWorkers, "Maximum number of files checksummed concurrently during scan")
+	flag.IntVar(&cfg.HashWorkers, "scan-workers", config.DefaultHashWorkers, "Deprecated: use -hash-workers")
+	flag.IntVar(&cfg.CopyWorkers, "copy-workers", config.DefaultCopyWorkers, "Maximum number of files copied concurrently")
+	flag.IntVar(&cfg.HashParallelThreshold, "hash-parallel-threshold", config.DefaultHashParallelThreshold, "Only checksum files concurrently when the scan has at least this many to hash")
+	flag.BoolVar(&cfg.AutoTuneScan, "auto-tune-scan", config.DefaultAutoTuneScan, "Adjust hashing concurrency (up to -hash-workers) by measuring throughput")
+	flag.BoolVar(&cfg.VerifyOnEqualMtime, "checksum-verify-on-equal-mtime", config.DefaultVerifyEqualMtime, "Compare checksums of files whose size and mtime are unchanged, cheaper than -checksum")
+	flag.BoolVar(&cfg.Stateless, "stateless", config.DefaultStateless, "Compare the source against a scan of the destination instead of a state file, and keep no state")
+	flag.BoolVar(&cfg.Adopt, "adopt", config.DefaultAdopt, "On the first run, treat identical files already in the destination as synced instead of overwriting them")
+	flag.BoolVar(&cfg.VerifyExecution, "verify-execution", config.DefaultVerifyExecution, "After the run, check that deleted paths are gone and copied ones exist (local destinations only)")
+	flag.BoolVar(&cfg.PruneEmptyDirs, "dedupe-empty-dirs", config.DefaultPruneEmptyDirs, "Remove destination directories left empty after the sync unless they exist in the source")
+	flag.IntVar(&cfg.SSHPort, "ssh-port", config.DefaultSSHPort, "SSH port for a remote [user@]host:path destination")
+	flag.StringVar(&cfg.SSHKey, "ssh-key", config.DefaultSSHKey, "Private key for a remote destination (default: ssh-agent, ~/.ssh/id_ed25519, ~/.ssh/id_rsa)")
+	flag.StringVar(&cfg.SSHKnownHosts, "ssh-known-hosts", config.DefaultSSHKnownHosts, "known_hosts file used to verify a remote destination (default: ~/.ssh/known_hosts)")
+	flag.BoolVar(&cfg.ReportDupes, "report-dupes", config.DefaultReportDupes, "Log groups of source files with identical content and the space they waste")
+	flag.StringVar(&cfg.ManifestOut, "manifest", config.DefaultManifestOut, "Write the source scan as a text manifest (path size mode checksum) to this file")
+	flag.BoolVar(&cfg.IntegrityScan, "integrity-scan", config.DefaultIntegrityScan, "Verify a synced <directory> against its recorded state instead of syncing")
+	flag.BoolVar(&cfg.PruneState, "prune-state", config.DefaultPruneState, "Drop state entries of <directory> that the current filters no longer track, without touching its files")
+	flag.StringVar(&cfg.VerifyManifest, "verify-manifest", config.DefaultVerifyManifest, "Verify <directory> against this manifest instead of syncing")
+	flag.StringVar(&cfg.SourceChecksums, "source-checksums", config.DefaultSourceChecksums, "JSON manifest of precomputed source checksums keyed by relative path; unlisted files are hashed")
+	flag.StringVar(&cfg.ChecksumCache, "checksum-cache", config.DefaultChecksumCache, "File caching source checksums by path, size and mtime, shared by syncs of the same source to any destination")
+	flag.BoolVar(&cfg.StrictScan, "strict-scan", config.DefaultStrictScan, "Fail the sync on the first source entry that cannot be read instead of skipping it")
+	flag.DurationVar(&cfg.DeleteDelay, "delete-delay", config.DefaultDeleteDelay, "Only delete destination paths once they have been missing from the source for this long, e.g. 24h; earlier runs mark them as pending")
+	flag.Func("max-delete", "Refuse to run more than N deletes (e.g. 500) or deletes of more than a percentage of the recorded entries (e.g. 20%); may be given twice", func(s string) error {
+		if raw, ok := strings.CutSuffix(s, "%"); ok {
+			percent, err := strconv.ParseFloat(raw, 64)
+			if err != nil || percent <= 0 || percent > 100 {
+				return fmt.Errorf("invalid delete percentage %q", s)
+			}
+			cfg.MaxDeletePercent = percent
+			return nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid delete count %q", s)
+		}
+		cfg.MaxDelete = n
+		return nil
+	})
+	flag.BoolVar(&cfg.ForceDelete, "force-delete", config.DefaultForceDelete, "Run deletes refused by -max-delete or because the source scanned empty")
+	flag.BoolVar(&cfg.AssumeStableSource, "assume-stable-source", config.DefaultAssumeStable, "Skip re-checking files for modification after hashing (e.g. read-only snapshots)")
+	flag.Func("max-file-size", "Skip files larger than this size, e.g. 500M or 2G (0 for unlimited)", func(s string) error {
+		size, err := ParseSize(s)
+		if err != nil {
+			return err
+		}
+		cfg.MaxFileSize = size
+		return nil
+	})
+	flag.Func("checksum-block", "Also record a checksum per block of this size for larger files to locate corruption, e.g. 4M", func(s string) error {
+		size, err := ParseSize(s)
+		if err != nil {
+			return err
+		}
+		cfg.ChecksumBlockSize = size
+		return nil
+	})
+	flag.Func("checksum-min", "Only hash and compare by content files of at least this size, e.g. 1M (see -checksum-max)", func(s string) error {
+		size, err := ParseSize(s)
+		if err != nil {
+			return err
+		}
+		cfg.ChecksumMin = size
+		return nil
+	})
+	flag.Func("checksum-max", "Only hash and compare by content files of at most this size, e.g. 1G; other files use size and mtime alone", func(s string) error {
+		size, err := ParseSize(s)
+		if err != nil {
+			return err
+		}
+		cfg.ChecksumMax = size
+		return nil
+	})
+	flag.Func("reserve-space", "Defer copies that would leave less than this much free space on the destination, e.g. 10G", func(s string) error {
+		size, err := ParseSize(s)
+		if err != nil {
+			return err
+		}
+		cfg.ReserveSpace = size
+		return nil
+	})
+	flag.Func("min-free-space", "Refuse to start a sync whose copies would leave less than this much free space on the destination, e.g. 1G", func(s string) error {
+		size, err := ParseSize(s)
+		if err != nil {
+			return err
+		}
+		cfg.MinFreeSpace = size
+		return nil
+	})
+	flag.Func("checkpoint", "Save state during the run every N completed actions (e.g. 500) or every interval (e.g. 30s, 5m); may be given twice", func(s string) error {
+		if n, err := strconv.Atoi(s); err == nil {
+			if n < 0 {
+				return fmt.Errorf("invalid checkpoint count %q", s)
+			}
+			cfg.CheckpointActions = n
+			return nil
+		}
+		d, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		cfg.CheckpointInterval = d
+		return nil
+	})
+	flag.Func("stale-temp-age", "Remove temp files left in the destination by crashed runs once they are this old (e.g. 30m, 0 for all); default 1h", func(s string) error {
+		d, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		cfg.StaleTempAge = d
+		return nil
+	})
+	flag.Func("scan-heartbeat", "Log scan progress this often while scanning (e.g. 10s, 0 to disable); default 5s", func(s string) error {
+		d, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		cfg.ScanHeartbeat = d
+		return nil
+	})
+	flag.Func("mtime-threshold", "Treat mtimes closer than this as unchanged (e.g. 2s for FAT, 0 for exact); default 1s", func(s string) error {
+		d, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		cfg.MtimeThreshold = d
+		return nil
+	})
+	flag.BoolVar(&cfg.StrictTypes, "strict-types", config.DefaultStrictTypes, "Fail instead of replacing destination files that are directories in the source, or vice versa")
+	flag.StringVar(&cfg.StatsFile, "stats-file", config.DefaultStatsFile, "Write run statistics as JSON to this file after each run")
+	flag.StringVar(&cfg.StateDir, "state-dir", config.DefaultStateDir, "Keep the state file in this directory instead of the destination (local destinations only)")
+	flag.BoolVar(&cfg.Lock, "lock", config.DefaultLock, "Refuse to run while another sync holds the destination's lock (local destinations only)")
+	flag.BoolVar(&cfg.ForceUnlock, "force-unlock", config.DefaultForceUnlock, "Remove a stale lock left by a crashed run before locking")
+	flag.StringVar(&cfg.PostHook, "post-hook", config.DefaultPostHook, "Run this command after a sync without fatal errors, with the summary in MIMIC_* environment variables")
+	flag.Func("events", "Stream action and progress events to stdout: jsonl (one JSON object per line)", func(s string) error {
+		if s != config.EventsJSONL {
+			return fmt.Errorf("unknown event format %q", s)
+		}
+		cfg.Events = s
+		return nil
+	})
+	flag.BoolVar(&cfg.Progress, "progress", config.DefaultProgress, "Show a live status line with file counts, transfer rate and ETA (terminals only)")
+	flag.Func("io-priority", "I/O scheduling priority: normal, low or idle (Linux only)", func(s string) error {
+		switch s {
+		case config.IOPriorityNormal, config.IOPriorityLow, config.IOPriorityIdle:
+			cfg.IOPriority = s
+			return nil
+		default:
+			return fmt.Errorf("unknown I/O priority %q", s)
+		}
+	})
+	flag.Func("io-pause", "Pause between chunks of large file copies to leave disk time to other programs, e.g. 5ms", func(s string) error {
+		d, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		cfg.ChunkPause = d
+		return nil
+	})
+	flag.BoolVar(&cfg.PreserveDirTimes, "preserve-dir-times", config.DefaultPreserveDirTimes, "Give destination directories the source directory modification times")
+	flag.BoolVar(&cfg.RootMetadata, "root-metadata", config.DefaultRootMetadata, "Give the destination root the source root's permissions and modification time")
+	flag.BoolVar(&cfg.Resume, "resume", config.DefaultResume, "Continue interrupted copies from their partial file when its content matches the source prefix (local destinations only)")
+	flag.BoolVar(&cfg.Update, "update", config.DefaultUpdate, "Skip files whose destination copy is newer than the source")
+	flag.BoolVar(&cfg.AppendGrowth, "append", config.DefaultAppendGrowth, "Append only the new tail of files that grew when the destination still holds their previous contents")
+	flag.BoolVar(&cfg.Preallocate, "preallocate", config.DefaultPreallocate, "Reserve the full size of large destination files before copying them (ignored with -sparse)")
+	flag.BoolVar(&cfg.Sparse, "sparse", config.DefaultSparse, "Keep zero-filled regions as holes in destination files (VM images, databases)")
+	flag.BoolVar(&cfg.OneFileSystem, "one-file-system", config.DefaultOneFileSystem, "Do not cross file system boundaries during scan (like rsync -x)")
+	flag.Func("exclude-fstype", "Comma separated filesystem types to skip during scan, e.g. nfs,fuse (Linux only)", func(s string) error {
+		cfg.ExcludeFSTypes = append(cfg.ExcludeFSTypes, splitList(s)...)
+		return nil
+	})
+	flag.Func("exclude-mount", "Comma separated mount point paths to skip during scan", func(s string) error {
+		cfg.ExcludeMounts = append(cfg.ExcludeMounts, splitList(s)...)
+		return nil
+	})
+
+	flag.BoolVar(&cfg.Phased, "phased", config.DefaultPhased, "Run all directory creations, then all copies, then all deletes (deepest first) instead of in path order")
+	flag.Func("copy-order", "Order of file copies: none or locality (group by directory and inode)", func(s string) error {
+		switch s {
+		case config.CopyOrderNone, config.CopyOrderLocality:
+			cfg.CopyOrder = s
+			return nil
+		default:
+			return fmt.Errorf("unknown copy order %q", s)
+		}
+	})
+
+	flag.BoolVar(&cfg.CaseInsensitive, "case-insensitive", config.DefaultCaseInsensitive, "Match paths regardless of case and apply case-only renames in place (for case-folding destinations)")
+	flag.BoolVar(&cfg.DereferenceRoot, "dereference-root", config.DefaultDereferenceRoot, "Follow the source directory if it is a symlink")
+	flag.Func("windows-names", "On Windows, what to do with source names Windows cannot store: error, skip or replace", func(s string) error {
+		switch s {
+		case config.WindowsNamesError, config.WindowsNamesSkip, config.WindowsNamesReplace:
+			cfg.WindowsNames = s
+			return nil
+		default:
+			return fmt.Errorf("unknown windows names strategy %q", s)
+		}
+	})
+	flag.BoolVar(&cfg.Flatten, "flatten", config.DefaultFlatten, "Copy every file straight into the destination root under its base name, without creating directories")
+	flag.StringVar(&cfg.RelativeTo, "relative-to", config.DefaultRelativeTo, "Place files on the destination by their path relative to this ancestor of the source directory, like rsync -R")
+	flag.BoolVar(&cfg.FlattenRename, "flatten-rename", config.DefaultFlattenRename, "With -flatten, append ~2, ~3, ... to files whose base name is already taken instead of failing")
+
+	flag.Func("symlinks", "How source symlinks are synced: preserve (recreate the link), dereference (copy the target file) or skip", func(s string) error {
+		switch s {
+		case config.SymlinkPreserve, config.SymlinkDereference, config.SymlinkSkip:
+			cfg.SymlinkPolicy = s
+			return nil
+		default:
+			return fmt.Errorf("unknown symlink policy %q", s)
+		}
+	})
+
+	flag.Func("future-mtimes", "What to do with source files modified in the future: warn, clamp (use the scan time, compare by size) or skip", func(s string) error {
+		switch s {
+		case config.FutureMtimesWarn, config.FutureMtimesClamp, config.FutureMtimesSkip:
+			cfg.FutureMtimes = s
+			return nil
+		default:
+			return fmt.Errorf("unknown future mtime handling %q", s)
+		}
+	})
+	flag.Func("future-threshold", "How far past the scan time an mtime must be to count as in the future (e.g. 10m); default 1h", func(s string) error {
+		d, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		cfg.FutureThreshold = d
+		return nil
+	})
+
+	flag.Func("only", "Comma separated action types to execute: create, update, delete", func(s string) error {
+		kinds, err := parseActionKinds(s)
+		cfg.OnlyActions = append(cfg.OnlyActions, kinds...)
+		return err
+	})
+	flag.Func("skip", "Comma separated action types to leave for a later run, e.g. delete", func(s string) error {
+		kinds, err := parseActionKinds(s)
+		cfg.SkipActions = append(cfg.SkipActions, kinds...)
+		return err
+	})
+
+	flag.Func("newer-than", "Only sync files modified at or after this time (RFC3339, YYYY-MM-DD or relative like 7d)", func(s string) error {
+		t, err := ParseTimeBound(s, time.Now())
+		if err != nil {
+			return err
+		}
+		cfg.NewerThan = t
+		return nil
+	})
+	flag.Func("older-than", "Only sync files modified before this time (RFC3339, YYYY-MM-DD or relative like 7d)", func(s string) error {
+		t, err := ParseTimeBound(s, time.Now())
+		if err != nil {
+			return err
+		}
+		cfg.OlderThan = t
+		return nil
+	})
 
 	flag.Parse()
 
+	if err := ApplyCompareMode(flag.CommandLine, cfg.CompareMode); err != nil {
+		logger.Error("Invalid -compare-mode", "error", err)
+		os.Exit(1)
+	}
+	if err := CheckLimits(cfg); err != nil {
+		logger.Error("Invalid limit", "error", err)
+		os.Exit(1)
+	}
+	if cfg.ChecksumMax > 0 && cfg.ChecksumMin > cfg.ChecksumMax {
+		logger.Error("-checksum-min must not be larger than -checksum-max")
+		os.Exit(1)
+	}
+	if cfg.VerifyManifest != "" {
+		if flag.NArg() != 1 {
+			logger.Error("Usage: mimic -verify-manifest <manifest> [options] <directory>")
+			flag.PrintDefaults()
+			os.Exit(1)
+		}
+		return cfg
+	}
+	if cfg.IntegrityScan {
+		if flag.NArg() != 1 {
+			logger.Error("Usage: mimic -integrity-scan [options] <directory>")
+			flag.PrintDefaults()
+			os.Exit(1)
+		}
+		return cfg
+	}
+	if cfg.PruneState {
+		if flag.NArg() != 1 {
+			logger.Error("Usage: mimic -prune-state [options] <directory>")
+			flag.PrintDefaults()
+			os.Exit(1)
+		}
+		return cfg
+	}
+
 	if flag.NArg() != 2 {
-		logger.Error("Usage: mimic [options] <source_directory> <destination_directory>")
+		logger.Error("Usage: mimic [options] <source_directory | archive> <destination_directory | [user@]host:path>")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
+	if target, ok := ParseRemoteTarget(flag.Arg(1)); ok {
+		cfg.Remote = &target
+	}
 
 	return cfg
 }
+
+// splitList splits a comma separated flag value, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseActionKinds splits a comma separated list of action types and rejects unknown ones
+func parseActionKinds(s string) ([]string, error) {
+	kinds := splitList(s)
+	for _, kind := range kinds {
+		switch kind {
+		case config.ActionKindCreate, config.ActionKindUpdate, config.ActionKindDelete:
+		default:
+			return nil, fmt.Errorf("unknown action type %q", kind)
+		}
+	}
+	return kinds, nil
+}
@@ -0,0 +1,270 @@
+package flags
+
+import (
+	"flag"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/syncer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected int64
+	}{
+		{name: "Plain bytes", input: "1024", expected: 1024},
+		{name: "Bytes suffix", input: "512B", expected: 512},
+		{name: "Kilobytes", input: "4K", expected: 4 << 10},
+		{name: "Kilobytes long suffix", input: "4KB", expected: 4 << 10},
+		{name: "Megabytes", input: "500M", expected: 500 << 20},
+		{name: "Gigabytes", input: "2G", expected: 2 << 30},
+		{name: "Gigabytes lowercase", input: "2g", expected: 2 << 30},
+		{name: "Gibibytes suffix", input: "2GiB", expected: 2 << 30},
+		{name: "Kibibytes suffix", input: "3kib", expected: 3 << 10},
+		{name: "Largest unit value", input: "8388607T", expected: 8388607 << 40},
+		{name: "Terabytes", input: "1T", expected: 1 << 40},
+		{name: "Fractional", input: "1.5G", expected: 3 << 29},
+		{name: "Zero", input: "0", expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			size, err := ParseSize(tc.input)
+			require.NoError(t, err, "Expected no error parsing %q", tc.input)
+			require.Equal(t, tc.expected, size, "Unexpected size for %q", tc.input)
+		})
+	}
+
+	invalidInputs := []string{"", "abc", "10X", "-5M", "M", "5IB", "iB", "NaN", "Inf", "+InfG", "10000000000G", "9223372036854775808"}
+	for _, input := range invalidInputs {
+		t.Run("Invalid "+input, func(t *testing.T) {
+			_, err := ParseSize(input)
+			require.ErrorIs(t, err, ErrInvalidSize, "Expected ErrInvalidSize for %q", input)
+		})
+	}
+}
+
+func TestParseBandwidthRule(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected config.BandwidthRule
+	}{
+		{input: "*.mp4=5000KB/s", expected: config.BandwidthRule{Pattern: "*.mp4", LimitKBps: 5000}},
+		{input: "*.mp4 => 5000KB/s", expected: config.BandwidthRule{Pattern: "*.mp4", LimitKBps: 5000}},
+		{input: "media/**=2M", expected: config.BandwidthRule{Pattern: "media/**", LimitKBps: 2048}},
+		{input: "*.{iso,img}=1.5MB/s", expected: config.BandwidthRule{Pattern: "*.{iso,img}", LimitKBps: 1536}},
+		{input: "*.conf=unlimited", expected: config.BandwidthRule{Pattern: "*.conf"}},
+		{input: "*.conf=0", expected: config.BandwidthRule{Pattern: "*.conf"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			rule, err := ParseBandwidthRule(tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, rule)
+		})
+	}
+
+	for _, input := range []string{"", "*.mp4", "=5M", "*.mp4=", "*.mp4=fast", "*.mp4=100B/s"} {
+		t.Run("Invalid "+input, func(t *testing.T) {
+			_, err := ParseBandwidthRule(input)
+			require.ErrorIs(t, err, ErrInvalidBandwidthRule)
+		})
+	}
+}
+
+func TestParseSizeExcludeRule(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected config.SizeExcludeRule
+	}{
+		{input: "*.iso:>1G", expected: config.SizeExcludeRule{Pattern: "*.iso", Size: 1 << 30}},
+		{input: "*.log:<1K", expected: config.SizeExcludeRule{Pattern: "*.log", Smaller: true, Size: 1 << 10}},
+		{input: "media/** : > 500MB", expected: config.SizeExcludeRule{Pattern: "media/**", Size: 500 << 20}},
+		{input: "c:/data/*.bin:>10", expected: config.SizeExcludeRule{Pattern: "c:/data/*.bin", Size: 10}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			rule, err := ParseSizeExcludeRule(tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, rule)
+		})
+	}
+
+	for _, input := range []string{"", "*.iso", ":>1G", "*.iso:", "*.iso:1G", "*.iso:>", "*.iso:>big", "*.iso:=1G"} {
+		t.Run("Invalid "+input, func(t *testing.T) {
+			_, err := ParseSizeExcludeRule(input)
+			require.ErrorIs(t, err, ErrInvalidSizeRule)
+		})
+	}
+}
+
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected time.Time
+	}{
+		{name: "RFC3339", input: "2024-01-02T03:04:05Z", expected: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{name: "Date only", input: "2024-01-02", expected: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "Relative days", input: "7d", expected: now.Add(-7 * 24 * time.Hour)},
+		{name: "Relative weeks", input: "2w", expected: now.Add(-14 * 24 * time.Hour)},
+		{name: "Relative hours", input: "12h", expected: now.Add(-12 * time.Hour)},
+		{name: "Relative compound", input: "1h30m", expected: now.Add(-90 * time.Minute)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bound, err := ParseTimeBound(tc.input, now)
+			require.NoError(t, err, "Expected no error parsing %q", tc.input)
+			require.True(t, tc.expected.Equal(bound), "Expected %v, got %v", tc.expected, bound)
+		})
+	}
+
+	for _, input := range []string{"", "yesterday", "7x", "-3d", "2024-13-01"} {
+		t.Run("Invalid "+input, func(t *testing.T) {
+			_, err := ParseTimeBound(input, now)
+			require.ErrorIs(t, err, ErrInvalidTime, "Expected ErrInvalidTime for %q", input)
+		})
+	}
+}
+
+func TestParseRemoteTarget(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected config.RemoteTarget
+		remote   bool
+	}{
+		{name: "User host path", input: "alice@backup.example.com:/srv/mirror", expected: config.RemoteTarget{User: "alice", Host: "backup.example.com", Path: "/srv/mirror"}, remote: true},
+		{name: "Host only", input: "nas:backups", expected: config.RemoteTarget{Host: "nas", Path: "backups"}, remote: true},
+		{name: "Empty path is home", input: "nas:", expected: config.RemoteTarget{Host: "nas", Path: "."}, remote: true},
+		{name: "Local path", input: "/mnt/backup", remote: false},
+		{name: "Relative path with colon", input: "./a:b", remote: false},
+		{name: "Windows drive", input: `C:\backup`, remote: false},
+		{name: "Missing host", input: "alice@:/srv", remote: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			target, ok := ParseRemoteTarget(tc.input)
+			require.Equal(t, tc.remote, ok, "Unexpected remote detection for %q", tc.input)
+			if tc.remote {
+				require.Equal(t, tc.expected, target)
+			}
+		})
+	}
+}
+
+// parseArgs runs Parse on a fresh command line of args followed by a source and a
+// destination.
+func parseArgs(t *testing.T, args ...string) *config.Config {
+	t.Helper()
+	origArgs, origFlags := os.Args, flag.CommandLine
+	t.Cleanup(func() { os.Args, flag.CommandLine = origArgs, origFlags })
+	flag.CommandLine = flag.NewFlagSet("mimic", flag.ContinueOnError)
+	os.Args = append(append([]string{"mimic"}, args...), "src", "dst")
+	return Parse()
+}
+
+func TestCompareMode(t *testing.T) {
+	mtime := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	entry := func(path string, size int64, mtime time.Time, checksum string) syncer.EntryInfo {
+		return syncer.EntryInfo{RelativePath: path, Size: size, Mtime: mtime, Checksum: checksum, Permissions: 0644}
+	}
+	recorded := map[string]syncer.EntryInfo{
+		"touched.txt": entry("touched.txt", 5, mtime, "aaaa"),
+		"content.txt": entry("content.txt", 5, mtime, "aaaa"),
+		"resized.txt": entry("resized.txt", 5, mtime, "aaaa"),
+		"same.txt":    entry("same.txt", 5, mtime, "aaaa"),
+	}
+	source := map[string]syncer.EntryInfo{
+		"touched.txt": entry("touched.txt", 5, mtime.Add(time.Hour), "aaaa"),
+		"content.txt": entry("content.txt", 5, mtime, "bbbb"),
+		"resized.txt": entry("resized.txt", 6, mtime, "cccc"),
+		"same.txt":    entry("same.txt", 5, mtime, "aaaa"),
+	}
+
+	testCases := []struct {
+		name     string
+		args     []string
+		noTimes  bool
+		checksum bool
+		verify   bool
+		updated  []string
+	}{
+		{name: "Default", updated: []string{"resized.txt", "touched.txt"}},
+		{name: "Quick", args: []string{"-compare-mode", "quick"}, noTimes: true, updated: []string{"resized.txt"}},
+		{name: "Standard", args: []string{"-compare-mode", "standard"}, updated: []string{"resized.txt", "touched.txt"}},
+		{
+			name: "Thorough", args: []string{"-compare-mode", "thorough"}, checksum: true, verify: true,
+			updated: []string{"content.txt", "resized.txt", "touched.txt"},
+		},
+		{
+			name: "Flag after preset overrides", args: []string{"-compare-mode", "thorough", "-checksum-verify-on-equal-mtime=false"},
+			checksum: true, updated: []string{"resized.txt", "touched.txt"},
+		},
+		{
+			name: "Flag before preset overrides", args: []string{"-no-times", "-compare-mode", "standard"},
+			noTimes: true, updated: []string{"resized.txt"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := parseArgs(t, tc.args...)
+			require.Equal(t, tc.noTimes, cfg.NoTimes, "Unexpected NoTimes")
+			require.Equal(t, tc.checksum, cfg.Checksum, "Unexpected Checksum")
+			require.Equal(t, tc.verify, cfg.VerifyOnEqualMtime, "Unexpected VerifyOnEqualMtime")
+
+			var updated []string
+			for _, action := range syncer.CompareStates(source, recorded, cfg) {
+				if action.Type == syncer.ActionUpdate {
+					updated = append(updated, action.RelativePath)
+				}
+			}
+			slices.Sort(updated)
+			require.Equal(t, tc.updated, updated)
+		})
+	}
+}
+
+func TestApplyCompareModeInvalid(t *testing.T) {
+	err := ApplyCompareMode(flag.NewFlagSet("mimic", flag.ContinueOnError), "paranoid")
+	require.ErrorIs(t, err, ErrInvalidCompareMode)
+}
+
+func TestCheckLimits(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(cfg *config.Config)
+		flag   string
+	}{
+		{name: "Defaults", modify: func(cfg *config.Config) {}},
+		{name: "ZeroChunkSize", modify: func(cfg *config.Config) { cfg.ChunkSize = 0 }, flag: "-chunk-size"},
+		{name: "ZeroQueueDepth", modify: func(cfg *config.Config) { cfg.CopyQueueDepth = 0 }, flag: "-copy-queue-depth"},
+		{name: "NegativeHashWorkers", modify: func(cfg *config.Config) { cfg.HashWorkers = -2 }, flag: "-hash-workers"},
+		{name: "ZeroCopyWorkers", modify: func(cfg *config.Config) { cfg.CopyWorkers = 0 }, flag: "-copy-workers"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewDefaultConfig()
+			tc.modify(cfg)
+			err := CheckLimits(cfg)
+			if tc.flag == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidLimit)
+			require.ErrorContains(t, err, tc.flag)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+)
+
+var ErrInvalidLimit = errors.New("flags: invalid limit")
+
+// CheckLimits rejects worker counts, queue depths and chunk sizes below one, which would
+// stall or break the copy and hash pools instead of limiting them.
+func CheckLimits(cfg *config.Config) error {
+	limits := []struct {
+		flag  string
+		value int64
+	}{
+		{"-chunk-size", cfg.ChunkSize},
+		{"-copy-queue-depth", int64(cfg.CopyQueueDepth)},
+		{"-hash-workers", int64(cfg.HashWorkers)},
+		{"-copy-workers", int64(cfg.CopyWorkers)},
+	}
+	for _, limit := range limits {
+		if limit.value < 1 {
+			return fmt.Errorf("%w: %s must be positive, got %d", ErrInvalidLimit, limit.flag, limit.value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package flags
+
+import (
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+)
+
+// ParseRemoteTarget recognises a [user@]host:path destination. Like scp, a colon only
+// marks a remote target when it appears before the first slash, so local paths such as
+// ./a:b and Windows drive letters (C:\dir) are left alone.
+func ParseRemoteTarget(s string) (config.RemoteTarget, bool) {
+	colon := strings.Index(s, ":")
+	if colon <= 0 {
+		return config.RemoteTarget{}, false
+	}
+	if slash := strings.IndexAny(s, `/\`); slash >= 0 && slash < colon {
+		return config.RemoteTarget{}, false
+	}
+
+	hostPart, path := s[:colon], s[colon+1:]
+	if len(hostPart) == 1 {
+		return config.RemoteTarget{}, false // Drive letter
+	}
+
+	var target config.RemoteTarget
+	if at := strings.LastIndex(hostPart, "@"); at >= 0 {
+		target.User, hostPart = hostPart[:at], hostPart[at+1:]
+	}
+	if hostPart == "" {
+		return config.RemoteTarget{}, false
+	}
+	target.Host = hostPart
+
+	if path == "" {
+		path = "."
+	}
+	target.Path = path
+	return target, true
+}
@@ -0,0 +1,58 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidSize = errors.New("flags: invalid size")
+
+// sizeUnits maps unit suffixes to their multiplier in bytes (binary units)
+var sizeUnits = map[string]int64{
+	"":  1,
+	"B": 1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+// ParseSize converts a human readable size like "500M", "2G" or "1.5GB" into bytes.
+// Plain numbers are treated as bytes.
+func ParseSize(s string) (int64, error) {
+	raw := strings.ToUpper(strings.TrimSpace(s))
+	if raw == "" {
+		return 0, fmt.Errorf("%w: empty value", ErrInvalidSize)
+	}
+
+	// Accept "KB"/"KiB" style suffixes as aliases for "K"
+	if n := len(raw); n > 2 && strings.HasSuffix(raw, "IB") && strings.ContainsAny(raw[n-3:n-2], "KMGT") {
+		raw = raw[:n-2]
+	}
+	if len(raw) > 1 && strings.HasSuffix(raw, "B") {
+		if _, ok := sizeUnits[raw[len(raw)-2:len(raw)-1]]; ok {
+			raw = strings.TrimSuffix(raw, "B")
+		}
+	}
+
+	numPart := strings.TrimRight(raw, "BKMGT")
+	unit := raw[len(numPart):]
+	multiplier, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("%w: unknown unit %q", ErrInvalidSize, unit)
+	}
+
+	value, err := strconv.ParseFloat(numPart, 64)
+	if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSize, s)
+	}
+
+	bytes := value * float64(multiplier)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("%w: %q exceeds %d bytes", ErrInvalidSize, s, int64(math.MaxInt64))
+	}
+	return int64(bytes), nil
+}
@@ -0,0 +1,59 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidTime = errors.New("flags: invalid time")
+
+// durationUnits extends time.ParseDuration with day and week suffixes
+var durationUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseTimeBound parses an absolute time (RFC3339 or YYYY-MM-DD) or a relative
+// duration like "7d", "12h" or "2w" which is resolved as that long before now.
+func ParseTimeBound(s string, now time.Time) (time.Time, error) {
+	raw := strings.TrimSpace(s)
+	if raw == "" {
+		return time.Time{}, fmt.Errorf("%w: empty value", ErrInvalidTime)
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, raw, now.Location()); err == nil {
+		return t, nil
+	}
+
+	d, err := ParseDuration(raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidTime, s)
+	}
+	return now.Add(-d), nil
+}
+
+// ParseDuration behaves like time.ParseDuration but also accepts "d" and "w" units.
+func ParseDuration(s string) (time.Duration, error) {
+	raw := strings.TrimSpace(s)
+	if len(raw) > 1 {
+		if unit, ok := durationUnits[raw[len(raw)-1:]]; ok {
+			value, err := strconv.ParseFloat(raw[:len(raw)-1], 64)
+			if err != nil || value < 0 {
+				return 0, fmt.Errorf("%w: %q", ErrInvalidTime, s)
+			}
+			return time.Duration(value * float64(unit)), nil
+		}
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTime, s)
+	}
+	return d, nil
+}
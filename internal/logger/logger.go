@@ -35,11 +35,72 @@ func (h NoOpHandler) WithGroup(_ string) slog.Handler {
 	return h
 }
 
+// PerFileKey is the attribute key that log lines about a single file or directory carry.
+const PerFileKey = "path"
+
+// SummaryOnlyHandler drops the per-file records (those with a PerFileKey attribute) of
+// the handler it wraps unless they are errors, so a huge run logs only what concerns it
+// as a whole.
+type SummaryOnlyHandler struct {
+	next    slog.Handler
+	perFile bool // PerFileKey was added with WithAttrs
+}
+
+// NewSummaryOnlyHandler wraps next in a SummaryOnlyHandler.
+func NewSummaryOnlyHandler(next slog.Handler) *SummaryOnlyHandler {
+	return &SummaryOnlyHandler{next: next}
+}
+
+func (h *SummaryOnlyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle passes errors and records without a PerFileKey attribute on to the wrapped handler.
+func (h *SummaryOnlyHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelError {
+		perFile := h.perFile
+		r.Attrs(func(attr slog.Attr) bool {
+			perFile = perFile || attr.Key == PerFileKey
+			return !perFile
+		})
+		if perFile {
+			return nil
+		}
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *SummaryOnlyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	perFile := h.perFile
+	for _, attr := range attrs {
+		perFile = perFile || attr.Key == PerFileKey
+	}
+	return &SummaryOnlyHandler{next: h.next.WithAttrs(attrs), perFile: perFile}
+}
+
+func (h *SummaryOnlyHandler) WithGroup(name string) slog.Handler {
+	return &SummaryOnlyHandler{next: h.next.WithGroup(name), perFile: h.perFile}
+}
+
 // Config holds logger configuration
 type Config struct {
 	Level   slog.Level
 	Output  io.Writer
 	Handler slog.Handler
+	// SummaryOnly drops per-file log lines below error level (see SummaryOnlyHandler)
+	SummaryOnly bool
+}
+
+// LevelFor maps the verbosity flags to a log level. Quiet takes precedence over verbose.
+func LevelFor(verbose, quiet bool) slog.Level {
+	switch {
+	case quiet:
+		return slog.LevelWarn
+	case verbose:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
 }
 
 // Initialize sets up the global logger with the specified configuration
@@ -56,6 +117,9 @@ func Initialize(cfg Config) {
 		})
 	}
 
+	if cfg.SummaryOnly {
+		handler = NewSummaryOnlyHandler(handler)
+	}
 	Logger = slog.New(handler)
 
 	log.SetOutput(output)
@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevelFor(t *testing.T) {
+	require.Equal(t, slog.LevelInfo, LevelFor(false, false))
+	require.Equal(t, slog.LevelDebug, LevelFor(true, false))
+	require.Equal(t, slog.LevelWarn, LevelFor(false, true))
+	require.Equal(t, slog.LevelWarn, LevelFor(true, true), "Expected quiet to override verbose")
+}
+
+func TestLevelFiltering(t *testing.T) {
+	testCases := []struct {
+		name     string
+		verbose  bool
+		quiet    bool
+		expected []string
+		dropped  []string
+	}{
+		{name: "Default", expected: []string{"info-msg", "warn-msg", "error-msg"}, dropped: []string{"debug-msg"}},
+		{name: "Verbose", verbose: true, expected: []string{"debug-msg", "info-msg", "warn-msg", "error-msg"}},
+		{name: "Quiet", quiet: true, expected: []string{"warn-msg", "error-msg"}, dropped: []string{"debug-msg", "info-msg"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			Initialize(Config{Level: LevelFor(tc.verbose, tc.quiet), Output: &buf})
+			t.Cleanup(InitNoOp)
+
+			Debug("debug-msg")
+			Info("info-msg")
+			Warn("warn-msg")
+			Error("error-msg")
+
+			out := buf.String()
+			for _, msg := range tc.expected {
+				require.Contains(t, out, msg)
+			}
+			for _, msg := range tc.dropped {
+				require.NotContains(t, out, msg)
+			}
+		})
+	}
+}
+
+func TestSummaryOnly(t *testing.T) {
+	var buf bytes.Buffer
+	Initialize(Config{Level: LevelFor(true, false), Output: &buf, SummaryOnly: true})
+	t.Cleanup(InitNoOp)
+
+	Info("copying file", PerFileKey, "a.txt")
+	Warn("file disappeared", PerFileKey, "b.txt")
+	Logger.With(PerFileKey, "c.txt").Warn("retrying")
+	Warn("run-level-warning")
+	Error("copy failed", PerFileKey, "d.txt")
+	report.Print(report.Summary{FilesCreated: 3})
+
+	out := buf.String()
+	for _, msg := range []string{"copying file", "file disappeared", "retrying"} {
+		require.NotContains(t, out, msg, "Expected per-file lines to be dropped")
+	}
+	require.Contains(t, out, "run-level-warning")
+	require.Contains(t, out, "copy failed", "Expected per-file errors to surface")
+	require.Contains(t, out, "d.txt")
+	require.Contains(t, out, "Files created: 3", "Expected the summary to be printed")
+}
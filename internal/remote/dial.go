@@ -0,0 +1,169 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const dialTimeout = 15 * time.Second
+
+var ErrRemoteAuth = errors.New("remote: no usable ssh authentication method")
+
+// Dial connects to the remote target described by cfg.Remote and returns a destination
+// rooted at its path. The connection is re-established transparently if it drops.
+func Dial(cfg *config.Config) (*SFTPDestination, error) {
+	target := cfg.Remote
+	// Every reconnect authenticates through the same agent connection, closed with dest
+	agentConn := dialAgent()
+	clientConfig, err := sshClientConfig(target, cfg, agentConn)
+	if err != nil {
+		if agentConn != nil {
+			_ = agentConn.Close()
+		}
+		return nil, err
+	}
+	address := net.JoinHostPort(target.Host, strconv.Itoa(cfg.SSHPort))
+
+	dial := func() (remoteFS, error) {
+		conn, err := ssh.Dial("tcp", address, clientConfig)
+		if err != nil {
+			return nil, err
+		}
+		client, err := sftp.NewClient(conn)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return &sftpSession{client: client, conn: conn}, nil
+	}
+
+	dest := newSFTPDestination(target.Path, cfg, dial)
+	if agentConn != nil {
+		dest.agent = agentConn
+	}
+	// Connect eagerly so configuration problems surface before the scan
+	if err := dest.do("connect", address, func(remoteFS) error { return nil }); err != nil {
+		_ = dest.Close()
+		return nil, err
+	}
+	return dest, nil
+}
+
+// dialAgent connects to the ssh-agent at SSH_AUTH_SOCK, returning nil when there is none.
+func dialAgent() net.Conn {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil
+	}
+	return conn
+}
+
+// sshClientConfig authenticates with the keys of agentConn, if not nil, and the key files.
+func sshClientConfig(target *config.RemoteTarget, cfg *config.Config, agentConn net.Conn) (*ssh.ClientConfig, error) {
+	home, _ := os.UserHomeDir()
+
+	username := target.User
+	if username == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRemoteConnect, err)
+		}
+		username = current.Username
+	}
+
+	knownHostsFile := cfg.SSHKnownHosts
+	if knownHostsFile == "" {
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading known hosts: %v", ErrRemoteConnect, err)
+	}
+
+	var auth []ssh.AuthMethod
+	if agentConn != nil {
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+	}
+
+	keyFiles := []string{cfg.SSHKey}
+	if cfg.SSHKey == "" {
+		keyFiles = []string{filepath.Join(home, ".ssh", "id_ed25519"), filepath.Join(home, ".ssh", "id_rsa")}
+	}
+	for _, keyFile := range keyFiles {
+		signer, err := loadSigner(keyFile)
+		if err != nil {
+			if cfg.SSHKey != "" || !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("%w: %v", ErrRemoteAuth, err)
+			}
+			continue
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if len(auth) == 0 {
+		return nil, ErrRemoteAuth
+	}
+
+	return &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         dialTimeout,
+	}, nil
+}
+
+func loadSigner(keyFile string) (ssh.Signer, error) {
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(key)
+}
+
+// sftpSession adapts an SFTP client and its SSH connection to remoteFS.
+type sftpSession struct {
+	client *sftp.Client
+	conn   *ssh.Client
+}
+
+func (s *sftpSession) Create(name string) (io.WriteCloser, error) { return s.client.Create(name) }
+func (s *sftpSession) Open(name string) (io.ReadCloser, error)    { return s.client.Open(name) }
+func (s *sftpSession) Stat(name string) (fs.FileInfo, error)      { return s.client.Stat(name) }
+func (s *sftpSession) MkdirAll(name string) error                 { return s.client.MkdirAll(name) }
+func (s *sftpSession) RemoveAll(name string) error                { return s.client.RemoveAll(name) }
+func (s *sftpSession) Chmod(name string, mode fs.FileMode) error  { return s.client.Chmod(name, mode) }
+
+// Rename replaces newname atomically where the server supports it.
+func (s *sftpSession) Rename(oldname, newname string) error {
+	if err := s.client.PosixRename(oldname, newname); err == nil {
+		return nil
+	}
+	_ = s.client.Remove(newname)
+	return s.client.Rename(oldname, newname)
+}
+
+func (s *sftpSession) Close() error {
+	clientErr := s.client.Close()
+	if err := s.conn.Close(); err != nil {
+		return err
+	}
+	return clientErr
+}
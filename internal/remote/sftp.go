@@ -0,0 +1,243 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+	"github.com/ogzhanolguncu/mimic/internal/syncer"
+	"github.com/pkg/sftp"
+)
+
+var (
+	ErrRemoteConnect = errors.New("remote: failed to connect")
+	ErrRemoteWrite   = errors.New("remote: failed to write a file")
+	ErrRemoteRead    = errors.New("remote: failed to read a local file")
+)
+
+// remoteFS is the subset of an SFTP session the destination needs; tests provide fakes.
+type remoteFS interface {
+	Create(name string) (io.WriteCloser, error)
+	Open(name string) (io.ReadCloser, error)
+	Stat(name string) (fs.FileInfo, error)
+	MkdirAll(name string) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+	Chmod(name string, mode fs.FileMode) error
+	Close() error
+}
+
+// dialFunc opens a new session; it is called again after a connection is lost.
+type dialFunc func() (remoteFS, error)
+
+// SFTPDestination is a syncer.Destination on a remote host reached over SFTP.
+// Operations that fail because the connection dropped are retried on a new session.
+type SFTPDestination struct {
+	root           string
+	bandwidthLimit int // KB/s, 0 for unlimited
+	dial           dialFunc
+
+	mu      sync.Mutex
+	session remoteFS
+	agent   io.Closer // ssh-agent connection the sessions authenticate with, if any
+}
+
+func newSFTPDestination(root string, cfg *config.Config, dial dialFunc) *SFTPDestination {
+	return &SFTPDestination{root: root, bandwidthLimit: cfg.BandwidthLimit, dial: dial}
+}
+
+// Root returns the remote destination directory.
+func (d *SFTPDestination) Root() string { return d.root }
+
+// Close ends the current session, if any, and the ssh-agent connection.
+func (d *SFTPDestination) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var err error
+	if d.session != nil {
+		err = d.session.Close()
+		d.session = nil
+	}
+	if d.agent != nil {
+		err = errors.Join(err, d.agent.Close())
+		d.agent = nil
+	}
+	return err
+}
+
+func (d *SFTPDestination) path(relPath string) string {
+	return path.Join(d.root, filepath.ToSlash(relPath))
+}
+
+// do runs op against a live session, reconnecting and retrying when the connection is lost.
+func (d *SFTPDestination) do(operation, name string, op func(remoteFS) error) error {
+	_, err := syncer.Retry(operation, name, func() (struct{}, error) {
+		d.mu.Lock()
+		if d.session == nil {
+			session, err := d.dial()
+			if err != nil {
+				d.mu.Unlock()
+				return struct{}{}, fmt.Errorf("%w: %v", ErrRemoteConnect, err)
+			}
+			d.session = session
+		}
+		session := d.session
+		d.mu.Unlock()
+
+		err := op(session)
+		if isConnectionLost(err) {
+			logger.Warn("remote connection lost, reconnecting", "operation", operation, "path", name)
+			d.mu.Lock()
+			if d.session == session {
+				_ = session.Close()
+				d.session = nil
+			}
+			d.mu.Unlock()
+		}
+		return struct{}{}, err
+	})
+	return err
+}
+
+func isConnectionLost(err error) bool {
+	return errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Copy uploads srcPath in chunkSize writes, throttled to the configured bandwidth limit.
+func (d *SFTPDestination) Copy(srcPath, relPath string, chunkSize int64) (int64, error) {
+	return d.CopyLimited(srcPath, relPath, chunkSize, d.bandwidthLimit)
+}
+
+// CopyLimited uploads like Copy, throttled to limitKBps instead (0 for unlimited).
+// The upload goes to a temporary file renamed into place once complete, so an
+// interrupted one never leaves a truncated file behind at relPath.
+func (d *SFTPDestination) CopyLimited(srcPath, relPath string, chunkSize int64, limitKBps int) (int64, error) {
+	var written int64
+	target := d.path(relPath)
+	temp := fileops.TempPath(target)
+	err := d.do("copy", target, func(session remoteFS) error {
+		written = 0
+		src, err := os.Open(srcPath)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrRemoteRead, err)
+		}
+		defer src.Close()
+
+		if err := session.MkdirAll(path.Dir(target)); err != nil {
+			return err
+		}
+		dst, err := session.Create(temp)
+		if err != nil {
+			return err
+		}
+
+		written, err = copyThrottled(dst, src, chunkSize, limitKBps)
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = session.Rename(temp, target)
+		}
+		if err != nil && !isConnectionLost(err) {
+			_ = session.RemoveAll(temp)
+			return fmt.Errorf("%w: %v", ErrRemoteWrite, err)
+		}
+		return err
+	})
+	return written, err
+}
+
+func (d *SFTPDestination) Mkdir(relPath string) error {
+	return d.do("mkdir", d.path(relPath), func(session remoteFS) error {
+		return session.MkdirAll(d.path(relPath))
+	})
+}
+
+func (d *SFTPDestination) Delete(relPath string) error {
+	err := d.do("delete", d.path(relPath), func(session remoteFS) error {
+		return session.RemoveAll(d.path(relPath))
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Rename moves oldRelPath to newRelPath.
+func (d *SFTPDestination) Rename(oldRelPath, newRelPath string) error {
+	return d.do("rename", d.path(oldRelPath), func(session remoteFS) error {
+		return session.Rename(d.path(oldRelPath), d.path(newRelPath))
+	})
+}
+
+// Chmod sets the permission bits of relPath to those of mode.
+func (d *SFTPDestination) Chmod(relPath string, mode fs.FileMode) error {
+	return d.do("chmod", d.path(relPath), func(session remoteFS) error {
+		return session.Chmod(d.path(relPath), mode.Perm())
+	})
+}
+
+func (d *SFTPDestination) Stat(relPath string) (fs.FileInfo, error) {
+	var info fs.FileInfo
+	err := d.do("stat", d.path(relPath), func(session remoteFS) error {
+		var err error
+		info, err = session.Stat(d.path(relPath))
+		return err
+	})
+	return info, err
+}
+
+func (d *SFTPDestination) Exists(relPath string) (bool, error) {
+	_, err := d.Stat(relPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// copyThrottled copies src to dst in chunkSize pieces, sleeping as needed to stay
+// under limitKBps (0 for unlimited).
+func copyThrottled(dst io.Writer, src io.Reader, chunkSize int64, limitKBps int) (int64, error) {
+	if chunkSize <= 0 {
+		chunkSize = config.DefaultChunkSize
+	}
+	if limitKBps > 0 {
+		// Keep chunks small enough that throttling stays smooth
+		chunkSize = min(chunkSize, int64(limitKBps)*1024)
+	}
+
+	buf := make([]byte, chunkSize)
+	start := time.Now()
+	var total int64
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			w, err := dst.Write(buf[:n])
+			total += int64(w)
+			if err != nil {
+				return total, err
+			}
+			if limitKBps > 0 {
+				expected := time.Duration(float64(total) / float64(limitKBps*1024) * float64(time.Second))
+				if ahead := expected - time.Since(start); ahead > 0 {
+					time.Sleep(ahead)
+				}
+			}
+		}
+		if readErr == io.EOF {
+			return total, nil
+		}
+		if readErr != nil {
+			return total, fmt.Errorf("%w: %v", ErrRemoteRead, readErr)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/syncer"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+)
+
+// dirFS is a remoteFS that serves a local directory, standing in for an SFTP session.
+// Remote paths are absolute paths inside that directory.
+type dirFS struct {
+	dropCreates *int  // Number of Create calls that fail as if the connection dropped
+	writeErr    error // Fails writes to created files partway through, if set
+	closed      bool
+}
+
+func (f *dirFS) Create(name string) (io.WriteCloser, error) {
+	if f.dropCreates != nil && *f.dropCreates > 0 {
+		*f.dropCreates--
+		return nil, sftp.ErrSSHFxConnectionLost
+	}
+	file, err := os.Create(name)
+	if err != nil || f.writeErr == nil {
+		return file, err
+	}
+	return failingFile{File: file, err: f.writeErr}, nil
+}
+
+// failingFile writes half of every write through to the file, then fails with err.
+type failingFile struct {
+	*os.File
+	err error
+}
+
+func (f failingFile) Write(p []byte) (int, error) {
+	n, _ := f.File.Write(p[:len(p)/2])
+	return n, f.err
+}
+func (f *dirFS) Open(name string) (io.ReadCloser, error)   { return os.Open(name) }
+func (f *dirFS) Stat(name string) (fs.FileInfo, error)     { return os.Stat(name) }
+func (f *dirFS) MkdirAll(name string) error                { return os.MkdirAll(name, 0755) }
+func (f *dirFS) RemoveAll(name string) error               { return os.RemoveAll(name) }
+func (f *dirFS) Rename(oldname, newname string) error      { return os.Rename(oldname, newname) }
+func (f *dirFS) Chmod(name string, mode fs.FileMode) error { return os.Chmod(name, mode) }
+func (f *dirFS) Close() error {
+	f.closed = true
+	return nil
+}
+
+func newTestDestination(t *testing.T, cfg *config.Config, dropCreates int) (*SFTPDestination, string, *int) {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "remote")
+	dials := 0
+	dest := newSFTPDestination(root, cfg, func() (remoteFS, error) {
+		dials++
+		return &dirFS{dropCreates: &dropCreates}, nil
+	})
+	t.Cleanup(func() { _ = dest.Close() })
+	return dest, root, &dials
+}
+
+func TestSFTPDestinationSync(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "docs"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "docs", "readme.md"), []byte("remote content"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "app.bin"), []byte("binary"), 0644))
+
+	cfg := config.NewDefaultConfig()
+	dest, root, _ := newTestDestination(t, cfg, 0)
+
+	state, err := syncer.LoadStateFS(dest.StateFS(), dest.Root(), cfg)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(root, ".sync_state"), "Expected state to live under the remote root")
+
+	entries, err := syncer.ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+	summary, err := syncer.ExecuteActionsTo(context.Background(), srcDir, dest, syncer.CompareStates(entries, state.Entries, cfg), cfg, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, summary.FilesCreated)
+
+	content, err := os.ReadFile(filepath.Join(root, "docs", "readme.md"))
+	require.NoError(t, err)
+	require.Equal(t, "remote content", string(content))
+
+	state.Entries = entries
+	require.NoError(t, syncer.SaveStateFS(dest.StateFS(), dest.Root(), state, cfg))
+	reloaded, err := syncer.LoadStateFS(dest.StateFS(), dest.Root(), cfg)
+	require.NoError(t, err)
+	require.Len(t, reloaded.Entries, len(entries))
+
+	t.Run("DeleteAndExists", func(t *testing.T) {
+		exists, err := dest.Exists("app.bin")
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		require.NoError(t, dest.Delete("app.bin"))
+		require.NoError(t, dest.Delete("app.bin"), "Expected deleting a missing path to succeed")
+
+		exists, err = dest.Exists("app.bin")
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+}
+
+func TestSFTPDestinationReconnects(t *testing.T) {
+	srcDir := t.TempDir()
+	srcPath := filepath.Join(srcDir, "file.txt")
+	require.NoError(t, os.WriteFile(srcPath, []byte("survives a dropped connection"), 0644))
+
+	dest, root, dials := newTestDestination(t, config.NewDefaultConfig(), 2)
+
+	written, err := dest.Copy(srcPath, "file.txt", 8)
+	require.NoError(t, err, "Expected the copy to succeed after reconnecting")
+	require.Equal(t, int64(len("survives a dropped connection")), written)
+	require.Equal(t, 3, *dials, "Expected a new session after each dropped connection")
+
+	content, err := os.ReadFile(filepath.Join(root, "file.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "survives a dropped connection", string(content))
+}
+
+func TestSFTPDestinationInterruptedCopy(t *testing.T) {
+	srcDir := t.TempDir()
+	srcPath := filepath.Join(srcDir, "file.txt")
+	require.NoError(t, os.WriteFile(srcPath, []byte("new content"), 0644))
+
+	root := filepath.Join(t.TempDir(), "remote")
+	require.NoError(t, os.MkdirAll(root, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "file.txt"), []byte("old content"), 0644))
+	dest := newSFTPDestination(root, config.NewDefaultConfig(), func() (remoteFS, error) {
+		return &dirFS{writeErr: errors.New("no space left on device")}, nil
+	})
+	t.Cleanup(func() { _ = dest.Close() })
+
+	_, err := dest.Copy(srcPath, "file.txt", 4)
+	require.ErrorIs(t, err, ErrRemoteWrite)
+
+	content, err := os.ReadFile(filepath.Join(root, "file.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "old content", string(content), "Expected a failed upload to leave the previous file untouched")
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "Expected the partial upload to be removed")
+}
+
+func TestCopyThrottled(t *testing.T) {
+	data := make([]byte, 20*1024)
+	src, err := os.CreateTemp(t.TempDir(), "src")
+	require.NoError(t, err)
+	_, err = src.Write(data)
+	require.NoError(t, err)
+	_, err = src.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	defer src.Close()
+
+	start := time.Now()
+	written, err := copyThrottled(io.Discard, src, 4096, 100) // 100 KB/s
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), written)
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond, "Expected the bandwidth limit to slow the copy")
+}
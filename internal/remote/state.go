@@ -0,0 +1,86 @@
+package remote
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/ogzhanolguncu/mimic/internal/syncer"
+)
+
+// StateFS returns a syncer.StateFS that keeps the state file on the remote host. Names
+// passed to it are absolute remote paths, as built by syncer from Root.
+func (d *SFTPDestination) StateFS() syncer.StateFS {
+	return sftpStateFS{d: d}
+}
+
+type sftpStateFS struct {
+	d *SFTPDestination
+}
+
+func (s sftpStateFS) WriteFile(name string, data []byte, _ os.FileMode) error {
+	return s.d.do("write_state", name, func(session remoteFS) error {
+		file, err := session.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(file, bytes.NewReader(data))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+}
+
+func (s sftpStateFS) ReadFile(name string) ([]byte, error) {
+	var data []byte
+	err := s.d.do("read_state", name, func(session remoteFS) error {
+		file, err := session.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+		return err
+	})
+	return data, err
+}
+
+// Open reads the whole file up front so a dropped connection is retried here rather
+// than surfacing halfway through a streaming decode.
+func (s sftpStateFS) Open(name string) (io.ReadCloser, error) {
+	data, err := s.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s sftpStateFS) Stat(name string) (fs.FileInfo, error) {
+	var info fs.FileInfo
+	err := s.d.do("stat_state", name, func(session remoteFS) error {
+		var err error
+		info, err = session.Stat(name)
+		return err
+	})
+	return info, err
+}
+
+func (s sftpStateFS) MkdirAll(name string, _ os.FileMode) error {
+	return s.d.do("mkdir_state", name, func(session remoteFS) error {
+		return session.MkdirAll(name)
+	})
+}
+
+func (s sftpStateFS) Rename(oldname, newname string) error {
+	return s.d.do("rename_state", newname, func(session remoteFS) error {
+		return session.Rename(oldname, newname)
+	})
+}
+
+func (s sftpStateFS) Remove(name string) error {
+	return s.d.do("remove_state", name, func(session remoteFS) error {
+		return session.RemoveAll(name)
+	})
+}
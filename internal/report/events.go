@@ -0,0 +1,91 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Event types written by an EventWriter.
+const (
+	EventStart    = "start"
+	EventComplete = "complete"
+	EventFail     = "fail"
+	EventProgress = "progress"
+	EventFinish   = "finish"
+)
+
+// Event is one line of an EventWriter stream. Progress and finish events carry the
+// totals instead of a path.
+type Event struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Path       string    `json:"path,omitempty"`
+	Bytes      int64     `json:"bytes"`
+	Files      int       `json:"files,omitempty"`
+	TotalFiles int       `json:"total_files,omitempty"`
+	TotalBytes int64     `json:"total_bytes,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// EventWriter is a Reporter that writes every action and, at most every
+// progressSampleInterval, the running totals to w as JSON lines for other programs
+// to follow. Write errors are dropped, so a closed reader cannot fail the sync.
+type EventWriter struct {
+	enc *json.Encoder
+	now func() time.Time
+
+	totalFiles   int
+	totalBytes   int64
+	files        int
+	bytes        int64
+	lastProgress time.Time
+}
+
+// NewEventWriter returns an EventWriter for a run of totalFiles actions copying totalBytes.
+func NewEventWriter(w io.Writer, totalFiles int, totalBytes int64) *EventWriter {
+	return newEventWriterClock(w, totalFiles, totalBytes, time.Now)
+}
+
+func newEventWriterClock(w io.Writer, totalFiles int, totalBytes int64, now func() time.Time) *EventWriter {
+	return &EventWriter{enc: json.NewEncoder(w), now: now, totalFiles: totalFiles, totalBytes: totalBytes, lastProgress: now()}
+}
+
+// Start writes a start event for the action on path.
+func (e *EventWriter) Start(path string, bytes int64) {
+	e.write(Event{Type: EventStart, Path: path, Bytes: bytes})
+}
+
+// Complete writes a complete event for the action on path, or a fail event with err.
+func (e *EventWriter) Complete(path string, bytes int64, err error) {
+	if err != nil {
+		e.write(Event{Type: EventFail, Path: path, Bytes: bytes, Error: err.Error()})
+		return
+	}
+	e.write(Event{Type: EventComplete, Path: path, Bytes: bytes})
+}
+
+// Update records the files and bytes completed so far and writes a progress event
+// at most every progressSampleInterval.
+func (e *EventWriter) Update(files int, bytes int64) {
+	e.files, e.bytes = files, bytes
+	if e.now().Sub(e.lastProgress) < progressSampleInterval {
+		return
+	}
+	e.lastProgress = e.now()
+	e.write(e.totals(EventProgress))
+}
+
+// Finish writes the final totals.
+func (e *EventWriter) Finish() {
+	e.write(e.totals(EventFinish))
+}
+
+func (e *EventWriter) totals(eventType string) Event {
+	return Event{Type: eventType, Bytes: e.bytes, Files: e.files, TotalFiles: e.totalFiles, TotalBytes: e.totalBytes}
+}
+
+func (e *EventWriter) write(event Event) {
+	event.Time = e.now().UTC()
+	_ = e.enc.Encode(event)
+}
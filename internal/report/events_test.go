@@ -0,0 +1,51 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decodeEvents(t *testing.T, data []byte) []Event {
+	t.Helper()
+	var events []Event
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), "Expected one JSON object per line")
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestEventWriter(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var buf bytes.Buffer
+	e := newEventWriterClock(&buf, 2, 300, clock.now)
+
+	e.Start("a.txt", 100)
+	e.Complete("a.txt", 100, nil)
+	e.Update(1, 100) // Inside the sample interval, no progress event
+	clock.advance(time.Second)
+	e.Start("b.txt", 200)
+	e.Complete("b.txt", 200, errors.New("disk full"))
+	e.Update(2, 300)
+	e.Finish()
+
+	events := decodeEvents(t, buf.Bytes())
+	var types []string
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	require.Equal(t, []string{EventStart, EventComplete, EventStart, EventFail, EventProgress, EventFinish}, types)
+
+	require.Equal(t, Event{Type: EventStart, Time: clock.t.Add(-time.Second), Path: "a.txt", Bytes: 100}, events[0])
+	require.Equal(t, "disk full", events[3].Error)
+	require.Equal(t, Event{Type: EventFinish, Time: clock.t, Bytes: 300, Files: 2, TotalFiles: 2, TotalBytes: 300}, events[5])
+}
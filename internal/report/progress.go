@@ -0,0 +1,159 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// progressSampleInterval is how often the rate is sampled and the line redrawn.
+	progressSampleInterval = 500 * time.Millisecond
+	// progressRateWeight is the weight of the newest sample in the moving average rate.
+	progressRateWeight = 0.3
+)
+
+// Reporter follows an executing sync: Start and Complete bracket each action, Update
+// carries the running totals and Finish ends the run.
+type Reporter interface {
+	Start(path string, bytes int64)
+	Complete(path string, bytes int64, err error)
+	Update(files int, bytes int64)
+	Finish()
+}
+
+// Progress draws a single, continually overwritten status line for an executing sync:
+//
+//	1,234/5,678 files  2.3 GB/10.1 GB  45.0 MB/s  ETA 00:02:31
+//
+// The rate is an exponentially weighted moving average of recent throughput and the
+// ETA is the remaining planned bytes at that rate. A nil Progress does nothing.
+type Progress struct {
+	w   io.Writer
+	now func() time.Time
+
+	totalFiles int
+	totalBytes int64
+	files      int
+	bytes      int64
+
+	rate        float64 // Bytes per second, valid once hasRate is set
+	hasRate     bool
+	sampleBytes int64
+	sampleTime  time.Time
+	lastWidth   int
+}
+
+// NewProgress returns a Progress for a run of totalFiles actions copying totalBytes.
+func NewProgress(w io.Writer, totalFiles int, totalBytes int64) *Progress {
+	return newProgressClock(w, totalFiles, totalBytes, time.Now)
+}
+
+func newProgressClock(w io.Writer, totalFiles int, totalBytes int64, now func() time.Time) *Progress {
+	return &Progress{w: w, now: now, totalFiles: totalFiles, totalBytes: totalBytes, sampleTime: now()}
+}
+
+// Start does nothing; the status line only shows totals.
+func (p *Progress) Start(path string, bytes int64) {}
+
+// Complete does nothing; the status line only shows totals.
+func (p *Progress) Complete(path string, bytes int64, err error) {}
+
+// Update records the files and bytes completed so far. The rate is resampled and the
+// line redrawn at most every progressSampleInterval.
+func (p *Progress) Update(files int, bytes int64) {
+	if p == nil {
+		return
+	}
+	p.files, p.bytes = files, bytes
+
+	now := p.now()
+	elapsed := now.Sub(p.sampleTime)
+	if elapsed < progressSampleInterval {
+		return
+	}
+	current := float64(bytes-p.sampleBytes) / elapsed.Seconds()
+	if p.hasRate {
+		p.rate = progressRateWeight*current + (1-progressRateWeight)*p.rate
+	} else {
+		p.rate, p.hasRate = current, true
+	}
+	p.sampleBytes, p.sampleTime = bytes, now
+	p.draw()
+}
+
+// Finish draws the final totals and ends the line.
+func (p *Progress) Finish() {
+	if p == nil {
+		return
+	}
+	p.draw()
+	fmt.Fprintln(p.w)
+}
+
+// ETA estimates the time left for the remaining planned bytes. It reports false until
+// a non-zero rate has been measured.
+func (p *Progress) ETA() (time.Duration, bool) {
+	if !p.hasRate || p.rate <= 0 {
+		return 0, false
+	}
+	remaining := max(p.totalBytes-p.bytes, 0)
+	return time.Duration(float64(remaining) / p.rate * float64(time.Second)), true
+}
+
+// Line formats the current status.
+func (p *Progress) Line() string {
+	rate, eta := "--", "--:--:--"
+	if p.hasRate {
+		rate = FormatSize(int64(p.rate)) + "/s"
+	}
+	if d, ok := p.ETA(); ok {
+		eta = formatClock(d)
+	}
+	return fmt.Sprintf("%s/%s files  %s/%s  %s  ETA %s",
+		formatCount(p.files), formatCount(p.totalFiles),
+		FormatSize(p.bytes), FormatSize(p.totalBytes), rate, eta)
+}
+
+func (p *Progress) draw() {
+	line := p.Line()
+	// Blank out what is left of a longer previous line
+	padding := strings.Repeat(" ", max(p.lastWidth-len(line), 0))
+	fmt.Fprintf(p.w, "\r%s%s", line, padding)
+	p.lastWidth = len(line)
+}
+
+// formatCount formats n with thousands separators, e.g. 1,234.
+func formatCount(n int) string {
+	digits := strconv.Itoa(n)
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return b.String()
+}
+
+// formatClock formats d as HH:MM:SS.
+func formatClock(d time.Duration) string {
+	seconds := int64(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// IsTerminal reports whether w is a character device such as an interactive terminal.
+func IsTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
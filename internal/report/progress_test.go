@@ -0,0 +1,84 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced clock for Progress tests.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestProgressRateAndETA(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var buf bytes.Buffer
+	p := newProgressClock(&buf, 10, 100<<20, clock.now)
+
+	_, ok := p.ETA()
+	require.False(t, ok, "Expected no ETA before the first sample")
+
+	// Updates inside the sample interval neither sample nor draw
+	clock.advance(100 * time.Millisecond)
+	p.Update(1, 1<<20)
+	require.False(t, p.hasRate)
+	require.Empty(t, buf.String())
+
+	// First sample: 10 MB in one second sets the rate directly
+	clock.advance(900 * time.Millisecond)
+	p.Update(2, 10<<20)
+	require.InDelta(t, float64(10<<20), p.rate, 1)
+	eta, ok := p.ETA()
+	require.True(t, ok)
+	require.Equal(t, 9*time.Second, eta, "Expected 90 MB remaining at 10 MB/s")
+
+	// Second sample: 20 MB/s is blended into the average
+	clock.advance(time.Second)
+	p.Update(4, 30<<20)
+	want := progressRateWeight*float64(20<<20) + (1-progressRateWeight)*float64(10<<20)
+	require.InDelta(t, want, p.rate, 1)
+	eta, ok = p.ETA()
+	require.True(t, ok)
+	require.Equal(t, time.Duration(float64(70<<20)/want*float64(time.Second)), eta)
+
+	// A stall decays the rate without dividing by zero
+	clock.advance(time.Second)
+	p.Update(4, 30<<20)
+	require.InDelta(t, (1-progressRateWeight)*want, p.rate, 1)
+
+	lines := strings.Split(buf.String(), "\r")
+	require.Len(t, lines, 4, "Expected one redraw per sample")
+	require.Equal(t, "4/10 files  30.0 MB/100.0 MB  9.1 MB/s  ETA 00:00:08", strings.TrimRight(lines[3], " "))
+}
+
+func TestProgressNil(t *testing.T) {
+	var p *Progress
+	p.Update(1, 1)
+	p.Finish()
+}
+
+func TestFormatCount(t *testing.T) {
+	testCases := []struct {
+		n    int
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{1234567, "1,234,567"},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.want, formatCount(tc.n))
+	}
+}
+
+func TestFormatClock(t *testing.T) {
+	require.Equal(t, "00:02:31", formatClock(151*time.Second))
+	require.Equal(t, "27:46:40", formatClock(100000*time.Second))
+}
@@ -0,0 +1,171 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"time"
+)
+
+// Summary holds the totals of a sync run, either planned (dry run) or executed.
+type Summary struct {
+	DryRun bool
+
+	FilesCreated int
+	FilesUpdated int
+	FilesDeleted int
+	DirsCreated  int
+	DirsDeleted  int
+	Unchanged    int
+	// Renamed counts entries whose path only changed in case and were renamed in place.
+	Renamed int
+	// PermsUpdated counts unchanged entries whose permissions alone were applied.
+	PermsUpdated int
+
+	BytesCreated int64 // Source size of created files
+	BytesUpdated int64 // Source size of updated files
+	BytesDeleted int64 // Last known size of deleted files
+
+	// BytesPlanned is the source size of every file scheduled for copying.
+	BytesPlanned int64
+	// BytesTransferred is what was actually written to the destination.
+	BytesTransferred int64
+	// BytesSkipped is planned bytes that did not need copying (destination already matched).
+	BytesSkipped int64
+	FilesSkipped int
+	// Deferred lists files that were not copied to keep reserved destination space free.
+	Deferred      []string
+	BytesDeferred int64
+	// Failed lists paths whose action failed in a run that continued past errors.
+	Failed []string
+	// Kept lists files left alone because the destination copy was newer than the source.
+	Kept    []string
+	Elapsed time.Duration
+	// Checksums maps the paths of files hashed while they were copied to their source
+	// checksum, so the caller can record them.
+	Checksums map[string]string `json:"-"`
+
+	// BytesReplaced is the current destination size of the files a dry run would update
+	// or delete. It is only meaningful when DestinationMeasured is set.
+	BytesReplaced       int64
+	DestinationMeasured bool
+}
+
+// NetChange is the planned growth of the destination: bytes written by creates and
+// updates minus the bytes they replace and the deletes free.
+func (s Summary) NetChange() int64 {
+	return s.BytesCreated + s.BytesUpdated - s.BytesReplaced
+}
+
+// Add adds the totals of other to s, e.g. to combine the batches of a run.
+func (s *Summary) Add(other Summary) {
+	s.FilesCreated += other.FilesCreated
+	s.FilesUpdated += other.FilesUpdated
+	s.FilesDeleted += other.FilesDeleted
+	s.DirsCreated += other.DirsCreated
+	s.DirsDeleted += other.DirsDeleted
+	s.Unchanged += other.Unchanged
+	s.Renamed += other.Renamed
+	s.PermsUpdated += other.PermsUpdated
+	s.BytesCreated += other.BytesCreated
+	s.BytesUpdated += other.BytesUpdated
+	s.BytesDeleted += other.BytesDeleted
+	s.BytesPlanned += other.BytesPlanned
+	s.BytesTransferred += other.BytesTransferred
+	s.BytesSkipped += other.BytesSkipped
+	s.FilesSkipped += other.FilesSkipped
+	s.Deferred = append(s.Deferred, other.Deferred...)
+	s.BytesDeferred += other.BytesDeferred
+	s.Failed = append(s.Failed, other.Failed...)
+	s.Kept = append(s.Kept, other.Kept...)
+	s.Elapsed += other.Elapsed
+	if len(other.Checksums) > 0 {
+		if s.Checksums == nil {
+			s.Checksums = make(map[string]string, len(other.Checksums))
+		}
+		maps.Copy(s.Checksums, other.Checksums)
+	}
+	s.BytesReplaced += other.BytesReplaced
+	s.DestinationMeasured = s.DestinationMeasured || other.DestinationMeasured
+}
+
+// Print renders the summary through the standard logger.
+func Print(s Summary) {
+	Render(log.Writer(), s)
+}
+
+// Render writes a human readable summary to w.
+func Render(w io.Writer, s Summary) {
+	if s.DryRun {
+		fmt.Fprintf(w, "==== DRY RUN MODE: No changes will be made ====\n")
+		fmt.Fprintf(w, "SUMMARY OF ACTIONS:\n")
+		fmt.Fprintf(w, "* Files to create: %d (total size: %s)\n", s.FilesCreated, FormatSize(s.BytesCreated))
+		fmt.Fprintf(w, "* Files to update: %d (total size: %s)\n", s.FilesUpdated, FormatSize(s.BytesUpdated))
+		fmt.Fprintf(w, "* Files to delete: %d (total size: %s)\n", s.FilesDeleted, FormatSize(s.BytesDeleted))
+		fmt.Fprintf(w, "* Directories to create: %d\n", s.DirsCreated)
+		fmt.Fprintf(w, "* Directories to delete: %d\n", s.DirsDeleted)
+		fmt.Fprintf(w, "* Unchanged: %d\n", s.Unchanged)
+		if s.Renamed > 0 {
+			fmt.Fprintf(w, "* Case-only renames: %d\n", s.Renamed)
+		}
+		if s.PermsUpdated > 0 {
+			fmt.Fprintf(w, "* Permission-only changes: %d\n", s.PermsUpdated)
+		}
+		if s.DestinationMeasured {
+			fmt.Fprintf(w, "* Net change: %s (%s replaced on the destination)\n", FormatDelta(s.NetChange()), FormatSize(s.BytesReplaced))
+		}
+		return
+	}
+
+	fmt.Fprintf(w, "==== SYNC SUMMARY ====\n")
+	fmt.Fprintf(w, "* Files created: %d (total size: %s)\n", s.FilesCreated, FormatSize(s.BytesCreated))
+	fmt.Fprintf(w, "* Files updated: %d (total size: %s)\n", s.FilesUpdated, FormatSize(s.BytesUpdated))
+	fmt.Fprintf(w, "* Files deleted: %d (total size: %s)\n", s.FilesDeleted, FormatSize(s.BytesDeleted))
+	fmt.Fprintf(w, "* Directories created: %d\n", s.DirsCreated)
+	fmt.Fprintf(w, "* Directories deleted: %d\n", s.DirsDeleted)
+	fmt.Fprintf(w, "* Unchanged: %d\n", s.Unchanged)
+	if s.Renamed > 0 {
+		fmt.Fprintf(w, "* Case-only renames: %d\n", s.Renamed)
+	}
+	if s.PermsUpdated > 0 {
+		fmt.Fprintf(w, "* Permission-only changes: %d\n", s.PermsUpdated)
+	}
+	fmt.Fprintf(w, "* Bytes transferred: %s of %s planned (skipped %s in %d unchanged files)\n",
+		FormatSize(s.BytesTransferred), FormatSize(s.BytesPlanned), FormatSize(s.BytesSkipped), s.FilesSkipped)
+	if len(s.Deferred) > 0 {
+		fmt.Fprintf(w, "* Deferred (insufficient space): %d (total size: %s)\n", len(s.Deferred), FormatSize(s.BytesDeferred))
+		for _, path := range s.Deferred {
+			fmt.Fprintf(w, "  - %s\n", path)
+		}
+	}
+	if len(s.Failed) > 0 {
+		fmt.Fprintf(w, "* Failed: %d\n", len(s.Failed))
+		for _, path := range s.Failed {
+			fmt.Fprintf(w, "  - %s\n", path)
+		}
+	}
+	fmt.Fprintf(w, "* Elapsed: %s\n", s.Elapsed.Round(time.Millisecond))
+}
+
+// FormatDelta formats a signed byte count with an explicit sign, e.g. +3.2 GB.
+func FormatDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + FormatSize(-delta)
+	}
+	return "+" + FormatSize(delta)
+}
+
+// FormatSize formats a byte count using the largest fitting binary unit.
+func FormatSize(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%d B", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	case size < 1024*1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	default:
+		return fmt.Sprintf("%.1f GB", float64(size)/(1024*1024*1024))
+	}
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	summary := Summary{
+		FilesCreated:     2,
+		FilesUpdated:     1,
+		FilesDeleted:     3,
+		DirsCreated:      1,
+		BytesCreated:     2048,
+		BytesPlanned:     5 << 20,
+		BytesTransferred: 3 << 20,
+		BytesSkipped:     2 << 20,
+		FilesSkipped:     1,
+		Elapsed:          1500 * time.Millisecond,
+	}
+
+	t.Run("RealRun", func(t *testing.T) {
+		var buf bytes.Buffer
+		Render(&buf, summary)
+
+		out := buf.String()
+		require.Contains(t, out, "SYNC SUMMARY")
+		require.Contains(t, out, "* Files created: 2 (total size: 2.0 KB)")
+		require.Contains(t, out, "* Files deleted: 3")
+		require.Contains(t, out, "* Directories created: 1")
+		require.Contains(t, out, "* Bytes transferred: 3.0 MB of 5.0 MB planned (skipped 2.0 MB in 1 unchanged files)")
+		require.Contains(t, out, "* Elapsed: 1.5s")
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		var buf bytes.Buffer
+		dry := summary
+		dry.DryRun = true
+		Render(&buf, dry)
+
+		out := buf.String()
+		require.Contains(t, out, "DRY RUN MODE")
+		require.Contains(t, out, "* Files to create: 2 (total size: 2.0 KB)")
+		require.NotContains(t, out, "Bytes transferred")
+		require.NotContains(t, out, "Net change", "Expected no net change without a measured destination")
+	})
+
+	t.Run("DryRunNetChange", func(t *testing.T) {
+		var buf bytes.Buffer
+		dry := summary
+		dry.DryRun = true
+		dry.BytesUpdated = 1024
+		dry.BytesReplaced = 4096
+		dry.DestinationMeasured = true
+		Render(&buf, dry)
+
+		require.Contains(t, buf.String(), "* Net change: -1.0 KB (4.0 KB replaced on the destination)")
+	})
+}
+
+func TestFormatDelta(t *testing.T) {
+	require.Equal(t, "+3.0 GB", FormatDelta(3<<30))
+	require.Equal(t, "-512 B", FormatDelta(-512))
+	require.Equal(t, "+0 B", FormatDelta(0))
+}
+
+func TestFormatSize(t *testing.T) {
+	require.Equal(t, "512 B", FormatSize(512))
+	require.Equal(t, "1.5 KB", FormatSize(1536))
+	require.Equal(t, "2.0 MB", FormatSize(2<<20))
+	require.Equal(t, "1.0 GB", FormatSize(1<<30))
+}
+
+func TestSummaryAdd(t *testing.T) {
+	total := Summary{FilesCreated: 1, BytesCreated: 10, Failed: []string{"a"}}
+	total.Add(Summary{FilesCreated: 2, BytesCreated: 5, Unchanged: 3, Failed: []string{"b"}, DestinationMeasured: true})
+
+	require.Equal(t, Summary{
+		FilesCreated:        3,
+		BytesCreated:        15,
+		Unchanged:           3,
+		Failed:              []string{"a", "b"},
+		DestinationMeasured: true,
+	}, total)
+}
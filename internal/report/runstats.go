@@ -0,0 +1,95 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// RunStats is the machine-readable record of a sync run written by -stats-file.
+type RunStats struct {
+	Started  time.Time `json:"started"`
+	WallTime float64   `json:"wall_time_seconds"`
+	DryRun   bool      `json:"dry_run"`
+
+	Actions ActionCounts `json:"actions"`
+	Bytes   ByteCounts   `json:"bytes"`
+	// Extensions breaks the changed files down by lower-cased extension ("" for none)
+	Extensions map[string]ExtensionStats `json:"extensions"`
+	// Errors lists what made the run fail, empty on success
+	Errors []string `json:"errors"`
+}
+
+// ActionCounts counts executed (or, in a dry run, planned) actions by type.
+type ActionCounts struct {
+	FilesCreated int `json:"files_created"`
+	FilesUpdated int `json:"files_updated"`
+	FilesDeleted int `json:"files_deleted"`
+	FilesSkipped int `json:"files_skipped"`
+	DirsCreated  int `json:"dirs_created"`
+	DirsDeleted  int `json:"dirs_deleted"`
+	Unchanged    int `json:"unchanged"`
+	Renamed      int `json:"renamed"`
+	PermsUpdated int `json:"perms_updated"`
+	Deferred     int `json:"deferred"`
+	Failed       int `json:"failed"`
+}
+
+// ByteCounts mirrors the byte totals of a Summary.
+type ByteCounts struct {
+	Planned     int64 `json:"planned"`
+	Transferred int64 `json:"transferred"`
+	Skipped     int64 `json:"skipped"`
+	Deferred    int64 `json:"deferred"`
+	Created     int64 `json:"created"`
+	Updated     int64 `json:"updated"`
+	Deleted     int64 `json:"deleted"`
+}
+
+// ExtensionStats totals the planned file actions for one extension.
+type ExtensionStats struct {
+	Created int   `json:"created"`
+	Updated int   `json:"updated"`
+	Deleted int   `json:"deleted"`
+	Bytes   int64 `json:"bytes"` // Source size of created and updated files, last known size of deleted ones
+}
+
+// NewRunStats fills the counts from s. Extensions and Errors are left to the caller.
+func NewRunStats(s Summary, started time.Time, wallTime time.Duration) RunStats {
+	return RunStats{
+		Started:  started,
+		WallTime: wallTime.Seconds(),
+		DryRun:   s.DryRun,
+		Actions: ActionCounts{
+			FilesCreated: s.FilesCreated,
+			FilesUpdated: s.FilesUpdated,
+			FilesDeleted: s.FilesDeleted,
+			FilesSkipped: s.FilesSkipped,
+			DirsCreated:  s.DirsCreated,
+			DirsDeleted:  s.DirsDeleted,
+			Unchanged:    s.Unchanged,
+			Renamed:      s.Renamed,
+			PermsUpdated: s.PermsUpdated,
+			Deferred:     len(s.Deferred),
+			Failed:       len(s.Failed),
+		},
+		Bytes: ByteCounts{
+			Planned:     s.BytesPlanned,
+			Transferred: s.BytesTransferred,
+			Skipped:     s.BytesSkipped,
+			Deferred:    s.BytesDeferred,
+			Created:     s.BytesCreated,
+			Updated:     s.BytesUpdated,
+			Deleted:     s.BytesDeleted,
+		},
+		Extensions: make(map[string]ExtensionStats),
+		Errors:     []string{},
+	}
+}
+
+// WriteRunStats encodes stats to w as indented JSON.
+func WriteRunStats(w io.Writer, stats RunStats) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stats)
+}
@@ -0,0 +1,58 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRunStats(t *testing.T) {
+	started := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	stats := NewRunStats(Summary{
+		FilesCreated:     2,
+		FilesDeleted:     1,
+		Unchanged:        4,
+		BytesPlanned:     300,
+		BytesTransferred: 200,
+		BytesSkipped:     100,
+		Deferred:         []string{"big.iso"},
+	}, started, 1500*time.Millisecond)
+	stats.Extensions[".txt"] = ExtensionStats{Created: 2, Bytes: 300}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteRunStats(&buf, stats))
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.ElementsMatch(t,
+		[]string{"started", "wall_time_seconds", "dry_run", "actions", "bytes", "extensions", "errors"},
+		keys(decoded))
+	require.Equal(t, "2024-05-06T07:08:09Z", decoded["started"])
+	require.Equal(t, 1.5, decoded["wall_time_seconds"])
+	require.Equal(t, []any{}, decoded["errors"], "Expected an empty error list rather than null")
+
+	actions := decoded["actions"].(map[string]any)
+	require.Equal(t, 2.0, actions["files_created"])
+	require.Equal(t, 1.0, actions["files_deleted"])
+	require.Equal(t, 4.0, actions["unchanged"])
+	require.Equal(t, 1.0, actions["deferred"])
+
+	byteCounts := decoded["bytes"].(map[string]any)
+	require.Equal(t, 300.0, byteCounts["planned"])
+	require.Equal(t, 200.0, byteCounts["transferred"])
+	require.Equal(t, 100.0, byteCounts["skipped"])
+
+	extensions := decoded["extensions"].(map[string]any)
+	require.Equal(t, map[string]any{"created": 2.0, "updated": 0.0, "deleted": 0.0, "bytes": 300.0}, extensions[".txt"])
+}
+
+func keys(m map[string]any) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
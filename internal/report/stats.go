@@ -0,0 +1,150 @@
+package report
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats accumulates the totals of an executing sync. All methods are safe for
+// concurrent use, so copy workers can record their results without coordination;
+// Snapshot turns the running totals into a Summary for reporting.
+type Stats struct {
+	filesCreated atomic.Int64
+	filesUpdated atomic.Int64
+	filesDeleted atomic.Int64
+	dirsCreated  atomic.Int64
+	dirsDeleted  atomic.Int64
+	unchanged    atomic.Int64
+	renamed      atomic.Int64
+	permsUpdated atomic.Int64
+	filesSkipped atomic.Int64
+
+	bytesCreated     atomic.Int64
+	bytesUpdated     atomic.Int64
+	bytesDeleted     atomic.Int64
+	bytesPlanned     atomic.Int64
+	bytesTransferred atomic.Int64
+	bytesSkipped     atomic.Int64
+	bytesDeferred    atomic.Int64
+
+	mu       sync.Mutex
+	deferred []string
+	failed   []string
+	kept     []string
+}
+
+// NewStats returns counters starting from base, e.g. the totals of a resumed run.
+func NewStats(base Summary) *Stats {
+	s := &Stats{}
+	s.filesCreated.Store(int64(base.FilesCreated))
+	s.filesUpdated.Store(int64(base.FilesUpdated))
+	s.filesDeleted.Store(int64(base.FilesDeleted))
+	s.dirsCreated.Store(int64(base.DirsCreated))
+	s.dirsDeleted.Store(int64(base.DirsDeleted))
+	s.unchanged.Store(int64(base.Unchanged))
+	s.renamed.Store(int64(base.Renamed))
+	s.permsUpdated.Store(int64(base.PermsUpdated))
+	s.filesSkipped.Store(int64(base.FilesSkipped))
+	s.bytesCreated.Store(base.BytesCreated)
+	s.bytesUpdated.Store(base.BytesUpdated)
+	s.bytesDeleted.Store(base.BytesDeleted)
+	s.bytesPlanned.Store(base.BytesPlanned)
+	s.bytesTransferred.Store(base.BytesTransferred)
+	s.bytesSkipped.Store(base.BytesSkipped)
+	s.bytesDeferred.Store(base.BytesDeferred)
+	s.deferred = slices.Clone(base.Deferred)
+	s.failed = slices.Clone(base.Failed)
+	return s
+}
+
+// AddCreated records a created file of the given source size.
+func (s *Stats) AddCreated(size int64) {
+	s.filesCreated.Add(1)
+	s.bytesCreated.Add(size)
+}
+
+// AddUpdated records an updated file of the given source size.
+func (s *Stats) AddUpdated(size int64) {
+	s.filesUpdated.Add(1)
+	s.bytesUpdated.Add(size)
+}
+
+// AddDeleted records a deleted file of the given last known size.
+func (s *Stats) AddDeleted(size int64) {
+	s.filesDeleted.Add(1)
+	s.bytesDeleted.Add(size)
+}
+
+func (s *Stats) AddDirCreated() { s.dirsCreated.Add(1) }
+func (s *Stats) AddDirDeleted() { s.dirsDeleted.Add(1) }
+func (s *Stats) AddUnchanged()  { s.unchanged.Add(1) }
+func (s *Stats) AddRenamed()    { s.renamed.Add(1) }
+
+// AddPermsUpdated records an entry whose permissions were applied without copying it.
+func (s *Stats) AddPermsUpdated() { s.permsUpdated.Add(1) }
+
+// AddPlanned records bytes scheduled for copying.
+func (s *Stats) AddPlanned(size int64) { s.bytesPlanned.Add(size) }
+
+// AddTransferred records bytes actually written to the destination.
+func (s *Stats) AddTransferred(n int64) { s.bytesTransferred.Add(n) }
+
+// AddSkipped records a planned copy that was not needed.
+func (s *Stats) AddSkipped(size int64) {
+	s.filesSkipped.Add(1)
+	s.bytesSkipped.Add(size)
+}
+
+// AddDeferred records a copy postponed to a later run.
+func (s *Stats) AddDeferred(path string, size int64) {
+	s.bytesDeferred.Add(size)
+	s.mu.Lock()
+	s.deferred = append(s.deferred, path)
+	s.mu.Unlock()
+}
+
+// AddFailed records an action that failed without stopping the run.
+func (s *Stats) AddFailed(path string) {
+	s.mu.Lock()
+	s.failed = append(s.failed, path)
+	s.mu.Unlock()
+}
+
+// AddKept records a copy left out because the destination copy was newer.
+func (s *Stats) AddKept(path string) {
+	s.mu.Lock()
+	s.kept = append(s.kept, path)
+	s.mu.Unlock()
+}
+
+// Snapshot returns the current totals. Elapsed is left for the caller to fill in.
+func (s *Stats) Snapshot() Summary {
+	s.mu.Lock()
+	deferred := slices.Clone(s.deferred)
+	failed := slices.Clone(s.failed)
+	kept := slices.Clone(s.kept)
+	s.mu.Unlock()
+
+	return Summary{
+		FilesCreated:     int(s.filesCreated.Load()),
+		FilesUpdated:     int(s.filesUpdated.Load()),
+		FilesDeleted:     int(s.filesDeleted.Load()),
+		DirsCreated:      int(s.dirsCreated.Load()),
+		DirsDeleted:      int(s.dirsDeleted.Load()),
+		Unchanged:        int(s.unchanged.Load()),
+		Renamed:          int(s.renamed.Load()),
+		PermsUpdated:     int(s.permsUpdated.Load()),
+		FilesSkipped:     int(s.filesSkipped.Load()),
+		BytesCreated:     s.bytesCreated.Load(),
+		BytesUpdated:     s.bytesUpdated.Load(),
+		BytesDeleted:     s.bytesDeleted.Load(),
+		BytesPlanned:     s.bytesPlanned.Load(),
+		BytesTransferred: s.bytesTransferred.Load(),
+		BytesSkipped:     s.bytesSkipped.Load(),
+		BytesDeferred:    s.bytesDeferred.Load(),
+		Deferred:         deferred,
+		Failed:           failed,
+		Kept:             kept,
+	}
+}
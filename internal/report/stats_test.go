@@ -0,0 +1,44 @@
+package report
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsConcurrentUpdates(t *testing.T) {
+	const workers = 32
+	const perWorker = 1000
+
+	stats := NewStats(Summary{FilesCreated: 5, BytesCreated: 500})
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWorker {
+				stats.AddCreated(10)
+				stats.AddPlanned(10)
+				stats.AddTransferred(10)
+				stats.AddUnchanged()
+				if i%100 == 0 {
+					stats.AddDeferred(fmt.Sprintf("w%d/f%d", w, i), 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	summary := stats.Snapshot()
+	total := workers * perWorker
+	require.Equal(t, 5+total, summary.FilesCreated, "Expected resumed totals plus every increment")
+	require.Equal(t, int64(500+10*total), summary.BytesCreated)
+	require.Equal(t, int64(10*total), summary.BytesPlanned)
+	require.Equal(t, int64(10*total), summary.BytesTransferred)
+	require.Equal(t, total, summary.Unchanged)
+	require.Len(t, summary.Deferred, workers*perWorker/100)
+	require.Equal(t, int64(workers*perWorker/100), summary.BytesDeferred)
+}
@@ -0,0 +1,81 @@
+package syncer
+
+import (
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+// bookkeepingFiles are mimic's own files in the destination, written by it or, like the
+// keep file, read by it, and are never adopted.
+var bookkeepingFiles = []string{
+	stateFile, fileops.TempPath(stateFile), stateBackupFile, stateFile + lockSuffix,
+	progressFile, fileops.TempPath(progressFile), keepFile,
+	fileops.PartialPath("*"), // Unfinished copies kept for -resume
+}
+
+// ScanDestination scans a destination directory like ScanSource, skipping mimic's own
+// bookkeeping files and the source-only filters (checksum manifest, mtime window).
+func ScanDestination(dstDir string, cfg *config.Config) (map[string]EntryInfo, error) {
+	dstCfg := *cfg
+	dstCfg.ExcludePatterns = append(append([]string{}, cfg.ExcludePatterns...), bookkeepingFiles...)
+	dstCfg.SourceChecksums = ""                                   // The manifest describes the source, not the destination
+	dstCfg.NewerThan, dstCfg.OlderThan = time.Time{}, time.Time{} // Copies may carry different mtimes
+	dstCfg.DereferenceRoot = true                                 // Copies were written through the link
+	return ScanSource(dstDir, &dstCfg)
+}
+
+// StatelessEntries scans dstDir for a stateless run and returns the entries to compare the
+// source scan against in place of the state, with the config to compare them under. Copies
+// do not carry the source mtime over, so times are ignored and files of equal size are
+// told apart by checksum. Destination entries the source lacks are planned as deletes.
+func StatelessEntries(dstDir string, cfg *config.Config) (map[string]EntryInfo, *config.Config, error) {
+	entries, err := ScanDestination(dstDir, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	compareCfg := *cfg
+	compareCfg.NoTimes, compareCfg.VerifyOnEqualMtime = true, true
+	return entries, &compareCfg, nil
+}
+
+// AdoptDestination seeds an empty state from files already present in dstDir, so a first
+// run against a pre-populated destination only copies what actually differs.
+//
+// A destination file is adopted when the source has the same path, size and checksum; it
+// is recorded with the source entry so CompareStates plans ActionNone for it. Directories
+// present on both sides are adopted as well. Differing files are left out and get copied
+// as creates. Destination-only files are left untracked so adoption never deletes data
+// mimic did not write.
+func AdoptDestination(dstDir string, sourceScan map[string]EntryInfo, cfg *config.Config) (map[string]EntryInfo, error) {
+	dstScan, err := ScanDestination(dstDir, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	adopted := make(map[string]EntryInfo)
+	untracked := 0
+	for path, dst := range dstScan {
+		source, ok := sourceScan[path]
+		if !ok {
+			untracked++
+			continue
+		}
+		if source.IsDir && dst.IsDir {
+			adopted[path] = source
+			continue
+		}
+		if !source.IsDir && !dst.IsDir && source.Size == dst.Size && source.Checksum == dst.Checksum {
+			adopted[path] = source
+		}
+	}
+
+	logger.Info("adopted existing destination entries",
+		"dir", dstDir,
+		"adopted", len(adopted),
+		"destination_entries", len(dstScan),
+		"untracked", untracked)
+	return adopted, nil
+}
@@ -0,0 +1,120 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdoptDestination(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	writeFile := func(root, rel, content string) {
+		path := filepath.Join(root, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	writeFile(srcDir, filepath.Join("docs", "same.txt"), "identical")
+	writeFile(srcDir, "changed.txt", "new content")
+	writeFile(srcDir, "missing.txt", "only in source")
+
+	// A manual copy made earlier, with different mtimes and one stale file
+	writeFile(dstDir, filepath.Join("docs", "same.txt"), "identical")
+	writeFile(dstDir, "changed.txt", "old content")
+	writeFile(dstDir, "extra.txt", "only in destination")
+	writeFile(dstDir, fileops.PartialPath("missing.txt"), "only in")
+
+	cfg := config.NewDefaultConfig()
+	state, err := LoadState(dstDir, cfg)
+	require.NoError(t, err)
+	require.Empty(t, state.Entries)
+
+	sourceEntries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+
+	adopted, err := AdoptDestination(dstDir, sourceEntries, cfg)
+	require.NoError(t, err)
+	require.NotContains(t, adopted, stateFile, "Expected bookkeeping files to be ignored")
+	require.NotContains(t, adopted, fileops.PartialPath("missing.txt"), "Expected resume partials to be ignored")
+	require.NotContains(t, adopted, "extra.txt", "Expected destination-only files to stay untracked")
+
+	actions := make(map[string]int)
+	for _, action := range CompareStates(sourceEntries, adopted, cfg) {
+		actions[action.RelativePath] = action.Type
+	}
+
+	require.Equal(t, map[string]int{
+		"docs":                            ActionNone,
+		filepath.Join("docs", "same.txt"): ActionNone,
+		"changed.txt":                     ActionCreate,
+		"missing.txt":                     ActionCreate,
+	}, actions)
+}
+
+func TestSyncStateless(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	writeFile := func(root, rel, content string) {
+		path := filepath.Join(root, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	writeFile(srcDir, filepath.Join("docs", "same.txt"), "identical")
+	writeFile(srcDir, "changed.txt", "new content")
+	writeFile(srcDir, "grown.txt", "longer than before")
+	writeFile(srcDir, "missing.txt", "only in source")
+	writeFile(dstDir, filepath.Join("docs", "same.txt"), "identical")
+	writeFile(dstDir, "changed.txt", "old content") // Same size, different content
+	writeFile(dstDir, "grown.txt", "short")
+	writeFile(dstDir, filepath.Join("stale", "extra.txt"), "only in destination")
+	writeFile(dstDir, fileops.PartialPath("missing.txt"), "only in") // Left for -resume
+
+	cfg := config.NewDefaultConfig()
+	cfg.Stateless = true
+	sourceEntries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+	dstEntries, compareCfg, err := StatelessEntries(dstDir, cfg)
+	require.NoError(t, err)
+
+	actions := make(map[string]int)
+	for _, action := range CompareStates(sourceEntries, dstEntries, compareCfg) {
+		actions[action.RelativePath] = action.Type
+	}
+	require.Equal(t, map[string]int{
+		"docs":                              ActionNone,
+		filepath.Join("docs", "same.txt"):   ActionNone,
+		"changed.txt":                       ActionUpdate,
+		"grown.txt":                         ActionUpdate,
+		"missing.txt":                       ActionCreate,
+		"stale":                             ActionRmdir,
+		filepath.Join("stale", "extra.txt"): ActionDelete,
+	}, actions, "Expected the plan to come from the destination scan alone")
+
+	summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.FilesCreated)
+	require.Equal(t, 2, summary.FilesUpdated)
+	require.NoFileExists(t, filepath.Join(dstDir, stateFile), "Expected no state to be written")
+	require.NoDirExists(t, filepath.Join(dstDir, "stale"))
+	require.FileExists(t, filepath.Join(dstDir, fileops.PartialPath("missing.txt")))
+
+	summary, err = Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.Zero(t, summary.FilesCreated+summary.FilesUpdated+summary.FilesDeleted, "Expected a second run to find nothing to do")
+
+	// Drift on the destination is repaired without any state to go by
+	writeFile(dstDir, "changed.txt", "tampered!!!")
+	summary, err = Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.FilesUpdated)
+	got, err := os.ReadFile(filepath.Join(dstDir, "changed.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "new content", string(got))
+}
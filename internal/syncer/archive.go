@@ -0,0 +1,340 @@
+package syncer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"cmp"
+	"compress/gzip"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+var (
+	ErrSyncerArchive     = errors.New("syncer: cannot read archive")
+	ErrSyncerArchivePath = errors.New("syncer: archive entry path escapes the root")
+)
+
+// Archive formats an ArchiveSource reads.
+const (
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz"
+	archiveZip   = "zip"
+)
+
+// archiveFormat returns the archive format named by path's extension, or "" when path
+// is not an archive.
+func archiveFormat(path string) string {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGz
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip
+	}
+	return ""
+}
+
+// IsArchive reports whether path has the extension of an archive that can be synced
+// from: .tar, .tar.gz, .tgz or .zip.
+func IsArchive(path string) bool {
+	return archiveFormat(path) != ""
+}
+
+// archiveMember is a file found by the scan, with what Open needs to extract it again.
+type archiveMember struct {
+	index int // Position in the archive
+	mode  fs.FileMode
+	mtime time.Time
+	file  *zip.File // Set for zip archives
+}
+
+// ArchiveSource reads a tar, gzip-compressed tar or zip archive as a Source. The scan
+// lists and checksums the entries in one streaming pass, and Open extracts a file to a
+// temporary copy. Tar archives can only be read front to back, so opening a file that
+// comes before the previous one in the archive rereads it from the start; archives
+// created by walking a directory are read once.
+type ArchiveSource struct {
+	path    string
+	format  string
+	members map[string]archiveMember // Keyed by path relative to the archive root
+
+	zipReader *zip.ReadCloser
+	tarReader *tar.Reader
+	tarClose  func() error
+	tarNext   int // Index of the entry tarReader returns next
+}
+
+func NewArchiveSource(path string) *ArchiveSource {
+	return &ArchiveSource{path: path, format: archiveFormat(path)}
+}
+
+// Scan lists the archive's entries like ScanSource lists a directory, applying the same
+// exclude patterns, size limit, mtime window and Windows name handling. Directories the
+// archive only implies through the paths of its files are added with the archive's mtime.
+// An entry whose path would leave the root fails the scan with ErrSyncerArchivePath.
+func (s *ArchiveSource) Scan(cfg *config.Config) (map[string]EntryInfo, error) {
+	logger.Debug("starting scan", "operation", "ArchiveScan", "archive", s.path)
+
+	archiveInfo, err := os.Stat(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyncerSrcNotExists, err)
+	}
+
+	entries := make(map[string]EntryInfo)
+	s.members = make(map[string]archiveMember)
+	index := -1
+	err = s.each(func(name string, info fs.FileInfo, zipFile *zip.File, open func() (io.ReadCloser, error)) error {
+		index++
+		relPath, err := archiveEntryPath(name)
+		if err != nil {
+			return &SyncError{Op: OpScan, Path: name, Err: err}
+		}
+		isDir := info.IsDir()
+		switch {
+		case relPath == ".":
+			return nil
+		case !isDir && !info.Mode().IsRegular():
+			logger.Warn("unsupported archive entry type, skipping entry", "path", relPath, "mode", info.Mode())
+			return nil
+		case shouldExclude(relPath, cfg.ExcludePatterns):
+			logger.Debug("skipping entry", "path", relPath)
+			return nil
+		case !isDir && cfg.MaxFileSize > 0 && info.Size() > cfg.MaxFileSize:
+			logger.Warn("file exceeds max file size, skipping entry", "path", relPath, "size", info.Size(), "max_size", cfg.MaxFileSize)
+			return nil
+		case !isDir && excludedBySize(relPath, info.Size(), cfg.SizeExcludeRules):
+			logger.Debug("file matches a size exclude rule, skipping entry", "path", relPath, "size", info.Size())
+			return nil
+		case !isDir && !withinMtimeWindow(info.ModTime(), cfg.NewerThan, cfg.OlderThan):
+			logger.Debug("file outside mtime window, skipping entry", "path", relPath, "mtime", info.ModTime())
+			return nil
+		}
+
+		entryPath, skip, err := windowsEntryPath(relPath, cfg)
+		if err != nil {
+			return &SyncError{Op: OpScan, Path: relPath, Err: err}
+		}
+		if skip {
+			return nil
+		}
+
+		entry := EntryInfo{
+			RelativePath: entryPath,
+			Mtime:        info.ModTime(),
+			IsDir:        isDir,
+			Permissions:  info.Mode(),
+		}
+		if entryPath != relPath {
+			entry.SourcePath = relPath
+		}
+		if !isDir {
+			entry.Size = info.Size()
+			if entry.Checksum, err = checksumReader(open); err != nil {
+				return &SyncError{Op: OpChecksum, Path: relPath, Err: err}
+			}
+			s.members[relPath] = archiveMember{index: index, mode: info.Mode(), mtime: info.ModTime(), file: zipFile}
+		}
+		// A later entry for the same path replaces an earlier one, as it does when extracting
+		entries[entryPath] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSyncerArchive, err)
+	}
+
+	addArchiveParents(entries, archiveInfo.ModTime(), cfg)
+	logger.Info("scan finished successfully", "operation", "ArchiveScan", "archive", s.path, "entries_found", len(entries))
+	return entries, nil
+}
+
+// Open extracts the file at relPath to a temporary file with the entry's permissions and
+// mtime; release removes it.
+func (s *ArchiveSource) Open(relPath string) (string, func(), error) {
+	member, ok := s.members[relPath]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s is not a file in %s", ErrSyncerNotExist, relPath, s.path)
+	}
+	contents, err := s.read(member)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %w", ErrSyncerArchive, err)
+	}
+	defer contents.Close()
+
+	temp, err := os.CreateTemp("", "mimic-archive-*")
+	if err != nil {
+		return "", nil, err
+	}
+	release := func() { _ = os.Remove(temp.Name()) }
+	_, err = io.Copy(temp, contents)
+	err = cmp.Or(err, temp.Close())
+	if err == nil {
+		err = os.Chmod(temp.Name(), cmp.Or(member.mode.Perm(), 0644))
+	}
+	if err == nil {
+		err = os.Chtimes(temp.Name(), member.mtime, member.mtime)
+	}
+	if err != nil {
+		release()
+		return "", nil, err
+	}
+	return temp.Name(), release, nil
+}
+
+// Close releases the open archive readers.
+func (s *ArchiveSource) Close() error {
+	var errs []error
+	if s.tarClose != nil {
+		errs = append(errs, s.tarClose())
+		s.tarReader, s.tarClose = nil, nil
+	}
+	if s.zipReader != nil {
+		errs = append(errs, s.zipReader.Close())
+		s.zipReader = nil
+	}
+	return errors.Join(errs...)
+}
+
+// each calls fn for every entry of the archive in archive order. open returns the
+// entry's contents and is only valid during the call.
+func (s *ArchiveSource) each(fn func(name string, info fs.FileInfo, zipFile *zip.File, open func() (io.ReadCloser, error)) error) error {
+	if s.format == archiveZip {
+		if s.zipReader == nil {
+			reader, err := zip.OpenReader(s.path)
+			if err != nil {
+				return err
+			}
+			s.zipReader = reader
+		}
+		for _, file := range s.zipReader.File {
+			if err := fn(file.Name, file.FileInfo(), file, file.Open); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	reader, closeTar, err := s.openTar()
+	if err != nil {
+		return err
+	}
+	defer closeTar()
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		open := func() (io.ReadCloser, error) { return io.NopCloser(reader), nil }
+		if err := fn(header.Name, header.FileInfo(), nil, open); err != nil {
+			return err
+		}
+	}
+}
+
+// openTar opens the archive as a tar stream, decompressing it for tar.gz archives.
+func (s *ArchiveSource) openTar() (*tar.Reader, func() error, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.format != archiveTarGz {
+		return tar.NewReader(file), file.Close, nil
+	}
+	decompressed, err := gzip.NewReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	return tar.NewReader(decompressed), func() error {
+		return errors.Join(decompressed.Close(), file.Close())
+	}, nil
+}
+
+// read returns the contents of member. For tar archives the open stream is advanced to
+// the member, or reopened first when it has already passed it.
+func (s *ArchiveSource) read(member archiveMember) (io.ReadCloser, error) {
+	if member.file != nil {
+		return member.file.Open()
+	}
+
+	if s.tarReader == nil || s.tarNext > member.index {
+		if s.tarClose != nil {
+			_ = s.tarClose()
+		}
+		reader, closeTar, err := s.openTar()
+		if err != nil {
+			s.tarReader, s.tarClose = nil, nil
+			return nil, err
+		}
+		s.tarReader, s.tarClose, s.tarNext = reader, closeTar, 0
+	}
+	for s.tarNext <= member.index {
+		if _, err := s.tarReader.Next(); err != nil {
+			_ = s.tarClose()
+			s.tarReader, s.tarClose = nil, nil
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("archive changed since the scan: %w", io.ErrUnexpectedEOF)
+			}
+			return nil, err
+		}
+		s.tarNext++
+	}
+	return io.NopCloser(s.tarReader), nil
+}
+
+// archiveEntryPath converts the name of an archive entry to a path relative to the
+// source root, rejecting absolute names and names that climb out of the root.
+func archiveEntryPath(name string) (string, error) {
+	cleaned := path.Clean(name)
+	relPath := filepath.FromSlash(cleaned)
+	if path.IsAbs(cleaned) || filepath.VolumeName(relPath) != "" ||
+		cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: %s", ErrSyncerArchivePath, name)
+	}
+	return relPath, nil
+}
+
+// checksumReader returns the hex xxHash of the contents open returns, the same checksum
+// generateChecksum computes for a local file.
+func checksumReader(open func() (io.ReadCloser, error)) (string, error) {
+	contents, err := open()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSyncerRead, err)
+	}
+	defer contents.Close()
+
+	hash := xxhash.New()
+	if _, err := io.Copy(hash, contents); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSyncerChecksum, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// addArchiveParents adds the missing parent directories of the scanned entries, which
+// archives often leave out, unless they are excluded.
+func addArchiveParents(entries map[string]EntryInfo, mtime time.Time, cfg *config.Config) {
+	for relPath := range entries {
+		for dir := filepath.Dir(relPath); dir != "."; dir = filepath.Dir(dir) {
+			if _, ok := entries[dir]; ok || shouldExclude(dir, cfg.ExcludePatterns) {
+				continue
+			}
+			entries[dir] = EntryInfo{RelativePath: dir, Mtime: mtime, IsDir: true, Permissions: fs.ModeDir | 0755}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package syncer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// archiveFile is an entry of a test archive; a name ending in '/' is a directory.
+type archiveFile struct {
+	name string
+	body string
+	mode int64
+}
+
+// writeArchive builds an archive of files in memory and writes it to dir/name, picking
+// the format from the name's extension.
+func writeArchive(t *testing.T, dir, name string, files []archiveFile) string {
+	t.Helper()
+	mtime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+
+	switch archiveFormat(name) {
+	case archiveZip:
+		zw := zip.NewWriter(&buf)
+		for _, file := range files {
+			header := &zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: mtime}
+			header.SetMode(os.FileMode(file.mode))
+			w, err := zw.CreateHeader(header)
+			require.NoError(t, err)
+			_, err = io.WriteString(w, file.body)
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+	default:
+		var w io.Writer = &buf
+		var gz *gzip.Writer
+		if archiveFormat(name) == archiveTarGz {
+			gz = gzip.NewWriter(&buf)
+			w = gz
+		}
+		tw := tar.NewWriter(w)
+		for _, file := range files {
+			header := &tar.Header{Name: file.name, Mode: file.mode, ModTime: mtime, Size: int64(len(file.body)), Typeflag: tar.TypeReg}
+			if file.name[len(file.name)-1] == '/' {
+				header.Typeflag, header.Size = tar.TypeDir, 0
+			}
+			require.NoError(t, tw.WriteHeader(header))
+			_, err := io.WriteString(tw, file.body)
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		if gz != nil {
+			require.NoError(t, gz.Close())
+		}
+	}
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	return path
+}
+
+func TestArchiveSourceScan(t *testing.T) {
+	archive := writeArchive(t, t.TempDir(), "release.tar.gz", []archiveFile{
+		{name: "./app/bin/run", body: "#!/bin/sh\n", mode: 0755},
+		{name: "app/config.yml", body: "port: 80\n", mode: 0644},
+		{name: "app/debug.log", body: "noise", mode: 0644},
+	})
+
+	// The same tree as a directory, to compare checksums with
+	dirRoot := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dirRoot, "app", "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dirRoot, "app", "bin", "run"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dirRoot, "app", "config.yml"), []byte("port: 80\n"), 0644))
+
+	cfg := config.NewDefaultConfig()
+	cfg.ExcludePatterns = []string{"*.log"}
+	src := NewArchiveSource(archive)
+	defer src.Close()
+
+	entries, err := src.Scan(cfg)
+	require.NoError(t, err)
+	dirEntries, err := ScanSource(dirRoot, cfg)
+	require.NoError(t, err)
+
+	require.ElementsMatch(t, []string{"app", filepath.Join("app", "bin"), filepath.Join("app", "bin", "run"), filepath.Join("app", "config.yml")},
+		slices.Collect(maps.Keys(entries)), "Expected implied parent directories and no excluded files")
+	require.True(t, entries["app"].IsDir)
+	for _, relPath := range []string{filepath.Join("app", "bin", "run"), filepath.Join("app", "config.yml")} {
+		require.Equal(t, dirEntries[relPath].Checksum, entries[relPath].Checksum, "Expected the streamed checksum of %s to match a file scan", relPath)
+		require.Equal(t, dirEntries[relPath].Size, entries[relPath].Size)
+	}
+	require.Equal(t, os.FileMode(0755), entries[filepath.Join("app", "bin", "run")].Permissions.Perm())
+}
+
+func TestArchiveSourceOpenOutOfOrder(t *testing.T) {
+	archive := writeArchive(t, t.TempDir(), "bundle.tar", []archiveFile{
+		{name: "a.txt", body: "first", mode: 0644},
+		{name: "b.txt", body: "second", mode: 0600},
+	})
+	src := NewArchiveSource(archive)
+	defer src.Close()
+	_, err := src.Scan(config.NewDefaultConfig())
+	require.NoError(t, err)
+
+	for _, tt := range []struct{ relPath, body string }{{"b.txt", "second"}, {"a.txt", "first"}, {"b.txt", "second"}} {
+		path, release, err := src.Open(tt.relPath)
+		require.NoError(t, err)
+		contents, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, tt.body, string(contents), "Expected %s to be extracted even after the stream passed it", tt.relPath)
+		release()
+		require.NoFileExists(t, path, "Expected release to remove the temporary copy")
+	}
+
+	_, _, err = src.Open("missing.txt")
+	require.ErrorIs(t, err, ErrSyncerNotExist)
+}
+
+func TestArchiveEntryPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "./a/b/", want: filepath.Join("a", "b")},
+		{name: "a//b.txt", want: filepath.Join("a", "b.txt")},
+		{name: "a/../b.txt", want: "b.txt"},
+		{name: "../evil.txt", wantErr: true},
+		{name: "a/../../evil.txt", wantErr: true},
+		{name: "/etc/passwd", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := archiveEntryPath(tt.name)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrSyncerArchivePath)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSyncFromArchive(t *testing.T) {
+	for _, name := range []string{"release.tar", "release.tar.gz", "release.tgz", "release.zip"} {
+		t.Run(name, func(t *testing.T) {
+			archiveDir, dstDir := t.TempDir(), t.TempDir()
+			cfg := config.NewDefaultConfig()
+
+			v1 := writeArchive(t, archiveDir, name, []archiveFile{
+				{name: "docs/", mode: 0755},
+				{name: "docs/readme.md", body: "v1 docs", mode: 0644},
+				{name: "bin/tool", body: "v1 tool", mode: 0755},
+				{name: "old.txt", body: "obsolete", mode: 0644},
+			})
+			summary, err := Sync(context.Background(), v1, dstDir, cfg)
+			require.NoError(t, err)
+			require.Equal(t, 3, summary.FilesCreated)
+			for relPath, body := range map[string]string{"docs/readme.md": "v1 docs", "bin/tool": "v1 tool", "old.txt": "obsolete"} {
+				contents, err := os.ReadFile(filepath.Join(dstDir, filepath.FromSlash(relPath)))
+				require.NoError(t, err)
+				require.Equal(t, body, string(contents))
+			}
+
+			v2 := writeArchive(t, archiveDir, name, []archiveFile{
+				{name: "docs/", mode: 0755},
+				{name: "docs/readme.md", body: "v1 docs", mode: 0644},
+				{name: "bin/tool", body: "v2 tool, longer", mode: 0755},
+			})
+			summary, err = Sync(context.Background(), v2, dstDir, cfg)
+			require.NoError(t, err)
+			require.Equal(t, 1, summary.FilesUpdated)
+			require.Equal(t, 1, summary.FilesDeleted)
+			require.Zero(t, summary.FilesCreated)
+
+			contents, err := os.ReadFile(filepath.Join(dstDir, "bin", "tool"))
+			require.NoError(t, err)
+			require.Equal(t, "v2 tool, longer", string(contents))
+			require.NoFileExists(t, filepath.Join(dstDir, "old.txt"))
+		})
+	}
+}
+
+func TestArchiveSourceRejectsEscapingEntries(t *testing.T) {
+	archive := writeArchive(t, t.TempDir(), "evil.tar", []archiveFile{{name: "../../etc/cron.d/evil", body: "x", mode: 0644}})
+
+	_, err := Sync(context.Background(), archive, t.TempDir(), config.NewDefaultConfig())
+	require.ErrorIs(t, err, ErrSyncerArchive)
+	require.ErrorIs(t, err, ErrSyncerArchivePath)
+}
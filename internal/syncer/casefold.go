@@ -0,0 +1,65 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
+)
+
+// caseRenames pairs every source path missing from the state with a state path that only
+// differs in case and is itself missing from the source. It returns the old state path
+// keyed by the new source path.
+func caseRenames(sourceScan, loadedStateEntries map[string]EntryInfo) map[string]string {
+	orphaned := make(map[string]string)
+	for _, path := range slices.Sorted(maps.Keys(loadedStateEntries)) {
+		if _, exists := sourceScan[path]; !exists {
+			orphaned[strings.ToLower(path)] = path
+		}
+	}
+
+	renames := make(map[string]string)
+	for _, path := range slices.Sorted(maps.Keys(sourceScan)) {
+		if _, exists := loadedStateEntries[path]; exists {
+			continue
+		}
+		folded := strings.ToLower(path)
+		if old, ok := orphaned[folded]; ok {
+			renames[path] = old
+			delete(orphaned, folded)
+		}
+	}
+	return renames
+}
+
+// renameCase moves the destination entry of a case-only rename to its new path. Parents
+// are handled before their children, so by now the entry sits under the new parent
+// and only its own name may still need changing. A direct rename can be a no-op or be
+// refused on case-folding file systems, so it goes through a temporary name; should the
+// second step fail, the entry is moved back to where it was.
+func renameCase(dst Destination, action SyncAction) error {
+	current := filepath.Join(filepath.Dir(action.RelativePath), filepath.Base(action.PreviousInfo.RelativePath))
+	if current == action.RelativePath {
+		return nil // Only a parent's case changed and the entry moved with it
+	}
+
+	mover, ok := dst.(renamer)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrSyncerRenameUnsupported, action.RelativePath)
+	}
+	temp := fileops.RenamePath(action.RelativePath)
+	if err := mover.Rename(current, temp); err != nil {
+		return err
+	}
+	if err := mover.Rename(temp, action.RelativePath); err != nil {
+		if backErr := mover.Rename(temp, current); backErr != nil {
+			return errors.Join(err, fmt.Errorf("moving %s back: %w", temp, backErr))
+		}
+		return err
+	}
+	return nil
+}
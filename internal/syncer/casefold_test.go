@@ -0,0 +1,203 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
+	"github.com/stretchr/testify/require"
+)
+
+// foldingDestination simulates a case-insensitive, case-preserving file system: paths
+// that only differ in case name the same entry, which keeps the case it was created with.
+// Like some real file systems it ignores renames between two spellings of one entry.
+type foldingDestination struct {
+	*memDestination
+}
+
+// stored returns the path relPath is stored under, resolving each component regardless of case.
+func (d *foldingDestination) stored(relPath string) string {
+	result := ""
+	for _, part := range strings.Split(relPath, string(filepath.Separator)) {
+		candidate := filepath.Join(result, part)
+		for existing := range d.dirs {
+			if strings.EqualFold(existing, candidate) {
+				candidate = existing
+			}
+		}
+		for existing := range d.files {
+			if strings.EqualFold(existing, candidate) {
+				candidate = existing
+			}
+		}
+		result = candidate
+	}
+	return result
+}
+
+func (d *foldingDestination) Copy(srcPath, relPath string, chunkSize int64) (int64, error) {
+	return d.memDestination.Copy(srcPath, d.stored(relPath), chunkSize)
+}
+
+func (d *foldingDestination) Mkdir(relPath string) error {
+	return d.memDestination.Mkdir(d.stored(relPath))
+}
+
+func (d *foldingDestination) Delete(relPath string) error {
+	return d.memDestination.Delete(d.stored(relPath))
+}
+
+func (d *foldingDestination) Stat(relPath string) (fs.FileInfo, error) {
+	return d.memDestination.Stat(d.stored(relPath))
+}
+
+func (d *foldingDestination) Exists(relPath string) (bool, error) {
+	return d.memDestination.Exists(d.stored(relPath))
+}
+
+func (d *foldingDestination) Rename(oldRelPath, newRelPath string) error {
+	from, to := d.stored(oldRelPath), d.stored(newRelPath)
+	if from == to {
+		return nil // Same entry: the new spelling is silently ignored
+	}
+	moved := func(path string) (string, bool) {
+		if path == from {
+			return to, true
+		}
+		if rest, ok := strings.CutPrefix(path, from+string(filepath.Separator)); ok {
+			return filepath.Join(to, rest), true
+		}
+		return "", false
+	}
+	for path, data := range d.files {
+		if target, ok := moved(path); ok {
+			delete(d.files, path)
+			d.files[target] = data
+		}
+	}
+	for path := range d.dirs {
+		if target, ok := moved(path); ok {
+			delete(d.dirs, path)
+			d.dirs[target] = true
+		}
+	}
+	return nil
+}
+
+func TestCompareStatesCaseInsensitive(t *testing.T) {
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	state := map[string]EntryInfo{
+		"Docs":                         {RelativePath: "Docs", Mtime: mtime, IsDir: true},
+		filepath.Join("Docs", "a.txt"): {RelativePath: filepath.Join("Docs", "a.txt"), Mtime: mtime, Size: 1},
+		"Foo.txt":                      {RelativePath: "Foo.txt", Mtime: mtime, Size: 3},
+		"gone.txt":                     {RelativePath: "gone.txt", Mtime: mtime, Size: 4},
+	}
+	source := map[string]EntryInfo{
+		"docs":                         {RelativePath: "docs", Mtime: mtime, IsDir: true},
+		filepath.Join("docs", "a.txt"): {RelativePath: filepath.Join("docs", "a.txt"), Mtime: mtime, Size: 1},
+		"foo.txt":                      {RelativePath: "foo.txt", Mtime: mtime, Size: 5},
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		actions := CompareStates(source, state, config.NewDefaultConfig())
+		kinds := map[int]int{}
+		for _, action := range actions {
+			kinds[action.Type]++
+		}
+		require.Equal(t, map[int]int{ActionCreate: 2, ActionMkdir: 1, ActionDelete: 3, ActionRmdir: 1}, kinds)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.CaseInsensitive = true
+
+		actions := CompareStates(source, state, cfg)
+		require.Len(t, actions, 4)
+		for _, action := range actions[:3] {
+			require.Equal(t, ActionUpdate, action.Type, "Expected %s to be renamed", action.RelativePath)
+			require.True(t, action.Reason.Has(ReasonCaseRenamed))
+		}
+		require.Equal(t, "Docs", actions[0].PreviousInfo.RelativePath)
+		require.Equal(t, ReasonCaseRenamed, actions[1].Reason, "Expected an unchanged file to only be renamed")
+		require.Equal(t, ReasonCaseRenamed|ReasonSizeChanged, actions[2].Reason)
+		require.Equal(t, "renamed from Foo.txt, size 3 B→5 B", DescribeReason(actions[2]))
+		require.Equal(t, SyncAction{Type: ActionDelete, RelativePath: "gone.txt", SourceInfo: state["gone.txt"]}, actions[3],
+			"Expected only the truly removed file to be deleted")
+	})
+}
+
+func TestExecuteActionsCaseOnlyRename(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "docs"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "docs", "readme.md"), []byte("read me"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "foo.txt"), []byte("new foo"), 0644))
+
+	cfg := config.NewDefaultConfig()
+	cfg.CaseInsensitive = true
+	source, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+
+	// The destination and state hold the same entries under their old case
+	dst := &foldingDestination{newMemDestination()}
+	dst.dirs["Docs"] = true
+	dst.files[filepath.Join("Docs", "README.md")] = []byte("read me")
+	dst.files["Foo.txt"] = []byte("old")
+	state := make(map[string]EntryInfo)
+	for path, entry := range source {
+		old := strings.NewReplacer("docs", "Docs", "readme", "README", "foo", "Foo").Replace(path)
+		entry.RelativePath = old
+		if path == "foo.txt" {
+			entry.Size = 3
+		}
+		state[old] = entry
+	}
+
+	actions := CompareStates(source, state, cfg)
+	summary, err := ExecuteActionsTo(context.Background(), srcDir, dst, actions, cfg, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]bool{"docs": true}, dst.dirs)
+	require.Equal(t, map[string][]byte{
+		filepath.Join("docs", "readme.md"): []byte("read me"),
+		"foo.txt":                          []byte("new foo"),
+	}, dst.files, "Expected entries renamed to the source case with their contents kept or updated")
+	require.Equal(t, 3, summary.Renamed)
+	require.Equal(t, 1, summary.FilesUpdated, "Expected only the changed file to be copied")
+	require.Zero(t, summary.FilesDeleted)
+}
+
+func TestRenameCaseUnsupported(t *testing.T) {
+	action := SyncAction{Type: ActionUpdate, RelativePath: "foo.txt", PreviousInfo: EntryInfo{RelativePath: "Foo.txt"}, Reason: ReasonCaseRenamed}
+	require.ErrorIs(t, renameCase(newMemDestination(), action), ErrSyncerRenameUnsupported)
+}
+
+// refusingRenamer is a local destination that refuses renames to refuse.
+type refusingRenamer struct {
+	*LocalDestination
+	refuse string
+}
+
+func (d refusingRenamer) Rename(oldRelPath, newRelPath string) error {
+	if newRelPath == d.refuse {
+		return errors.New("rename refused")
+	}
+	return d.LocalDestination.Rename(oldRelPath, newRelPath)
+}
+
+func TestRenameCaseMovesBackOnFailure(t *testing.T) {
+	dstDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "Foo.txt"), []byte("foo"), 0644))
+	dst := refusingRenamer{LocalDestination: NewLocalDestination(dstDir, config.NewDefaultConfig()), refuse: "foo.txt"}
+
+	action := SyncAction{Type: ActionUpdate, RelativePath: "foo.txt", PreviousInfo: EntryInfo{RelativePath: "Foo.txt"}, Reason: ReasonCaseRenamed}
+	require.Error(t, renameCase(dst, action))
+	require.FileExists(t, filepath.Join(dstDir, "Foo.txt"), "Expected the entry back under its old name")
+	require.NoFileExists(t, filepath.Join(dstDir, fileops.RenamePath("foo.txt")))
+}
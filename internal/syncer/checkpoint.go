@@ -0,0 +1,78 @@
+package syncer
+
+import (
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+// Checkpointer applies completed actions to a state and saves it periodically, so an
+// interrupted run leaves behind a state that already covers the work it finished and
+// the next run only plans the remainder. A nil Checkpointer does nothing.
+type Checkpointer struct {
+	state    *SyncState
+	save     func(*SyncState) error
+	every    int
+	interval time.Duration
+
+	pending  int
+	lastSave time.Time
+}
+
+// NewCheckpointer returns a Checkpointer that saves state with save every
+// cfg.CheckpointActions completed actions or every cfg.CheckpointInterval, whichever
+// comes first. It returns nil when neither is set.
+func NewCheckpointer(state *SyncState, save func(*SyncState) error, cfg *config.Config) *Checkpointer {
+	if cfg.CheckpointActions <= 0 && cfg.CheckpointInterval <= 0 {
+		return nil
+	}
+	return &Checkpointer{
+		state:    state,
+		save:     save,
+		every:    cfg.CheckpointActions,
+		interval: cfg.CheckpointInterval,
+		lastSave: clock.Now(),
+	}
+}
+
+// Record applies a completed action to the state and saves it when a checkpoint is due.
+// Save failures are logged rather than returned; the final state save still happens.
+func (c *Checkpointer) Record(action SyncAction) {
+	if c == nil {
+		return
+	}
+
+	if action.Type == ActionDelete || action.Type == ActionRmdir {
+		delete(c.state.Entries, action.RelativePath)
+	} else {
+		if action.Reason.Has(ReasonCaseRenamed) {
+			// The old path no longer exists; left in the state it would be planned as a delete
+			delete(c.state.Entries, action.PreviousInfo.RelativePath)
+		}
+		c.state.Entries[action.RelativePath] = action.SourceInfo
+	}
+	c.pending++
+
+	due := (c.every > 0 && c.pending >= c.every) ||
+		(c.interval > 0 && since(c.lastSave) >= c.interval)
+	if due {
+		if err := c.Flush(); err != nil {
+			logger.Warn("cannot save checkpoint", "error", err)
+		}
+	}
+}
+
+// Flush saves the state if any actions were recorded since the last save.
+func (c *Checkpointer) Flush() error {
+	if c == nil || c.pending == 0 {
+		return nil
+	}
+	if err := c.save(c.state); err != nil {
+		return err
+	}
+	logger.Debug("saved checkpoint", "actions", c.pending)
+	c.pending = 0
+	c.lastSave = clock.Now()
+	return nil
+}
@@ -0,0 +1,85 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// interruptingDestination fails every copy once its budget of copies is spent.
+type interruptingDestination struct {
+	*memDestination
+	copiesLeft int
+}
+
+func (d *interruptingDestination) Copy(srcPath, relPath string, chunkSize int64) (int64, error) {
+	if d.copiesLeft == 0 {
+		return 0, errInterrupted
+	}
+	d.copiesLeft--
+	return d.memDestination.Copy(srcPath, relPath, chunkSize)
+}
+
+func TestCheckpointResumesInterruptedRun(t *testing.T) {
+	srcDir := t.TempDir()
+	stateDir := t.TempDir()
+	cfg := config.NewDefaultConfig()
+	cfg.CheckpointActions = 2
+
+	for i := range 5 {
+		name := fmt.Sprintf("file%d.txt", i)
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(name), 0644))
+	}
+	save := func(s *SyncState) error { return SaveState(stateDir, s, cfg) }
+
+	// First run is interrupted after three copies
+	state, err := LoadState(stateDir, cfg)
+	require.NoError(t, err)
+	entries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+	dst := &interruptingDestination{memDestination: newMemDestination(), copiesLeft: 3}
+	_, err = ExecuteActionsTo(context.Background(), srcDir, dst, CompareStates(entries, state.Entries, cfg), cfg, NewCheckpointer(state, save, cfg))
+	require.ErrorIs(t, err, errInterrupted)
+
+	saved, err := LoadState(stateDir, cfg)
+	require.NoError(t, err)
+	require.Len(t, saved.Entries, 3, "Expected the checkpoint to cover every completed copy")
+	for path := range saved.Entries {
+		require.Contains(t, dst.files, path, "Expected checkpointed entries to exist on the destination")
+	}
+
+	// Resumed run only plans the remainder
+	entries, err = ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+	var remaining []SyncAction
+	for _, action := range CompareStates(entries, saved.Entries, cfg) {
+		if action.Type != ActionNone {
+			remaining = append(remaining, action)
+		}
+	}
+	require.Len(t, remaining, 2, "Expected only the uncopied files to be planned")
+
+	dst.copiesLeft = -1
+	summary, err := ExecuteActionsTo(context.Background(), srcDir, dst, remaining, cfg, NewCheckpointer(saved, save, cfg))
+	require.NoError(t, err)
+	require.Equal(t, 2, summary.FilesCreated)
+	require.Len(t, dst.files, 5, "Expected the resumed run to complete the sync")
+}
+
+func TestNewCheckpointerDisabled(t *testing.T) {
+	cfg := config.NewDefaultConfig()
+	checkpoint := NewCheckpointer(&SyncState{Entries: map[string]EntryInfo{}}, nil, cfg)
+	require.Nil(t, checkpoint, "Expected no checkpointer without an action count or interval")
+
+	// A nil checkpointer is safe to use
+	checkpoint.Record(SyncAction{Type: ActionCreate, RelativePath: "a"})
+	require.NoError(t, checkpoint.Flush())
+}
@@ -0,0 +1,107 @@
+package syncer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+)
+
+var ErrSyncerChecksumCache = errors.New("syncer: failed to read checksum cache")
+
+// cachedChecksum is a checksum cache record: the full checksum of a file as it was when
+// it had this size and mtime.
+type cachedChecksum struct {
+	Size     int64  `json:"size"`
+	Mtime    int64  `json:"mtime"` // Unix nanoseconds
+	Checksum string `json:"checksum"`
+}
+
+// ChecksumCache holds full file checksums keyed by absolute source path, so that syncs
+// of the same source to different destinations, each with its own state, hash a file
+// only once while it keeps its size and mtime. It is safe for concurrent use.
+type ChecksumCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedChecksum
+	seen    map[string]bool // Paths looked up or stored since loading
+	changed bool
+}
+
+// LoadChecksumCache reads the checksum cache at path. A missing file gives an empty cache.
+func LoadChecksumCache(path string) (*ChecksumCache, error) {
+	cache := &ChecksumCache{entries: make(map[string]cachedChecksum), seen: make(map[string]bool)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyncerChecksumCache, err)
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrSyncerChecksumCache, path, err)
+	}
+	return cache, nil
+}
+
+// Lookup returns the cached checksum of the file at path if it still has the given size
+// and mtime.
+func (c *ChecksumCache) Lookup(path string, size int64, mtime time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen[path] = true
+	entry, ok := c.entries[path]
+	if !ok || entry.Size != size || entry.Mtime != mtime.UnixNano() {
+		return "", false
+	}
+	return entry.Checksum, true
+}
+
+// Store records the checksum of the file at path with its size and mtime.
+func (c *ChecksumCache) Store(path string, size int64, mtime time.Time, checksum string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen[path] = true
+	c.entries[path] = cachedChecksum{Size: size, Mtime: mtime.UnixNano(), Checksum: checksum}
+	c.changed = true
+}
+
+// Save writes the cache to path, replacing it only once fully written. Records of files
+// below root that were neither looked up nor stored since loading are dropped, as the
+// walk of root no longer found them; records of other roots are kept. Nothing is
+// written when the cache is unchanged. Two runs saving at once keep only the records
+// of the last one.
+func (c *ChecksumCache) Save(path, root string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prefix := root + string(filepath.Separator)
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) && !c.seen[key] {
+			delete(c.entries, key)
+			c.changed = true
+		}
+	}
+	if !c.changed {
+		return nil
+	}
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(c.entries)
+	}); err != nil {
+		return err
+	}
+	c.changed = false
+	return nil
+}
+
+// cacheable reports whether the scan's checksums can go into the checksum cache, which
+// holds full checksums only.
+func cacheable(cfg *config.Config) bool {
+	return cfg.ChecksumCache != "" && !cfg.QuickHash && cfg.ChecksumBlockSize == 0
+}
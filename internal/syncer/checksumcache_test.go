@@ -0,0 +1,116 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadChecksumCache(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := LoadChecksumCache(filepath.Join(dir, "missing.json"))
+	require.NoError(t, err)
+	_, ok := cache.Lookup("/src/a.txt", 1, time.Unix(1, 0))
+	require.False(t, ok, "Expected a missing cache file to give an empty cache")
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	require.NoError(t, os.WriteFile(corrupt, []byte("{not json"), 0644))
+	_, err = LoadChecksumCache(corrupt)
+	require.ErrorIs(t, err, ErrSyncerChecksumCache)
+
+	path := filepath.Join(dir, "cache.json")
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC)
+	cache.Store("/src/a.txt", 7, mtime, "abc")
+	cache.Store("/other/b.txt", 3, mtime, "def")
+	require.NoError(t, cache.Save(path, "/src"))
+
+	loaded, err := LoadChecksumCache(path)
+	require.NoError(t, err)
+	checksum, ok := loaded.Lookup("/src/a.txt", 7, mtime)
+	require.True(t, ok)
+	require.Equal(t, "abc", checksum)
+	_, ok = loaded.Lookup("/src/a.txt", 7, mtime.Add(time.Nanosecond))
+	require.False(t, ok, "Expected a changed mtime to miss")
+	_, ok = loaded.Lookup("/src/a.txt", 8, mtime)
+	require.False(t, ok, "Expected a changed size to miss")
+
+	// b.txt belongs to another root, c.txt was never looked up below /src
+	loaded.Store("/src/c.txt", 1, mtime, "123")
+	require.NoError(t, loaded.Save(path, "/src"))
+	fresh, err := LoadChecksumCache(path)
+	require.NoError(t, err)
+	require.Len(t, fresh.entries, 3)
+	require.NoError(t, fresh.Save(path, "/other"))
+	fresh, err = LoadChecksumCache(path)
+	require.NoError(t, err)
+	require.Len(t, fresh.entries, 2, "Expected records below the saved root that were not visited to be dropped")
+	require.Contains(t, fresh.entries, "/src/a.txt")
+}
+
+func TestSyncChecksumCache(t *testing.T) {
+	srcDir := t.TempDir()
+	for i := range 5 {
+		name := filepath.Join(srcDir, fmt.Sprintf("file-%d.txt", i))
+		require.NoError(t, os.WriteFile(name, []byte(fmt.Sprintf("content %d", i)), 0644))
+	}
+	cachePath := filepath.Join(t.TempDir(), "checksums.json")
+
+	var hashed atomic.Int64
+	checksumFile = func(path string, assumeStable bool) ([]byte, error) {
+		hashed.Add(1)
+		return generateChecksum(path, assumeStable)
+	}
+	t.Cleanup(func() { checksumFile = generateChecksum })
+
+	testCases := []struct {
+		name      string
+		modify    func(t *testing.T)
+		streaming bool
+		expected  int64
+	}{
+		{name: "Cold", expected: 5},
+		{name: "Warm", expected: 0},
+		{name: "WarmStreaming", streaming: true, expected: 0},
+		{name: "ChangedFile", modify: func(t *testing.T) {
+			path := filepath.Join(srcDir, "file-2.txt")
+			require.NoError(t, os.WriteFile(path, []byte("changed"), 0644))
+			later := time.Now().Add(time.Hour)
+			require.NoError(t, os.Chtimes(path, later, later))
+		}, expected: 1},
+		{name: "ChangedFileCached", expected: 0},
+	}
+
+	// Every run syncs to a new destination, so no state helps skip hashing
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.modify != nil {
+				tc.modify(t)
+			}
+			hashed.Store(0)
+			cfg := config.NewDefaultConfig()
+			cfg.ChecksumCache = cachePath
+			cfg.Streaming = tc.streaming
+			dstDir := t.TempDir()
+
+			_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, hashed.Load(), "Expected %d files to be hashed", tc.expected)
+
+			state, err := LoadState(dstDir, cfg)
+			require.NoError(t, err)
+			scanned, err := ScanSource(srcDir, config.NewDefaultConfig())
+			require.NoError(t, err)
+			for path, entry := range scanned {
+				require.Equal(t, entry.Checksum, state.Entries[path].Checksum, "Expected the cached checksum of %s to be current", path)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package syncer
+
+import "time"
+
+// Clock tells the current time. The syncer reads the time through clock, so tests can
+// freeze or advance it instead of depending on the wall clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock backed by time.Now.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// clock is the Clock used for timestamps, intervals and elapsed times; tests swap it
+// for a fake one.
+var clock Clock = systemClock{}
+
+// since is time.Since measured on clock.
+func since(t time.Time) time.Duration {
+	return clock.Now().Sub(t)
+}
@@ -0,0 +1,109 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a Clock that stands still until the test moves it.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// useFakeClock makes the syncer read the time from a fake clock set to now for the rest of the test.
+func useFakeClock(t *testing.T, now time.Time) *fakeClock {
+	fake := &fakeClock{now: now}
+	orig := clock
+	clock = fake
+	t.Cleanup(func() { clock = orig })
+	return fake
+}
+
+func TestSaveStateLastSync(t *testing.T) {
+	start := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	fake := useFakeClock(t, start)
+	dstDir := t.TempDir()
+	cfg := config.NewDefaultConfig()
+
+	state, err := LoadState(dstDir, cfg)
+	require.NoError(t, err)
+	require.Equal(t, start.UnixMilli(), state.LastSync, "Expected a fresh state to be stamped with the clock")
+
+	fake.Advance(90 * time.Minute)
+	require.NoError(t, SaveState(dstDir, state, cfg))
+	loaded, err := LoadState(dstDir, cfg)
+	require.NoError(t, err)
+	require.Equal(t, start.Add(90*time.Minute).UnixMilli(), loaded.LastSync)
+}
+
+func TestScanSourceFutureThreshold(t *testing.T) {
+	mtime := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	srcDir := t.TempDir()
+	path := filepath.Join(srcDir, "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0644))
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+
+	testCases := []struct {
+		name     string
+		now      time.Time
+		expected time.Time
+	}{
+		{name: "WithinThreshold", now: mtime.Add(-59 * time.Minute), expected: mtime},
+		{name: "AtThreshold", now: mtime.Add(-time.Hour), expected: mtime},
+		{name: "BeyondThreshold", now: mtime.Add(-61 * time.Minute), expected: mtime.Add(-61 * time.Minute)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			useFakeClock(t, tc.now)
+			cfg := config.NewDefaultConfig()
+			cfg.FutureMtimes, cfg.FutureThreshold = config.FutureMtimesClamp, time.Hour
+
+			entries, err := ScanSource(srcDir, cfg)
+			require.NoError(t, err)
+			require.True(t, tc.expected.Equal(entries["file.txt"].Mtime), "got mtime %v", entries["file.txt"].Mtime)
+		})
+	}
+}
+
+func TestCheckpointerInterval(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	cfg := config.NewDefaultConfig()
+	cfg.CheckpointInterval = time.Minute
+	saves := 0
+	checkpoint := NewCheckpointer(&SyncState{Entries: map[string]EntryInfo{}}, func(*SyncState) error {
+		saves++
+		return nil
+	}, cfg)
+
+	checkpoint.Record(SyncAction{Type: ActionCreate, RelativePath: "a.txt"})
+	require.Equal(t, 0, saves, "Expected no checkpoint before the interval passed")
+
+	fake.Advance(time.Minute)
+	checkpoint.Record(SyncAction{Type: ActionCreate, RelativePath: "b.txt"})
+	require.Equal(t, 1, saves)
+
+	fake.Advance(30 * time.Second)
+	checkpoint.Record(SyncAction{Type: ActionCreate, RelativePath: "c.txt"})
+	require.Equal(t, 1, saves, "Expected the interval to restart at the last checkpoint")
+}
@@ -0,0 +1,75 @@
+package syncer
+
+// appliedAction is an action ExecuteActionsFrom has started, with what it needs to
+// account for the action once it finishes.
+type appliedAction struct {
+	action           SyncAction
+	bytes            int64 // Bytes the action copies, for progress
+	deferredChecksum bool  // The checksum was left for the copy to fill in
+}
+
+// copyResult is a finished copy and its error.
+type copyResult struct {
+	done appliedAction
+	err  error
+}
+
+// copyPool runs file copies for ExecuteActionsFrom on up to a fixed number of goroutines.
+// Copies are started and their results handled on the caller's goroutine, so only the
+// copy itself runs concurrently.
+type copyPool struct {
+	workers  int
+	results  chan copyResult
+	running  int
+	inFlight int64 // Bytes of the copies still running
+}
+
+func newCopyPool(workers int) *copyPool {
+	return &copyPool{workers: workers, results: make(chan copyResult)}
+}
+
+// parallelCopy reports whether the action may run alongside others: a plain file copy.
+// Links and case-only renames, which touch more than their own path, run alone.
+func parallelCopy(action SyncAction) bool {
+	return isFileCopy(action) && action.SourceInfo.SymlinkTarget == "" && !action.Reason.Has(ReasonCaseRenamed)
+}
+
+// free waits until a worker is free, handing the copies that finish meanwhile to
+// finish, and returns the first error finish returns.
+func (p *copyPool) free(finish func(appliedAction, error) error) error {
+	for p.running >= p.workers {
+		if err := p.next(finish); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// start runs apply for done on a new goroutine. Call free first.
+func (p *copyPool) start(done appliedAction, apply func(*SyncAction) error) {
+	p.running++
+	p.inFlight += done.bytes
+	go func() {
+		err := apply(&done.action)
+		p.results <- copyResult{done: done, err: err}
+	}()
+}
+
+// wait hands every running copy to finish once it is done and returns the first error
+// finish returned.
+func (p *copyPool) wait(finish func(appliedAction, error) error) error {
+	var first error
+	for p.running > 0 {
+		if err := p.next(finish); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (p *copyPool) next(finish func(appliedAction, error) error) error {
+	result := <-p.results
+	p.running--
+	p.inFlight -= result.done.bytes
+	return finish(result.done, result.err)
+}
@@ -0,0 +1,88 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrencyCounter tracks how many calls are running at once and the most seen.
+type concurrencyCounter struct {
+	active, peak atomic.Int64
+}
+
+// enter counts a call as running, holds it long enough for others to overlap, and
+// counts it as done.
+func (c *concurrencyCounter) enter() {
+	n := c.active.Add(1)
+	for {
+		p := c.peak.Load()
+		if n <= p || c.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	c.active.Add(-1)
+}
+
+func TestSyncWorkerLimits(t *testing.T) {
+	srcDir := t.TempDir()
+	for i := range 12 {
+		name := filepath.Join(srcDir, fmt.Sprintf("file-%02d.txt", i))
+		require.NoError(t, os.WriteFile(name, []byte(fmt.Sprintf("content %d", i)), 0644))
+	}
+
+	var hashes, copies concurrencyCounter
+	checksumFile = func(path string, assumeStable bool) ([]byte, error) {
+		hashes.enter()
+		return generateChecksum(path, assumeStable)
+	}
+	injectFault = func(string) error {
+		copies.enter()
+		return nil
+	}
+	t.Cleanup(func() { checksumFile, injectFault = generateChecksum, nil })
+
+	testCases := []struct {
+		name        string
+		hashWorkers int
+		copyWorkers int
+	}{
+		{name: "MoreCopiers", hashWorkers: 1, copyWorkers: 3},
+		{name: "MoreHashers", hashWorkers: 3, copyWorkers: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hashes.peak.Store(0)
+			copies.peak.Store(0)
+			dstDir := t.TempDir()
+			cfg := config.NewDefaultConfig()
+			cfg.HashWorkers, cfg.CopyWorkers = tc.hashWorkers, tc.copyWorkers
+			cfg.HashParallelThreshold = 1
+
+			summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+			require.Equal(t, 12, summary.FilesCreated)
+			got, err := os.ReadFile(filepath.Join(dstDir, "file-07.txt"))
+			require.NoError(t, err)
+			require.Equal(t, "content 7", string(got))
+
+			require.LessOrEqual(t, hashes.peak.Load(), int64(tc.hashWorkers), "Expected at most %d concurrent hashers", tc.hashWorkers)
+			require.LessOrEqual(t, copies.peak.Load(), int64(tc.copyWorkers), "Expected at most %d concurrent copies", tc.copyWorkers)
+			if tc.copyWorkers > 1 {
+				require.Greater(t, copies.peak.Load(), int64(1), "Expected copies to run concurrently")
+			}
+			if tc.hashWorkers > 1 {
+				require.Greater(t, hashes.peak.Load(), int64(1), "Expected hashing to run concurrently")
+			}
+		})
+	}
+}
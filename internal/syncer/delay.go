@@ -0,0 +1,60 @@
+package syncer
+
+import (
+	"maps"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+)
+
+// DelayDeletes holds back the deletes of paths that have not been missing from the
+// source for cfg.DeleteDelay yet, so a source that is briefly unavailable does not wipe
+// the destination. A path is marked in state.PendingDeletes on the first run that plans
+// its delete, and the delete only goes ahead on a later run once the delay has passed
+// since. PendingDeletes is rewritten to hold the paths of this run's deletes, so a
+// delete that fails stays due while a path that is back in the source starts over
+// should it go missing again. The paths of held actions are returned so callers can
+// keep their recorded state (see ReconcileEntries).
+func DelayDeletes(actions []SyncAction, state *SyncState, cfg *config.Config) ([]SyncAction, []string) {
+	if cfg.DeleteDelay <= 0 {
+		return actions, nil
+	}
+
+	now := clock.Now()
+	pending := make(map[string]int64)
+	kept := make([]SyncAction, 0, len(actions))
+	var held []string
+	for _, action := range actions {
+		if action.Type != ActionDelete && action.Type != ActionRmdir {
+			kept = append(kept, action)
+			continue
+		}
+		since, ok := state.PendingDeletes[action.RelativePath]
+		if !ok {
+			since = now.UnixMilli()
+		}
+		pending[action.RelativePath] = since
+		if now.Sub(time.UnixMilli(since)) >= cfg.DeleteDelay {
+			kept = append(kept, action)
+			continue
+		}
+		held = append(held, action.RelativePath)
+	}
+	state.PendingDeletes = pending
+	if len(pending) == 0 {
+		state.PendingDeletes = nil
+	}
+	return kept, held
+}
+
+// prunePendingDeletes forgets the pending deletes of paths that are no longer in the
+// state, as the run deleted them.
+func prunePendingDeletes(state *SyncState) {
+	maps.DeleteFunc(state.PendingDeletes, func(path string, _ int64) bool {
+		_, ok := state.Entries[path]
+		return !ok
+	})
+	if len(state.PendingDeletes) == 0 {
+		state.PendingDeletes = nil
+	}
+}
@@ -0,0 +1,75 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncDeleteDelay(t *testing.T) {
+	start := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Streaming=%t", streaming), func(t *testing.T) {
+			fake := useFakeClock(t, start)
+			srcDir, dstDir := t.TempDir(), t.TempDir()
+			srcFile, dstFile := filepath.Join(srcDir, "gone.txt"), filepath.Join(dstDir, "gone.txt")
+			require.NoError(t, os.WriteFile(srcFile, []byte("content"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "stays.txt"), []byte("stays"), 0644))
+			cfg := config.NewDefaultConfig()
+			cfg.DeleteDelay = time.Hour
+			cfg.Streaming = streaming
+			sync := func(t *testing.T) (*Summary, *SyncState) {
+				summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+				require.NoError(t, err)
+				state, err := LoadState(dstDir, cfg)
+				require.NoError(t, err)
+				return summary, state
+			}
+			sync(t)
+
+			// Missing on the first run: marked, not deleted
+			require.NoError(t, os.Remove(srcFile))
+			summary, state := sync(t)
+			require.Zero(t, summary.FilesDeleted)
+			require.FileExists(t, dstFile)
+			require.Equal(t, map[string]int64{"gone.txt": start.UnixMilli()}, state.PendingDeletes)
+			require.Contains(t, state.Entries, "gone.txt", "Expected a held delete to stay recorded")
+
+			// Back before the delay passed: never deleted, and no longer pending
+			fake.Advance(30 * time.Minute)
+			require.NoError(t, os.WriteFile(srcFile, []byte("content"), 0644))
+			_, state = sync(t)
+			require.FileExists(t, dstFile)
+			require.Empty(t, state.PendingDeletes)
+
+			// Missing again: the delay starts over
+			fake.Advance(40 * time.Minute)
+			require.NoError(t, os.Remove(srcFile))
+			marked := clock.Now()
+			summary, state = sync(t)
+			require.Zero(t, summary.FilesDeleted)
+			require.Equal(t, map[string]int64{"gone.txt": marked.UnixMilli()}, state.PendingDeletes)
+
+			fake.Advance(59 * time.Minute)
+			summary, _ = sync(t)
+			require.Zero(t, summary.FilesDeleted, "Expected no delete before the delay passed")
+			require.FileExists(t, dstFile)
+
+			// Still missing once the delay passed: deleted
+			fake.Advance(time.Minute)
+			summary, state = sync(t)
+			require.Equal(t, 1, summary.FilesDeleted)
+			require.NoFileExists(t, dstFile)
+			require.FileExists(t, filepath.Join(dstDir, "stays.txt"))
+			require.Empty(t, state.PendingDeletes)
+			require.NotContains(t, state.Entries, "gone.txt")
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package syncer
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
+)
+
+// Destination is the target ExecuteActionsTo applies actions to. Paths are relative to
+// the destination root; srcPath in Copy is a local source path.
+//
+// Implementations may additionally provide:
+//   - Checksum(relPath string) (string, error) so checksum mode can skip identical files
+//   - FreeSpace() (uint64, error) so the reserve-space check can run
+//   - Chtimes(relPath string, mtime time.Time) error so directory mtimes can be preserved
+//   - Rename(oldRelPath, newRelPath string) error so case-only renames can be applied
+//   - Append(srcPath, relPath string, fromOffset int64) (int64, error) so files that
+//     only grew can be updated by appending their tail
+//   - Chmod(relPath string, mode fs.FileMode) error so permission-only changes can be applied
+//   - Symlink(target, relPath string) error so symlinks can be preserved
+//   - CopyLimited(srcPath, relPath string, chunkSize int64, limitKBps int) (int64, error)
+//     so copies can be throttled per file (see config.BandwidthRules)
+//   - CopyChecksum(srcPath, relPath string, chunkSize int64) (int64, string, error)
+//     so a copy can hash the source as it reads it (see config.ChecksumOnCopy)
+type Destination interface {
+	// Copy writes the file at srcPath to relPath, creating parents, and returns the bytes written.
+	Copy(srcPath, relPath string, chunkSize int64) (int64, error)
+	// Mkdir creates relPath and any missing parents.
+	Mkdir(relPath string) error
+	// Delete removes relPath recursively; a missing path is not an error.
+	Delete(relPath string) error
+	// Stat describes relPath.
+	Stat(relPath string) (fs.FileInfo, error)
+	// Exists reports whether relPath exists.
+	Exists(relPath string) (bool, error)
+}
+
+type checksummer interface {
+	Checksum(relPath string) (string, error)
+}
+
+type spaceReporter interface {
+	FreeSpace() (uint64, error)
+}
+
+type timeSetter interface {
+	Chtimes(relPath string, mtime time.Time) error
+}
+
+type renamer interface {
+	Rename(oldRelPath, newRelPath string) error
+}
+
+type limitedCopier interface {
+	CopyLimited(srcPath, relPath string, chunkSize int64, limitKBps int) (int64, error)
+}
+
+type checksumCopier interface {
+	CopyChecksum(srcPath, relPath string, chunkSize int64) (int64, string, error)
+}
+
+type symlinker interface {
+	Symlink(target, relPath string) error
+}
+
+type chmoder interface {
+	Chmod(relPath string, mode fs.FileMode) error
+}
+
+type appender interface {
+	Append(srcPath, relPath string, fromOffset int64) (int64, error)
+}
+
+// LocalDestination is the default Destination, backed by fileops on a local directory.
+type LocalDestination struct {
+	root      string
+	copyOpts  fileops.CopyOptions // Sparse, resumable, paced and throttled copies, from the config
+	longPaths bool                // Address entries with extended-length paths on Windows
+	// preserveSpecial keeps the setuid, setgid and sticky bits in Chmod
+	preserveSpecial bool
+}
+
+func NewLocalDestination(root string, cfg *config.Config) *LocalDestination {
+	return &LocalDestination{root: root, copyOpts: fileops.CopyOptions{
+		Sparse:              cfg.Sparse,
+		Preallocate:         cfg.Preallocate,
+		Resume:              cfg.Resume,
+		ChunkPause:          cfg.ChunkPause,
+		BatchThreshold:      cfg.BatchThreshold,
+		QueueDepth:          cfg.CopyQueueDepth,
+		LimitKBps:           cfg.BandwidthLimit,
+		PreserveSpecialBits: cfg.PreserveSpecialBits,
+		IORetries:           cfg.IORetries,
+	}, longPaths: cfg.LongPaths, preserveSpecial: cfg.PreserveSpecialBits}
+}
+
+func (d *LocalDestination) path(relPath string) string {
+	if d.longPaths {
+		return extendedLengthPath(filepath.Join(d.root, relPath))
+	}
+	return filepath.Join(d.root, relPath)
+}
+
+func (d *LocalDestination) Root() string { return d.root }
+
+// StateFS returns the filesystem the state file is kept on.
+func (d *LocalDestination) StateFS() StateFS { return stateFS }
+
+func (d *LocalDestination) Copy(srcPath, relPath string, chunkSize int64) (int64, error) {
+	return fileops.CopyFileWith(srcPath, d.path(relPath), chunkSize, d.copyOpts)
+}
+
+// CopyLimited copies like Copy, kept under limitKBps KB/s (0 for unlimited).
+func (d *LocalDestination) CopyLimited(srcPath, relPath string, chunkSize int64, limitKBps int) (int64, error) {
+	opts := d.copyOpts
+	opts.LimitKBps = limitKBps
+	return fileops.CopyFileWith(srcPath, d.path(relPath), chunkSize, opts)
+}
+
+// CopyChecksum copies like Copy and also returns the checksum of what it copied.
+func (d *LocalDestination) CopyChecksum(srcPath, relPath string, chunkSize int64) (int64, string, error) {
+	return fileops.CopyFileChecksum(srcPath, d.path(relPath), chunkSize, d.copyOpts)
+}
+
+func (d *LocalDestination) Mkdir(relPath string) error {
+	_, err := fileops.CreateDir(d.path(relPath))
+	return err
+}
+
+func (d *LocalDestination) Delete(relPath string) error {
+	_, err := fileops.DeletePath(d.path(relPath))
+	return err
+}
+
+func (d *LocalDestination) Stat(relPath string) (fs.FileInfo, error) {
+	return os.Stat(d.path(relPath))
+}
+
+func (d *LocalDestination) Exists(relPath string) (bool, error) {
+	return fileops.PathExists(d.path(relPath))
+}
+
+func (d *LocalDestination) Checksum(relPath string) (string, error) {
+	checksum, err := generateChecksum(d.path(relPath), false)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(checksum), nil
+}
+
+func (d *LocalDestination) FreeSpace() (uint64, error) {
+	return freeSpace(d.root)
+}
+
+// Chtimes sets both the access and modification time of relPath to mtime.
+func (d *LocalDestination) Chtimes(relPath string, mtime time.Time) error {
+	return os.Chtimes(d.path(relPath), mtime, mtime)
+}
+
+// Rename moves oldRelPath to newRelPath.
+func (d *LocalDestination) Rename(oldRelPath, newRelPath string) error {
+	return os.Rename(d.path(oldRelPath), d.path(newRelPath))
+}
+
+// Symlink creates relPath as a symlink to target, replacing a file or link already there.
+func (d *LocalDestination) Symlink(target, relPath string) error {
+	path := d.path(relPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Symlink(target, path)
+}
+
+// Chmod sets the permission bits of relPath to those of mode, and with
+// config.PreserveSpecialBits its setuid, setgid and sticky bits as well.
+func (d *LocalDestination) Chmod(relPath string, mode fs.FileMode) error {
+	if d.preserveSpecial {
+		return fileops.SetMode(d.path(relPath), mode)
+	}
+	return os.Chmod(d.path(relPath), mode.Perm())
+}
+
+// Append writes the part of srcPath past fromOffset to the end of relPath, which must
+// hold exactly the first fromOffset bytes of srcPath. See fileops.AppendCopy.
+func (d *LocalDestination) Append(srcPath, relPath string, fromOffset int64) (int64, error) {
+	return fileops.AppendCopy(srcPath, d.path(relPath), fromOffset)
+}
@@ -0,0 +1,86 @@
+package syncer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// memDestination is an in-memory Destination for executor tests.
+type memDestination struct {
+	files map[string][]byte
+	dirs  map[string]bool
+}
+
+func newMemDestination() *memDestination {
+	return &memDestination{files: make(map[string][]byte), dirs: make(map[string]bool)}
+}
+
+func (m *memDestination) Copy(srcPath, relPath string, _ int64) (int64, error) {
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	if parent := filepath.Dir(relPath); parent != "." {
+		_ = m.Mkdir(parent)
+	}
+	m.files[relPath] = data
+	return int64(len(data)), nil
+}
+
+func (m *memDestination) Mkdir(relPath string) error {
+	for dir := relPath; dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		m.dirs[dir] = true
+	}
+	return nil
+}
+
+func (m *memDestination) Delete(relPath string) error {
+	prefix := relPath + string(filepath.Separator)
+	for path := range m.files {
+		if path == relPath || strings.HasPrefix(path, prefix) {
+			delete(m.files, path)
+		}
+	}
+	for path := range m.dirs {
+		if path == relPath || strings.HasPrefix(path, prefix) {
+			delete(m.dirs, path)
+		}
+	}
+	return nil
+}
+
+func (m *memDestination) Stat(relPath string) (fs.FileInfo, error) {
+	if data, ok := m.files[relPath]; ok {
+		return memFileInfo{name: filepath.Base(relPath), size: int64(len(data))}, nil
+	}
+	if m.dirs[relPath] {
+		return memFileInfo{name: filepath.Base(relPath), dir: true}, nil
+	}
+	return nil, fs.ErrNotExist
+}
+
+func (m *memDestination) Exists(relPath string) (bool, error) {
+	_, err := m.Stat(relPath)
+	return err == nil, nil
+}
+
+type memFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return i.dir }
+func (i memFileInfo) Sys() any           { return nil }
+func (i memFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
@@ -0,0 +1,53 @@
+package syncer
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+	"github.com/ogzhanolguncu/mimic/internal/report"
+)
+
+// dupeKey identifies file content for FindDuplicates.
+type dupeKey struct {
+	checksum string
+	size     int64
+}
+
+// FindDuplicates groups the files in entries that share a checksum and size. Each group
+// is sorted and the groups are ordered by their first path. Empty files, symlinks, files
+// without a checksum and quick fingerprints (see EntryInfo.QuickHash), which cannot prove
+// equal content, are left out.
+func FindDuplicates(entries map[string]EntryInfo) [][]string {
+	byContent := make(map[dupeKey][]string)
+	for path, entry := range entries {
+		if entry.IsDir || entry.Size == 0 || entry.Checksum == "" || entry.QuickHash || entry.SymlinkTarget != "" {
+			continue
+		}
+		key := dupeKey{checksum: entry.Checksum, size: entry.Size}
+		byContent[key] = append(byContent[key], path)
+	}
+
+	var groups [][]string
+	for _, paths := range byContent {
+		if len(paths) > 1 {
+			slices.Sort(paths)
+			groups = append(groups, paths)
+		}
+	}
+	slices.SortFunc(groups, func(a, b []string) int { return strings.Compare(a[0], b[0]) })
+	return groups
+}
+
+// reportDuplicates logs every group of duplicate source files and the space taken by
+// all but one copy of each.
+func reportDuplicates(entries map[string]EntryInfo) {
+	groups := FindDuplicates(entries)
+	var wasted int64
+	for _, group := range groups {
+		size := entries[group[0]].Size
+		wasted += size * int64(len(group)-1)
+		logger.Info("Duplicate files", "paths", group, "size", report.FormatSize(size))
+	}
+	logger.Info("Duplicate scan finished", "groups", len(groups), "wasted", report.FormatSize(wasted))
+}
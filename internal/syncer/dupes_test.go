@@ -0,0 +1,46 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	srcDir := t.TempDir()
+	files := map[string]string{
+		"a.txt":            "shared content",
+		"copies/a.txt":     "shared content",
+		"copies/b.txt":     "shared content",
+		"unique.txt":       "only once",
+		"pair/one.bin":     "twin",
+		"pair/two.bin":     "twin",
+		"empty1.txt":       "",
+		"empty2.txt":       "",
+		"nested/other.txt": "shared content!",
+	}
+	for name, content := range files {
+		path := filepath.Join(srcDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	entries, err := ScanSource(srcDir, config.NewDefaultConfig())
+	require.NoError(t, err)
+
+	require.Equal(t, [][]string{
+		{"a.txt", filepath.Join("copies", "a.txt"), filepath.Join("copies", "b.txt")},
+		{filepath.Join("pair", "one.bin"), filepath.Join("pair", "two.bin")},
+	}, FindDuplicates(entries))
+
+	t.Run("QuickFingerprintsIgnored", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.QuickHash = true
+		entries, err := ScanSource(srcDir, cfg)
+		require.NoError(t, err)
+		require.Empty(t, FindDuplicates(entries))
+	})
+}
@@ -0,0 +1,30 @@
+package syncer
+
+// Operations a SyncError can report.
+const (
+	OpScan     = "scan"
+	OpChecksum = "checksum"
+	OpMkdir    = "mkdir"
+	OpCopy     = "copy"
+	OpDelete   = "delete"
+	OpRename   = "rename"
+	OpChmod    = "chmod"
+	OpSymlink  = "symlink"
+)
+
+// SyncError is a failure on a single entry: the operation, the path relative to the
+// source or destination root, and the underlying error. errors.Is and errors.As see
+// through it, so the ErrSyncer* sentinels and fs errors still match.
+type SyncError struct {
+	Op   string // One of the Op* constants
+	Path string
+	Err  error
+}
+
+func (e *SyncError) Error() string {
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
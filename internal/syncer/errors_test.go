@@ -0,0 +1,120 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncError(t *testing.T) {
+	err := fmt.Errorf("outer: %w", &SyncError{Op: OpChecksum, Path: "a.txt", Err: fmt.Errorf("%w: %w", ErrSyncerChecksum, fs.ErrPermission)})
+
+	require.ErrorIs(t, err, ErrSyncerChecksum, "Expected the sentinel to match through the SyncError")
+	require.ErrorIs(t, err, fs.ErrPermission)
+	var syncErr *SyncError
+	require.ErrorAs(t, err, &syncErr)
+	require.Equal(t, OpChecksum, syncErr.Op)
+	require.Equal(t, "a.txt", syncErr.Path)
+	require.Equal(t, "checksum a.txt: syncer: checksum calculation failed: permission denied", syncErr.Error())
+}
+
+func TestExecuteActionsSyncError(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "file.txt"), []byte("payload"), 0644))
+	cfg := config.NewDefaultConfig()
+	entries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+	actions := append(CompareStates(entries, map[string]EntryInfo{}, cfg),
+		SyncAction{Type: ActionDelete, RelativePath: "stale.txt"})
+	denied := fmt.Errorf("wrapped: %w", fs.ErrPermission)
+
+	t.Run("FailFast", func(t *testing.T) {
+		dst := &flakyDestination{memDestination: newMemDestination(), failures: 100, err: denied, attempts: map[string]int{}}
+
+		_, err := ExecuteActionsTo(context.Background(), srcDir, dst, actions, cfg, nil)
+		var syncErr *SyncError
+		require.ErrorAs(t, err, &syncErr)
+		require.Equal(t, &SyncError{Op: OpMkdir, Path: "dir", Err: denied}, syncErr)
+		require.ErrorIs(t, err, fs.ErrPermission)
+	})
+
+	t.Run("Collected", func(t *testing.T) {
+		dst := &flakyDestination{memDestination: newMemDestination(), failures: 100, err: denied, attempts: map[string]int{}}
+		cfg := config.NewDefaultConfig()
+		cfg.ContinueOnError = true
+
+		_, err := ExecuteActionsTo(context.Background(), srcDir, dst, actions, cfg, nil)
+		require.ErrorIs(t, err, ErrSyncerActionsFailed)
+
+		var failed []SyncError
+		for _, wrapped := range err.(interface{ Unwrap() []error }).Unwrap() {
+			joined, ok := wrapped.(interface{ Unwrap() []error })
+			if !ok {
+				continue
+			}
+			for _, e := range joined.Unwrap() {
+				var syncErr *SyncError
+				require.ErrorAs(t, e, &syncErr)
+				failed = append(failed, SyncError{Op: syncErr.Op, Path: syncErr.Path})
+			}
+		}
+		require.Equal(t, []SyncError{
+			{Op: OpMkdir, Path: "dir"},
+			{Op: OpCopy, Path: "file.txt"},
+			{Op: OpDelete, Path: "stale.txt"},
+		}, failed, "Expected one SyncError per failed action")
+	})
+}
+
+func TestExecuteActionsPathTooLong(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "short.txt"), []byte("payload"), 0644))
+	cfg := config.NewDefaultConfig()
+	cfg.ContinueOnError = true
+	entries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+
+	// No file system takes a name this long
+	longName := strings.Repeat("n", 300)
+	actions := append([]SyncAction{{Type: ActionMkdir, RelativePath: longName, SourceInfo: EntryInfo{RelativePath: longName, IsDir: true}}},
+		CompareStates(entries, map[string]EntryInfo{}, cfg)...)
+
+	summary, err := ExecuteActions(srcDir, dstDir, actions, cfg)
+	require.ErrorIs(t, err, ErrSyncerActionsFailed)
+	require.ErrorIs(t, err, ErrSyncerPathTooLong, "Expected the failure to be reported as a too-long path")
+	var syncErr *SyncError
+	require.ErrorAs(t, err, &syncErr)
+	require.Equal(t, OpMkdir, syncErr.Op)
+	require.Equal(t, longName, syncErr.Path)
+
+	require.Equal(t, []string{longName}, summary.Failed)
+	require.Equal(t, 1, summary.FilesCreated, "Expected the rest of the run to go on")
+	require.FileExists(t, filepath.Join(dstDir, "short.txt"))
+}
+
+func TestScanSourceSyncError(t *testing.T) {
+	defer func(prev bool) { windowsTarget = prev }(windowsTarget)
+	windowsTarget = true
+
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "dir"), 0755))
+	if err := os.WriteFile(filepath.Join(srcDir, "dir", "nul"), []byte("x"), 0644); err != nil {
+		t.Skipf("cannot create a reserved name here: %v", err)
+	}
+
+	_, err := ScanSource(srcDir, config.NewDefaultConfig())
+	require.ErrorIs(t, err, ErrSyncerDirWalk)
+	require.ErrorIs(t, err, ErrSyncerWindowsName)
+	var syncErr *SyncError
+	require.ErrorAs(t, err, &syncErr)
+	require.Equal(t, OpScan, syncErr.Op)
+	require.Equal(t, filepath.Join("dir", "nul"), syncErr.Path)
+}
@@ -0,0 +1,223 @@
+package syncer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/report"
+)
+
+// noChecksum stands in for the checksum column of directories in a text manifest.
+const noChecksum = "-"
+
+// WriteManifest writes entries as a portable text manifest, one entry per line sorted by
+// path:
+//
+//	"relpath" size mode checksum
+//
+// relpath is a Go-quoted, slash-separated path, mode is in ls form (-rw-r--r--,
+// drwxr-xr-x) and checksum is the hex xxhash64. Directories have size 0 and checksum "-".
+func WriteManifest(w io.Writer, entries map[string]EntryInfo) error {
+	bw := bufio.NewWriter(w)
+	for _, path := range slices.Sorted(maps.Keys(entries)) {
+		entry := entries[path]
+		size, mode, checksum := entry.Size, entry.Permissions.Perm(), entry.Checksum
+		if entry.IsDir {
+			// Directory sizes are filesystem specific, so they are not recorded
+			size, mode, checksum = 0, mode|fs.ModeDir, ""
+		}
+		if checksum == "" || entry.QuickHash {
+			// A quick fingerprint would be mistaken for a content checksum by readers
+			checksum = noChecksum
+		}
+		if _, err := fmt.Fprintf(bw, "%s %d %s %s\n",
+			strconv.Quote(filepath.ToSlash(path)), size, mode, checksum); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadManifest parses a manifest written by WriteManifest back into entries keyed by
+// relative path. Only the fields stored in the manifest are populated.
+func ReadManifest(r io.Reader) (map[string]EntryInfo, error) {
+	entries := make(map[string]EntryInfo)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		entry, err := parseManifestLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrManifestParse, lineNo, err)
+		}
+		entries[entry.RelativePath] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrManifestRead, err)
+	}
+	return entries, nil
+}
+
+func parseManifestLine(line string) (EntryInfo, error) {
+	quoted, err := strconv.QuotedPrefix(line)
+	if err != nil {
+		return EntryInfo{}, fmt.Errorf("bad path: %v", err)
+	}
+	path, _ := strconv.Unquote(quoted)
+
+	fields := strings.Fields(line[len(quoted):])
+	if len(fields) != 3 {
+		return EntryInfo{}, fmt.Errorf("expected size, mode and checksum after path")
+	}
+	size, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || size < 0 {
+		return EntryInfo{}, fmt.Errorf("bad size %q", fields[0])
+	}
+	mode, err := parseModeString(fields[1])
+	if err != nil {
+		return EntryInfo{}, err
+	}
+
+	entry := EntryInfo{
+		RelativePath: filepath.Clean(filepath.FromSlash(path)),
+		Size:         size,
+		IsDir:        mode.IsDir(),
+		Permissions:  mode,
+	}
+	if fields[2] != noChecksum {
+		entry.Checksum = fields[2]
+	}
+	return entry, nil
+}
+
+// parseModeString parses the ls-style mode written by WriteManifest.
+func parseModeString(s string) (fs.FileMode, error) {
+	const perms = "rwxrwxrwx"
+	if len(s) != 1+len(perms) || (s[0] != 'd' && s[0] != '-') {
+		return 0, fmt.Errorf("bad mode %q", s)
+	}
+
+	var mode fs.FileMode
+	if s[0] == 'd' {
+		mode |= fs.ModeDir
+	}
+	for i, c := range s[1:] {
+		switch byte(c) {
+		case perms[i]:
+			mode |= 1 << (len(perms) - 1 - i)
+		case '-':
+		default:
+			return 0, fmt.Errorf("bad mode %q", s)
+		}
+	}
+	return mode, nil
+}
+
+// Mismatch is one difference found by VerifyManifest or VerifyState.
+type Mismatch struct {
+	Path    string
+	Problem string
+}
+
+// VerifyManifest compares a scan against the entries of a manifest and returns the
+// differences sorted by path. Modification times are not compared.
+func VerifyManifest(expected, scanned map[string]EntryInfo) []Mismatch {
+	return compareEntries(expected, scanned, true, "not in manifest")
+}
+
+// compareEntries reports every expected entry that is missing or differs in the scan,
+// then every scanned entry that was not expected, described as extraProblem.
+// Permissions are only compared with checkMode.
+func compareEntries(expected, scanned map[string]EntryInfo, checkMode bool, extraProblem string) []Mismatch {
+	var mismatches []Mismatch
+	for _, path := range slices.Sorted(maps.Keys(expected)) {
+		want := expected[path]
+		got, ok := scanned[path]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, Mismatch{Path: path, Problem: "missing"})
+		case want.IsDir != got.IsDir:
+			mismatches = append(mismatches, Mismatch{Path: path, Problem: "type differs"})
+		case want.IsDir:
+			// Directories only need to exist
+		case want.Size != got.Size:
+			mismatches = append(mismatches, Mismatch{Path: path, Problem: fmt.Sprintf("size %d, expected %d", got.Size, want.Size)})
+		case want.Checksum != "" && want.QuickHash == got.QuickHash && want.Checksum != got.Checksum:
+			problem := "checksum differs"
+			if blocks := divergedBlocks(want, got); len(blocks) > 0 {
+				problem += fmt.Sprintf(" in %d of %d blocks of %s: %s", len(blocks), len(want.BlockChecksums),
+					report.FormatSize(want.BlockSize), joinInts(blocks))
+			}
+			mismatches = append(mismatches, Mismatch{Path: path, Problem: problem})
+		case checkMode && want.Permissions.Perm() != got.Permissions.Perm():
+			mismatches = append(mismatches, Mismatch{Path: path, Problem: fmt.Sprintf("mode %s, expected %s", got.Permissions.Perm(), want.Permissions.Perm())})
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(scanned)) {
+		if _, ok := expected[path]; !ok {
+			mismatches = append(mismatches, Mismatch{Path: path, Problem: extraProblem})
+		}
+	}
+	slices.SortStableFunc(mismatches, func(a, b Mismatch) int { return strings.Compare(a.Path, b.Path) })
+	return mismatches
+}
+
+// divergedBlocks returns the indexes of the blocks whose checksums differ between two
+// entries hashed with the same block size, or nil when their blocks cannot be compared.
+func divergedBlocks(want, got EntryInfo) []int {
+	if want.BlockSize == 0 || want.BlockSize != got.BlockSize || len(want.BlockChecksums) != len(got.BlockChecksums) {
+		return nil
+	}
+	var diverged []int
+	for i, checksum := range want.BlockChecksums {
+		if checksum != got.BlockChecksums[i] {
+			diverged = append(diverged, i)
+		}
+	}
+	return diverged
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeManifestFile exports the scan to path, replacing it only once fully written.
+func writeManifestFile(path string, entries map[string]EntryInfo) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return WriteManifest(w, entries)
+	})
+}
+
+// writeFileAtomic writes path through a temporary file so readers never see a
+// partially written file.
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tempFile := path + ".tmp"
+	file, err := os.Create(tempFile)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		_ = file.Close()
+		_ = os.Remove(tempFile)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(tempFile)
+		return err
+	}
+	return os.Rename(tempFile, path)
+}
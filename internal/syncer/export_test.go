@@ -0,0 +1,91 @@
+package syncer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "sub dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "sub dir", "na\"me.txt"), []byte("quoted"), 0640))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "run.sh"), []byte("#!/bin/sh"), 0755))
+
+	entries, err := ScanSource(srcDir, config.NewDefaultConfig())
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteManifest(&buf, entries))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	require.True(t, strings.HasPrefix(lines[0], `"run.sh" 9 -rwxr-xr-x `), "Unexpected line %q", lines[0])
+	require.Equal(t, `"sub dir" 0 drwxr-xr-x -`, lines[1][:len(`"sub dir" 0 drwxr-xr-x -`)])
+
+	var again bytes.Buffer
+	require.NoError(t, WriteManifest(&again, entries))
+	require.Equal(t, buf.String(), again.String(), "Expected a stable format")
+
+	read, err := ReadManifest(&buf)
+	require.NoError(t, err)
+	require.Len(t, read, len(entries))
+	for path, entry := range entries {
+		if !entry.IsDir {
+			require.Equal(t, entry.Size, read[path].Size, "size of %s", path)
+		}
+		require.Equal(t, entry.IsDir, read[path].IsDir, "type of %s", path)
+		require.Equal(t, entry.Checksum, read[path].Checksum, "checksum of %s", path)
+		require.Equal(t, entry.Permissions.Perm(), read[path].Permissions.Perm(), "mode of %s", path)
+	}
+	require.Empty(t, VerifyManifest(read, entries), "Expected a scan to verify against its own manifest")
+
+	t.Run("UsableAsSourceChecksums", func(t *testing.T) {
+		manifestPath := filepath.Join(t.TempDir(), "manifest.txt")
+		require.NoError(t, os.WriteFile(manifestPath, again.Bytes(), 0644))
+
+		checksums, err := LoadChecksumManifest(manifestPath)
+		require.NoError(t, err)
+		require.Equal(t, entries["run.sh"].Checksum, checksums["run.sh"].Checksum)
+		require.NotContains(t, checksums, "sub dir", "Expected directories to carry no checksum")
+	})
+
+	t.Run("InvalidLines", func(t *testing.T) {
+		for _, line := range []string{`run.sh 9 -rwxr-xr-x abc`, `"run.sh" x -rwxr-xr-x abc`, `"run.sh" 9 rwx abc`, `"run.sh" 9`} {
+			_, err := ReadManifest(strings.NewReader(line))
+			require.ErrorIs(t, err, ErrManifestParse, "Expected parse error for %q", line)
+		}
+	})
+}
+
+func TestVerifyManifestMismatches(t *testing.T) {
+	expected := map[string]EntryInfo{
+		"dir":         {RelativePath: "dir", IsDir: true},
+		"missing.txt": {RelativePath: "missing.txt", Size: 1, Checksum: "aa", Permissions: 0644},
+		"resized.txt": {RelativePath: "resized.txt", Size: 10, Checksum: "bb", Permissions: 0644},
+		"edited.txt":  {RelativePath: "edited.txt", Size: 5, Checksum: "cc", Permissions: 0644},
+		"chmod.txt":   {RelativePath: "chmod.txt", Size: 5, Checksum: "dd", Permissions: 0644},
+		"same.txt":    {RelativePath: "same.txt", Size: 5, Checksum: "ee", Permissions: 0644},
+	}
+	scanned := map[string]EntryInfo{
+		"dir":         {RelativePath: "dir", IsDir: true, Permissions: 0700},
+		"resized.txt": {RelativePath: "resized.txt", Size: 12, Checksum: "bb", Permissions: 0644},
+		"edited.txt":  {RelativePath: "edited.txt", Size: 5, Checksum: "ff", Permissions: 0644},
+		"chmod.txt":   {RelativePath: "chmod.txt", Size: 5, Checksum: "dd", Permissions: 0600},
+		"same.txt":    {RelativePath: "same.txt", Size: 5, Checksum: "ee", Permissions: 0644},
+		"extra.txt":   {RelativePath: "extra.txt", Size: 1},
+	}
+
+	require.Equal(t, []Mismatch{
+		{Path: "chmod.txt", Problem: "mode -rw-------, expected -rw-r--r--"},
+		{Path: "edited.txt", Problem: "checksum differs"},
+		{Path: "extra.txt", Problem: "not in manifest"},
+		{Path: "missing.txt", Problem: "missing"},
+		{Path: "resized.txt", Problem: "size 12, expected 10"},
+	}, VerifyManifest(expected, scanned))
+}
@@ -0,0 +1,82 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrSyncerSimulated = errors.New("syncer: simulated failure")
+	ErrFaultPlan       = errors.New("syncer: invalid fault plan")
+)
+
+// injectFault, when set, runs before every copy attempt and fails the attempt with the
+// error it returns. Only tests and builds with the simulate_errors tag set it.
+var injectFault func(relPath string) error
+
+// faultPlan decides which copies fail for -simulate-errors. Copies are numbered by the
+// first attempt of each path, so every Nth file fails however often it is retried.
+type faultPlan struct {
+	every int      // Fail every Nth copied file (0 for none)
+	paths []string // Fail copies of paths matching these patterns, as for -exclude
+	times int      // Failing attempts per faulted file before it succeeds (0 for all)
+
+	mu       sync.Mutex
+	order    map[string]int
+	attempts map[string]int
+}
+
+// parseFaultPlan parses a comma separated fault plan such as "every=3,path=*.log,times=2".
+// The path key may be repeated.
+func parseFaultPlan(spec string) (*faultPlan, error) {
+	plan := &faultPlan{order: map[string]int{}, attempts: map[string]int{}}
+	for _, part := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: %q is not key=value", ErrFaultPlan, part)
+		}
+		switch key {
+		case "path":
+			plan.paths = append(plan.paths, value)
+		case "every", "times":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: %s needs a non-negative count, got %q", ErrFaultPlan, key, value)
+			}
+			if key == "every" {
+				plan.every = n
+			} else {
+				plan.times = n
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown key %q", ErrFaultPlan, key)
+		}
+	}
+	if plan.every == 0 && len(plan.paths) == 0 {
+		return nil, fmt.Errorf("%w: %q selects no copies to fail", ErrFaultPlan, spec)
+	}
+	return plan, nil
+}
+
+// fault is the injectFault hook for the plan.
+func (p *faultPlan) fault(relPath string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n, ok := p.order[relPath]
+	if !ok {
+		n = len(p.order) + 1
+		p.order[relPath] = n
+	}
+	if !(p.every > 0 && n%p.every == 0) && !shouldExclude(relPath, p.paths) {
+		return nil
+	}
+	p.attempts[relPath]++
+	if p.times > 0 && p.attempts[relPath] > p.times {
+		return nil
+	}
+	return fmt.Errorf("%w: copy of %s, attempt %d", ErrSyncerSimulated, relPath, p.attempts[relPath])
+}
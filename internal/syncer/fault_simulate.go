@@ -0,0 +1,15 @@
+//go:build simulate_errors
+
+package syncer
+
+// SimulateErrors makes copies fail as described by the fault plan spec, e.g.
+// "every=3,path=*.log,times=2" (see parseFaultPlan). It exists only in builds with the
+// simulate_errors tag, for exercising retries, -continue-on-error and checkpoints.
+func SimulateErrors(spec string) error {
+	plan, err := parseFaultPlan(spec)
+	if err != nil {
+		return err
+	}
+	injectFault = plan.fault
+	return nil
+}
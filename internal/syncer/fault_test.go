@@ -0,0 +1,115 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFaultPlan(t *testing.T) {
+	testCases := []struct {
+		spec      string
+		every     int
+		paths     []string
+		times     int
+		expectErr bool
+	}{
+		{spec: "every=3", every: 3},
+		{spec: "path=*.log, path=bad/,times=2", paths: []string{"*.log", "bad/"}, times: 2},
+		{spec: "times=2", expectErr: true},
+		{spec: "every=-1", expectErr: true},
+		{spec: "every", expectErr: true},
+		{spec: "often=2", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			plan, err := parseFaultPlan(tc.spec)
+			if tc.expectErr {
+				require.ErrorIs(t, err, ErrFaultPlan)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.every, plan.every)
+			require.Equal(t, tc.paths, plan.paths)
+			require.Equal(t, tc.times, plan.times)
+		})
+	}
+}
+
+func TestSyncInjectedFaults(t *testing.T) {
+	srcDir := t.TempDir()
+	for i := range 6 {
+		name := filepath.Join(srcDir, fmt.Sprintf("file%d.txt", i))
+		require.NoError(t, os.WriteFile(name, []byte(name), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "bad.log"), []byte("log"), 0644))
+
+	inject := func(t *testing.T, spec string) {
+		plan, err := parseFaultPlan(spec)
+		require.NoError(t, err)
+		injectFault = plan.fault
+		t.Cleanup(func() { injectFault = nil })
+	}
+
+	t.Run("ContinueOnError", func(t *testing.T) {
+		inject(t, "path=*.log")
+		dstDir := t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.ContinueOnError = true
+		cfg.Retries = 2
+
+		summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.ErrorIs(t, err, ErrSyncerActionsFailed)
+		require.ErrorIs(t, err, ErrSyncerSimulated)
+		require.Equal(t, []string{"bad.log"}, summary.Failed)
+		require.Equal(t, 6, summary.FilesCreated)
+	})
+
+	t.Run("RetriesRecover", func(t *testing.T) {
+		inject(t, "every=2,times=2")
+		dstDir := t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.Retries = 3
+
+		summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.NoError(t, err, "Expected each faulted copy to succeed on its third attempt")
+		require.Equal(t, 7, summary.FilesCreated)
+	})
+
+	t.Run("RetriesExhausted", func(t *testing.T) {
+		inject(t, "every=2,times=3")
+		dstDir := t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.Retries = 3
+
+		_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.ErrorIs(t, err, ErrSyncerSimulated)
+		require.ErrorContains(t, err, "attempt 3")
+	})
+
+	t.Run("CheckpointResumes", func(t *testing.T) {
+		inject(t, "every=4")
+		dstDir := t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.Retries = 1
+		cfg.CheckpointActions = 1
+		cfg.CopyWorkers = 1 // Copies finish in order, so the fault lands on the fourth
+
+		_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.ErrorIs(t, err, ErrSyncerSimulated)
+		state, err := LoadState(dstDir, cfg)
+		require.NoError(t, err)
+		require.Len(t, state.Entries, 3, "Expected the copies before the fault to be checkpointed")
+
+		injectFault = nil
+		summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.NoError(t, err)
+		require.Equal(t, 4, summary.FilesCreated, "Expected the rerun to copy only the remainder")
+	})
+}
@@ -0,0 +1,78 @@
+package syncer
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+)
+
+// actionKind maps an action type to the name used by the -only and -skip filters.
+func actionKind(actionType int) string {
+	switch actionType {
+	case ActionCreate, ActionMkdir:
+		return config.ActionKindCreate
+	case ActionUpdate, ActionChmod:
+		return config.ActionKindUpdate
+	case ActionDelete, ActionRmdir:
+		return config.ActionKindDelete
+	default:
+		return ""
+	}
+}
+
+// FilterActions drops actions excluded by cfg.OnlyActions and cfg.SkipActions.
+// ActionNone entries are always kept. The paths of dropped actions are returned so
+// callers can keep their previous state (see ReconcileEntries) and retry them later.
+func FilterActions(actions []SyncAction, cfg *config.Config) ([]SyncAction, []string) {
+	if len(cfg.OnlyActions) == 0 && len(cfg.SkipActions) == 0 {
+		return actions, nil
+	}
+
+	kept := make([]SyncAction, 0, len(actions))
+	var skipped []string
+	for _, action := range actions {
+		kind := actionKind(action.Type)
+		if kind != "" &&
+			((len(cfg.OnlyActions) > 0 && !slices.Contains(cfg.OnlyActions, kind)) ||
+				slices.Contains(cfg.SkipActions, kind)) {
+			skipped = append(skipped, action.RelativePath)
+			continue
+		}
+		kept = append(kept, action)
+	}
+
+	return kept, skipped
+}
+
+// dropUnreadableDeletes drops the deletes of entries at or below a path the scan skipped
+// as unreadable (see config.StrictScan), since they may well still exist in the source.
+// It serves the same way for files skipped for falling outside the mtime window.
+// The paths of dropped actions are returned so callers can keep their recorded state
+// (see ReconcileEntries) and compare them again on the next run.
+func dropUnreadableDeletes(actions []SyncAction, unreadable []string) ([]SyncAction, []string) {
+	if len(unreadable) == 0 {
+		return actions, nil
+	}
+	kept := make([]SyncAction, 0, len(actions))
+	var held []string
+	for _, action := range actions {
+		if (action.Type == ActionDelete || action.Type == ActionRmdir) && belowUnreadable(action.RelativePath, unreadable) {
+			held = append(held, action.RelativePath)
+			continue
+		}
+		kept = append(kept, action)
+	}
+	return kept, held
+}
+
+// belowUnreadable reports whether relPath is one of the unreadable paths or lies below one.
+func belowUnreadable(relPath string, unreadable []string) bool {
+	for _, path := range unreadable {
+		if relPath == path || strings.HasPrefix(relPath, path+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
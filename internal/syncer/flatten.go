@@ -0,0 +1,33 @@
+package syncer
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// flatEntryPath maps the source file recorded as entryPath to its base name for
+// config.Flatten. The walk is lexical, so when two files share a base name the one
+// scanned first keeps it; with rename the later one becomes name~2.ext (or ~3, ...),
+// and without it the collision is an ErrSyncerFlattenCollision.
+func flatEntryPath(entryPath string, entries map[string]EntryInfo, rename bool) (string, error) {
+	base := filepath.Base(entryPath)
+	taken, ok := entries[base]
+	if !ok {
+		return base, nil
+	}
+	if !rename {
+		return "", fmt.Errorf("%w: %s is also the base name of %s", ErrSyncerFlattenCollision,
+			base, cmp.Or(taken.SourcePath, taken.RelativePath))
+	}
+
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s~%d%s", stem, n, ext)
+		if _, ok := entries[candidate]; !ok {
+			return candidate, nil
+		}
+	}
+}
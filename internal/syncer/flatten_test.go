@@ -0,0 +1,107 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlatEntryPath(t *testing.T) {
+	entries := map[string]EntryInfo{
+		"report.pdf":   {RelativePath: "report.pdf", SourcePath: "2023/report.pdf"},
+		"report~2.pdf": {RelativePath: "report~2.pdf", SourcePath: "2024/report.pdf"},
+		"notes":        {RelativePath: "notes", SourcePath: "a/notes"},
+	}
+
+	testCases := []struct {
+		name      string
+		entryPath string
+		rename    bool
+		expected  string
+		expectErr bool
+	}{
+		{name: "FreeName", entryPath: filepath.Join("a", "b", "photo.jpg"), expected: "photo.jpg"},
+		{name: "Collision", entryPath: filepath.Join("2025", "report.pdf"), expectErr: true},
+		{name: "RenameSkipsTaken", entryPath: filepath.Join("2025", "report.pdf"), rename: true, expected: "report~3.pdf"},
+		{name: "RenameWithoutExtension", entryPath: filepath.Join("b", "notes"), rename: true, expected: "notes~2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := flatEntryPath(tc.entryPath, entries, tc.rename)
+			if tc.expectErr {
+				require.ErrorIs(t, err, ErrSyncerFlattenCollision)
+				require.ErrorContains(t, err, "2023/report.pdf")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestSyncFlatten(t *testing.T) {
+	writeTree := func(t *testing.T, files map[string]string) string {
+		srcDir := t.TempDir()
+		for name, content := range files {
+			path := filepath.Join(srcDir, filepath.FromSlash(name))
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		}
+		return srcDir
+	}
+
+	t.Run("CopiesIntoRoot", func(t *testing.T) {
+		srcDir := writeTree(t, map[string]string{"a/b/one.txt": "one", "c/two.txt": "two", "three.txt": "three"})
+		dstDir := t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.Flatten = true
+
+		_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.NoError(t, err)
+		for name, content := range map[string]string{"one.txt": "one", "two.txt": "two", "three.txt": "three"} {
+			got, err := os.ReadFile(filepath.Join(dstDir, name))
+			require.NoError(t, err)
+			require.Equal(t, content, string(got))
+		}
+		for _, dir := range []string{"a", "c"} {
+			require.NoDirExists(t, filepath.Join(dstDir, dir))
+		}
+
+		// Removing a nested source file deletes its flattened copy
+		require.NoError(t, os.Remove(filepath.Join(srcDir, "c", "two.txt")))
+		_, err = Sync(context.Background(), srcDir, dstDir, cfg)
+		require.NoError(t, err)
+		require.NoFileExists(t, filepath.Join(dstDir, "two.txt"))
+	})
+
+	t.Run("CollisionFails", func(t *testing.T) {
+		srcDir := writeTree(t, map[string]string{"a/same.txt": "first", "b/same.txt": "second"})
+		dstDir := t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.Flatten = true
+
+		_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.ErrorIs(t, err, ErrSyncerFlattenCollision)
+		require.NoFileExists(t, filepath.Join(dstDir, "same.txt"))
+	})
+
+	t.Run("CollisionRenamed", func(t *testing.T) {
+		srcDir := writeTree(t, map[string]string{"a/same.txt": "first", "b/same.txt": "second"})
+		dstDir := t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.Flatten, cfg.FlattenRename = true, true
+
+		_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.NoError(t, err)
+		for name, content := range map[string]string{"same.txt": "first", "same~2.txt": "second"} {
+			got, err := os.ReadFile(filepath.Join(dstDir, name))
+			require.NoError(t, err)
+			require.Equal(t, content, string(got))
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package syncer
+
+import (
+	"path"
+	"strings"
+)
+
+// expandBraces expands {a,b} alternations, including nested ones, into every pattern
+// they describe: "*.{jpg,png}" becomes "*.jpg" and "*.png". Braces without a top-level
+// comma or without a closing brace are kept literally.
+func expandBraces(pattern string) []string {
+	open := strings.IndexByte(pattern, '{')
+	for open >= 0 {
+		depth := 0
+		var commas []int
+		for i := open; i < len(pattern); i++ {
+			switch pattern[i] {
+			case '{':
+				depth++
+			case ',':
+				if depth == 1 {
+					commas = append(commas, i)
+				}
+			case '}':
+				depth--
+			}
+			if depth > 0 {
+				continue
+			}
+
+			if len(commas) == 0 {
+				break // Literal braces, look for the next group
+			}
+			prefix, suffix := pattern[:open], pattern[i+1:]
+			start := open + 1
+			var expanded []string
+			for _, end := range append(commas, i) {
+				expanded = append(expanded, expandBraces(prefix+pattern[start:end]+suffix)...)
+				start = end + 1
+			}
+			return expanded
+		}
+		if depth > 0 {
+			break // Unclosed brace
+		}
+		next := strings.IndexByte(pattern[open+1:], '{')
+		if next < 0 {
+			break
+		}
+		open += next + 1
+	}
+	return []string{pattern}
+}
+
+// matchGlob reports whether the slash-separated name matches pattern. Each segment is
+// matched with path.Match, and a "**" segment matches zero or more whole segments.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// matchGlobOrParent reports whether name or any of its parent directories matches pattern.
+func matchGlobOrParent(pattern, name string) bool {
+	for {
+		if matchGlob(pattern, name) {
+			return true
+		}
+		slash := strings.LastIndexByte(name, '/')
+		if slash < 0 {
+			return false
+		}
+		name = name[:slash]
+	}
+}
+
+// matchBelow reports whether pattern could match a path below the directory name, judged
+// from the pattern alone: its leading segments match every segment of name with at least
+// one segment left over, or a "**" segment is reached first.
+func matchBelow(pattern, name string) bool {
+	segments := strings.Split(pattern, "/")
+	for _, segment := range strings.Split(name, "/") {
+		if len(segments) == 0 {
+			return false
+		}
+		if segments[0] == "**" {
+			return true
+		}
+		if matched, _ := path.Match(segments[0], segment); !matched {
+			return false
+		}
+		segments = segments[1:]
+	}
+	return len(segments) > 0
+}
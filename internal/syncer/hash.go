@@ -0,0 +1,230 @@
+package syncer
+
+import (
+	"encoding/hex"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+const (
+	autoTuneInterval  = 250 * time.Millisecond
+	autoTuneTolerance = 0.05 // Relative throughput change treated as noise
+)
+
+// checksumFile is the hashing function used by the scan; tests swap it to observe concurrency.
+var checksumFile = generateChecksum
+
+// hashJob is a file discovered by the walk that still needs its checksum.
+type hashJob struct {
+	relPath string
+	path    string
+	size    int64
+}
+
+// hashResult is the outcome of hashing a single job.
+type hashResult struct {
+	checksum string
+	blocks   []string // Per-block checksums with cfg.ChecksumBlockSize
+	quick    bool     // checksum is a quick fingerprint (cfg.QuickHash)
+	skip     bool     // File vanished or could not be hashed, and is left out of the scan
+	failed   bool     // skip because hashing failed: the file still exists and its copy is kept
+}
+
+// hashFiles checksums the jobs with at most cfg.HashWorkers concurrent hashers.
+// With cfg.AutoTuneScan the limit starts at one and is adjusted by watching throughput.
+// Fewer jobs than cfg.HashParallelThreshold are hashed serially on the calling goroutine.
+// Results are returned in job order, and hashed bytes are counted in progress.
+func hashFiles(rootDir string, jobs []hashJob, cfg *config.Config, progress *scanProgress) []hashResult {
+	results := make([]hashResult, len(jobs))
+	if len(jobs) == 0 {
+		return results
+	}
+
+	workers := max(cfg.HashWorkers, 1)
+	if workers == 1 || len(jobs) < cfg.HashParallelThreshold {
+		for i, job := range jobs {
+			results[i] = hashOne(rootDir, job, cfg)
+			progress.addHashed(job.size)
+		}
+		return results
+	}
+	limiter := newDynamicLimiter(workers)
+
+	var hashedBytes atomic.Int64
+	stopTuner := func() {}
+	if cfg.AutoTuneScan && workers > 1 {
+		limiter.SetLimit(1)
+		stopTuner = startAutoTuner(limiter, newScanTuner(workers), &hashedBytes)
+	}
+	defer stopTuner()
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				limiter.Acquire()
+				results[i] = hashOne(rootDir, jobs[i], cfg)
+				hashedBytes.Add(jobs[i].size)
+				progress.addHashed(jobs[i].size)
+				limiter.Release()
+			}
+		}()
+	}
+
+	for i := range jobs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return results
+}
+
+// hashOne checksums a single job. Block checksums need the whole content, so
+// cfg.QuickHash only applies without cfg.ChecksumBlockSize.
+func hashOne(rootDir string, job hashJob, cfg *config.Config) hashResult {
+	var blockBytes [][]byte
+	quick := cfg.QuickHash && cfg.ChecksumBlockSize == 0
+	checksumBytes, err := retryableOpWithResult("checksum", rootDir, func() ([]byte, error) {
+		if quick {
+			return generateQuickChecksum(job.path, cfg.AssumeStableSource)
+		}
+		if cfg.ChecksumBlockSize > 0 && job.size > cfg.ChecksumBlockSize {
+			checksum, blocks, err := generateBlockChecksums(job.path, cfg.ChecksumBlockSize, cfg.AssumeStableSource)
+			blockBytes = blocks
+			return checksum, err
+		}
+		return checksumFile(job.path, cfg.AssumeStableSource)
+	})
+	if err != nil {
+		if errors.Is(err, ErrSyncerNotExist) {
+			logger.Warn("file disappeared before checksum, skipping entry", "path", job.path)
+			return hashResult{skip: true}
+		}
+		// An empty checksum would pass for a file that was never compared by content
+		logger.Warn("checksum failed, skipping file", "path", job.path,
+			"error", &SyncError{Op: OpChecksum, Path: job.relPath, Err: err})
+		return hashResult{skip: true, failed: true}
+	}
+	result := hashResult{checksum: hex.EncodeToString(checksumBytes), quick: quick}
+	for _, block := range blockBytes {
+		result.blocks = append(result.blocks, hex.EncodeToString(block))
+	}
+	return result
+}
+
+// dynamicLimiter is a semaphore whose capacity can change while in use.
+type dynamicLimiter struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+}
+
+func newDynamicLimiter(limit int) *dynamicLimiter {
+	l := &dynamicLimiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+func (l *dynamicLimiter) Acquire() {
+	l.mu.Lock()
+	for l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+	l.mu.Unlock()
+}
+
+func (l *dynamicLimiter) Release() {
+	l.mu.Lock()
+	l.active--
+	l.mu.Unlock()
+	l.cond.Signal()
+}
+
+func (l *dynamicLimiter) SetLimit(limit int) {
+	l.mu.Lock()
+	l.limit = max(limit, 1)
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+// scanTuner hill-climbs the hashing concurrency: it keeps moving in the current
+// direction while throughput improves and reverses when throughput drops.
+type scanTuner struct {
+	max            int
+	current        int
+	direction      int
+	lastThroughput float64
+}
+
+func newScanTuner(maxWorkers int) *scanTuner {
+	return &scanTuner{max: max(maxWorkers, 1), current: 1, direction: 1}
+}
+
+// step consumes a throughput sample (bytes/sec) measured at the current
+// concurrency and returns the concurrency to use next. Bytes only count once a file
+// is hashed, so a sample without any, taken while large files are still hashing, says
+// nothing about the concurrency and is skipped.
+func (t *scanTuner) step(throughput float64) int {
+	if throughput <= 0 {
+		return t.current
+	}
+	if t.lastThroughput > 0 {
+		change := (throughput - t.lastThroughput) / t.lastThroughput
+		if change < -autoTuneTolerance {
+			t.direction = -t.direction
+		} else if change <= autoTuneTolerance {
+			// Plateau, hold the current level
+			t.lastThroughput = throughput
+			return t.current
+		}
+	}
+	t.lastThroughput = throughput
+
+	t.current = min(max(t.current+t.direction, 1), t.max)
+	return t.current
+}
+
+// startAutoTuner samples hashing throughput periodically and feeds it to the tuner.
+// The returned func stops the sampler.
+func startAutoTuner(limiter *dynamicLimiter, tuner *scanTuner, hashedBytes *atomic.Int64) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(autoTuneInterval)
+		defer ticker.Stop()
+
+		last := hashedBytes.Load()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				current := hashedBytes.Load()
+				throughput := float64(current-last) / autoTuneInterval.Seconds()
+				last = current
+
+				limit := tuner.step(throughput)
+				limiter.SetLimit(limit)
+				logger.Debug("auto-tuned scan concurrency", "workers", limit, "bytes_per_sec", throughput)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
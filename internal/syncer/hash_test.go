@@ -0,0 +1,313 @@
+package syncer
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashFilesRespectsLimit(t *testing.T) {
+	testDir := t.TempDir()
+	for i := range 20 {
+		name := filepath.Join(testDir, fmt.Sprintf("file-%02d.txt", i))
+		require.NoError(t, os.WriteFile(name, []byte(fmt.Sprintf("content %d", i)), 0644))
+	}
+
+	serialEntries, err := ScanSource(testDir, config.NewDefaultConfig())
+	require.NoError(t, err)
+
+	var active, peak atomic.Int64
+	checksumFile = func(path string, assumeStable bool) ([]byte, error) {
+		n := active.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		active.Add(-1)
+		return generateChecksum(path, assumeStable)
+	}
+	t.Cleanup(func() { checksumFile = generateChecksum })
+
+	for _, workers := range []int{1, 3} {
+		t.Run(fmt.Sprintf("Workers%d", workers), func(t *testing.T) {
+			peak.Store(0)
+			cfg := config.NewDefaultConfig()
+			cfg.HashWorkers = workers
+			cfg.HashParallelThreshold = 1
+
+			entries, err := ScanSource(testDir, cfg)
+			require.NoError(t, err)
+			require.Equal(t, serialEntries, entries, "Expected identical results regardless of concurrency")
+			require.LessOrEqual(t, peak.Load(), int64(workers), "Expected at most %d concurrent hashers", workers)
+			require.Positive(t, peak.Load())
+		})
+	}
+}
+
+func TestScanSourceChecksumEdgeCases(t *testing.T) {
+	testDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "empty.txt"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "good.txt"), []byte("good"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "bad.txt"), []byte("bad"), 0644))
+
+	t.Run("ZeroByteFile", func(t *testing.T) {
+		entries, err := ScanSource(testDir, config.NewDefaultConfig())
+		require.NoError(t, err)
+		empty := entries["empty.txt"]
+		require.Zero(t, empty.Size)
+		require.Equal(t, hex.EncodeToString(xxhash.New().Sum(nil)), empty.Checksum,
+			"Expected the checksum of empty input, not a missing checksum")
+	})
+
+	t.Run("ChecksumError", func(t *testing.T) {
+		checksumFile = func(path string, assumeStable bool) ([]byte, error) {
+			if filepath.Base(path) == "bad.txt" {
+				return nil, ErrSyncerChecksum
+			}
+			return generateChecksum(path, assumeStable)
+		}
+		t.Cleanup(func() { checksumFile = generateChecksum })
+
+		entries, err := ScanSource(testDir, config.NewDefaultConfig())
+		require.NoError(t, err)
+		require.NotContains(t, entries, "bad.txt", "Expected a file that cannot be hashed to be skipped")
+		require.NotEmpty(t, entries["good.txt"].Checksum)
+		require.Contains(t, entries, "empty.txt")
+	})
+}
+
+func TestSyncKeepsFilesFailingChecksum(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Streaming=%t", streaming), func(t *testing.T) {
+			srcDir, dstDir := t.TempDir(), t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "good.txt"), []byte("good"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "bad.txt"), []byte("bad"), 0644))
+			cfg := config.NewDefaultConfig()
+			cfg.Streaming = streaming
+			_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+
+			checksumFile = func(path string, assumeStable bool) ([]byte, error) {
+				if filepath.Base(path) == "bad.txt" {
+					return nil, fs.ErrPermission
+				}
+				return generateChecksum(path, assumeStable)
+			}
+			t.Cleanup(func() { checksumFile = generateChecksum })
+
+			summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+			require.Zero(t, summary.FilesDeleted, "Expected no deletes for a file that cannot be hashed")
+			require.FileExists(t, filepath.Join(dstDir, "bad.txt"))
+
+			state, err := LoadState(dstDir, cfg)
+			require.NoError(t, err)
+			require.Contains(t, state.Entries, "bad.txt", "Expected the entry to stay recorded")
+		})
+	}
+}
+
+func TestHashFilesParallelThreshold(t *testing.T) {
+	testDir := t.TempDir()
+	for i := range 8 {
+		name := filepath.Join(testDir, fmt.Sprintf("file-%02d.txt", i))
+		require.NoError(t, os.WriteFile(name, []byte(fmt.Sprintf("content %d", i)), 0644))
+	}
+
+	var active, peak atomic.Int64
+	checksumFile = func(path string, assumeStable bool) ([]byte, error) {
+		n := active.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		active.Add(-1)
+		return generateChecksum(path, assumeStable)
+	}
+	t.Cleanup(func() { checksumFile = generateChecksum })
+
+	tests := []struct {
+		name      string
+		threshold int
+		parallel  bool
+	}{
+		{name: "BelowThreshold", threshold: 9, parallel: false},
+		{name: "AtThreshold", threshold: 8, parallel: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peak.Store(0)
+			cfg := config.NewDefaultConfig()
+			cfg.HashWorkers = 4
+			cfg.HashParallelThreshold = tt.threshold
+
+			entries, err := ScanSource(testDir, cfg)
+			require.NoError(t, err)
+			require.Len(t, entries, 8)
+			if tt.parallel {
+				require.Greater(t, peak.Load(), int64(1), "Expected concurrent hashing at the threshold")
+			} else {
+				require.Equal(t, int64(1), peak.Load(), "Expected serial hashing below the threshold")
+			}
+		})
+	}
+}
+
+func BenchmarkHashFiles(b *testing.B) {
+	for _, size := range []int{4, 256} {
+		testDir := b.TempDir()
+		for i := range size {
+			name := filepath.Join(testDir, fmt.Sprintf("file-%03d.txt", i))
+			require.NoError(b, os.WriteFile(name, []byte(fmt.Sprintf("content %d", i)), 0644))
+		}
+		for _, threshold := range []int{0, size + 1} {
+			mode := "Parallel"
+			if threshold > size {
+				mode = "Serial"
+			}
+			b.Run(fmt.Sprintf("Files%d/%s", size, mode), func(b *testing.B) {
+				cfg := config.NewDefaultConfig()
+				cfg.HashWorkers = 4
+				cfg.HashParallelThreshold = threshold
+				for range b.N {
+					if _, err := ScanSource(testDir, cfg); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestScanTunerStep(t *testing.T) {
+	t.Run("ConvergesOnPeak", func(t *testing.T) {
+		// Synthetic disk: throughput peaks at 3 concurrent hashers
+		throughput := map[int]float64{1: 100, 2: 180, 3: 240, 4: 200, 5: 150, 6: 120}
+		tuner := newScanTuner(6)
+
+		concurrency := 1
+		var visited []int
+		for range 20 {
+			concurrency = tuner.step(throughput[concurrency])
+			visited = append(visited, concurrency)
+		}
+
+		require.Equal(t, []int{2, 3, 4, 3}, visited[:4], "Expected ramp up then back off after the drop")
+		for _, c := range visited[4:] {
+			require.GreaterOrEqual(t, c, 2, "Expected to stay near the peak")
+			require.LessOrEqual(t, c, 4, "Expected to stay near the peak")
+		}
+		require.Contains(t, visited[4:], 3)
+	})
+
+	t.Run("ClampsAtMax", func(t *testing.T) {
+		tuner := newScanTuner(3)
+		concurrency := 1
+		for range 10 {
+			concurrency = tuner.step(float64(concurrency) * 100)
+			require.LessOrEqual(t, concurrency, 3)
+		}
+		require.Equal(t, 3, concurrency, "Expected linear scaling to saturate at max")
+	})
+
+	t.Run("HoldsOnPlateau", func(t *testing.T) {
+		tuner := newScanTuner(8)
+		require.Equal(t, 2, tuner.step(100))
+		require.Equal(t, 2, tuner.step(102), "Expected a change within tolerance to hold")
+		require.Equal(t, 2, tuner.step(99))
+	})
+
+	t.Run("SkipsEmptySamples", func(t *testing.T) {
+		tuner := newScanTuner(8)
+		require.Equal(t, 2, tuner.step(100))
+		require.Equal(t, 2, tuner.step(0), "Expected a sample without completed files to be skipped")
+		require.Equal(t, 3, tuner.step(200), "Expected the climb to continue against the last real sample")
+	})
+}
+
+func TestHashFilesAutoTune(t *testing.T) {
+	testDir := t.TempDir()
+	for i := range 10 {
+		name := filepath.Join(testDir, fmt.Sprintf("file-%02d.txt", i))
+		require.NoError(t, os.WriteFile(name, []byte(fmt.Sprintf("content %d", i)), 0644))
+	}
+
+	cfg := config.NewDefaultConfig()
+	cfg.HashWorkers = 4
+	cfg.HashParallelThreshold = 1
+	cfg.AutoTuneScan = true
+
+	entries, err := ScanSource(testDir, cfg)
+	require.NoError(t, err)
+	require.Len(t, entries, 10)
+	for _, entry := range entries {
+		require.NotEmpty(t, entry.Checksum)
+	}
+}
+
+func TestGenerateBlockChecksums(t *testing.T) {
+	const blockSize = 64 << 10
+	content := make([]byte, 10*blockSize+123) // A short trailing block
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	path := filepath.Join(t.TempDir(), "large.bin")
+	require.NoError(t, os.WriteFile(path, content, 0644))
+
+	whole, before, err := generateBlockChecksums(path, blockSize, false)
+	require.NoError(t, err)
+	require.Len(t, before, 11)
+	plain, err := generateChecksum(path, false)
+	require.NoError(t, err)
+	require.Equal(t, plain, whole, "Expected the whole-file checksum to match the plain one")
+
+	content[5*blockSize+42] ^= 0xff
+	require.NoError(t, os.WriteFile(path, content, 0644))
+	_, after, err := generateBlockChecksums(path, blockSize, false)
+	require.NoError(t, err)
+
+	for i := range before {
+		if i == 5 {
+			require.NotEqual(t, before[i], after[i], "Expected the modified block's digest to change")
+		} else {
+			require.Equal(t, before[i], after[i], "Expected block %d to be unaffected", i)
+		}
+	}
+}
+
+func TestScanSourceBlockChecksums(t *testing.T) {
+	testDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "large.bin"), make([]byte, 10<<10), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "small.txt"), []byte("tiny"), 0644))
+
+	cfg := config.NewDefaultConfig()
+	cfg.ChecksumBlockSize = 4 << 10
+	entries, err := ScanSource(testDir, cfg)
+	require.NoError(t, err)
+
+	require.Len(t, entries["large.bin"].BlockChecksums, 3)
+	require.Equal(t, int64(4<<10), entries["large.bin"].BlockSize)
+	require.Empty(t, entries["small.txt"].BlockChecksums, "Expected no blocks for a file within one block")
+	require.Zero(t, entries["small.txt"].BlockSize)
+
+	plain, err := ScanSource(testDir, config.NewDefaultConfig())
+	require.NoError(t, err)
+	require.Equal(t, plain["large.bin"].Checksum, entries["large.bin"].Checksum)
+	require.Nil(t, plain["large.bin"].BlockChecksums, "Expected block checksums only when enabled")
+}
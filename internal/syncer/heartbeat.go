@@ -0,0 +1,64 @@
+package syncer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+// scanHeartbeatFiles is how many scanned entries trigger a heartbeat regardless of time.
+var scanHeartbeatFiles int64 = 10000
+
+// scanHeartbeat reports scan progress; tests swap it to record heartbeats.
+var scanHeartbeat = func(dir string, files, hashedBytes int64) {
+	logger.Info("scan in progress", "dir", dir, "entries_scanned", files, "bytes_hashed", hashedBytes)
+}
+
+// scanProgress counts what a scan has covered and emits a heartbeat every
+// cfg.ScanHeartbeat, or every scanHeartbeatFiles entries, whichever comes first.
+// A nil scanProgress does nothing, so callers need not check whether heartbeats are on.
+type scanProgress struct {
+	mu          sync.Mutex
+	dir         string
+	interval    time.Duration
+	files       int64
+	hashedBytes int64
+	lastFiles   int64
+	lastEmit    time.Time
+}
+
+// newScanProgress returns the progress tracker for a scan of dir, or nil when
+// heartbeats are disabled or cfg.Quiet is set.
+func newScanProgress(dir string, cfg *config.Config) *scanProgress {
+	if cfg.ScanHeartbeat <= 0 || cfg.Quiet {
+		return nil
+	}
+	return &scanProgress{dir: dir, interval: cfg.ScanHeartbeat, lastEmit: clock.Now()}
+}
+
+// addFile counts one scanned entry.
+func (p *scanProgress) addFile() {
+	p.add(1, 0)
+}
+
+// addHashed counts bytes read by the hashing pool.
+func (p *scanProgress) addHashed(n int64) {
+	p.add(0, n)
+}
+
+func (p *scanProgress) add(files, hashedBytes int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.files += files
+	p.hashedBytes += hashedBytes
+	if p.files-p.lastFiles < scanHeartbeatFiles && since(p.lastEmit) < p.interval {
+		return
+	}
+	p.lastFiles, p.lastEmit = p.files, clock.Now()
+	scanHeartbeat(p.dir, p.files, p.hashedBytes)
+}
@@ -0,0 +1,66 @@
+package syncer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanSourceHeartbeat(t *testing.T) {
+	srcDir := t.TempDir()
+	for i := range 25 {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, fmt.Sprintf("file-%02d.txt", i)), []byte("content"), 0644))
+	}
+
+	type beat struct{ files, hashed int64 }
+	var beats []beat
+	origHeartbeat, origFiles := scanHeartbeat, scanHeartbeatFiles
+	scanHeartbeat = func(_ string, files, hashedBytes int64) {
+		beats = append(beats, beat{files, hashedBytes})
+	}
+	scanHeartbeatFiles = 10
+	t.Cleanup(func() {
+		scanHeartbeat, scanHeartbeatFiles = origHeartbeat, origFiles
+	})
+
+	testCases := []struct {
+		name     string
+		modify   func(cfg *config.Config)
+		expected []beat
+	}{
+		{
+			// All 25 files are walked before anything is hashed
+			name:     "EveryTenEntries",
+			modify:   func(cfg *config.Config) {},
+			expected: []beat{{10, 0}, {20, 0}},
+		},
+		{
+			name:   "Quiet",
+			modify: func(cfg *config.Config) { cfg.Quiet = true },
+		},
+		{
+			name:   "Disabled",
+			modify: func(cfg *config.Config) { cfg.ScanHeartbeat = 0 },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			beats = nil
+			cfg := config.NewDefaultConfig()
+			cfg.ScanHeartbeat = time.Hour
+			cfg.HashWorkers = 1
+			tc.modify(cfg)
+
+			entries, err := ScanSource(srcDir, cfg)
+			require.NoError(t, err)
+			require.Len(t, entries, 25)
+			require.Equal(t, tc.expected, beats)
+		})
+	}
+}
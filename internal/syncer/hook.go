@@ -0,0 +1,105 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/report"
+)
+
+var ErrSyncerHook = errors.New("syncer: post-sync hook failed")
+
+// RunPostHook runs command after a sync from src to dst. The command line is split into
+// arguments like a shell would (see splitCommand) but run directly, with the summary
+// exported through MIMIC_* environment variables and its output sent to mimic's own.
+// A failing or non-zero exiting command is returned wrapped in ErrSyncerHook, so
+// errors.As still finds the *exec.ExitError and its exit code.
+func RunPostHook(ctx context.Context, command, src, dst string, summary report.Summary) error {
+	args, err := splitCommand(command)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSyncerHook, err)
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("%w: empty command", ErrSyncerHook)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), hookEnv(src, dst, summary)...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %w", ErrSyncerHook, err)
+	}
+	return nil
+}
+
+// hookEnv describes the run to the post-sync hook.
+func hookEnv(src, dst string, summary report.Summary) []string {
+	vars := []struct {
+		name  string
+		value string
+	}{
+		{"MIMIC_SOURCE", src},
+		{"MIMIC_DESTINATION", dst},
+		{"MIMIC_FILES_CREATED", strconv.Itoa(summary.FilesCreated)},
+		{"MIMIC_FILES_UPDATED", strconv.Itoa(summary.FilesUpdated)},
+		{"MIMIC_FILES_DELETED", strconv.Itoa(summary.FilesDeleted)},
+		{"MIMIC_FILES_UNCHANGED", strconv.Itoa(summary.Unchanged)},
+		{"MIMIC_FILES_FAILED", strconv.Itoa(len(summary.Failed))},
+		{"MIMIC_DIRS_CREATED", strconv.Itoa(summary.DirsCreated)},
+		{"MIMIC_DIRS_DELETED", strconv.Itoa(summary.DirsDeleted)},
+		{"MIMIC_BYTES_COPIED", strconv.FormatInt(summary.BytesTransferred, 10)},
+		{"MIMIC_ELAPSED_SECONDS", strconv.FormatFloat(summary.Elapsed.Seconds(), 'f', 3, 64)},
+	}
+	env := make([]string, len(vars))
+	for i, v := range vars {
+		env[i] = v.name + "=" + v.value
+	}
+	return env
+}
+
+// splitCommand splits a command line on unquoted whitespace. Single quotes keep their
+// contents literally, double quotes keep whitespace, and a backslash outside single
+// quotes escapes the next character.
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg, escaped := false, false
+	var quote rune
+
+	for _, r := range command {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape in command")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,87 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPostHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook script needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "hook.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\nenv | grep '^MIMIC_' > \"$1\"\nexit \"$2\"\n"), 0755))
+	summary := report.Summary{FilesCreated: 3, FilesUpdated: 2, FilesDeleted: 1, DirsCreated: 4, BytesTransferred: 2048, Failed: []string{"a"}}
+
+	t.Run("ExportsSummary", func(t *testing.T) {
+		envFile := filepath.Join(dir, "env with space.txt")
+		require.NoError(t, RunPostHook(context.Background(), script+` "`+envFile+`" 0`, "/src", "/dst", summary))
+
+		contents, err := os.ReadFile(envFile)
+		require.NoError(t, err)
+		env := make(map[string]string)
+		for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+			name, value, _ := strings.Cut(line, "=")
+			env[name] = value
+		}
+		for name, want := range map[string]string{
+			"MIMIC_SOURCE":        "/src",
+			"MIMIC_DESTINATION":   "/dst",
+			"MIMIC_FILES_CREATED": "3",
+			"MIMIC_FILES_UPDATED": "2",
+			"MIMIC_FILES_DELETED": "1",
+			"MIMIC_FILES_FAILED":  "1",
+			"MIMIC_DIRS_CREATED":  "4",
+			"MIMIC_BYTES_COPIED":  "2048",
+		} {
+			require.Equal(t, want, env[name], "Expected %s in the hook environment", name)
+		}
+	})
+
+	t.Run("PropagatesExitCode", func(t *testing.T) {
+		err := RunPostHook(context.Background(), script+" "+filepath.Join(dir, "env.txt")+" 3", "/src", "/dst", summary)
+		require.ErrorIs(t, err, ErrSyncerHook)
+		var exitErr *exec.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		require.Equal(t, 3, exitErr.ExitCode())
+	})
+
+	t.Run("MissingCommand", func(t *testing.T) {
+		require.ErrorIs(t, RunPostHook(context.Background(), filepath.Join(dir, "missing"), "/src", "/dst", summary), ErrSyncerHook)
+	})
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{command: "systemctl restart app", want: []string{"systemctl", "restart", "app"}},
+		{command: `notify-send "Sync done" 'it''s ok'`, want: []string{"notify-send", "Sync done", "its ok"}},
+		{command: `echo a\ b "x\"y" ''`, want: []string{"echo", "a b", `x"y`, ""}},
+		{command: "  spaced\targs  ", want: []string{"spaced", "args"}},
+		{command: "", want: nil},
+		{command: `echo "unterminated`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			got, err := splitCommand(tt.command)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+)
+
+// IntegrityScan audits a previously synced destination against the state recorded in
+// it: files whose size or checksum drifted, recorded entries that went missing and
+// entries that are not in the state. Unlike LoadState it never creates a state file.
+// Files recorded with block checksums are hashed per block too, so a changed file
+// reports which blocks diverged.
+func IntegrityScan(dstDir string, cfg *config.Config) ([]Mismatch, error) {
+	_, stateFileLocation, _ := statePaths(dstDir, cfg)
+	state, err := loadStateFile(stateFS, stateFileLocation, cfg)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: no state file in %s", ErrSyncStateRead, dstDir)
+		}
+		return nil, err
+	}
+
+	scanCfg := *cfg
+	if scanCfg.ChecksumBlockSize == 0 {
+		scanCfg.ChecksumBlockSize = recordedBlockSize(state.Entries)
+	}
+	scanned, err := ScanDestination(dstDir, &scanCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return VerifyState(state.Entries, scanned), nil
+}
+
+// VerifyState compares a destination scan against recorded state entries and returns
+// the differences sorted by path. Permissions and modification times are not compared,
+// since copies do not carry them over exactly.
+func VerifyState(recorded, scanned map[string]EntryInfo) []Mismatch {
+	return compareEntries(recorded, scanned, false, "not in state")
+}
+
+// recordedBlockSize returns the block size the recorded entries were hashed with, or 0
+// when none carry block checksums.
+func recordedBlockSize(entries map[string]EntryInfo) int64 {
+	for _, entry := range entries {
+		if entry.BlockSize > 0 {
+			return entry.BlockSize
+		}
+	}
+	return 0
+}
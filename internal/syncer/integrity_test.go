@@ -0,0 +1,77 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegrityScan(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	cfg := config.NewDefaultConfig()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "dir"), 0755))
+	for name, content := range map[string]string{
+		"flipped.txt":                      "original",
+		"grown.txt":                        "short",
+		filepath.Join("dir", "gone.txt"):   "soon deleted",
+		filepath.Join("dir", "intact.txt"): "untouched",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644))
+	}
+	_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	mismatches, err := IntegrityScan(dstDir, cfg)
+	require.NoError(t, err)
+	require.Empty(t, mismatches, "Expected a freshly synced destination to match its state")
+
+	// Drift the destination behind mimic's back
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "flipped.txt"), []byte("ORIGINAL"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "grown.txt"), []byte("much longer"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(dstDir, "dir", "gone.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "stray.txt"), []byte("extra"), 0644))
+
+	mismatches, err = IntegrityScan(dstDir, cfg)
+	require.NoError(t, err)
+	require.Equal(t, []Mismatch{
+		{Path: filepath.Join("dir", "gone.txt"), Problem: "missing"},
+		{Path: "flipped.txt", Problem: "checksum differs"},
+		{Path: "grown.txt", Problem: "size 11, expected 5"},
+		{Path: "stray.txt", Problem: "not in state"},
+	}, mismatches)
+
+	t.Run("NoState", func(t *testing.T) {
+		emptyDir := t.TempDir()
+		_, err := IntegrityScan(emptyDir, cfg)
+		require.ErrorIs(t, err, ErrSyncStateRead)
+		require.NoFileExists(t, filepath.Join(emptyDir, stateFile), "Expected the audit not to create a state file")
+	})
+}
+
+func TestIntegrityScanBlocks(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	cfg := config.NewDefaultConfig()
+	cfg.ChecksumBlockSize = 4 << 10
+
+	content := make([]byte, 16<<10)
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "disk.img"), content, 0644))
+	_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	// Corrupt one byte in the third block of the copy
+	content[2*(4<<10)+7] = 1
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "disk.img"), content, 0644))
+
+	mismatches, err := IntegrityScan(dstDir, config.NewDefaultConfig())
+	require.NoError(t, err)
+	require.Equal(t, []Mismatch{
+		{Path: "disk.img", Problem: "checksum differs in 1 of 4 blocks of 4.0 KB: 2"},
+	}, mismatches, "Expected the block size to be taken from the state and the diverged block named")
+}
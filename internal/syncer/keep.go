@@ -0,0 +1,117 @@
+package syncer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+// keepFile lists, at the destination root, patterns of destination paths that are never
+// deleted. It uses the exclude pattern syntax, one pattern per line; blank lines and
+// lines starting with # are skipped.
+const keepFile = ".mimic-keep"
+
+// LoadKeepPatterns reads the keep patterns of the destination dstDir from its keepFile
+// through fsys. A missing file means nothing is protected.
+func LoadKeepPatterns(fsys StateFS, dstDir string) ([]string, error) {
+	data, err := fsys.ReadFile(filepath.Join(dstDir, keepFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSyncerKeepFile, err)
+	}
+
+	var patterns []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSyncerKeepFile, err)
+	}
+	return patterns, nil
+}
+
+// KeepActions drops the deletes of paths matching one of the keep patterns. Unlike
+// exclude patterns, which leave source entries out of the scan, these protect what is on
+// the destination. A directory delete is also dropped when anything below the directory
+// matches, tracked or not, since removing the directory would take it along: with
+// dstRoot set, a local destination, that is looked up, and otherwise it is enough that
+// a pattern could match below it. The keep file itself is always protected. It returns
+// the remaining actions and the protected paths.
+func KeepActions(actions []SyncAction, patterns []string, dstRoot string) ([]SyncAction, []string) {
+	remaining := make([]SyncAction, 0, len(actions))
+	var protected []string
+	for _, action := range actions {
+		isDelete := action.Type == ActionDelete || action.Type == ActionRmdir
+		if isDelete && (action.RelativePath == keepFile || shouldExclude(action.RelativePath, patterns) ||
+			action.Type == ActionRmdir && len(patterns) > 0 && keptBelow(dstRoot, action.RelativePath, patterns)) {
+			logger.Info("keeping protected destination path", "path", action.RelativePath)
+			protected = append(protected, action.RelativePath)
+			continue
+		}
+		remaining = append(remaining, action)
+	}
+	return remaining, protected
+}
+
+// keepRoot is the root KeepActions looks below directories in: that of a local
+// destination, or none.
+func keepRoot(dst Destination) string {
+	if local, ok := dst.(*LocalDestination); ok {
+		return local.Root()
+	}
+	return ""
+}
+
+// keptBelow reports whether the destination directory dir under dstRoot holds an entry
+// matching one of the keep patterns. A directory that cannot be read counts as holding
+// one, so nothing is deleted on a guess. Without dstRoot the directory cannot be looked
+// into, and any pattern that could match below it counts (see mayMatchBelow).
+func keptBelow(dstRoot, dir string, patterns []string) bool {
+	if dstRoot == "" {
+		return mayMatchBelow(dir, patterns)
+	}
+	found := errors.New("found")
+	err := filepath.WalkDir(filepath.Join(dstRoot, dir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dstRoot, path)
+		if err != nil {
+			return err
+		}
+		if shouldExclude(relPath, patterns) {
+			return found
+		}
+		return nil
+	})
+	return err != nil && !errors.Is(err, fs.ErrNotExist)
+}
+
+// mayMatchBelow reports whether one of the patterns could match a path below the
+// directory dir. Patterns without a slash match names at any depth, so they always could;
+// the others are anchored at the root and checked segment by segment (see matchBelow).
+func mayMatchBelow(dir string, patterns []string) bool {
+	slashDir := filepath.ToSlash(dir)
+	for _, pattern := range patterns {
+		for _, alt := range expandBraces(pattern) {
+			dirPattern, isDir := strings.CutSuffix(alt, "/")
+			if !isDir && !strings.Contains(alt, "/") || matchBelow(dirPattern, slashDir) {
+				return true
+			}
+		}
+	}
+	return false
+}
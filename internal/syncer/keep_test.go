@@ -0,0 +1,113 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadKeepPatterns(t *testing.T) {
+	dstDir := t.TempDir()
+	patterns, err := LoadKeepPatterns(stateFS, dstDir)
+	require.NoError(t, err)
+	require.Empty(t, patterns, "Expected a missing keep file to protect nothing")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, keepFile), []byte("# generated\n*.log\n\n  cache/  \n"), 0644))
+	patterns, err = LoadKeepPatterns(stateFS, dstDir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"*.log", "cache/"}, patterns)
+}
+
+func TestKeepActions(t *testing.T) {
+	dstRoot := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dstRoot, "build", "cache"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dstRoot, "build", "cache", "a.tmp"), []byte("a"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dstRoot, "other"), 0755))
+
+	testCases := []struct {
+		name       string
+		patterns   []string
+		dstRoot    string
+		path       string
+		actionType int
+		protected  bool
+	}{
+		{name: "KeepFileWithoutPatterns", path: keepFile, actionType: ActionDelete, protected: true},
+		{name: "UnmatchedWithoutPatterns", path: "a.txt", actionType: ActionDelete, protected: false},
+		{name: "LocalKeptBelow", patterns: []string{"*.tmp"}, dstRoot: dstRoot, path: "build", actionType: ActionRmdir, protected: true},
+		{name: "LocalNothingBelow", patterns: []string{"*.tmp"}, dstRoot: dstRoot, path: "other", actionType: ActionRmdir, protected: false},
+		{name: "RemoteBaseNamePattern", patterns: []string{"*.tmp"}, path: "other", actionType: ActionRmdir, protected: true},
+		{name: "RemoteAnchoredBelow", patterns: []string{"build/cache/"}, path: "build", actionType: ActionRmdir, protected: true},
+		{name: "RemoteAnchoredDoubleStar", patterns: []string{"build/**/*.tmp"}, path: filepath.Join("build", "cache"), actionType: ActionRmdir, protected: true},
+		{name: "RemoteAnchoredElsewhere", patterns: []string{"build/cache/"}, path: "other", actionType: ActionRmdir, protected: false},
+		{name: "RemoteAnchoredAtDir", patterns: []string{"build/"}, path: filepath.Join("build", "cache"), actionType: ActionRmdir, protected: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actions := []SyncAction{{Type: tc.actionType, RelativePath: tc.path}}
+			remaining, protected := KeepActions(actions, tc.patterns, tc.dstRoot)
+			if tc.protected {
+				require.Empty(t, remaining)
+				require.Equal(t, []string{tc.path}, protected)
+			} else {
+				require.Equal(t, actions, remaining)
+				require.Empty(t, protected)
+			}
+		})
+	}
+}
+
+func TestSyncStatelessKeepsKeepFile(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, keepFile), []byte("# nothing yet\n"), 0644))
+	cfg := config.NewDefaultConfig()
+	cfg.Stateless = true
+
+	summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.Zero(t, summary.FilesDeleted)
+	require.FileExists(t, filepath.Join(dstDir, keepFile))
+}
+
+func TestSyncKeepFile(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	for _, name := range []string{"a.txt", filepath.Join("build", "out.bin"), filepath.Join("logs", "app.log"), "old.txt"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(srcDir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(name), 0644))
+	}
+	cfg := config.NewDefaultConfig()
+	_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	// An untracked cache file lives in a directory that leaves the source
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "build", "cache.tmp"), []byte("cache"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, keepFile), []byte("*.tmp\nlogs/\n"), 0644))
+	require.NoError(t, os.RemoveAll(filepath.Join(srcDir, "build")))
+	require.NoError(t, os.RemoveAll(filepath.Join(srcDir, "logs")))
+	require.NoError(t, os.Remove(filepath.Join(srcDir, "old.txt")))
+
+	summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dstDir, "build", "cache.tmp"), "Expected the kept file to survive its directory's delete")
+	require.NoFileExists(t, filepath.Join(dstDir, "build", "out.bin"), "Expected tracked files beside it to be deleted")
+	require.FileExists(t, filepath.Join(dstDir, "logs", "app.log"))
+	require.NoFileExists(t, filepath.Join(dstDir, "old.txt"))
+	require.FileExists(t, filepath.Join(dstDir, keepFile))
+	require.Equal(t, 2, summary.FilesDeleted)
+	for _, action := range summary.Actions {
+		require.NotContains(t, []string{"build", "logs", filepath.Join("logs", "app.log")}, action.RelativePath)
+	}
+
+	// Protected paths are no longer tracked, so they are not planned again
+	state, err := LoadState(dstDir, cfg)
+	require.NoError(t, err)
+	require.NotContains(t, state.Entries, filepath.Join("logs", "app.log"))
+	summary, err = Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.Zero(t, summary.FilesDeleted+summary.DirsDeleted)
+}
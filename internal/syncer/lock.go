@@ -0,0 +1,87 @@
+package syncer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+var (
+	ErrSyncerLocked = errors.New("syncer: another sync is in progress")
+	ErrSyncerLock   = errors.New("syncer: cannot lock the destination")
+)
+
+const lockSuffix = ".lock"
+
+// lockInfo is written into the lock file to tell who holds it.
+type lockInfo struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Started time.Time `json:"started"`
+}
+
+// AcquireLock takes the lock of dstDir, a file next to its state file (see statePaths)
+// created exclusively, so a second run on the same destination fails fast with
+// ErrSyncerLocked naming the holder. With cfg.ForceUnlock an existing lock is removed
+// first, for locks left behind by a run that crashed. The returned func releases the lock.
+func AcquireLock(dstDir string, cfg *config.Config) (func(), error) {
+	stateDir, stateFileLocation, _ := statePaths(dstDir, cfg)
+	path := stateFileLocation + lockSuffix
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSyncerLock, err)
+	}
+
+	if cfg.ForceUnlock {
+		if holder, err := readLock(path); err == nil {
+			logger.Warn("removing existing lock", "path", path, "pid", holder.PID, "host", holder.Host, "started", holder.Started)
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %w", ErrSyncerLock, err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		holder, readErr := readLock(path)
+		if readErr != nil {
+			return nil, fmt.Errorf("%w: %s exists (use -force-unlock if no other run is active)", ErrSyncerLocked, path)
+		}
+		return nil, fmt.Errorf("%w: held by pid %d on %s since %s (use -force-unlock if that run is gone)",
+			ErrSyncerLocked, holder.PID, holder.Host, holder.Started.Format(time.RFC3339))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSyncerLock, err)
+	}
+
+	host, _ := os.Hostname()
+	err = json.NewEncoder(file).Encode(lockInfo{PID: os.Getpid(), Host: host, Started: clock.Now()})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("%w: %w", ErrSyncerLock, err)
+	}
+
+	logger.Debug("acquired lock", "path", path)
+	return func() {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			logger.Warn("cannot release lock", "path", path, "error", err)
+		}
+	}, nil
+}
+
+func readLock(path string) (lockInfo, error) {
+	var holder lockInfo
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return holder, err
+	}
+	return holder, json.Unmarshal(data, &holder)
+}
@@ -0,0 +1,57 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireLock(t *testing.T) {
+	dstDir := t.TempDir()
+	cfg := config.NewDefaultConfig()
+
+	release, err := AcquireLock(dstDir, cfg)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dstDir, stateFile+lockSuffix))
+
+	_, err = AcquireLock(dstDir, cfg)
+	require.ErrorIs(t, err, ErrSyncerLocked)
+	require.Contains(t, err.Error(), "pid "+strconv.Itoa(os.Getpid()), "Expected the error to name the holder")
+
+	release()
+	require.NoFileExists(t, filepath.Join(dstDir, stateFile+lockSuffix))
+	release, err = AcquireLock(dstDir, cfg)
+	require.NoError(t, err, "Expected the lock to be free once released")
+	release()
+}
+
+func TestSyncLock(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "file.txt"), []byte("payload"), 0644))
+	cfg := config.NewDefaultConfig()
+	cfg.Lock = true
+
+	// A run that is still going, or crashed, holds the lock
+	release, err := AcquireLock(dstDir, cfg)
+	require.NoError(t, err)
+	defer release()
+
+	_, err = Sync(context.Background(), srcDir, dstDir, cfg)
+	require.ErrorIs(t, err, ErrSyncerLocked)
+	require.NoFileExists(t, filepath.Join(dstDir, "file.txt"), "Expected the rejected run to change nothing")
+
+	forced := *cfg
+	forced.ForceUnlock = true
+	summary, err := Sync(context.Background(), srcDir, dstDir, &forced)
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.FilesCreated)
+	require.NoFileExists(t, filepath.Join(dstDir, stateFile+lockSuffix), "Expected the run to release its lock")
+
+	_, err = Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+}
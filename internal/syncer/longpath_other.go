@@ -0,0 +1,18 @@
+//go:build !windows
+
+package syncer
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isPathTooLong reports whether err is the file system rejecting a path or name as too long.
+func isPathTooLong(err error) bool {
+	return errors.Is(err, syscall.ENAMETOOLONG)
+}
+
+// extendedLengthPath only changes paths on Windows.
+func extendedLengthPath(path string) string {
+	return path
+}
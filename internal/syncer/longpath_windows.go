@@ -0,0 +1,36 @@
+//go:build windows
+
+package syncer
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// errorFilenameExcedRange is ERROR_FILENAME_EXCED_RANGE, which Windows returns for paths
+// over MAX_PATH.
+const errorFilenameExcedRange = syscall.Errno(206)
+
+// isPathTooLong reports whether err is the file system rejecting a path or name as too long.
+func isPathTooLong(err error) bool {
+	return errors.Is(err, syscall.ENAMETOOLONG) || errors.Is(err, errorFilenameExcedRange)
+}
+
+// extendedLengthPath returns path with the \\?\ prefix, which lets the Windows file APIs
+// take paths longer than MAX_PATH. Prefixed paths are not normalized by Windows, so path
+// is made absolute and cleaned first.
+func extendedLengthPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:] // \\server\share\dir
+	}
+	return `\\?\` + abs
+}
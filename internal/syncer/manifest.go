@@ -0,0 +1,80 @@
+package syncer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+var (
+	ErrManifestRead  = errors.New("syncer: failed to read manifest")
+	ErrManifestParse = errors.New("syncer: failed to parse manifest")
+)
+
+// ManifestEntry is a precomputed checksum for one source file. Checksum must be
+// in the same format the scan produces (hex encoded xxhash64).
+type ManifestEntry struct {
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+// LoadChecksumManifest reads a JSON object mapping source-relative paths to their
+// size and checksum, e.g. {"bin/app": {"size": 1024, "checksum": "9f86d081..."}}.
+// A text manifest written by WriteManifest is accepted as well.
+func LoadChecksumManifest(path string) (map[string]ManifestEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrManifestRead, err)
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] != '{' {
+		return checksumsFromTextManifest(data)
+	}
+
+	var raw map[string]ManifestEntry
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrManifestParse, err)
+	}
+
+	// Normalise keys so they match the cleaned relative paths produced by the walk
+	manifest := make(map[string]ManifestEntry, len(raw))
+	for relPath, entry := range raw {
+		if entry.Checksum == "" {
+			return nil, fmt.Errorf("%w: empty checksum for %q", ErrManifestParse, relPath)
+		}
+		manifest[filepath.Clean(filepath.FromSlash(relPath))] = entry
+	}
+	return manifest, nil
+}
+
+func checksumsFromTextManifest(data []byte) (map[string]ManifestEntry, error) {
+	entries, err := ReadManifest(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	manifest := make(map[string]ManifestEntry, len(entries))
+	for relPath, entry := range entries {
+		if !entry.IsDir && entry.Checksum != "" {
+			manifest[relPath] = ManifestEntry{Size: entry.Size, Checksum: entry.Checksum}
+		}
+	}
+	return manifest, nil
+}
+
+// manifestChecksum returns the manifest checksum for relPath when it is listed with a
+// matching size. A size mismatch means the manifest is stale, so the file gets hashed.
+func manifestChecksum(manifest map[string]ManifestEntry, relPath string, size int64) (string, bool) {
+	entry, ok := manifest[relPath]
+	if !ok {
+		return "", false
+	}
+	if entry.Size != size {
+		logger.Warn("checksum manifest size mismatch, hashing file", "path", relPath, "manifest_size", entry.Size, "size", size)
+		return "", false
+	}
+	return entry.Checksum, true
+}
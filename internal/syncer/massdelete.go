@@ -0,0 +1,43 @@
+package syncer
+
+import (
+	"fmt"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+// checkDeletes returns an ErrSyncerMassDelete error when the deletes among actions look
+// like the source vanished rather than changed: the scan found no entries at all
+// (scanned) but recorded entries would be deleted, as with an unmounted share, or there
+// are more deletes than cfg.MaxDelete or than cfg.MaxDeletePercent of the recorded
+// entries. With cfg.ForceDelete such deletes are only logged.
+func checkDeletes(actions []SyncAction, scanned, recorded int, cfg *config.Config) error {
+	deletes := 0
+	for _, action := range actions {
+		if action.Type == ActionDelete || action.Type == ActionRmdir {
+			deletes++
+		}
+	}
+	if deletes == 0 {
+		return nil
+	}
+
+	var err error
+	switch {
+	case scanned == 0:
+		err = fmt.Errorf("%w: the source is empty but %d recorded entries would be deleted; is it mounted?", ErrSyncerMassDelete, deletes)
+	case cfg.MaxDelete > 0 && deletes > cfg.MaxDelete:
+		err = fmt.Errorf("%w: %d deletes exceed -max-delete %d", ErrSyncerMassDelete, deletes, cfg.MaxDelete)
+	case cfg.MaxDeletePercent > 0 && recorded > 0 && float64(deletes)*100 > cfg.MaxDeletePercent*float64(recorded):
+		err = fmt.Errorf("%w: %d deletes are %.1f%% of %d recorded entries, above -max-delete %g%%",
+			ErrSyncerMassDelete, deletes, float64(deletes)*100/float64(recorded), recorded, cfg.MaxDeletePercent)
+	default:
+		return nil
+	}
+	if cfg.ForceDelete {
+		logger.Warn("Deleting past the mass delete guard", "reason", err)
+		return nil
+	}
+	return fmt.Errorf("%w (use -force-delete if this is intended)", err)
+}
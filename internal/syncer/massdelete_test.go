@@ -0,0 +1,91 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDeletes(t *testing.T) {
+	deletes := func(n int) []SyncAction {
+		actions := []SyncAction{{Type: ActionCreate, RelativePath: "new.txt"}}
+		for i := range n {
+			actions = append(actions, SyncAction{Type: ActionDelete, RelativePath: fmt.Sprintf("file-%d.txt", i)})
+		}
+		return actions
+	}
+
+	testCases := []struct {
+		name      string
+		actions   []SyncAction
+		scanned   int
+		modify    func(cfg *config.Config)
+		expectErr string
+	}{
+		{name: "NoDeletes", actions: deletes(0)},
+		{name: "Some", actions: deletes(3), scanned: 7},
+		{name: "EmptySource", actions: deletes(10), expectErr: "source is empty"},
+		{name: "EmptySourceForced", actions: deletes(10), modify: func(cfg *config.Config) { cfg.ForceDelete = true }},
+		{name: "AtCount", actions: deletes(3), scanned: 7, modify: func(cfg *config.Config) { cfg.MaxDelete = 3 }},
+		{name: "OverCount", actions: deletes(4), scanned: 6, modify: func(cfg *config.Config) { cfg.MaxDelete = 3 }, expectErr: "exceed -max-delete 3"},
+		{name: "AtPercent", actions: deletes(5), scanned: 5, modify: func(cfg *config.Config) { cfg.MaxDeletePercent = 50 }},
+		{name: "OverPercent", actions: deletes(6), scanned: 4, modify: func(cfg *config.Config) { cfg.MaxDeletePercent = 50 }, expectErr: "60.0% of 10 recorded entries"},
+		{name: "OverPercentForced", actions: deletes(6), scanned: 4, modify: func(cfg *config.Config) {
+			cfg.MaxDeletePercent, cfg.ForceDelete = 50, true
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewDefaultConfig()
+			if tc.modify != nil {
+				tc.modify(cfg)
+			}
+			err := checkDeletes(tc.actions, tc.scanned, 10, cfg)
+			if tc.expectErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrSyncerMassDelete)
+			require.ErrorContains(t, err, tc.expectErr)
+		})
+	}
+}
+
+func TestSyncMassDeleteGuard(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Streaming=%t", streaming), func(t *testing.T) {
+			srcDir, dstDir := t.TempDir(), t.TempDir()
+			for i := range 10 {
+				require.NoError(t, os.WriteFile(filepath.Join(srcDir, fmt.Sprintf("file-%d.txt", i)), []byte("content"), 0644))
+			}
+			cfg := config.NewDefaultConfig()
+			cfg.Streaming = streaming
+			_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+
+			// The source looks like an unmounted share
+			entries, err := os.ReadDir(srcDir)
+			require.NoError(t, err)
+			for _, entry := range entries {
+				require.NoError(t, os.Remove(filepath.Join(srcDir, entry.Name())))
+			}
+			_, err = Sync(context.Background(), srcDir, dstDir, cfg)
+			require.ErrorIs(t, err, ErrSyncerMassDelete)
+			for i := range 10 {
+				require.FileExists(t, filepath.Join(dstDir, fmt.Sprintf("file-%d.txt", i)), "Expected a refused run to delete nothing")
+			}
+
+			cfg.ForceDelete = true
+			summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+			require.Equal(t, 10, summary.FilesDeleted, "Expected -force-delete to let the deletes through")
+			require.NoFileExists(t, filepath.Join(dstDir, "file-0.txt"))
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package syncer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+var (
+	ErrMountInfoParse       = errors.New("syncer: failed to parse mount info")
+	ErrMountInfoUnsupported = errors.New("syncer: mount info is not supported on this platform")
+)
+
+// mountInfo describes a single mounted filesystem.
+type mountInfo struct {
+	MountPoint string
+	FSType     string
+}
+
+// parseMountInfo parses the /proc/self/mountinfo format, e.g.
+//
+//	36 35 98:0 /mnt1 /mnt/parent rw,noatime master:1 - ext3 /dev/root rw
+//
+// The mount point is the fifth field, the filesystem type follows the "-" separator.
+func parseMountInfo(r io.Reader) ([]mountInfo, error) {
+	var mounts []mountInfo
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		sep := -1
+		for i, field := range fields {
+			if field == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 5 || sep < 5 || sep+1 >= len(fields) {
+			return nil, fmt.Errorf("%w: malformed line %q", ErrMountInfoParse, line)
+		}
+
+		mounts = append(mounts, mountInfo{
+			MountPoint: unescapeMountPath(fields[4]),
+			FSType:     fields[sep+1],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMountInfoParse, err)
+	}
+
+	return mounts, nil
+}
+
+// unescapeMountPath decodes the octal escapes (\040 for space etc.) used in mountinfo.
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if v, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(path[i])
+	}
+	return sb.String()
+}
+
+// matchMounts returns the set of mount points that should be skipped, either because
+// their filesystem type is excluded or because the mount point itself is excluded.
+// An fstype of "fuse" also matches subtypes such as "fuse.sshfs".
+func matchMounts(mounts []mountInfo, fsTypes []string, mountPaths []string) map[string]bool {
+	skip := make(map[string]bool)
+
+	for _, mount := range mounts {
+		mountPoint := filepath.Clean(mount.MountPoint)
+		for _, fsType := range fsTypes {
+			if mount.FSType == fsType || strings.HasPrefix(mount.FSType, fsType+".") {
+				skip[mountPoint] = true
+			}
+		}
+		for _, mountPath := range mountPaths {
+			if mountPoint == filepath.Clean(mountPath) {
+				skip[mountPoint] = true
+			}
+		}
+	}
+
+	return skip
+}
+
+// excludedMounts resolves the configured fstype/mount exclusions into absolute
+// directory paths to skip during the walk. When mount info is unavailable the
+// explicit mount paths are still honored and fstype exclusion is disabled.
+func excludedMounts(fsTypes []string, mountPaths []string) map[string]bool {
+	if len(fsTypes) == 0 && len(mountPaths) == 0 {
+		return nil
+	}
+
+	absPaths := make([]string, 0, len(mountPaths))
+	for _, mountPath := range mountPaths {
+		if abs, err := filepath.Abs(mountPath); err == nil {
+			absPaths = append(absPaths, abs)
+		}
+	}
+
+	mounts, err := loadMounts()
+	if err != nil {
+		logger.Warn("mount info unavailable, fstype exclusion disabled", "error", err)
+		skip := make(map[string]bool, len(absPaths))
+		for _, mountPath := range absPaths {
+			skip[filepath.Clean(mountPath)] = true
+		}
+		return skip
+	}
+
+	return matchMounts(mounts, fsTypes, absPaths)
+}
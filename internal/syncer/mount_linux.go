@@ -0,0 +1,21 @@
+//go:build linux
+
+package syncer
+
+import (
+	"fmt"
+	"os"
+)
+
+const mountInfoPath = "/proc/self/mountinfo"
+
+// loadMounts reads the mount table of the current process.
+func loadMounts() ([]mountInfo, error) {
+	file, err := os.Open(mountInfoPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyncerRead, err)
+	}
+	defer file.Close()
+
+	return parseMountInfo(file)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package syncer
+
+// loadMounts is not supported outside Linux; callers fall back to explicit mount paths.
+func loadMounts() ([]mountInfo, error) {
+	return nil, ErrMountInfoUnsupported
+}
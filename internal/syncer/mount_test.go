@@ -0,0 +1,123 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+const syntheticMountInfo = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+35 22 0:31 / /mnt/nfs rw,relatime shared:20 - nfs4 server:/export rw,vers=4.2
+36 22 0:32 / /mnt/share rw,relatime shared:21 - nfs server:/share rw
+37 22 0:33 / /home/user/remote rw,nosuid,nodev shared:22 - fuse.sshfs user@host:/ rw
+38 22 0:34 / /mnt/my\040disk rw,relatime shared:23 master:1 - ext4 /dev/sdb1 rw
+`
+
+func TestParseMountInfo(t *testing.T) {
+	mounts, err := parseMountInfo(strings.NewReader(syntheticMountInfo))
+	require.NoError(t, err, "Expected no error parsing mountinfo")
+	require.Len(t, mounts, 5, "Expected 5 mounts")
+
+	require.Equal(t, mountInfo{MountPoint: "/", FSType: "ext4"}, mounts[0])
+	require.Equal(t, mountInfo{MountPoint: "/mnt/nfs", FSType: "nfs4"}, mounts[1])
+	require.Equal(t, mountInfo{MountPoint: "/home/user/remote", FSType: "fuse.sshfs"}, mounts[3])
+	require.Equal(t, "/mnt/my disk", mounts[4].MountPoint, "Expected octal escapes to be decoded")
+
+	_, err = parseMountInfo(strings.NewReader("36 35 98:0 /mnt1\n"))
+	require.ErrorIs(t, err, ErrMountInfoParse, "Expected parse error for malformed line")
+}
+
+func TestMatchMounts(t *testing.T) {
+	mounts, err := parseMountInfo(strings.NewReader(syntheticMountInfo))
+	require.NoError(t, err, "Expected no error parsing mountinfo")
+
+	testCases := []struct {
+		name       string
+		fsTypes    []string
+		mountPaths []string
+		expected   map[string]bool
+	}{
+		{
+			name:     "Exact fstype",
+			fsTypes:  []string{"nfs"},
+			expected: map[string]bool{"/mnt/share": true},
+		},
+		{
+			name:     "Fuse matches subtypes",
+			fsTypes:  []string{"fuse"},
+			expected: map[string]bool{"/home/user/remote": true},
+		},
+		{
+			name:       "Mount path",
+			mountPaths: []string{"/mnt/my disk/"},
+			expected:   map[string]bool{"/mnt/my disk": true},
+		},
+		{
+			name:       "Fstype and mount path combined",
+			fsTypes:    []string{"nfs4"},
+			mountPaths: []string{"/mnt/share"},
+			expected:   map[string]bool{"/mnt/nfs": true, "/mnt/share": true},
+		},
+		{
+			name:       "Nothing matches",
+			fsTypes:    []string{"cifs"},
+			mountPaths: []string{"/not/a/mount"},
+			expected:   map[string]bool{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, matchMounts(mounts, tc.fsTypes, tc.mountPaths))
+		})
+	}
+}
+
+func TestScanSourceOneFileSystem(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "mnt", "nested"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "local"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "mnt", "nested", "huge.img"), []byte("remote"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "local", "file.txt"), []byte("local"), 0644))
+
+	// Pretend "mnt" is a mount point for a different device
+	originalDeviceOf := deviceOf
+	deviceOf = func(info os.FileInfo) (uint64, bool) {
+		if info.Name() == "mnt" {
+			return 2, true
+		}
+		return 1, true
+	}
+	t.Cleanup(func() { deviceOf = originalDeviceOf })
+
+	t.Run("SkipsOtherDevices", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.OneFileSystem = true
+
+		entries, err := ScanSource(srcDir, cfg)
+		require.NoError(t, err)
+		require.Contains(t, entries, filepath.Join("local", "file.txt"))
+		require.NotContains(t, entries, "mnt", "Expected the mount point to be skipped")
+		require.NotContains(t, entries, filepath.Join("mnt", "nested", "huge.img"))
+	})
+
+	t.Run("DisabledCrossesDevices", func(t *testing.T) {
+		entries, err := ScanSource(srcDir, config.NewDefaultConfig())
+		require.NoError(t, err)
+		require.Contains(t, entries, filepath.Join("mnt", "nested", "huge.img"))
+	})
+
+	t.Run("NoDeviceIdsIsNoop", func(t *testing.T) {
+		deviceOf = func(os.FileInfo) (uint64, bool) { return 0, false }
+		cfg := config.NewDefaultConfig()
+		cfg.OneFileSystem = true
+
+		entries, err := ScanSource(srcDir, cfg)
+		require.NoError(t, err)
+		require.Contains(t, entries, filepath.Join("mnt", "nested", "huge.img"))
+	})
+}
@@ -0,0 +1,114 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// isFileCopy reports whether the action copies file contents to the destination.
+func isFileCopy(action SyncAction) bool {
+	return (action.Type == ActionCreate || action.Type == ActionUpdate) && !action.SourceInfo.IsDir
+}
+
+// Execution phases of planPhases.
+const (
+	phaseDirs = iota
+	phaseFiles
+	phaseDeletes
+)
+
+// actionPhase returns the planPhases phase of action. Directory updates are case renames,
+// which have to happen before anything is written below the new name.
+func actionPhase(action SyncAction) int {
+	switch {
+	case action.Type == ActionMkdir, action.Type == ActionUpdate && action.SourceInfo.IsDir:
+		return phaseDirs
+	case action.Type == ActionDelete, action.Type == ActionRmdir:
+		return phaseDeletes
+	default:
+		return phaseFiles
+	}
+}
+
+// planPhases reorders actions for config.Phased: directories are created shallow to deep,
+// then files are copied and other changes applied in their planned order, and finally
+// entries are deleted deep to shallow. Directory times, which writing children changes,
+// are stamped after all phases (see ApplyDirTimes).
+func planPhases(actions []SyncAction) []SyncAction {
+	ordered := slices.Clone(actions)
+	slices.SortStableFunc(ordered, func(a, b SyncAction) int {
+		phaseA, phaseB := actionPhase(a), actionPhase(b)
+		if phaseA != phaseB {
+			return phaseA - phaseB
+		}
+		switch phaseA {
+		case phaseDirs:
+			if c := pathDepth(a.RelativePath) - pathDepth(b.RelativePath); c != 0 {
+				return c
+			}
+		case phaseDeletes:
+			if c := pathDepth(b.RelativePath) - pathDepth(a.RelativePath); c != 0 {
+				return c
+			}
+		default:
+			return 0
+		}
+		return strings.Compare(a.RelativePath, b.RelativePath)
+	})
+	return ordered
+}
+
+// pathDepth is the number of directories above relPath.
+func pathDepth(relPath string) int {
+	return strings.Count(filepath.ToSlash(relPath), "/")
+}
+
+// orderByLocality reorders the file copy actions so that files from the same source
+// directory are copied together, and within a directory in on-disk inode order where
+// the platform exposes it. Copies only move within runs of consecutive copies, so none
+// passes an action it may depend on, such as the one creating its parent directory.
+// All other actions keep their original positions.
+func orderByLocality(srcRoot string, actions []SyncAction) []SyncAction {
+	ordered := slices.Clone(actions)
+
+	inodes := make(map[string]uint64)
+	for _, action := range ordered {
+		if !isFileCopy(action) {
+			continue
+		}
+		if info, err := os.Lstat(filepath.Join(srcRoot, action.sourcePath())); err == nil {
+			if ino, ok := fileInode(info); ok {
+				inodes[action.RelativePath] = ino
+			}
+		}
+	}
+
+	byLocality := func(a, b SyncAction) int {
+		if c := strings.Compare(filepath.Dir(a.RelativePath), filepath.Dir(b.RelativePath)); c != 0 {
+			return c
+		}
+		inoA, inoB := inodes[a.RelativePath], inodes[b.RelativePath]
+		if inoA != inoB {
+			if inoA < inoB {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.RelativePath, b.RelativePath)
+	}
+	for start := 0; start < len(ordered); {
+		if !isFileCopy(ordered[start]) {
+			start++
+			continue
+		}
+		end := start + 1
+		for end < len(ordered) && isFileCopy(ordered[end]) {
+			end++
+		}
+		slices.SortStableFunc(ordered[start:end], byLocality)
+		start = end
+	}
+	return ordered
+}
@@ -0,0 +1,156 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanPhases(t *testing.T) {
+	file := EntryInfo{Size: 10}
+	dir := EntryInfo{IsDir: true}
+	actions := []SyncAction{
+		{Type: ActionDelete, RelativePath: "gone.txt"},
+		{Type: ActionMkdir, RelativePath: filepath.Join("a", "b", "c"), SourceInfo: dir},
+		{Type: ActionCreate, RelativePath: filepath.Join("a", "b", "c", "new.txt"), SourceInfo: file},
+		{Type: ActionRmdir, RelativePath: "old"},
+		{Type: ActionMkdir, RelativePath: "a", SourceInfo: dir},
+		{Type: ActionUpdate, RelativePath: "z.txt", SourceInfo: file},
+		{Type: ActionDelete, RelativePath: filepath.Join("old", "sub", "deep.txt")},
+		{Type: ActionNone, RelativePath: "same.txt", SourceInfo: file},
+		{Type: ActionMkdir, RelativePath: filepath.Join("a", "b"), SourceInfo: dir},
+		{Type: ActionRmdir, RelativePath: filepath.Join("old", "sub")},
+		{Type: ActionCreate, RelativePath: filepath.Join("a", "first.txt"), SourceInfo: file},
+		{Type: ActionChmod, RelativePath: "mode.sh", SourceInfo: file},
+	}
+
+	var got []string
+	for _, action := range planPhases(actions) {
+		got = append(got, action.RelativePath)
+	}
+	require.Equal(t, []string{
+		// Directories, shallow to deep
+		"a",
+		filepath.Join("a", "b"),
+		filepath.Join("a", "b", "c"),
+		// Files and other changes, in planned order
+		filepath.Join("a", "b", "c", "new.txt"),
+		"z.txt",
+		"same.txt",
+		filepath.Join("a", "first.txt"),
+		"mode.sh",
+		// Deletes, deep to shallow
+		filepath.Join("old", "sub", "deep.txt"),
+		filepath.Join("old", "sub"),
+		"gone.txt",
+		"old",
+	}, got)
+	require.Equal(t, ActionDelete, actions[0].Type, "Expected the planned actions to be left untouched")
+}
+
+func TestSyncPhased(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "old", "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "old", "sub", "deep.txt"), []byte("deep"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "keep.txt"), []byte("keep"), 0644))
+	cfg := config.NewDefaultConfig()
+	cfg.Phased = true
+
+	_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	// Replace the old tree with a new nested one
+	require.NoError(t, os.RemoveAll(filepath.Join(srcDir, "old")))
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "new", "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "new", "sub", "deep.txt"), []byte("new deep"), 0644))
+
+	summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.Equal(t, 2, summary.DirsCreated)
+	require.NoDirExists(t, filepath.Join(dstDir, "old"))
+	got, err := os.ReadFile(filepath.Join(dstDir, "new", "sub", "deep.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "new deep", string(got))
+	require.FileExists(t, filepath.Join(dstDir, "keep.txt"))
+}
+
+func TestOrderByLocality(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	files := []string{
+		filepath.Join("b", "one.txt"),
+		filepath.Join("a", "one.txt"),
+		filepath.Join("b", "two.txt"),
+		"root.txt",
+		filepath.Join("a", "two.txt"),
+	}
+	for _, rel := range files {
+		path := filepath.Join(srcDir, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755), "Failed to create parent directory")
+		require.NoError(t, os.WriteFile(path, []byte("content of "+rel), 0644), "Failed to create source file")
+	}
+
+	entries, err := ScanSource(srcDir, config.NewDefaultConfig())
+	require.NoError(t, err, "Expected no error scanning source")
+
+	// Interleave directories on purpose to simulate random planning order
+	var actions []SyncAction
+	for _, rel := range files {
+		actions = append(actions, SyncAction{Type: ActionCreate, RelativePath: rel, SourceInfo: entries[rel]})
+	}
+	actions = append(actions, SyncAction{Type: ActionDelete, RelativePath: "stale.txt"})
+
+	ordered := orderByLocality(srcDir, actions)
+	require.Len(t, ordered, len(actions), "Expected no actions to be lost")
+	require.Equal(t, ActionDelete, ordered[len(ordered)-1].Type, "Expected non-copy actions to keep their position")
+
+	t.Run("GroupsByDirectory", func(t *testing.T) {
+		seen := make(map[string]bool)
+		lastDir := ""
+		for _, action := range ordered {
+			if !isFileCopy(action) {
+				continue
+			}
+			dir := filepath.Dir(action.RelativePath)
+			if dir != lastDir {
+				require.False(t, seen[dir], "Expected files of %q to be contiguous", dir)
+				seen[dir] = true
+				lastDir = dir
+			}
+		}
+		require.Len(t, seen, 3, "Expected three source directories")
+	})
+
+	t.Run("KeepsCopiesAfterTheirParent", func(t *testing.T) {
+		// "+dir/x" sorts before "a.txt" by directory, but must wait for its parent
+		actions := []SyncAction{
+			{Type: ActionCreate, RelativePath: "a.txt"},
+			{Type: ActionMkdir, RelativePath: "+dir", SourceInfo: EntryInfo{IsDir: true}},
+			{Type: ActionCreate, RelativePath: filepath.Join("+dir", "x")},
+		}
+		require.Equal(t, actions, orderByLocality(srcDir, actions))
+	})
+
+	t.Run("DoesNotMutateInput", func(t *testing.T) {
+		require.Equal(t, filepath.Join("b", "one.txt"), actions[0].RelativePath, "Expected input slice to be untouched")
+	})
+
+	t.Run("ExecutesCorrectly", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.CopyOrder = config.CopyOrderLocality
+
+		_, err := ExecuteActions(srcDir, dstDir, actions, cfg)
+		require.NoError(t, err, "Expected no error executing actions")
+
+		for _, rel := range files {
+			content, err := os.ReadFile(filepath.Join(dstDir, rel))
+			require.NoError(t, err, "Expected destination file %q to exist", rel)
+			require.Equal(t, "content of "+rel, string(content), "Expected identical content for %q", rel)
+		}
+	})
+}
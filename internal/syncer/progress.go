@@ -0,0 +1,76 @@
+package syncer
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+	"github.com/ogzhanolguncu/mimic/internal/report"
+)
+
+const (
+	progressFile          = ".sync_progress"
+	progressFlushInterval = time.Second
+)
+
+// persistedProgress carries the totals of an interrupted run over to the next one.
+type persistedProgress struct {
+	Summary   report.Summary `json:"s"`
+	UpdatedAt int64          `json:"u"`
+}
+
+// loadProgress returns the totals left behind by an interrupted run, if any. The lists
+// of paths belong to the run that made them and are not carried over.
+func loadProgress(dstRoot string) (report.Summary, bool) {
+	data, err := os.ReadFile(filepath.Join(dstRoot, progressFile))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logger.Warn("cannot read persisted progress, starting from zero", "error", err)
+		}
+		return report.Summary{}, false
+	}
+
+	var progress persistedProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		logger.Warn("cannot parse persisted progress, starting from zero", "error", err)
+		return report.Summary{}, false
+	}
+
+	logger.Info("resuming from persisted progress",
+		"files_created", progress.Summary.FilesCreated,
+		"bytes_transferred", progress.Summary.BytesTransferred)
+	progress.Summary.Deferred, progress.Summary.Failed, progress.Summary.Kept = nil, nil, nil
+	return progress.Summary, true
+}
+
+// saveProgress atomically persists the running totals into dstRoot.
+func saveProgress(dstRoot string, summary report.Summary) error {
+	summary.Deferred, summary.Failed, summary.Kept = nil, nil, nil
+	data, err := json.Marshal(persistedProgress{Summary: summary, UpdatedAt: clock.Now().UnixMilli()})
+	if err != nil {
+		return err
+	}
+
+	location := filepath.Join(dstRoot, progressFile)
+	tempFile := fileops.TempPath(location)
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempFile, location); err != nil {
+		_ = os.Remove(tempFile)
+		return err
+	}
+	return nil
+}
+
+// clearProgress removes persisted totals once a run has completed.
+func clearProgress(dstRoot string) {
+	if err := os.Remove(filepath.Join(dstRoot, progressFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Warn("cannot remove persisted progress", "error", err)
+	}
+}
@@ -0,0 +1,95 @@
+package syncer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistedProgressAcrossResume(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	files := map[string]string{
+		"first.txt":  "first segment",
+		"second.txt": "also first segment",
+		"third.txt":  "second segment",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644))
+	}
+
+	cfg := config.NewDefaultConfig()
+	cfg.PersistProgress = true
+
+	entries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+
+	// First segment is interrupted by a file that vanished from the source
+	firstSegment := []SyncAction{
+		{Type: ActionCreate, RelativePath: "first.txt", SourceInfo: entries["first.txt"]},
+		{Type: ActionCreate, RelativePath: "second.txt", SourceInfo: entries["second.txt"]},
+		{Type: ActionCreate, RelativePath: "vanished.txt", SourceInfo: EntryInfo{RelativePath: "vanished.txt", Size: 99}},
+	}
+	partial, err := ExecuteActions(srcDir, dstDir, firstSegment, cfg)
+	require.Error(t, err, "Expected the first segment to be interrupted")
+	require.Equal(t, 2, partial.FilesCreated)
+
+	_, err = os.Stat(filepath.Join(dstDir, progressFile))
+	require.NoError(t, err, "Expected progress to be persisted after interruption")
+
+	// Resumed run only has the remaining work
+	secondSegment := []SyncAction{
+		{Type: ActionCreate, RelativePath: "third.txt", SourceInfo: entries["third.txt"]},
+	}
+	final, err := ExecuteActions(srcDir, dstDir, secondSegment, cfg)
+	require.NoError(t, err)
+
+	require.Equal(t, 3, final.FilesCreated, "Expected totals to span both segments")
+	require.Equal(t, int64(len(files["first.txt"])+len(files["second.txt"])+len(files["third.txt"])), final.BytesTransferred)
+	require.GreaterOrEqual(t, final.Elapsed, partial.Elapsed, "Expected elapsed time to accumulate")
+
+	_, err = os.Stat(filepath.Join(dstDir, progressFile))
+	require.ErrorIs(t, err, os.ErrNotExist, "Expected progress to be cleared after a completed run")
+
+	t.Run("ContinueOnErrorCompletes", func(t *testing.T) {
+		otherDst := t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.PersistProgress = true
+		cfg.ContinueOnError = true
+
+		failed, err := ExecuteActions(srcDir, otherDst, firstSegment, cfg)
+		require.ErrorIs(t, err, ErrSyncerActionsFailed)
+		require.Equal(t, []string{"vanished.txt"}, failed.Failed)
+		_, err = os.Stat(filepath.Join(otherDst, progressFile))
+		require.ErrorIs(t, err, os.ErrNotExist, "Expected a run with only failed actions to clear its progress")
+
+		next, err := ExecuteActions(srcDir, otherDst, secondSegment, cfg)
+		require.NoError(t, err)
+		require.Empty(t, next.Failed, "Expected failures not to carry over to the next run")
+		require.Equal(t, 1, next.FilesCreated)
+	})
+
+	t.Run("ListsNotPersisted", func(t *testing.T) {
+		otherDst := t.TempDir()
+		require.NoError(t, saveProgress(otherDst, report.Summary{FilesCreated: 2, Failed: []string{"a.txt"}, Deferred: []string{"b.txt"}}))
+		prior, ok := loadProgress(otherDst)
+		require.True(t, ok)
+		require.Equal(t, 2, prior.FilesCreated)
+		require.Empty(t, prior.Failed)
+		require.Empty(t, prior.Deferred)
+	})
+
+	t.Run("DisabledDoesNotPersist", func(t *testing.T) {
+		otherDst := t.TempDir()
+		_, err := ExecuteActions(srcDir, otherDst, firstSegment, config.NewDefaultConfig())
+		require.Error(t, err)
+
+		_, err = os.Stat(filepath.Join(otherDst, progressFile))
+		require.ErrorIs(t, err, os.ErrNotExist, "Expected no progress file without the option")
+	})
+}
@@ -0,0 +1,77 @@
+package syncer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/report"
+)
+
+// ChangeReason records which attributes made CompareStates plan an update.
+// Several reasons can be set at once.
+type ChangeReason uint8
+
+const (
+	ReasonSizeChanged ChangeReason = 1 << iota
+	ReasonMtimeChanged
+	ReasonChecksumDiffered
+	ReasonPermsChanged
+	// ReasonCaseRenamed marks an entry recorded under a path that only differs in case
+	// (see config.CaseInsensitive); PreviousInfo.RelativePath holds the old path.
+	ReasonCaseRenamed
+)
+
+// Has reports whether all bits of other are set in r.
+func (r ChangeReason) Has(other ChangeReason) bool {
+	return r&other == other
+}
+
+// changeReason compares the recorded entry with the scanned one.
+func changeReason(previous, current EntryInfo, mtimeChanged bool) ChangeReason {
+	var reason ChangeReason
+	if previous.Size != current.Size {
+		reason |= ReasonSizeChanged
+	}
+	if mtimeChanged {
+		reason |= ReasonMtimeChanged
+	}
+	if checksumsDiffer(previous, current) {
+		reason |= ReasonChecksumDiffered
+	}
+	if previous.Permissions != current.Permissions {
+		reason |= ReasonPermsChanged
+	}
+	return reason
+}
+
+// checksumsDiffer reports whether both entries carry a checksum of the same kind (full or
+// quick, see EntryInfo.QuickHash) and they differ.
+func checksumsDiffer(previous, current EntryInfo) bool {
+	return previous.Checksum != "" && current.Checksum != "" && previous.QuickHash == current.QuickHash &&
+		previous.Checksum != current.Checksum
+}
+
+// DescribeReason renders the reason of an update for humans, e.g. "size 100 B→200 B, mtime".
+// It returns an empty string for actions without a reason.
+func DescribeReason(action SyncAction) string {
+	var parts []string
+	if action.Reason.Has(ReasonCaseRenamed) {
+		parts = append(parts, "renamed from "+filepath.Base(action.PreviousInfo.RelativePath))
+	}
+	if action.Reason.Has(ReasonSizeChanged) {
+		parts = append(parts, fmt.Sprintf("size %s→%s",
+			report.FormatSize(action.PreviousInfo.Size), report.FormatSize(action.SourceInfo.Size)))
+	}
+	if action.Reason.Has(ReasonMtimeChanged) {
+		parts = append(parts, "mtime")
+	}
+	if action.Reason.Has(ReasonChecksumDiffered) {
+		parts = append(parts, "checksum")
+	}
+	if action.Reason.Has(ReasonPermsChanged) {
+		parts = append(parts, fmt.Sprintf("perms %s→%s",
+			action.PreviousInfo.Permissions.Perm(), action.SourceInfo.Permissions.Perm()))
+	}
+	return strings.Join(parts, ", ")
+}
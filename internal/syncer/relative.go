@@ -0,0 +1,50 @@
+package syncer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// relativePrefix returns the path of absRoot below base for config.RelativeTo, which is
+// prepended to every scanned entry. It is empty without a base, when base is the root
+// itself, and with flatten, which drops all directories anyway.
+func relativePrefix(absRoot, base string, flatten bool) (string, error) {
+	if base == "" || flatten {
+		return "", nil
+	}
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrSyncerFaultyRelPath, err)
+	}
+	prefix, err := filepath.Rel(absBase, absRoot)
+	if err != nil || prefix == ".." || strings.HasPrefix(prefix, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s is not below %s", ErrSyncerRelativeTo, absRoot, absBase)
+	}
+	if prefix == "." {
+		return "", nil
+	}
+	return prefix, nil
+}
+
+// addPrefixDirs records the directories of prefix, from the top down to the source root
+// itself, so the destination gets them with their source metadata like any other
+// directory. Their SourcePath is relative to the root, e.g. ".." for a parent.
+func addPrefixDirs(entries map[string]EntryInfo, absRoot, prefix string) error {
+	sourcePath := "."
+	for dir := prefix; dir != "." && dir != ""; dir, sourcePath = filepath.Dir(dir), filepath.Join(sourcePath, "..") {
+		info, err := os.Stat(filepath.Join(absRoot, sourcePath))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrSyncerSrcNotExists, err)
+		}
+		entries[dir] = EntryInfo{
+			RelativePath: dir,
+			Mtime:        info.ModTime(),
+			IsDir:        true,
+			Permissions:  info.Mode(),
+			SourcePath:   sourcePath,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelativePrefix(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "projects", "app")
+
+	testCases := []struct {
+		name      string
+		base      string
+		flatten   bool
+		expected  string
+		expectErr bool
+	}{
+		{name: "NoBase", expected: ""},
+		{name: "Ancestor", base: base, expected: filepath.Join("projects", "app")},
+		{name: "Parent", base: filepath.Join(base, "projects"), expected: "app"},
+		{name: "RootItself", base: root, expected: ""},
+		{name: "Flatten", base: base, flatten: true, expected: ""},
+		{name: "NotAnAncestor", base: filepath.Join(base, "other"), expectErr: true},
+		{name: "Below", base: filepath.Join(root, "src"), expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prefix, err := relativePrefix(root, tc.base, tc.flatten)
+			if tc.expectErr {
+				require.ErrorIs(t, err, ErrSyncerRelativeTo)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, prefix)
+		})
+	}
+}
+
+func TestSyncRelativeTo(t *testing.T) {
+	base := t.TempDir()
+	srcDir := filepath.Join(base, "projects", "app")
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "src"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "go.mod"), []byte("module app"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "src", "main.go"), []byte("package main"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "projects", "outside.txt"), []byte("not synced"), 0644))
+
+	dstDir := t.TempDir()
+	cfg := config.NewDefaultConfig()
+	cfg.RelativeTo = base
+
+	_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	for name, content := range map[string]string{
+		"projects/app/go.mod":      "module app",
+		"projects/app/src/main.go": "package main",
+	} {
+		got, err := os.ReadFile(filepath.Join(dstDir, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		require.Equal(t, content, string(got))
+	}
+	require.NoFileExists(t, filepath.Join(dstDir, "go.mod"))
+	require.NoFileExists(t, filepath.Join(dstDir, "projects", "outside.txt"), "Expected only the source root's contents")
+
+	// A second run finds everything, including the prefix directories, unchanged
+	summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.Zero(t, summary.FilesCreated+summary.FilesUpdated+summary.FilesDeleted)
+}
@@ -0,0 +1,35 @@
+package syncer
+
+import (
+	"os"
+
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+// ApplyRootMetadata gives the root of dst the permissions and mtime of the local
+// directory srcRoot (see config.RootMetadata). The scan never records the root
+// itself, so it is read here. Callers apply it once nothing else will write to the
+// destination root, since a later state or lock file would bump its mtime and a
+// read-only mode could keep them from being written at all. Failures are logged and
+// skipped, like ApplyDirTimes.
+func ApplyRootMetadata(srcRoot string, dst Destination) {
+	info, err := os.Stat(srcRoot)
+	if err != nil {
+		logger.Warn("cannot read source root metadata", "path", srcRoot, "error", err)
+		return
+	}
+	if setter, ok := dst.(chmoder); ok {
+		if err := setter.Chmod(".", info.Mode()); err != nil {
+			logger.Warn("cannot set destination root mode", "error", err)
+		}
+	} else {
+		logger.Warn("destination does not support changing modes, root mode not preserved")
+	}
+	if setter, ok := dst.(timeSetter); ok {
+		if err := setter.Chtimes(".", info.ModTime()); err != nil {
+			logger.Warn("cannot set destination root mtime", "error", err)
+		}
+	} else {
+		logger.Warn("destination does not support setting times, root mtime not preserved")
+	}
+}
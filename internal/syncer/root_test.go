@@ -0,0 +1,79 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncRootMetadata(t *testing.T) {
+	rootMtime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	newSource := func(t *testing.T, mode os.FileMode) string {
+		srcDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("alpha"), 0644))
+		require.NoError(t, os.Chmod(srcDir, mode))
+		require.NoError(t, os.Chtimes(srcDir, rootMtime, rootMtime))
+		t.Cleanup(func() { os.Chmod(srcDir, 0755) })
+		return srcDir
+	}
+	requireRoot := func(t *testing.T, dstDir string, mode os.FileMode) {
+		info, err := os.Stat(dstDir)
+		require.NoError(t, err)
+		require.Equal(t, mode, info.Mode().Perm())
+		require.True(t, info.ModTime().Equal(rootMtime), "Expected the source root mtime, got %v", info.ModTime())
+	}
+
+	t.Run("Sync", func(t *testing.T) {
+		srcDir, dstDir := newSource(t, 0750), t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.RootMetadata = true
+
+		_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.NoError(t, err)
+		require.FileExists(t, filepath.Join(dstDir, "a.txt"))
+		// The state file and lock live in the root, so they must not bump its mtime afterwards
+		requireRoot(t, dstDir, 0750)
+	})
+
+	t.Run("ReadOnlyRootKeepsState", func(t *testing.T) {
+		srcDir, dstDir := newSource(t, 0555), t.TempDir()
+		t.Cleanup(func() { os.Chmod(dstDir, 0755) })
+		cfg := config.NewDefaultConfig()
+		cfg.RootMetadata = true
+
+		_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.NoError(t, err)
+		requireRoot(t, dstDir, 0555)
+
+		state, err := LoadState(dstDir, cfg)
+		require.NoError(t, err)
+		require.Contains(t, state.Entries, "a.txt")
+	})
+
+	t.Run("ExecuteActions", func(t *testing.T) {
+		srcDir, dstDir := newSource(t, 0700), t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.RootMetadata = true
+		entries, err := ScanSource(srcDir, cfg)
+		require.NoError(t, err)
+
+		_, err = ExecuteActions(srcDir, dstDir, CompareStates(entries, map[string]EntryInfo{}, cfg), cfg)
+		require.NoError(t, err)
+		requireRoot(t, dstDir, 0700)
+	})
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		srcDir, dstDir := newSource(t, 0750), t.TempDir()
+
+		_, err := Sync(context.Background(), srcDir, dstDir, config.NewDefaultConfig())
+		require.NoError(t, err)
+		info, err := os.Stat(dstDir)
+		require.NoError(t, err)
+		require.False(t, info.ModTime().Equal(rootMtime))
+	})
+}
@@ -0,0 +1,46 @@
+package syncer
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/report"
+)
+
+// buildRunStats records a finished (or failed) run for -stats-file. The extension
+// breakdown covers every planned file action in result.
+func buildRunStats(result *Summary, runErr error, started time.Time, wallTime time.Duration) report.RunStats {
+	stats := report.NewRunStats(result.Summary, started, wallTime)
+	if runErr != nil {
+		// Joined action failures are listed one per entry
+		stats.Errors = append(stats.Errors, strings.Split(runErr.Error(), "\n")...)
+	}
+
+	for _, action := range result.Actions {
+		if action.SourceInfo.IsDir || action.Type == ActionNone || action.Type == ActionChmod {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(action.RelativePath))
+		extStats := stats.Extensions[ext]
+		switch action.Type {
+		case ActionCreate:
+			extStats.Created++
+		case ActionUpdate:
+			extStats.Updated++
+		case ActionDelete:
+			extStats.Deleted++
+		}
+		extStats.Bytes += action.SourceInfo.Size
+		stats.Extensions[ext] = extStats
+	}
+	return stats
+}
+
+// writeStatsFile writes stats as JSON to path, replacing it only once fully written.
+func writeStatsFile(path string, stats report.RunStats) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return report.WriteRunStats(w, stats)
+	})
+}
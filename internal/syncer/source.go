@@ -0,0 +1,49 @@
+package syncer
+
+import (
+	"path/filepath"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+)
+
+// Source is what a sync reads from. Paths are relative to the source root.
+type Source interface {
+	// Scan returns the source entries keyed by their relative path, like ScanSource.
+	Scan(cfg *config.Config) (map[string]EntryInfo, error)
+	// Open makes the file at relPath readable on the local file system and returns its
+	// path together with a func that releases it once the copy is done.
+	Open(relPath string) (path string, release func(), err error)
+	// Close releases the source.
+	Close() error
+}
+
+// DirSource is the default Source, a local directory.
+type DirSource struct {
+	root string
+}
+
+func NewDirSource(root string) *DirSource {
+	return &DirSource{root: root}
+}
+
+func (s *DirSource) Root() string { return s.root }
+
+func (s *DirSource) Scan(cfg *config.Config) (map[string]EntryInfo, error) {
+	return ScanSource(s.root, cfg)
+}
+
+// Open returns the file's own path; nothing needs releasing.
+func (s *DirSource) Open(relPath string) (string, func(), error) {
+	return filepath.Join(s.root, relPath), func() {}, nil
+}
+
+func (s *DirSource) Close() error { return nil }
+
+// OpenSource returns an ArchiveSource when path names a supported archive (see
+// IsArchive) and a DirSource otherwise.
+func OpenSource(path string) Source {
+	if IsArchive(path) {
+		return NewArchiveSource(path)
+	}
+	return NewDirSource(path)
+}
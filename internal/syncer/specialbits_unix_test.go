@@ -0,0 +1,43 @@
+//go:build unix
+
+package syncer
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncPreserveSpecialBits(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	sharedDir := filepath.Join(srcDir, "shared")
+	require.NoError(t, os.Mkdir(sharedDir, 0755))
+	require.NoError(t, os.Chmod(sharedDir, 0775|fs.ModeSetgid|fs.ModeSticky))
+	tool := filepath.Join(srcDir, "tool")
+	require.NoError(t, os.WriteFile(tool, []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.Chmod(tool, 0755|fs.ModeSetgid))
+
+	info, err := os.Stat(tool)
+	require.NoError(t, err)
+	if info.Mode()&fs.ModeSetgid == 0 {
+		t.Skip("file system does not keep the setgid bit")
+	}
+
+	cfg := config.NewDefaultConfig()
+	cfg.PreserveSpecialBits = true
+	_, err = Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	dirInfo, err := os.Stat(filepath.Join(dstDir, "shared"))
+	require.NoError(t, err)
+	require.Equal(t, 0775|fs.ModeDir|fs.ModeSetgid|fs.ModeSticky, dirInfo.Mode())
+
+	fileInfo, err := os.Stat(filepath.Join(dstDir, "tool"))
+	require.NoError(t, err)
+	require.Equal(t, 0755|fs.ModeSetgid, fileInfo.Mode())
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package syncer
+
+import "os"
+
+// fileInode is not available on this platform.
+func fileInode(_ os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// fileDevice is not available on this platform.
+func fileDevice(_ os.FileInfo) (uint64, bool) {
+	return 0, false
+}
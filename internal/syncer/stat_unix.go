@@ -0,0 +1,26 @@
+//go:build unix
+
+package syncer
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns the inode number of the file, if available.
+func fileInode(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Ino), true
+}
+
+// fileDevice returns the id of the device holding the file, if available.
+func fileDevice(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Dev), true
+}
@@ -1,14 +1,22 @@
 package syncer
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
-	"time"
+	"slices"
 
+	"github.com/cespare/xxhash/v2"
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
 	"github.com/ogzhanolguncu/mimic/internal/logger"
 )
 
@@ -16,6 +24,11 @@ type SyncState struct {
 	Version  int                  `json:"v"`  // Schema version of the state file.
 	LastSync int64                `json:"ls"` // When the previous sync completed.
 	Entries  map[string]EntryInfo `json:"e"`  // Maps relative paths to their metadata.
+	// PendingDeletes maps the paths whose delete is held back by config.DeleteDelay to when
+	// they were first found missing from the source (Unix milliseconds).
+	PendingDeletes map[string]int64 `json:"pd,omitempty"`
+	// Checksum of the fields above, recorded with cfg.VerifyStateChecksum (see stateChecksum).
+	Checksum string `json:"cs,omitempty"`
 }
 
 var (
@@ -28,11 +41,87 @@ var (
 	ErrSyncStateRead          = errors.New("sync_state: failed to read a file")
 	ErrSyncStateJSONParse     = errors.New("sync_state: failed to parse JSON")
 	ErrSyncStateJSONSerialize = errors.New("sync_state: failed to serialize JSON")
+	ErrSyncStateVerify        = errors.New("sync_state: written state does not match in-memory state")
+	ErrSyncStateChecksum      = errors.New("sync_state: state does not match its checksum")
 )
 
-const stateFile = ".sync_state"
+const (
+	stateFile       = ".sync_state"
+	stateBackupFile = stateFile + ".bak" // The state as of the save before the latest
+	// stateFormatNDJSON marks a state file saved with cfg.StateNDJSON (see encodeState).
+	stateFormatNDJSON = "ndjson"
+)
+
+// stateHeader is the first line of an NDJSON state file: the state without its entries,
+// which follow as one ndjsonEntry per line.
+type stateHeader struct {
+	Format string `json:"fmt,omitempty"`
+	*SyncState
+}
+
+// ndjsonEntry is one entry line of an NDJSON state file.
+type ndjsonEntry struct {
+	Path  string    `json:"p"`
+	Entry EntryInfo `json:"e"`
+}
+
+// StateFS is the set of file operations used to persist state. The default works on
+// the local filesystem; remote destinations provide their own. Tests swap stateFS to
+// inject faults.
+type StateFS interface {
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	ReadFile(name string) ([]byte, error)
+	Open(name string) (io.ReadCloser, error)
+	Stat(name string) (fs.FileInfo, error)
+	MkdirAll(path string, perm os.FileMode) error
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+}
+
+type osStateFS struct{}
+
+func (osStateFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+func (osStateFS) ReadFile(name string) ([]byte, error)         { return os.ReadFile(name) }
+func (osStateFS) Open(name string) (io.ReadCloser, error)      { return os.Open(name) }
+func (osStateFS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (osStateFS) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+func (osStateFS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (osStateFS) Remove(name string) error                     { return os.Remove(name) }
 
-func LoadState(dstDir string) (*SyncState, error) {
+var stateFS StateFS = osStateFS{}
+
+// statePaths returns where the state of dstDir is kept: the directory holding it, the
+// state file and its backup. That is dstDir itself unless cfg.StateDir is set; the files
+// then live there, named after a hash of dstDir's absolute path so every destination
+// gets its own.
+func statePaths(dstDir string, cfg *config.Config) (dir, file, backup string) {
+	if cfg.StateDir == "" {
+		return dstDir, filepath.Join(dstDir, stateFile), filepath.Join(dstDir, stateBackupFile)
+	}
+	key, err := filepath.Abs(dstDir)
+	if err != nil {
+		key = filepath.Clean(dstDir)
+	}
+	file = filepath.Join(cfg.StateDir, fmt.Sprintf("%016x%s", xxhash.Sum64String(key), stateFile))
+	return cfg.StateDir, file, file + ".bak"
+}
+
+// LoadState reads the state file of dstDir, creating a fresh one if it does not exist.
+// The file is kept in dstDir, or in cfg.StateDir when set (see statePaths).
+// An empty or corrupt state file falls back to the backup kept by SaveState, and failing
+// that to a fresh state, so the run becomes a full create rather than failing. With
+// cfg.VerifyStateChecksum so does a state that no longer matches its recorded checksum.
+// With cfg.StreamStateLoad the entries are decoded one at a time instead of
+// unmarshalling the whole file at once, which keeps peak memory low for huge states.
+// Both state file formats are read whatever cfg.StateNDJSON is set to.
+func LoadState(dstDir string, cfg *config.Config) (*SyncState, error) {
+	return LoadStateFS(stateFS, dstDir, cfg)
+}
+
+// LoadStateFS is LoadState against an arbitrary StateFS, e.g. a remote destination.
+func LoadStateFS(fsys StateFS, dstDir string, cfg *config.Config) (*SyncState, error) {
 	if dstDir == "" {
 		return nil, ErrSyncStateEmptyDst
 	}
@@ -40,38 +129,282 @@ func LoadState(dstDir string) (*SyncState, error) {
 	op := "LoadState"
 	logger.Debug("loading state", "operation", op, "dir", dstDir)
 
-	stateFileLocation := filepath.Join(dstDir, stateFile)
+	_, stateFileLocation, backupLocation := statePaths(dstDir, cfg)
 
-	_, err := os.Stat(stateFileLocation)
-	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
-			logger.Info("state file does not exist, creating new one", "operation", op, "path", stateFileLocation)
+	synState, err := loadStateFile(fsys, stateFileLocation, cfg)
+	switch {
+	case err == nil:
+		return synState, nil
+	case errors.Is(err, ErrSyncStateJSONParse), errors.Is(err, ErrSyncStateChecksum):
+		logger.Warn("state file is empty or corrupt, trying the backup", "operation", op, "path", stateFileLocation, "error", err)
+	case errors.Is(err, fs.ErrNotExist):
+		// A save interrupted between rotating and replacing leaves only the backup
+	default:
+		return nil, err
+	}
 
-			data := &SyncState{
-				Version:  1,
-				LastSync: time.Now().UnixMilli(),
-				Entries:  make(map[string]EntryInfo),
-			}
+	if synState, backupErr := loadStateFile(fsys, backupLocation, cfg); backupErr == nil {
+		logger.Warn("recovered state from backup; changes since that save will be synced again", "operation", op, "path", backupLocation)
+		return synState, nil
+	}
+
+	if errors.Is(err, fs.ErrNotExist) {
+		logger.Info("state file does not exist, creating new one", "operation", op, "path", stateFileLocation)
+	} else {
+		logger.Warn("no usable state found, starting fresh; every file will be copied", "operation", op, "path", stateFileLocation)
+	}
+	data := &SyncState{
+		Version:  1,
+		LastSync: clock.Now().UnixMilli(),
+		Entries:  make(map[string]EntryInfo),
+	}
+	return data, SaveStateFS(fsys, dstDir, data, cfg)
+}
+
+// loadStateFile reads and decodes a single state file. A missing file is reported with
+// an error wrapping fs.ErrNotExist, an undecodable one with ErrSyncStateJSONParse and,
+// with cfg.VerifyStateChecksum, an altered one with ErrSyncStateChecksum.
+func loadStateFile(fsys StateFS, path string, cfg *config.Config) (*SyncState, error) {
+	synState, err := decodeStateFile(fsys, path, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.VerifyStateChecksum {
+		if err := verifyStateChecksum(synState); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, path)
+		}
+	}
+	return normalizeStatePaths(synState), nil
+}
 
-			return data, SaveState(dstDir, data)
+func decodeStateFile(fsys StateFS, path string, cfg *config.Config) (*SyncState, error) {
+	if _, err := fsys.Stat(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, err
 		}
 		return nil, fmt.Errorf("%w: %v", ErrSyncStateRead, err)
 	}
 
-	data, err := os.ReadFile(stateFileLocation)
+	if cfg.StreamStateLoad {
+		return loadStateStreaming(fsys, path)
+	}
+
+	data, err := fsys.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrSyncStateRead, err)
 	}
+	return decodeState(data)
+}
 
+// decodeState decodes a whole state file held in memory, in either format.
+func decodeState(data []byte) (*SyncState, error) {
 	synState := &SyncState{}
-	if err := json.Unmarshal(data, synState); err != nil {
+	header := stateHeader{SyncState: synState}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&header); err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrSyncStateJSONParse, err)
 	}
+	if synState.Entries == nil {
+		synState.Entries = make(map[string]EntryInfo)
+	}
+	if err := decodeEntryLines(dec, header.Format, synState.Entries); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: unexpected data after the state", ErrSyncStateJSONParse)
+	}
 
 	return synState, nil
 }
 
-func SaveState(dstDir string, state *SyncState) error {
+// decodeEntryLines decodes the entry lines that follow the header of an NDJSON state
+// file into entries, one at a time. format is the one the header declared; a state
+// without one has no entry lines.
+func decodeEntryLines(dec *json.Decoder, format string, entries map[string]EntryInfo) error {
+	switch format {
+	case "":
+		return nil
+	case stateFormatNDJSON:
+	default:
+		return fmt.Errorf("%w: unknown state format %q", ErrSyncStateJSONParse, format)
+	}
+	for dec.More() {
+		var line ndjsonEntry
+		if err := dec.Decode(&line); err != nil {
+			return fmt.Errorf("%w: %v", ErrSyncStateJSONParse, err)
+		}
+		entries[line.Path] = line.Entry
+	}
+	return nil
+}
+
+// stateChecksum hashes the version, last sync time, every entry and every pending delete
+// in path order, so it does not depend on how the file is formatted or whether it was
+// decoded streaming.
+func stateChecksum(state *SyncState) (string, error) {
+	digest := xxhash.New()
+	fmt.Fprintf(digest, "%d %d\n", state.Version, state.LastSync)
+	for _, path := range slices.Sorted(maps.Keys(state.Entries)) {
+		entry, err := json.Marshal(state.Entries[path])
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrSyncStateJSONSerialize, err)
+		}
+		fmt.Fprintf(digest, "%q %s\n", path, entry)
+	}
+	for _, path := range slices.Sorted(maps.Keys(state.PendingDeletes)) {
+		fmt.Fprintf(digest, "pending %q %d\n", path, state.PendingDeletes[path])
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// verifyStateChecksum checks a decoded state against its recorded checksum. A state saved
+// before checksums were enabled has none and is accepted.
+func verifyStateChecksum(state *SyncState) error {
+	if state.Checksum == "" {
+		logger.Debug("state has no checksum to verify")
+		return nil
+	}
+	sum, err := stateChecksum(state)
+	if err != nil {
+		return err
+	}
+	if sum != state.Checksum {
+		return fmt.Errorf("%w: recorded %s, computed %s", ErrSyncStateChecksum, state.Checksum, sum)
+	}
+	return nil
+}
+
+// normalizeStatePaths rewrites slash-separated entry paths to the platform separator,
+// so a state written on a Unix-like system keeps matching the scan on Windows.
+func normalizeStatePaths(state *SyncState) *SyncState {
+	if filepath.Separator == '/' {
+		return state
+	}
+	entries := make(map[string]EntryInfo, len(state.Entries))
+	for relPath, entry := range state.Entries {
+		entry.RelativePath = filepath.FromSlash(entry.RelativePath)
+		entries[filepath.FromSlash(relPath)] = entry
+	}
+	state.Entries = entries
+	if state.PendingDeletes != nil {
+		pending := make(map[string]int64, len(state.PendingDeletes))
+		for relPath, since := range state.PendingDeletes {
+			pending[filepath.FromSlash(relPath)] = since
+		}
+		state.PendingDeletes = pending
+	}
+	return state
+}
+
+// loadStateStreaming decodes the state file token by token, building the entries
+// map incrementally so the raw file never has to be held in memory as a whole. The
+// entries of an NDJSON state file are read line by line after its header.
+func loadStateStreaming(fsys StateFS, stateFileLocation string) (*SyncState, error) {
+	file, err := fsys.Open(stateFileLocation)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyncStateRead, err)
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(file))
+	synState := &SyncState{Entries: make(map[string]EntryInfo)}
+	var format string
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSyncStateJSONParse, err)
+		}
+
+		switch key {
+		case "v":
+			err = dec.Decode(&synState.Version)
+		case "ls":
+			err = dec.Decode(&synState.LastSync)
+		case "e":
+			err = decodeEntriesStreaming(dec, synState.Entries)
+		case "pd":
+			err = dec.Decode(&synState.PendingDeletes)
+		case "cs":
+			err = dec.Decode(&synState.Checksum)
+		case "fmt":
+			err = dec.Decode(&format)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSyncStateJSONParse, err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	if err := decodeEntryLines(dec, format, synState.Entries); err != nil {
+		return nil, err
+	}
+
+	return synState, nil
+}
+
+// decodeEntriesStreaming decodes the "e" object one entry at a time into entries.
+func decodeEntriesStreaming(dec *json.Decoder, entries map[string]EntryInfo) error {
+	first, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if first == nil {
+		return nil // "e": null
+	}
+	if delim, ok := first.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected object for entries, got %v", first)
+	}
+
+	for dec.More() {
+		pathToken, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		path, ok := pathToken.(string)
+		if !ok {
+			return fmt.Errorf("expected entry key, got %v", pathToken)
+		}
+
+		var entry EntryInfo
+		if err := dec.Decode(&entry); err != nil {
+			return err
+		}
+		entries[path] = entry
+	}
+
+	return expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSyncStateJSONParse, err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("%w: expected %v, got %v", ErrSyncStateJSONParse, want, token)
+	}
+	return nil
+}
+
+// SaveState atomically writes the state file of dstDir via a temp file and rename, into
+// dstDir or cfg.StateDir (see statePaths).
+// With cfg.VerifyStateWrite the temp file is read back and compared against the
+// in-memory state before it replaces the previous state file.
+// With cfg.StateNDJSON the file is written in the NDJSON format (see encodeState).
+func SaveState(dstDir string, state *SyncState, cfg *config.Config) error {
+	return SaveStateFS(stateFS, dstDir, state, cfg)
+}
+
+// SaveStateFS is SaveState against an arbitrary StateFS, e.g. a remote destination.
+func SaveStateFS(fsys StateFS, dstDir string, state *SyncState, cfg *config.Config) error {
 	if state == nil {
 		return ErrSyncStateNil
 	}
@@ -82,29 +415,159 @@ func SaveState(dstDir string, state *SyncState) error {
 	op := "SaveState"
 	logger.Debug("saving state", "operation", op, "dir", dstDir)
 
-	stateFileLocation := filepath.Join(dstDir, stateFile)
+	stateDir, stateFileLocation, backupLocation := statePaths(dstDir, cfg)
 
-	state.LastSync = time.Now().UnixMilli()
+	state.LastSync = clock.Now().UnixMilli()
+	state.Checksum = ""
+	if cfg.VerifyStateChecksum {
+		sum, err := stateChecksum(state)
+		if err != nil {
+			return err
+		}
+		state.Checksum = sum
+	}
 
-	data, err := json.Marshal(state)
+	data, err := encodeState(state, cfg.StateNDJSON)
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrSyncStateJSONSerialize, err)
+		return err
 	}
 
-	if err := os.MkdirAll(dstDir, 0755); err != nil {
+	if err := fsys.MkdirAll(stateDir, 0755); err != nil {
 		return fmt.Errorf("%w: %v", ErrSyncStateDstDir, err)
 	}
 
-	tempFile := stateFileLocation + ".tmp"
-	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+	tempFile := fileops.TempPath(stateFileLocation)
+	if err := fsys.WriteFile(tempFile, data, 0644); err != nil {
 		return fmt.Errorf("%w: %v", ErrSyncStateWrite, err)
 	}
 
-	if err := os.Rename(tempFile, stateFileLocation); err != nil {
-		_ = os.Remove(tempFile)
+	if cfg.VerifyStateWrite {
+		if err := verifyStateFile(fsys, tempFile, data, state); err != nil {
+			_ = fsys.Remove(tempFile)
+			return err
+		}
+	}
+
+	// Keep the previous state as a fallback in case this one is ever found corrupt
+	if _, err := fsys.Stat(stateFileLocation); err == nil {
+		if err := fsys.Rename(stateFileLocation, backupLocation); err != nil {
+			logger.Warn("cannot keep a backup of the previous state", "operation", op, "error", err)
+		}
+	}
+
+	if err := fsys.Rename(tempFile, stateFileLocation); err != nil {
+		_ = fsys.Remove(tempFile)
 		return fmt.Errorf("%w: %v", ErrSyncStateReplace, err)
 	}
 
 	logger.Info("state saved successfully", "operation", op)
 	return nil
 }
+
+// encodeState serializes state as a single JSON object or, with ndjson, as a stateHeader
+// line followed by one ndjsonEntry line per entry in path order, so a streaming load
+// never has to decode more than one entry at a time.
+func encodeState(state *SyncState, ndjson bool) ([]byte, error) {
+	if !ndjson {
+		data, err := json.Marshal(state)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSyncStateJSONSerialize, err)
+		}
+		return data, nil
+	}
+
+	header := *state
+	header.Entries = nil
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if err := enc.Encode(stateHeader{Format: stateFormatNDJSON, SyncState: &header}); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyncStateJSONSerialize, err)
+	}
+	for _, path := range slices.Sorted(maps.Keys(state.Entries)) {
+		if err := enc.Encode(ndjsonEntry{Path: path, Entry: state.Entries[path]}); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSyncStateJSONSerialize, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// verifyStateFile reads back a freshly written state file and checks that it matches
+// both the serialized bytes and the in-memory state it was produced from.
+func verifyStateFile(fsys StateFS, path string, expected []byte, state *SyncState) error {
+	written, err := fsys.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSyncStateRead, err)
+	}
+	if !bytes.Equal(written, expected) {
+		return fmt.Errorf("%w: content differs (%d bytes written, %d expected)", ErrSyncStateVerify, len(written), len(expected))
+	}
+
+	reloaded, err := decodeState(written)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSyncStateVerify, err)
+	}
+	if reloaded.Version != state.Version || reloaded.LastSync != state.LastSync || len(reloaded.Entries) != len(state.Entries) {
+		return fmt.Errorf("%w: reloaded state differs", ErrSyncStateVerify)
+	}
+
+	return nil
+}
+
+// ReconcileEntries builds the entries to persist after a run. It starts from the
+// scanned source entries and, for every path whose action was not applied (deferred,
+// filtered out or failed), keeps the previously recorded entry instead, or drops the
+// path when it was never recorded, so the next run plans the same action again.
+func ReconcileEntries(previous, scanned map[string]EntryInfo, notApplied []string) map[string]EntryInfo {
+	entries := maps.Clone(scanned)
+	if entries == nil {
+		entries = make(map[string]EntryInfo)
+	}
+
+	for _, path := range notApplied {
+		if old, ok := previous[path]; ok {
+			entries[path] = old
+		} else {
+			delete(entries, path)
+		}
+	}
+
+	return entries
+}
+
+// PruneState drops the entries that a scan with cfg would no longer produce: paths
+// matching the exclude patterns and files above the maximum size or matching a size
+// exclude rule. It returns the dropped paths in sorted order. Entries outside the scan's
+// mtime window are kept, as a sync leaves their destination copies in place.
+func PruneState(state *SyncState, cfg *config.Config) []string {
+	var dropped []string
+	for _, path := range slices.Sorted(maps.Keys(state.Entries)) {
+		entry := state.Entries[path]
+		tooLarge := !entry.IsDir && cfg.MaxFileSize > 0 && entry.Size > cfg.MaxFileSize
+		sizeExcluded := !entry.IsDir && excludedBySize(path, entry.Size, cfg.SizeExcludeRules)
+		if tooLarge || sizeExcluded || shouldExclude(path, cfg.ExcludePatterns) {
+			delete(state.Entries, path)
+			dropped = append(dropped, path)
+		}
+	}
+	return dropped
+}
+
+// PruneStateFile applies PruneState to the state file in dstDir and rewrites it unless
+// nothing was dropped or cfg.DryRun is set. The destination's files are never touched,
+// and a missing state file is an error rather than being created.
+func PruneStateFile(dstDir string, cfg *config.Config) ([]string, error) {
+	_, stateFileLocation, _ := statePaths(dstDir, cfg)
+	state, err := loadStateFile(stateFS, stateFileLocation, cfg)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: no state file in %s", ErrSyncStateRead, dstDir)
+		}
+		return nil, err
+	}
+
+	dropped := PruneState(state, cfg)
+	if len(dropped) == 0 || cfg.DryRun {
+		return dropped, nil
+	}
+	return dropped, SaveState(dstDir, state, cfg)
+}
@@ -1,11 +1,19 @@
 package syncer
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
 	"github.com/stretchr/testify/require"
 )
 
@@ -32,7 +40,7 @@ func TestSaveAndLoadState(t *testing.T) {
 	}
 
 	// Test SaveState
-	err = SaveState(tempDir, originalState)
+	err = SaveState(tempDir, originalState, config.NewDefaultConfig())
 	require.NoError(t, err, "SaveState should not return an error")
 
 	// Verify file exists
@@ -41,7 +49,7 @@ func TestSaveAndLoadState(t *testing.T) {
 	require.NoError(t, err, "State file should exist")
 
 	// Test LoadState
-	loadedState, err := LoadState(tempDir)
+	loadedState, err := LoadState(tempDir, config.NewDefaultConfig())
 	require.NoError(t, err, "LoadState should not return an error")
 
 	// Verify content
@@ -63,7 +71,7 @@ func TestLoadStateNonExistent(t *testing.T) {
 	defer require.NoError(t, os.RemoveAll(tempDir))
 
 	// Test LoadState on a directory with no existing state file
-	state, err := LoadState(tempDir)
+	state, err := LoadState(tempDir, config.NewDefaultConfig())
 	require.NoError(t, err, "LoadState should create a new state file if none exists")
 	require.NotNil(t, state, "LoadState should return a non-nil state")
 	require.Equal(t, 1, state.Version)
@@ -77,12 +85,350 @@ func TestLoadStateNonExistent(t *testing.T) {
 
 func TestSaveStateErrors(t *testing.T) {
 	// Test nil state
-	err := SaveState("/tmp", nil)
+	err := SaveState("/tmp", nil, config.NewDefaultConfig())
 	require.Error(t, err)
 	require.Equal(t, ErrSyncStateNil, err)
 
 	// Test empty destination
-	err = SaveState("", &SyncState{})
+	err = SaveState("", &SyncState{}, config.NewDefaultConfig())
 	require.Error(t, err)
 	require.Equal(t, ErrSyncStateEmptyDst, err)
 }
+
+func TestStateDir(t *testing.T) {
+	stateDir, dstA, dstB := t.TempDir(), t.TempDir(), t.TempDir()
+	cfg := config.NewDefaultConfig()
+	cfg.StateDir = filepath.Join(stateDir, "states")
+
+	saved := &SyncState{Version: 1, Entries: map[string]EntryInfo{"a.txt": {RelativePath: "a.txt", Size: 1}}}
+	require.NoError(t, SaveState(dstA, saved, cfg))
+	require.NoFileExists(t, filepath.Join(dstA, stateFile), "Expected the destination to stay free of state")
+
+	loaded, err := LoadState(dstA, cfg)
+	require.NoError(t, err)
+	require.Contains(t, loaded.Entries, "a.txt", "Expected the state to be found again in the state dir")
+
+	fresh, err := LoadState(dstB, cfg)
+	require.NoError(t, err)
+	require.Empty(t, fresh.Entries, "Expected another destination not to see the first one's state")
+
+	files, err := os.ReadDir(cfg.StateDir)
+	require.NoError(t, err)
+	require.Len(t, files, 2, "Expected one state file per destination")
+	_, fileA, _ := statePaths(dstA, cfg)
+	_, fileB, _ := statePaths(dstB, cfg)
+	require.NotEqual(t, fileA, fileB)
+
+	// A relative spelling of the same destination maps to the same file
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	relA, err := filepath.Rel(wd, dstA)
+	require.NoError(t, err)
+	_, fileRelA, _ := statePaths(relA, cfg)
+	require.Equal(t, fileA, fileRelA)
+}
+
+func TestLoadStateStreaming(t *testing.T) {
+	mtime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	originalState := &SyncState{
+		Version: 1,
+		Entries: make(map[string]EntryInfo),
+	}
+	for i := range 200 {
+		path := filepath.Join("dir", fmt.Sprintf("file-%03d.txt", i))
+		originalState.Entries[path] = EntryInfo{
+			RelativePath: path,
+			Mtime:        mtime.Add(time.Duration(i) * time.Second),
+			Size:         int64(i * 10),
+			Checksum:     fmt.Sprintf("%016x", i),
+			Permissions:  0644,
+		}
+	}
+	originalState.Entries["dir"] = EntryInfo{RelativePath: "dir", IsDir: true, Permissions: os.ModeDir | 0755}
+	originalState.PendingDeletes = map[string]int64{"gone.txt": 1700000000000}
+
+	for _, ndjson := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ndjson=%v", ndjson), func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := config.NewDefaultConfig()
+			cfg.StateNDJSON = ndjson
+			cfg.VerifyStateChecksum, cfg.VerifyStateWrite = true, true
+			require.NoError(t, SaveState(dir, originalState, cfg), "SaveState should not return an error")
+
+			data, err := os.ReadFile(filepath.Join(dir, stateFile))
+			require.NoError(t, err)
+			if ndjson {
+				require.Equal(t, 202, bytes.Count(data, []byte("\n")), "Expected a header line and a line per entry")
+			}
+
+			fullState, err := LoadState(dir, cfg)
+			require.NoError(t, err, "Full LoadState should not return an error")
+
+			cfg.StreamStateLoad = true
+			streamedState, err := LoadState(dir, cfg)
+			require.NoError(t, err, "Streamed LoadState should not return an error")
+
+			require.Equal(t, fullState, streamedState, "Streamed load should match full load")
+			require.Equal(t, originalState.Entries, streamedState.Entries)
+			require.Equal(t, originalState.PendingDeletes, streamedState.PendingDeletes)
+		})
+	}
+
+	t.Run("MalformedFile", func(t *testing.T) {
+		malformedDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(malformedDir, stateFile), []byte(`{"v":1,"e":{"a":`), 0644))
+
+		_, err := loadStateStreaming(stateFS, filepath.Join(malformedDir, stateFile))
+		require.ErrorIs(t, err, ErrSyncStateJSONParse, "Expected parse error for truncated state")
+	})
+
+	t.Run("TruncatedNDJSON", func(t *testing.T) {
+		malformedDir := t.TempDir()
+		content := `{"fmt":"ndjson","v":1,"ls":0,"e":null}` + "\n" + `{"p":"a.txt","e":{"Size":`
+		require.NoError(t, os.WriteFile(filepath.Join(malformedDir, stateFile), []byte(content), 0644))
+
+		_, err := loadStateStreaming(stateFS, filepath.Join(malformedDir, stateFile))
+		require.ErrorIs(t, err, ErrSyncStateJSONParse, "Expected parse error for a truncated entry line")
+		_, err = decodeState([]byte(content))
+		require.ErrorIs(t, err, ErrSyncStateJSONParse)
+	})
+}
+
+func TestLoadStateRecoversCorruptFile(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{"ZeroBytes", ""},
+		{"TruncatedJSON", `{"v":1,"ls":1700000000000,"e":{"a.txt":{"RelativePath":"a.t`},
+		{"Garbage", "\x00\x00\x00"},
+	}
+
+	for _, tc := range testCases {
+		for _, streaming := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/streaming=%v", tc.name, streaming), func(t *testing.T) {
+				cfg := config.NewDefaultConfig()
+				cfg.StreamStateLoad = streaming
+
+				t.Run("Fresh", func(t *testing.T) {
+					dir := t.TempDir()
+					require.NoError(t, os.WriteFile(filepath.Join(dir, stateFile), []byte(tc.content), 0644))
+
+					state, err := LoadState(dir, cfg)
+					require.NoError(t, err, "Expected a corrupt state file to be recovered from")
+					require.Empty(t, state.Entries, "Expected a fresh state without a backup")
+
+					reloaded, err := LoadState(dir, cfg)
+					require.NoError(t, err, "Expected the fresh state to have been saved")
+					require.Empty(t, reloaded.Entries)
+				})
+
+				t.Run("Backup", func(t *testing.T) {
+					dir := t.TempDir()
+					saved := &SyncState{Version: 1, Entries: map[string]EntryInfo{"a.txt": {RelativePath: "a.txt", Size: 3}}}
+					require.NoError(t, SaveState(dir, saved, cfg))
+					require.NoError(t, SaveState(dir, saved, cfg), "Expected the second save to rotate a backup")
+					require.NoError(t, os.WriteFile(filepath.Join(dir, stateFile), []byte(tc.content), 0644))
+
+					state, err := LoadState(dir, cfg)
+					require.NoError(t, err)
+					require.Contains(t, state.Entries, "a.txt", "Expected the backup state to be used")
+				})
+			})
+		}
+	}
+}
+
+func TestLoadStateVerifyChecksum(t *testing.T) {
+	saved := &SyncState{Version: 1, Entries: map[string]EntryInfo{
+		"a.txt": {RelativePath: "a.txt", Size: 3, Checksum: "aaaa"},
+	}}
+	tampered := &SyncState{Version: 1, Entries: map[string]EntryInfo{
+		"a.txt": {RelativePath: "a.txt", Size: 3, Checksum: "aaaa"},
+		"b.txt": {RelativePath: "b.txt", Size: 5},
+	}}
+
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("streaming=%v", streaming), func(t *testing.T) {
+			cfg := config.NewDefaultConfig()
+			cfg.VerifyStateChecksum = true
+			cfg.StreamStateLoad = streaming
+
+			// Rewrite the state file as if by hand, keeping the checksum of the saved state
+			tamper := func(t *testing.T, dir string, edit func(data []byte) []byte) {
+				t.Helper()
+				path := filepath.Join(dir, stateFile)
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, edit(data), 0644))
+			}
+
+			t.Run("Intact", func(t *testing.T) {
+				dir := t.TempDir()
+				require.NoError(t, SaveState(dir, saved, cfg))
+				state, err := loadStateFile(stateFS, filepath.Join(dir, stateFile), cfg)
+				require.NoError(t, err)
+				require.NotEmpty(t, state.Checksum)
+				require.Equal(t, saved.Entries, state.Entries)
+			})
+
+			t.Run("EditedEntry", func(t *testing.T) {
+				dir := t.TempDir()
+				require.NoError(t, SaveState(dir, saved, cfg))
+				tamper(t, dir, func(data []byte) []byte {
+					return bytes.Replace(data, []byte(`"aaaa"`), []byte(`"bbbb"`), 1)
+				})
+				_, err := loadStateFile(stateFS, filepath.Join(dir, stateFile), cfg)
+				require.ErrorIs(t, err, ErrSyncStateChecksum, "Expected the edit to be caught")
+			})
+
+			t.Run("FallsBackToBackup", func(t *testing.T) {
+				dir := t.TempDir()
+				require.NoError(t, SaveState(dir, saved, cfg))
+				require.NoError(t, SaveState(dir, saved, cfg), "Expected the second save to rotate a backup")
+				sum := saved.Checksum
+				tamperedData, err := json.Marshal(tampered)
+				require.NoError(t, err)
+				tamper(t, dir, func([]byte) []byte {
+					return bytes.Replace(tamperedData, []byte(`"e":`), []byte(`"cs":"`+sum+`","e":`), 1)
+				})
+
+				state, err := LoadState(dir, cfg)
+				require.NoError(t, err)
+				require.Equal(t, saved.Entries, state.Entries, "Expected the backup instead of the tampered state")
+			})
+
+			t.Run("WithoutChecksum", func(t *testing.T) {
+				dir := t.TempDir()
+				require.NoError(t, SaveState(dir, saved, config.NewDefaultConfig()))
+				state, err := LoadState(dir, cfg)
+				require.NoError(t, err, "Expected a state saved without a checksum to be accepted")
+				require.Equal(t, saved.Entries, state.Entries)
+			})
+		})
+	}
+}
+
+// corruptingStateFS flips a byte whenever a temp state file is read back.
+type corruptingStateFS struct {
+	osStateFS
+}
+
+func (corruptingStateFS) ReadFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err == nil && strings.HasSuffix(name, ".tmp") && len(data) > 0 {
+		data[len(data)/2] ^= 0xFF
+	}
+	return data, err
+}
+
+func TestSaveStateVerifyWrite(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := config.NewDefaultConfig()
+	cfg.VerifyStateWrite = true
+
+	oldState := &SyncState{
+		Version: 1,
+		Entries: map[string]EntryInfo{"old.txt": {RelativePath: "old.txt", Size: 10}},
+	}
+	require.NoError(t, SaveState(tempDir, oldState, cfg), "Verified save should succeed on a healthy FS")
+
+	stateFS = corruptingStateFS{}
+	t.Cleanup(func() { stateFS = osStateFS{} })
+
+	newState := &SyncState{
+		Version: 1,
+		Entries: map[string]EntryInfo{"new.txt": {RelativePath: "new.txt", Size: 20}},
+	}
+	err := SaveState(tempDir, newState, cfg)
+	require.ErrorIs(t, err, ErrSyncStateVerify, "Expected verification to fail on corrupted read back")
+
+	stateFS = osStateFS{}
+	loaded, err := LoadState(tempDir, config.NewDefaultConfig())
+	require.NoError(t, err, "Previous state should still load")
+	require.Contains(t, loaded.Entries, "old.txt", "Expected previous state to be preserved")
+	require.NotContains(t, loaded.Entries, "new.txt", "Expected failed state not to be installed")
+
+	_, err = os.Stat(filepath.Join(tempDir, fileops.TempPath(stateFile)))
+	require.ErrorIs(t, err, os.ErrNotExist, "Expected temp file to be cleaned up")
+}
+
+func TestReconcileEntries(t *testing.T) {
+	previous := map[string]EntryInfo{
+		"kept.txt":    {Size: 1, Checksum: "old"},
+		"updated.txt": {Size: 2, Checksum: "old"},
+	}
+	scanned := map[string]EntryInfo{
+		"kept.txt":    {Size: 1, Checksum: "old"},
+		"updated.txt": {Size: 3, Checksum: "new"},
+		"created.txt": {Size: 4, Checksum: "new"},
+	}
+
+	reconciled := ReconcileEntries(previous, scanned, []string{"updated.txt", "created.txt"})
+
+	require.Equal(t, map[string]EntryInfo{
+		"kept.txt":    {Size: 1, Checksum: "old"},
+		"updated.txt": {Size: 2, Checksum: "old"},
+	}, reconciled, "Expected not-applied paths to keep their previous state")
+	require.Len(t, scanned, 3, "Expected scanned entries to be left untouched")
+}
+
+func TestPruneState(t *testing.T) {
+	newState := func() *SyncState {
+		return &SyncState{Version: 1, Entries: map[string]EntryInfo{
+			"main.go":                              {RelativePath: "main.go", Size: 10},
+			"debug.log":                            {RelativePath: "debug.log", Size: 20},
+			"build":                                {RelativePath: "build", IsDir: true},
+			filepath.Join("build", "out.bin"):      {RelativePath: filepath.Join("build", "out.bin"), Size: 30},
+			"docs":                                 {RelativePath: "docs", IsDir: true},
+			filepath.Join("docs", "huge.pdf"):      {RelativePath: filepath.Join("docs", "huge.pdf"), Size: 5000},
+			filepath.Join("docs", "guide", "a.md"): {RelativePath: filepath.Join("docs", "guide", "a.md"), Size: 40},
+		}}
+	}
+	cfg := config.NewDefaultConfig()
+	cfg.ExcludePatterns = []string{"*.log", "build/"}
+	cfg.MaxFileSize = 1000
+
+	t.Run("InMemory", func(t *testing.T) {
+		state := newState()
+		dropped := PruneState(state, cfg)
+
+		require.Equal(t, []string{
+			"build",
+			filepath.Join("build", "out.bin"),
+			"debug.log",
+			filepath.Join("docs", "huge.pdf"),
+		}, dropped)
+		require.ElementsMatch(t, []string{"main.go", "docs", filepath.Join("docs", "guide", "a.md")},
+			slices.Collect(maps.Keys(state.Entries)), "Expected tracked entries to remain")
+	})
+
+	t.Run("File", func(t *testing.T) {
+		dstDir := t.TempDir()
+		require.NoError(t, SaveState(dstDir, newState(), cfg))
+		require.NoError(t, os.WriteFile(filepath.Join(dstDir, "debug.log"), []byte("kept"), 0644))
+
+		dryRun := *cfg
+		dryRun.DryRun = true
+		dropped, err := PruneStateFile(dstDir, &dryRun)
+		require.NoError(t, err)
+		require.Len(t, dropped, 4)
+		state, err := LoadState(dstDir, cfg)
+		require.NoError(t, err)
+		require.Len(t, state.Entries, 7, "Expected a dry run to leave the state file alone")
+
+		_, err = PruneStateFile(dstDir, cfg)
+		require.NoError(t, err)
+		state, err = LoadState(dstDir, cfg)
+		require.NoError(t, err)
+		require.Len(t, state.Entries, 3)
+		require.FileExists(t, filepath.Join(dstDir, "debug.log"), "Expected destination files to be left alone")
+	})
+
+	t.Run("MissingState", func(t *testing.T) {
+		dstDir := t.TempDir()
+		_, err := PruneStateFile(dstDir, cfg)
+		require.ErrorIs(t, err, ErrSyncStateRead)
+		require.NoFileExists(t, filepath.Join(dstDir, stateFile), "Expected no state file to be created")
+	})
+}
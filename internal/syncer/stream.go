@@ -0,0 +1,302 @@
+package syncer
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+// streamBatchSize is how many planned actions the streaming pipeline executes at a time.
+var streamBatchSize = 1024
+
+// checkStreaming returns an ErrSyncerStreamingOption error naming what in src or cfg
+// needs the whole source at once and so cannot run with cfg.Streaming.
+func checkStreaming(src Source, cfg *config.Config) error {
+	if _, ok := src.(*DirSource); !ok {
+		return fmt.Errorf("%w: the source must be a directory", ErrSyncerStreamingOption)
+	}
+	options := []struct {
+		set  bool
+		flag string
+	}{
+		{cfg.Flatten, "-flatten"},
+		{cfg.RelativeTo != "", "-relative-to"},
+		{cfg.CaseInsensitive, "-case-insensitive"},
+		{cfg.Stateless, "-stateless"},
+		{cfg.Adopt, "-adopt"},
+		{cfg.ManifestOut != "", "-manifest"},
+		{cfg.ReportDupes, "-report-dupes"},
+		{cfg.Phased, "-phased"},
+		{cfg.PreserveDirTimes, "-preserve-dir-times"},
+		{cfg.PruneEmptyDirs, "-dedupe-empty-dirs"},
+		{cfg.VerifyExecution, "-verify-execution"},
+		{cfg.Events != "", "-events"},
+		{cfg.PersistProgress, "-persist-progress"},
+	}
+	for _, option := range options {
+		if option.set {
+			return fmt.Errorf("%w: %s", ErrSyncerStreamingOption, option.flag)
+		}
+	}
+	return nil
+}
+
+// streamSource walks the local directory rootDir like ScanSource, but sends the entries
+// on the returned channel in walk order as they are found instead of collecting them.
+// Files are hashed by up to cfg.HashWorkers goroutines while the walk goes on, and at
+// most that many entries wait for their checksum. With noHash files are sent without
+// a checksum for the caller or the copy to fill in (see scanSource).
+// The channel is closed when the walk ends or ctx is cancelled; wait then returns the
+// entry paths skipped as unreadable, by the walk or a failed checksum, the files left
+// out for falling outside the mtime window, and its error.
+func streamSource(ctx context.Context, rootDir string, cfg *config.Config, noHash bool) (entries <-chan EntryInfo, wait func() (unreadable, outsideWindow []string, err error), err error) {
+	walk, err := newSourceWalk(rootDir, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	var reuse map[string]EntryInfo
+	if noHash {
+		reuse = map[string]EntryInfo{}
+	}
+
+	workers := max(cfg.HashWorkers, 1)
+	// Each entry gets a channel that yields it once hashed, or is closed to drop it
+	pending := make(chan chan EntryInfo, workers)
+	hashers := make(chan struct{}, workers)
+	out := make(chan EntryInfo)
+
+	var walkErr error
+	walked := make(chan struct{})
+	go func() {
+		defer close(walked)
+		defer close(pending)
+		walkErr = walk.walk(nil, reuse, func(entry EntryInfo, path string, hash bool) error {
+			result := make(chan EntryInfo, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if !hash {
+				result <- entry
+				return nil
+			}
+			select {
+			case hashers <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			go func() {
+				defer func() { <-hashers }()
+				defer close(result)
+				job := hashJob{relPath: entry.RelativePath, path: path, size: entry.Size}
+				hashed := hashOne(walk.root, job, cfg)
+				walk.progress.addHashed(job.size)
+				if hashed.failed {
+					walk.markUnreadable(entry.RelativePath)
+				}
+				if !hashed.skip {
+					entry = withChecksum(entry, hashed, cfg)
+					walk.cacheChecksum(entry)
+					result <- entry
+				}
+			}()
+			return nil
+		})
+		if walkErr == nil {
+			// Taking every hasher slot waits for the last checksums and unreadable paths
+			for range workers {
+				hashers <- struct{}{}
+			}
+			walk.saveCache()
+		}
+	}()
+
+	go func() {
+		defer close(out)
+		for result := range pending {
+			var entry EntryInfo
+			var ok bool
+			select {
+			case entry, ok = <-result:
+			case <-ctx.Done():
+				return
+			}
+			if !ok {
+				continue
+			}
+			select {
+			case out <- entry:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, func() ([]string, []string, error) {
+		<-walked
+		walk.mu.Lock()
+		defer walk.mu.Unlock()
+		return walk.unreadable, walk.outsideWindow, walkErr
+	}, nil
+}
+
+// runStreaming is runPipeline for cfg.Streaming. Entries are compared against the loaded
+// state as the source walk finds them, and the planned actions are executed in batches
+// of streamBatchSize, so the source is never held in memory as a whole: peak memory is
+// the state, the set of source paths seen and one batch. Deletes are planned once the
+// walk ends. Completed actions are applied to the state as they finish, and the state is
+// saved at the end as usual. Free space checks cover one batch at a time, no progress
+// line is shown since the totals are unknown up front, and result.Actions is left empty.
+// Deletes of paths matching the keep patterns are dropped (see KeepActions), and so are
+// those of paths at or below an entry the walk could not read, of files outside the
+// mtime window and of paths still held back by cfg.DeleteDelay (see DelayDeletes). Deletes refused by the mass delete guard (see
+// checkDeletes) are all left out, and its error is returned once the rest is saved.
+func runStreaming(ctx context.Context, src *DirSource, dst StateDestination, cfg *config.Config, state *SyncState, keep []string, result *Summary) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	onCopy := checksumOnCopy(cfg)
+	entries, wait, err := streamSource(ctx, src.Root(), cfg, onCopy)
+	if err != nil {
+		return err
+	}
+	logger.Info("Streaming source entries", "batch_size", streamBatchSize)
+
+	if state.Entries == nil {
+		state.Entries = make(map[string]EntryInfo)
+	}
+	priorEntries := len(state.Entries)
+	dstRoot := dst.Root()
+	// Every completed action goes into the state, whether or not checkpoints are saved
+	recorder := &Checkpointer{
+		state: state,
+		save: func(s *SyncState) error {
+			return SaveStateFS(dst.StateFS(), dstRoot, s, cfg)
+		},
+		every:    cfg.CheckpointActions,
+		interval: cfg.CheckpointInterval,
+		lastSave: clock.Now(),
+	}
+	execCfg := *cfg
+	execCfg.Progress = false
+
+	start := clock.Now()
+	var batch []SyncAction
+	var failures []error
+	flush := func() error {
+		actions, _ := FilterActions(batch, cfg) // Filtered entries keep their recorded state
+		batch = nil
+		actions, protected := KeepActions(actions, keep, keepRoot(dst))
+		if !cfg.DryRun {
+			for _, path := range protected {
+				delete(state.Entries, path)
+			}
+		}
+		if cfg.DryRun {
+			planned := PlanSummary(actions)
+			MeasureDestination(dst, actions, &planned)
+			result.Summary.Add(planned)
+			return nil
+		}
+		executed, err := ExecuteActionsFrom(ctx, src, dst, actions, &execCfg, recorder)
+		executed.Elapsed = 0
+		result.Summary.Add(executed)
+		if err != nil && !errors.Is(err, ErrSyncerActionsFailed) {
+			return err
+		}
+		if err != nil {
+			failures = append(failures, err)
+		}
+		// Unchanged entries are not recorded by the run but still pick up their scanned metadata
+		for _, action := range actions {
+			if action.Type == ActionNone {
+				state.Entries[action.RelativePath] = action.SourceInfo
+			}
+		}
+		return nil
+	}
+	add := func(action SyncAction) error {
+		batch = append(batch, action)
+		if len(batch) < streamBatchSize {
+			return nil
+		}
+		return flush()
+	}
+
+	seen := make(map[string]struct{})
+	for entry := range entries {
+		path := entry.RelativePath
+		if _, taken := seen[path]; taken {
+			return &SyncError{Op: OpScan, Path: cmp.Or(entry.SourcePath, path),
+				Err: fmt.Errorf("%w: maps to %s, which another source entry already uses", ErrSyncerWindowsName, path)}
+		}
+		seen[path] = struct{}{}
+
+		recorded, found := state.Entries[path]
+		if onCopy && found && entry.Checksum == "" && !entry.IsDir && reusableChecksum(entry, recorded, cfg) {
+			entry.Checksum, entry.QuickHash = recorded.Checksum, recorded.QuickHash
+		}
+		if err := add(compareEntry(path, entry, recorded, found, 0, cfg)); err != nil {
+			return err
+		}
+	}
+	unreadable, outsideWindow, err := wait()
+	if err != nil {
+		return err
+	}
+
+	// Whatever the walk did not produce is gone from the source, unless it could not be
+	// read or fell outside the mtime window
+	var gone []string
+	for path := range state.Entries {
+		if _, ok := seen[path]; !ok && !belowUnreadable(path, unreadable) && !belowUnreadable(path, outsideWindow) {
+			gone = append(gone, path)
+		}
+	}
+	slices.Sort(gone)
+	deletes := make([]SyncAction, 0, len(gone))
+	for _, path := range gone {
+		recorded := state.Entries[path]
+		deletes = append(deletes, SyncAction{Type: deleteAction(recorded), RelativePath: path, SourceInfo: recorded})
+	}
+	deletes, pending := DelayDeletes(deletes, state, cfg)
+	if len(pending) > 0 {
+		logger.Info("Holding back deletes until the delete delay passes", "count", len(pending), "delay", cfg.DeleteDelay)
+	}
+	// The rest of the run is already done, so only the deletes are refused
+	guardErr := checkDeletes(deletes, len(seen), priorEntries, cfg)
+	if guardErr != nil && cfg.DryRun {
+		logger.Warn("A real run would not delete", "error", guardErr)
+		guardErr = nil
+	} else if guardErr != nil {
+		deletes = nil
+	}
+	for _, action := range deletes {
+		if err := add(action); err != nil {
+			return err
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	result.Elapsed = since(start)
+
+	if cfg.DryRun {
+		result.DryRun = true
+		if err := checkFreeSpace(dst, result.BytesPlanned, cfg); err != nil {
+			logger.Warn("A real run would not start", "error", err)
+		}
+		return nil
+	}
+	prunePendingDeletes(state)
+	if err := SaveStateFS(dst.StateFS(), dstRoot, state, cfg); err != nil {
+		return err
+	}
+	return errors.Join(append(failures, guardErr)...)
+}
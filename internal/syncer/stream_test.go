@@ -0,0 +1,163 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// writeStreamTree fills dir with files spread over nested directories.
+func writeStreamTree(t testing.TB, dir string, files int) {
+	for i := range files {
+		path := filepath.Join(dir, fmt.Sprintf("d%d", i%7), fmt.Sprintf("s%d", i%3), fmt.Sprintf("file-%04d.txt", i))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf("content %d", i)), 0644))
+	}
+}
+
+func TestSyncStreaming(t *testing.T) {
+	origBatch := streamBatchSize
+	streamBatchSize = 16
+	t.Cleanup(func() { streamBatchSize = origBatch })
+
+	srcDir := t.TempDir()
+	writeStreamTree(t, srcDir, 200)
+	memDst, streamDst := t.TempDir(), t.TempDir()
+	memCfg := config.NewDefaultConfig()
+	streamCfg := config.NewDefaultConfig()
+	streamCfg.Streaming = true
+
+	// requireSameRun syncs both destinations and checks they planned, did and recorded the same
+	requireSameRun := func(t *testing.T, dryRun bool) {
+		memCfg.DryRun, streamCfg.DryRun = dryRun, dryRun
+		memSummary, err := Sync(context.Background(), srcDir, memDst, memCfg)
+		require.NoError(t, err)
+		streamSummary, err := Sync(context.Background(), srcDir, streamDst, streamCfg)
+		require.NoError(t, err)
+
+		memSummary.Elapsed, streamSummary.Elapsed = 0, 0
+		require.Equal(t, memSummary.Summary, streamSummary.Summary)
+		require.Empty(t, streamSummary.Actions)
+
+		memState, err := LoadState(memDst, memCfg)
+		require.NoError(t, err)
+		streamState, err := LoadState(streamDst, streamCfg)
+		require.NoError(t, err)
+		require.Equal(t, memState.Entries, streamState.Entries)
+	}
+
+	t.Run("Initial", func(t *testing.T) {
+		requireSameRun(t, true)
+		requireSameRun(t, false)
+		got, err := os.ReadFile(filepath.Join(streamDst, "d3", "s1", "file-0010.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "content 10", string(got))
+	})
+
+	t.Run("Changes", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, "d1", "s1", "file-0001.txt"), []byte("changed and longer"), 0644))
+		require.NoError(t, os.RemoveAll(filepath.Join(srcDir, "d2")))
+		require.NoError(t, os.Remove(filepath.Join(srcDir, "d0", "s0", "file-0000.txt")))
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, "new.txt"), []byte("new"), 0644))
+
+		requireSameRun(t, true)
+		requireSameRun(t, false)
+		require.NoDirExists(t, filepath.Join(streamDst, "d2"))
+		require.FileExists(t, filepath.Join(streamDst, "new.txt"))
+		require.NoFileExists(t, filepath.Join(streamDst, "d0", "s0", "file-0000.txt"))
+	})
+
+	t.Run("ChecksumOnCopy", func(t *testing.T) {
+		memCfg.ChecksumOnCopy, streamCfg.ChecksumOnCopy = true, true
+		t.Cleanup(func() { memCfg.ChecksumOnCopy, streamCfg.ChecksumOnCopy = false, false })
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, "new.txt"), []byte("newer"), 0644))
+
+		requireSameRun(t, false)
+	})
+}
+
+func TestCheckStreaming(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(cfg *config.Config)
+		flag   string
+	}{
+		{name: "Supported", modify: func(cfg *config.Config) {}},
+		{name: "Flatten", modify: func(cfg *config.Config) { cfg.Flatten = true }, flag: "-flatten"},
+		{name: "Stateless", modify: func(cfg *config.Config) { cfg.Stateless = true }, flag: "-stateless"},
+		{name: "Events", modify: func(cfg *config.Config) { cfg.Events = config.EventsJSONL }, flag: "-events"},
+		{name: "PersistProgress", modify: func(cfg *config.Config) { cfg.PersistProgress = true }, flag: "-persist-progress"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewDefaultConfig()
+			cfg.Streaming = true
+			tc.modify(cfg)
+
+			_, err := Sync(context.Background(), t.TempDir(), t.TempDir(), cfg)
+			if tc.flag == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrSyncerStreamingOption)
+			require.ErrorContains(t, err, tc.flag)
+		})
+	}
+}
+
+// BenchmarkComparePipeline reports the heap still held once every source entry has been
+// compared, which for the in-memory path includes the whole scan and action list.
+func BenchmarkComparePipeline(b *testing.B) {
+	srcDir := b.TempDir()
+	writeStreamTree(b, srcDir, 5000)
+	cfg := config.NewDefaultConfig()
+	state := map[string]EntryInfo{}
+
+	pipelines := map[string]func() any{
+		"InMemory": func() any {
+			entries, err := ScanSource(srcDir, cfg)
+			require.NoError(b, err)
+			return CompareStates(entries, state, cfg)
+		},
+		"Streaming": func() any {
+			entries, wait, err := streamSource(context.Background(), srcDir, cfg, false)
+			require.NoError(b, err)
+			seen := make(map[string]struct{})
+			for entry := range entries {
+				seen[entry.RelativePath] = struct{}{}
+				recorded, found := state[entry.RelativePath]
+				_ = compareEntry(entry.RelativePath, entry, recorded, found, 0, cfg)
+			}
+			_, _, err = wait()
+			require.NoError(b, err)
+			return seen
+		},
+	}
+
+	for _, name := range []string{"InMemory", "Streaming"} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			var before, after runtime.MemStats
+			var retained uint64
+			for range b.N {
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				held := pipelines[name]()
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				if after.HeapAlloc > before.HeapAlloc {
+					retained += after.HeapAlloc - before.HeapAlloc
+				}
+				runtime.KeepAlive(held)
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+		})
+	}
+}
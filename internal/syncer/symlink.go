@@ -0,0 +1,97 @@
+package syncer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+var ErrSyncerSymlinkUnsupported = errors.New("syncer: destination cannot create symlinks")
+
+// scanSymlink reads the symlink at path for the scan and returns its target with the info
+// to record. With config.SymlinkDereference that is the info of the file the link points
+// to, whose contents are synced; otherwise it is the link's own. A nil info leaves the
+// entry out: a dereferenced link that dangles, points to a directory or is part of a
+// cycle (see linksToAncestor).
+func scanSymlink(path, relPath string, info fs.FileInfo, cfg *config.Config) (string, fs.FileInfo, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if cfg.SymlinkPolicy != config.SymlinkDereference {
+		return target, info, nil
+	}
+
+	targetInfo, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		logger.Warn("dangling symlink, skipping entry", "path", relPath, "target", target)
+		return target, nil, nil
+	case errors.Is(err, syscall.ELOOP):
+		logger.Warn("symlink cycle, skipping entry", "path", relPath, "target", target)
+		return target, nil, nil
+	case err != nil:
+		return "", nil, err
+	case targetInfo.IsDir() && linksToAncestor(path, targetInfo):
+		logger.Warn("symlink to an ancestor directory forms a cycle, skipping entry", "path", relPath, "target", target)
+		return target, nil, nil
+	case targetInfo.IsDir():
+		logger.Warn("symlink to a directory is not followed, skipping entry", "path", relPath, "target", target)
+		return target, nil, nil
+	}
+	return target, targetInfo, nil
+}
+
+// linksToAncestor reports whether the directory target, which the symlink at path
+// resolves to, is one of the directories containing path, so that following the link
+// would walk the same tree forever. Directories are compared by device and inode.
+func linksToAncestor(path string, target fs.FileInfo) bool {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if info, err := os.Stat(dir); err == nil && os.SameFile(info, target) {
+			return true
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return false
+		}
+	}
+}
+
+// linkChecksum is the checksum recorded for a symlink that is not dereferenced: the
+// xxHash of its target, so retargeting the link updates it.
+func linkChecksum(target string) string {
+	hash := xxhash.New()
+	_, _ = hash.WriteString(target)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// isLink reports whether entry is a symlink that cfg realizes as something other than a
+// copy of its target.
+func isLink(entry EntryInfo, cfg *config.Config) bool {
+	return entry.SymlinkTarget != "" && cfg.SymlinkPolicy != config.SymlinkDereference
+}
+
+// createSymlink recreates the action's symlink on dst with the recorded target.
+func createSymlink(dst Destination, action SyncAction, cfg *config.Config) error {
+	linker, ok := dst.(symlinker)
+	if !ok {
+		return ErrSyncerSymlinkUnsupported
+	}
+	err := retryAction(cfg, OpSymlink, action.RelativePath, func() error {
+		return linker.Symlink(action.SourceInfo.SymlinkTarget, action.RelativePath)
+	})
+	if err != nil {
+		return fmt.Errorf("%w -> %s", err, action.SourceInfo.SymlinkTarget)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncSymlinkPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs extra privileges on Windows")
+	}
+
+	outsideDir := t.TempDir()
+	outside := filepath.Join(outsideDir, "outside.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("outside the tree"), 0644))
+
+	tests := []struct {
+		policy string
+		check  func(t *testing.T, dstDir string)
+	}{
+		{
+			policy: config.SymlinkPreserve,
+			check: func(t *testing.T, dstDir string) {
+				for link, target := range map[string]string{"inside-link": "data.txt", "outside-link": outside} {
+					got, err := os.Readlink(filepath.Join(dstDir, link))
+					require.NoError(t, err, "Expected %s to be recreated as a symlink", link)
+					require.Equal(t, target, got)
+				}
+			},
+		},
+		{
+			policy: config.SymlinkDereference,
+			check: func(t *testing.T, dstDir string) {
+				for link, body := range map[string]string{"inside-link": "inside the tree", "outside-link": "outside the tree"} {
+					info, err := os.Lstat(filepath.Join(dstDir, link))
+					require.NoError(t, err)
+					require.True(t, info.Mode().IsRegular(), "Expected %s to be copied as a regular file", link)
+					got, err := os.ReadFile(filepath.Join(dstDir, link))
+					require.NoError(t, err)
+					require.Equal(t, body, string(got))
+				}
+			},
+		},
+		{
+			policy: config.SymlinkSkip,
+			check: func(t *testing.T, dstDir string) {
+				for _, link := range []string{"inside-link", "outside-link"} {
+					_, err := os.Lstat(filepath.Join(dstDir, link))
+					require.ErrorIs(t, err, os.ErrNotExist, "Expected %s to be left off the destination", link)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			srcDir, dstDir := t.TempDir(), t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data.txt"), []byte("inside the tree"), 0644))
+			require.NoError(t, os.Symlink("data.txt", filepath.Join(srcDir, "inside-link")))
+			require.NoError(t, os.Symlink(outside, filepath.Join(srcDir, "outside-link")))
+			cfg := config.NewDefaultConfig()
+			cfg.SymlinkPolicy = tt.policy
+
+			_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+			tt.check(t, dstDir)
+			got, err := os.ReadFile(filepath.Join(dstDir, "data.txt"))
+			require.NoError(t, err)
+			require.Equal(t, "inside the tree", string(got))
+
+			summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+			require.Zero(t, summary.FilesCreated+summary.FilesUpdated, "Expected a second run to find nothing to do")
+		})
+	}
+}
+
+func TestSyncSymlinkRetarget(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs extra privileges on Windows")
+	}
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(name), 0644))
+	}
+	require.NoError(t, os.Symlink("a.txt", filepath.Join(srcDir, "current")))
+	cfg := config.NewDefaultConfig()
+	cfg.SymlinkPolicy = config.SymlinkPreserve
+	_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(filepath.Join(srcDir, "current")))
+	require.NoError(t, os.Symlink("b.txt", filepath.Join(srcDir, "current")))
+	summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.FilesUpdated)
+	got, err := os.Readlink(filepath.Join(dstDir, "current"))
+	require.NoError(t, err)
+	require.Equal(t, "b.txt", got)
+}
+
+func TestScanSourceSymlinkCycle(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs extra privileges on Windows")
+	}
+
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data.txt"), []byte("data"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "nested", "deeper"), 0755))
+	// a -> b -> a, and directories linking back up the tree
+	require.NoError(t, os.Symlink("b", filepath.Join(srcDir, "a")))
+	require.NoError(t, os.Symlink("a", filepath.Join(srcDir, "b")))
+	require.NoError(t, os.Symlink("..", filepath.Join(srcDir, "nested", "up")))
+	require.NoError(t, os.Symlink(srcDir, filepath.Join(srcDir, "nested", "deeper", "root")))
+
+	var logs bytes.Buffer
+	logger.Initialize(logger.Config{Level: slog.LevelDebug, Output: &logs})
+	t.Cleanup(func() { logger.Logger = nil })
+
+	cfg := config.NewDefaultConfig()
+	cfg.SymlinkPolicy = config.SymlinkDereference
+
+	done := make(chan struct{})
+	var entries map[string]EntryInfo
+	var err error
+	go func() {
+		defer close(done)
+		entries, err = ScanSource(srcDir, cfg)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the scan to finish despite the symlink cycles")
+	}
+
+	require.NoError(t, err)
+	require.Contains(t, entries, "data.txt")
+	for _, link := range []string{"a", "b", filepath.Join("nested", "up"), filepath.Join("nested", "deeper", "root")} {
+		require.NotContains(t, entries, link, "Expected the cyclic link %s to be skipped", link)
+	}
+	require.Equal(t, 2, strings.Count(logs.String(), "symlink cycle, skipping entry"), "Expected the link loop to be logged")
+	require.Equal(t, 2, strings.Count(logs.String(), "forms a cycle"), "Expected both ancestor links to be logged")
+}
@@ -0,0 +1,309 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+	"github.com/ogzhanolguncu/mimic/internal/report"
+)
+
+// StateDestination is a Destination that also keeps the sync state under its root.
+type StateDestination interface {
+	Destination
+	Root() string
+	StateFS() StateFS
+}
+
+// Summary is the outcome of Sync. In dry-run mode it holds the planned totals and
+// nothing was changed.
+type Summary struct {
+	report.Summary
+	// Actions are the planned actions after -only/-skip filtering, including unchanged entries
+	Actions []SyncAction
+}
+
+// Sync mirrors srcDir into the local directory dstDir. See SyncTo.
+func Sync(ctx context.Context, srcDir, dstDir string, cfg *config.Config) (*Summary, error) {
+	return SyncTo(ctx, srcDir, NewLocalDestination(dstDir, cfg), cfg)
+}
+
+// SyncTo mirrors srcDir into dst. srcDir may also name an archive (see IsArchive),
+// whose contents are then mirrored instead. See SyncFrom.
+func SyncTo(ctx context.Context, srcDir string, dst StateDestination, cfg *config.Config) (*Summary, error) {
+	src := OpenSource(srcDir)
+	defer func() {
+		if err := src.Close(); err != nil {
+			logger.Warn("Cannot close source", "path", srcDir, "error", err)
+		}
+	}()
+	return SyncFrom(ctx, src, dst, cfg)
+}
+
+// SyncFrom runs the whole pipeline from src to dst: load the state, scan the source, plan
+// and execute the actions, then save the new state. With cfg.DryRun it stops after
+// planning and returns the planned totals. Cancelling ctx stops the run between
+// actions; the state then covers whatever was checkpointed. A summary is returned
+// alongside ErrSyncerActionsFailed when only individual actions failed.
+func SyncFrom(ctx context.Context, src Source, dst StateDestination, cfg *config.Config) (*Summary, error) {
+	start := clock.Now()
+	result := &Summary{}
+	err := runPipeline(ctx, src, dst, cfg, result)
+	if cfg.RootMetadata && !cfg.DryRun && (err == nil || errors.Is(err, ErrSyncerActionsFailed)) {
+		if dir, ok := src.(*DirSource); ok {
+			ApplyRootMetadata(dir.Root(), dst)
+		} else {
+			logger.Warn("Root metadata is only available for directory sources, skipping")
+		}
+	}
+
+	if cfg.StatsFile != "" {
+		stats := buildRunStats(result, err, start, since(start))
+		if writeErr := writeStatsFile(cfg.StatsFile, stats); writeErr != nil {
+			logger.Warn("Cannot write stats file", "path", cfg.StatsFile, "error", writeErr)
+		}
+	}
+	if err != nil && !errors.Is(err, ErrSyncerActionsFailed) {
+		return nil, err
+	}
+	return result, err
+}
+
+// runPipeline does the work of SyncFrom, filling result as the run progresses so a
+// failed run still reports what it planned and did.
+func runPipeline(ctx context.Context, src Source, dst StateDestination, cfg *config.Config, result *Summary) error {
+	dstRoot := dst.Root()
+	_, local := dst.(*LocalDestination)
+	if cfg.StateDir != "" && !local {
+		logger.Warn("A state dir is only supported for local destinations, keeping the state on the destination")
+		localCfg := *cfg
+		localCfg.StateDir = ""
+		cfg = &localCfg
+	}
+	stateless := cfg.Stateless && local
+	if cfg.Stateless && !local {
+		logger.Warn("Stateless mode is only supported for local destinations, using the state file")
+	}
+	if cfg.Streaming {
+		if err := checkStreaming(src, cfg); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Lock && !cfg.DryRun {
+		if !local {
+			logger.Warn("Locking is only supported for local destinations, skipping")
+		} else {
+			release, err := AcquireLock(dstRoot, cfg)
+			if err != nil {
+				return err
+			}
+			defer release()
+		}
+	}
+
+	// Load or create state
+	state := &SyncState{Version: 1}
+	if !stateless {
+		var err error
+		if state, err = LoadStateFS(dst.StateFS(), dstRoot, cfg); err != nil {
+			return err
+		}
+	}
+
+	// A crashed run can leave temp files behind; young ones may belong to a concurrent run
+	if local && !cfg.DryRun {
+		cleanupTempFiles(dstRoot, state, cfg)
+	}
+	keep, err := LoadKeepPatterns(dst.StateFS(), dstRoot)
+	if err != nil {
+		return err
+	}
+	if cfg.Streaming {
+		return runStreaming(ctx, src.(*DirSource), dst, cfg, state, keep, result)
+	}
+
+	// Scan source, leaving files that will be copied to be hashed by the copy
+	var sourceEntries map[string]EntryInfo
+	var unreadable, outsideWindow []string
+	if dir, ok := src.(*DirSource); ok {
+		var reuse map[string]EntryInfo
+		if checksumOnCopy(cfg) {
+			if reuse = state.Entries; reuse == nil {
+				reuse = map[string]EntryInfo{}
+			}
+		}
+		sourceEntries, unreadable, outsideWindow, err = scanSource(dir.Root(), cfg, reuse)
+	} else {
+		sourceEntries, err = src.Scan(cfg)
+	}
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if cfg.ManifestOut != "" {
+		if err := writeManifestFile(cfg.ManifestOut, sourceEntries); err != nil {
+			return err
+		}
+		logger.Info("Wrote manifest", "path", cfg.ManifestOut, "entries", len(sourceEntries))
+	}
+	if cfg.ReportDupes {
+		reportDuplicates(sourceEntries)
+	}
+
+	// Compare against the destination itself, or seed a fresh state from what it already holds
+	compareCfg := cfg
+	if stateless {
+		logger.Info("Scanning destination")
+		if state.Entries, compareCfg, err = StatelessEntries(dstRoot, cfg); err != nil {
+			return err
+		}
+	} else if cfg.Adopt && len(state.Entries) == 0 {
+		if !local {
+			logger.Warn("Adopting is only supported for local destinations, skipping")
+		} else {
+			logger.Info("Adopting existing destination files")
+			if state.Entries, err = AdoptDestination(dstRoot, sourceEntries, cfg); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Compare states and determine actions
+	logger.Info("Comparing states")
+	actions := CompareStates(sourceEntries, state.Entries, compareCfg)
+
+	actions, filtered := FilterActions(actions, cfg)
+	if len(filtered) > 0 {
+		logger.Info("Leaving filtered actions for a later run", "count", len(filtered))
+	}
+	actions, held := dropUnreadableDeletes(actions, unreadable)
+	if len(held) > 0 {
+		logger.Warn("Not deleting destination entries the scan could not read", "count", len(held))
+	}
+	actions, outside := dropUnreadableDeletes(actions, outsideWindow)
+	if len(outside) > 0 {
+		logger.Info("Not deleting destination files outside the mtime window", "count", len(outside))
+	}
+	// Protected paths drop out of the state, so they are not planned for deletion again
+	actions, protected := KeepActions(actions, keep, keepRoot(dst))
+	if len(protected) > 0 {
+		logger.Info("Keeping paths protected by the keep file", "count", len(protected))
+	}
+	var pending []string
+	if stateless && cfg.DeleteDelay > 0 {
+		logger.Warn("A delete delay needs the state file to track pending deletes, deleting right away")
+	} else {
+		actions, pending = DelayDeletes(actions, state, cfg)
+		if len(pending) > 0 {
+			logger.Info("Holding back deletes until the delete delay passes", "count", len(pending), "delay", cfg.DeleteDelay)
+		}
+	}
+	if err := checkDeletes(actions, len(sourceEntries), len(state.Entries), cfg); err != nil {
+		if !cfg.DryRun {
+			return err
+		}
+		logger.Warn("A real run would not start", "error", err)
+	}
+
+	result.Actions = actions
+
+	if cfg.DryRun {
+		result.Summary = PlanSummary(actions)
+		MeasureDestination(dst, actions, &result.Summary)
+		if err := checkFreeSpace(dst, result.BytesPlanned, cfg); err != nil {
+			logger.Warn("A real run would not start", "error", err)
+		}
+		return nil
+	}
+
+	// Filter out "none" actions for reporting
+	actionCount := len(slices.DeleteFunc(slices.Clone(actions), func(a SyncAction) bool {
+		return a.Type == ActionNone
+	}))
+	logger.Info("Found actions to perform", "count", actionCount)
+
+	// Execute actions
+	logger.Info("Executing sync actions")
+	var checkpoint *Checkpointer
+	if !stateless {
+		checkpoint = NewCheckpointer(state, func(s *SyncState) error {
+			return SaveStateFS(dst.StateFS(), dstRoot, s, cfg)
+		}, cfg)
+	}
+	executed, actionErr := ExecuteActionsFrom(ctx, src, dst, actions, cfg, checkpoint)
+	result.Summary = executed
+	if actionErr != nil && !errors.Is(actionErr, ErrSyncerActionsFailed) {
+		return actionErr
+	}
+	// Filtered, unreadable, out of window, pending, deferred, failed and kept files are retried next run
+	notApplied := slices.Concat(filtered, held, outside, pending, executed.Deferred, executed.Failed, executed.Kept)
+	if cfg.PruneEmptyDirs {
+		if !local {
+			logger.Warn("Pruning empty directories is only supported for local destinations, skipping")
+		} else {
+			pruned, err := PruneEmptyDirs(dstRoot, actions, sourceEntries, slices.Concat(notApplied, protected), keep)
+			if err != nil {
+				return err
+			}
+			result.DirsDeleted += len(pruned)
+			// Pruning touched the parents of removed directories
+			if cfg.PreserveDirTimes && len(pruned) > 0 {
+				ApplyDirTimes(dst, actions)
+			}
+		}
+	}
+
+	if cfg.VerifyExecution {
+		if !local {
+			logger.Warn("Verifying execution is only supported for local destinations, skipping")
+		} else if err := verifyExecution(dstRoot, actions, executed.Deferred); err != nil {
+			actionErr = errors.Join(actionErr, err)
+		}
+	}
+
+	if stateless {
+		return actionErr
+	}
+
+	for path, checksum := range executed.Checksums {
+		if entry, ok := sourceEntries[path]; ok && entry.Checksum == "" {
+			entry.Checksum = checksum
+			sourceEntries[path] = entry
+		}
+	}
+
+	// Update and save state, keeping the recorded entries of what was not applied
+	state.Entries = ReconcileEntries(state.Entries, sourceEntries, notApplied)
+	prunePendingDeletes(state)
+	if err := SaveStateFS(dst.StateFS(), dstRoot, state, cfg); err != nil {
+		return err
+	}
+	return actionErr
+}
+
+// cleanupTempFiles removes what a crashed run left behind in the local dstRoot, except
+// for paths the state tracks, and in cfg.StateDir when the state is kept there (see
+// fileops.CleanupTempFiles).
+func cleanupTempFiles(dstRoot string, state *SyncState, cfg *config.Config) {
+	removed, err := fileops.CleanupTempFiles(dstRoot, cfg.StaleTempAge, func(relPath string) bool {
+		_, ok := state.Entries[relPath]
+		return ok
+	})
+	if cfg.StateDir != "" {
+		stateRemoved, stateErr := fileops.CleanupTempFiles(cfg.StateDir, cfg.StaleTempAge, nil)
+		removed, err = append(removed, stateRemoved...), errors.Join(err, stateErr)
+	}
+	if err != nil {
+		logger.Warn("Cannot clean up stale temp files", "error", err)
+	}
+	if len(removed) > 0 {
+		logger.Info("Removed stale temp files", "count", len(removed))
+	}
+}
@@ -0,0 +1,333 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
+	"github.com/ogzhanolguncu/mimic/internal/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("alpha"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "dir", "b.txt"), []byte("bravo"), 0644))
+
+	t.Run("DryRun", func(t *testing.T) {
+		dstDir := t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.DryRun = true
+
+		summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.NoError(t, err)
+		require.True(t, summary.DryRun)
+		require.Equal(t, 2, summary.FilesCreated)
+		require.Equal(t, 1, summary.DirsCreated)
+		require.Len(t, summary.Actions, 3)
+
+		require.NoFileExists(t, filepath.Join(dstDir, "a.txt"), "Expected a dry run not to copy files")
+		require.NoDirExists(t, filepath.Join(dstDir, "dir"), "Expected a dry run not to create directories")
+	})
+
+	t.Run("Real", func(t *testing.T) {
+		dstDir := t.TempDir()
+		cfg := config.NewDefaultConfig()
+
+		summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.NoError(t, err)
+		require.False(t, summary.DryRun)
+		require.Equal(t, 2, summary.FilesCreated)
+		require.Equal(t, int64(len("alpha")+len("bravo")), summary.BytesTransferred)
+
+		got, err := os.ReadFile(filepath.Join(dstDir, "dir", "b.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "bravo", string(got))
+
+		state, err := LoadState(dstDir, cfg)
+		require.NoError(t, err)
+		require.Len(t, state.Entries, 3, "Expected the state to be saved")
+
+		// A second run finds nothing to do
+		summary, err = Sync(context.Background(), srcDir, dstDir, cfg)
+		require.NoError(t, err)
+		require.Equal(t, 3, summary.Unchanged)
+		require.Zero(t, summary.FilesCreated)
+	})
+
+	t.Run("StatsFile", func(t *testing.T) {
+		dstDir := t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.StatsFile = filepath.Join(t.TempDir(), "stats.json")
+
+		_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(cfg.StatsFile)
+		require.NoError(t, err)
+		var stats report.RunStats
+		require.NoError(t, json.Unmarshal(data, &stats))
+		require.Equal(t, 2, stats.Actions.FilesCreated)
+		require.Equal(t, report.ExtensionStats{Created: 2, Bytes: int64(len("alpha") + len("bravo"))}, stats.Extensions[".txt"])
+		require.Empty(t, stats.Errors)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		dstDir := t.TempDir()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := Sync(ctx, srcDir, dstDir, config.NewDefaultConfig())
+		require.ErrorIs(t, err, context.Canceled)
+		require.NoFileExists(t, filepath.Join(dstDir, "a.txt"))
+	})
+}
+
+func TestSyncDirectoryMtimeChange(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	cfg := config.NewDefaultConfig()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "dir", "a.txt"), []byte("alpha"), 0644))
+	_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	// Adding a file bumps the directory's mtime
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "dir", "b.txt"), []byte("bravo"), 0644))
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(srcDir, "dir"), later, later))
+
+	summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.Equal(t, 1, summary.FilesCreated)
+	require.Zero(t, summary.FilesUpdated, "Expected the directory itself not to be updated")
+	require.FileExists(t, filepath.Join(dstDir, "dir", "a.txt"), "Expected existing files in the directory to survive")
+	require.FileExists(t, filepath.Join(dstDir, "dir", "b.txt"))
+}
+
+func TestBuildRunStats(t *testing.T) {
+	result := &Summary{Actions: []SyncAction{
+		{Type: ActionCreate, RelativePath: "a.txt", SourceInfo: EntryInfo{Size: 10}},
+		{Type: ActionCreate, RelativePath: filepath.Join("docs", "b.TXT"), SourceInfo: EntryInfo{Size: 20}},
+		{Type: ActionUpdate, RelativePath: "main.go", SourceInfo: EntryInfo{Size: 30}},
+		{Type: ActionDelete, RelativePath: "old.go", SourceInfo: EntryInfo{Size: 5}},
+		{Type: ActionCreate, RelativePath: "Makefile", SourceInfo: EntryInfo{Size: 7}},
+		{Type: ActionMkdir, RelativePath: "docs", SourceInfo: EntryInfo{IsDir: true}},
+		{Type: ActionNone, RelativePath: "same.txt", SourceInfo: EntryInfo{Size: 99}},
+	}}
+	result.FilesCreated = 3
+
+	stats := buildRunStats(result, errors.New("disk full"), time.Now(), time.Second)
+
+	require.Equal(t, map[string]report.ExtensionStats{
+		".txt": {Created: 2, Bytes: 30},
+		".go":  {Updated: 1, Deleted: 1, Bytes: 35},
+		"":     {Created: 1, Bytes: 7},
+	}, stats.Extensions, "Expected file actions grouped by lower-cased extension")
+	require.Equal(t, 3, stats.Actions.FilesCreated)
+	require.Equal(t, []string{"disk full"}, stats.Errors)
+}
+
+func TestSyncDryRunNetChange(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	cfg := config.NewDefaultConfig()
+
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "updated.txt"), []byte("aaaa"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "removed.txt"), []byte("xxxxxxxx"), 0644))
+	_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "updated.txt"), make([]byte, 20), 0644))
+	require.NoError(t, os.Remove(filepath.Join(srcDir, "removed.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "created.txt"), make([]byte, 30), 0644))
+	// The destination copy grew since the last sync; its current size is what gets replaced
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "updated.txt"), []byte("bbbbbb"), 0644))
+
+	cfg.DryRun = true
+	summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	require.True(t, summary.DestinationMeasured)
+	require.Equal(t, int64(30), summary.BytesCreated)
+	require.Equal(t, int64(20), summary.BytesUpdated)
+	require.Equal(t, int64(6+8), summary.BytesReplaced, "Expected current sizes of the updated and deleted files")
+	require.Equal(t, int64(30+20-6-8), summary.NetChange())
+
+	got, err := os.ReadFile(filepath.Join(dstDir, "updated.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "bbbbbb", string(got), "Expected the dry run to leave the destination untouched")
+}
+
+// failingDestination is a local destination whose copies to the given paths always fail.
+type failingDestination struct {
+	*LocalDestination
+	failPaths map[string]bool
+}
+
+func (d *failingDestination) Copy(srcPath, relPath string, chunkSize int64) (int64, error) {
+	if d.failPaths[relPath] {
+		return 0, fmt.Errorf("copy %s: %w", relPath, fs.ErrPermission)
+	}
+	return d.LocalDestination.Copy(srcPath, relPath, chunkSize)
+}
+
+func TestSyncContinueOnError(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("alpha"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "bad.txt"), []byte("broken"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "dir", "c.txt"), []byte("charlie"), 0644))
+
+	t.Run("StopsByDefault", func(t *testing.T) {
+		dstDir := t.TempDir()
+		cfg := config.NewDefaultConfig()
+		dst := &failingDestination{NewLocalDestination(dstDir, cfg), map[string]bool{"bad.txt": true}}
+
+		summary, err := SyncTo(context.Background(), srcDir, dst, cfg)
+		require.ErrorIs(t, err, fs.ErrPermission)
+		require.NotErrorIs(t, err, ErrSyncerActionsFailed)
+		require.Nil(t, summary)
+		require.NoDirExists(t, filepath.Join(dstDir, "dir"), "Expected the run to stop at the first failure")
+	})
+
+	t.Run("Continues", func(t *testing.T) {
+		dstDir := t.TempDir()
+		cfg := config.NewDefaultConfig()
+		cfg.ContinueOnError = true
+		dst := &failingDestination{NewLocalDestination(dstDir, cfg), map[string]bool{"bad.txt": true}}
+
+		summary, err := SyncTo(context.Background(), srcDir, dst, cfg)
+		require.ErrorIs(t, err, ErrSyncerActionsFailed)
+		require.ErrorIs(t, err, fs.ErrPermission, "Expected the joined error to keep each cause")
+		require.ErrorContains(t, err, "bad.txt")
+		require.NotNil(t, summary, "Expected the summary of a partially failed run")
+		require.Equal(t, []string{"bad.txt"}, summary.Failed)
+		require.Equal(t, 2, summary.FilesCreated)
+		require.FileExists(t, filepath.Join(dstDir, "dir", "c.txt"), "Expected actions after the failure to run")
+
+		state, err := LoadState(dstDir, cfg)
+		require.NoError(t, err)
+		require.Contains(t, state.Entries, "a.txt")
+		require.Contains(t, state.Entries, filepath.Join("dir", "c.txt"))
+		require.NotContains(t, state.Entries, "bad.txt", "Expected the failed copy not to be recorded as synced")
+
+		// Once the destination recovers the failed file is copied again
+		summary, err = Sync(context.Background(), srcDir, dstDir, cfg)
+		require.NoError(t, err)
+		require.Equal(t, 1, summary.FilesCreated)
+		require.Empty(t, summary.Failed)
+		require.FileExists(t, filepath.Join(dstDir, "bad.txt"))
+	})
+}
+
+func TestSyncEvents(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("alpha"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "dir", "b.txt"), []byte("bravo!"), 0644))
+
+	var buf bytes.Buffer
+	eventsOut = &buf
+	t.Cleanup(func() { eventsOut = os.Stdout })
+	cfg := config.NewDefaultConfig()
+	cfg.Events = config.EventsJSONL
+
+	_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	started := make(map[string]int)
+	completed := make(map[string]int)
+	decoder := json.NewDecoder(&buf)
+	var events []report.Event
+	for decoder.More() {
+		var event report.Event
+		require.NoError(t, decoder.Decode(&event))
+		events = append(events, event)
+	}
+	for i, event := range events {
+		switch event.Type {
+		case report.EventStart:
+			started[event.Path] = i
+		case report.EventComplete:
+			start, ok := started[event.Path]
+			require.True(t, ok, "Expected %s to start before it completes", event.Path)
+			require.Less(t, start, i)
+			completed[event.Path] = i
+		}
+	}
+	require.Len(t, completed, 3, "Expected a start and complete for the directory and both files")
+	require.Equal(t, int64(6), events[completed[filepath.Join("dir", "b.txt")]].Bytes)
+
+	last := events[len(events)-1]
+	require.Equal(t, report.EventFinish, last.Type)
+	require.Equal(t, int64(11), last.Bytes)
+}
+
+func TestSyncPruneEmptyDirsLeavesHeldDeletes(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(cfg *config.Config)
+	}{
+		{name: "DeleteDelay", modify: func(cfg *config.Config) { cfg.DeleteDelay = time.Hour }},
+		{name: "SkipDelete", modify: func(cfg *config.Config) { cfg.SkipActions = []string{"delete"} }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srcDir, dstDir := t.TempDir(), t.TempDir()
+			require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "empty"), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("alpha"), 0644))
+			cfg := config.NewDefaultConfig()
+			cfg.PruneEmptyDirs = true
+			tc.modify(cfg)
+			_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+
+			require.NoError(t, os.Mkdir(filepath.Join(dstDir, "untracked"), 0755))
+			require.NoError(t, os.Remove(filepath.Join(srcDir, "empty")))
+			_, err = Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+
+			require.DirExists(t, filepath.Join(dstDir, "untracked"), "Expected a directory the user created to survive")
+			require.DirExists(t, filepath.Join(dstDir, "empty"), "Expected a directory whose delete was held back to survive")
+			state, err := LoadState(dstDir, cfg)
+			require.NoError(t, err)
+			require.Contains(t, state.Entries, "empty")
+		})
+	}
+}
+
+func TestSyncCleansUpStaleTempFiles(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "mirrored.mimic.tmp"), []byte("synced"), 0644))
+	cfg := config.NewDefaultConfig()
+	cfg.StateDir = t.TempDir()
+	cfg.StaleTempAge = 0
+	_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	// What a crashed run leaves behind
+	_, stateFile, _ := statePaths(dstDir, cfg)
+	leftovers := []string{fileops.TempPath(stateFile), filepath.Join(dstDir, fileops.TempPath("a.txt"))}
+	for _, path := range leftovers {
+		require.NoError(t, os.WriteFile(path, []byte("partial"), 0644))
+	}
+
+	_, err = Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	for _, path := range leftovers {
+		require.NoFileExists(t, path)
+	}
+	require.FileExists(t, filepath.Join(dstDir, "mirrored.mimic.tmp"), "Expected a synced file sharing the temp suffix to be kept")
+}
@@ -1,34 +1,58 @@
 package syncer
 
 import (
+	"cmp"
+	"context"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cespare/xxhash/v2"
 	"github.com/ogzhanolguncu/mimic/internal/config"
 	"github.com/ogzhanolguncu/mimic/internal/fileops"
 	"github.com/ogzhanolguncu/mimic/internal/logger"
+	"github.com/ogzhanolguncu/mimic/internal/report"
 )
 
+// Action types. Files and directories get distinct creates and deletes; ActionUpdate is
+// only planned for a directory to rename it (see ReasonCaseRenamed).
 const (
 	ActionNone   = 0x00
-	ActionCreate = 0x01
-	ActionUpdate = 0x02
-	ActionDelete = 0x03
+	ActionCreate = 0x01 // Copy a new file
+	ActionUpdate = 0x02 // Copy a changed file over the old one
+	ActionDelete = 0x03 // Delete a file
+	ActionMkdir  = 0x04 // Create a directory
+	ActionRmdir  = 0x05 // Delete a directory with anything left in it
+	ActionChmod  = 0x06 // Only apply the permissions of an otherwise unchanged entry
 )
 
 type SyncAction struct {
 	Type         int
 	RelativePath string
-	SourceInfo   EntryInfo
+	// SourceInfo describes the entry the action is about: the scanned source entry for
+	// creates, updates and unchanged files, and the last recorded state entry for deletes.
+	SourceInfo EntryInfo
+	// PreviousInfo is the recorded state entry an update replaces (empty for other actions).
+	PreviousInfo EntryInfo
+	// Reason records what triggered an update (zero for other actions).
+	Reason ChangeReason
+}
+
+// sourcePath returns the path of the action's entry relative to the source root.
+func (a SyncAction) sourcePath() string {
+	return cmp.Or(a.SourceInfo.SourcePath, a.RelativePath)
 }
 
 type EntryInfo struct {
@@ -38,34 +62,190 @@ type EntryInfo struct {
 	IsDir        bool        // True if this entry is a directory.
 	Checksum     string      // Hash of file contents (empty for directories).
 	Permissions  os.FileMode // Full file mode bits (type + permissions).
+	// BlockChecksums hash consecutive BlockSize byte blocks of the file; only recorded with
+	// config.ChecksumBlockSize for files larger than one block.
+	BlockChecksums []string `json:",omitempty"`
+	BlockSize      int64    `json:",omitempty"`
+	// QuickHash marks a Checksum that only fingerprints the size, head and tail of the file
+	// (config.QuickHash); it is never compared against a full-content checksum.
+	QuickHash bool `json:",omitempty"`
+	// SymlinkTarget is the target of a symlink, as read from the link; empty for other entries.
+	// Unless config.SymlinkDereference applies, Checksum then hashes the target.
+	SymlinkTarget string `json:",omitempty"`
+	// MtimeClamped marks a file whose mtime was in the future and was replaced by the scan
+	// time (config.FutureMtimesClamp), so its mtime says nothing about changes.
+	MtimeClamped bool `json:"-"`
+	// SourcePath is the path relative to the source root when the entry is stored under
+	// a different name (see windowsEntryPath); empty when they are the same.
+	SourcePath string `json:"-"`
 }
 
 var (
-	ErrSyncerRead          = errors.New("syncer: read error")
-	ErrSyncerNotExist      = errors.New("syncer: path does not exist")
-	ErrSyncerNoDir         = errors.New("syncer: path is not a dir")
-	ErrSyncerSrcNotExists  = errors.New("syncer: src dir does not exist")
-	ErrSyncerChecksum      = errors.New("syncer: checksum calculation failed")
-	ErrEmptySrcDir         = errors.New("syncer: src dir is empty")
-	ErrEmptySrcNotADir     = errors.New("syncer: src is not a dir")
-	ErrSyncerFaultyRelPath = errors.New("syncer: rel path cannot be calculated")
-	ErrSyncerDirWalk       = errors.New("syncer: dir walk failed")
+	ErrSyncerRead              = errors.New("syncer: read error")
+	ErrSyncerNotExist          = errors.New("syncer: path does not exist")
+	ErrSyncerNoDir             = errors.New("syncer: path is not a dir")
+	ErrSyncerSrcNotExists      = errors.New("syncer: src dir does not exist")
+	ErrSyncerChecksum          = errors.New("syncer: checksum calculation failed")
+	ErrEmptySrcDir             = errors.New("syncer: src dir is empty")
+	ErrEmptySrcNotADir         = errors.New("syncer: src is not a dir")
+	ErrSyncerFaultyRelPath     = errors.New("syncer: rel path cannot be calculated")
+	ErrSyncerDirWalk           = errors.New("syncer: dir walk failed")
+	ErrSyncerTypeConflict      = errors.New("syncer: destination entry has a different type than the source")
+	ErrSyncerActionsFailed     = errors.New("syncer: some actions failed")
+	ErrSyncerWindowsName       = errors.New("syncer: name cannot be stored on Windows")
+	ErrSyncerFlattenCollision  = errors.New("syncer: flattened name is used by another source file")
+	ErrSyncerRelativeTo        = errors.New("syncer: source root is not inside the relative-to base")
+	ErrSyncerExecutionMismatch = errors.New("syncer: destination does not reflect the executed actions")
+	ErrSyncerStreamingOption   = errors.New("syncer: option not supported with -streaming")
+	ErrSyncerKeepFile          = errors.New("syncer: cannot read the keep file")
+	ErrSyncerRenameUnsupported = errors.New("syncer: destination cannot rename entries")
+	ErrSyncerMassDelete        = errors.New("syncer: refusing to delete this many destination entries")
+	ErrSyncerRootSymlink       = errors.New("syncer: root dir is a symlink")
+	ErrSyncerInsufficientSpace = errors.New("syncer: not enough free space on the destination")
+	ErrSyncerChmodUnsupported  = errors.New("syncer: destination cannot change permissions")
+	ErrSyncerPathTooLong       = errors.New("syncer: path too long for the destination")
+	ErrSyncerDestinationNewer  = errors.New("syncer: destination is newer than the source")
 )
 
+// checksumOnCopy reports whether cfg.ChecksumOnCopy is in effect. Modes that need every
+// source checksum before anything is copied (checksum comparison, verification of
+// equal mtimes or of a checksum band, manifests, duplicate reports, block checksums,
+// adoption, stateless planning) and quick fingerprints, which are cheap anyway, keep
+// hashing at scan time.
+func checksumOnCopy(cfg *config.Config) bool {
+	return cfg.ChecksumOnCopy && !cfg.Checksum && !cfg.VerifyOnEqualMtime && cfg.ManifestOut == "" &&
+		!cfg.ReportDupes && cfg.ChecksumBlockSize == 0 && !cfg.Stateless && !cfg.Adopt && !cfg.QuickHash &&
+		!checksumBand(cfg)
+}
+
+// checksumBand reports whether cfg limits hashing to a band of file sizes (see
+// config.ChecksumMin).
+func checksumBand(cfg *config.Config) bool {
+	return cfg.ChecksumMin > 0 || cfg.ChecksumMax > 0
+}
+
+// inChecksumBand reports whether a file of the given size lies in the checksum band of cfg.
+func inChecksumBand(size int64, cfg *config.Config) bool {
+	return checksumBand(cfg) && size >= cfg.ChecksumMin && (cfg.ChecksumMax == 0 || size <= cfg.ChecksumMax)
+}
+
 // ScanSource scans the root directory recursively and returns a map of all entries
 // keyed by their relative path, containing their metadata.
 // Errors during scanning of individual files (e.g., checksum failure) are logged,
-// and the file is skipped, allowing the scan to continue. More critical errors
-// (e.g., cannot read root directory, permission denied on subdirectory traversal)
-// will halt the scan and return an error.
-func ScanSource(rootDir string) (map[string]EntryInfo, error) {
+// and the file is skipped, allowing the scan to continue. So are entries that cannot
+// be read, such as a directory whose listing fails, which are reported together once
+// the walk ends; with cfg.StrictScan they halt the scan instead. An unreadable root
+// always halts the scan.
+// File checksums are computed by up to cfg.HashWorkers concurrent hashers once the walk completes.
+// With a checksum band (see config.ChecksumMin) only files sized within it are hashed.
+// With cfg.ChecksumCache a file whose size and mtime match its cached record takes the
+// cached checksum instead of being hashed, and new checksums are saved to the cache.
+// Files larger than cfg.MaxFileSize (when set), matching one of cfg.SizeExcludeRules or
+// outside the cfg.NewerThan/cfg.OlderThan mtime window are left out of the result;
+// directories are always traversed.
+func ScanSource(rootDir string, cfg *config.Config) (map[string]EntryInfo, error) {
+	entries, _, _, err := scanSource(rootDir, cfg, nil)
+	return entries, err
+}
+
+// scanSource is ScanSource that also returns the entry paths skipped as unreadable by
+// the walk or a failed checksum (see dropUnreadableDeletes) and the files left out for
+// falling outside the mtime window, whose destination copies stay. For -checksum-on-copy
+// reuse is non-nil: a file whose entry in reuse still matches on size and mtime keeps
+// its recorded checksum, and every other file is left without one for the copy to fill
+// in (see copyOrSkip).
+func scanSource(rootDir string, cfg *config.Config, reuse map[string]EntryInfo) (entries map[string]EntryInfo, unreadable, outsideWindow []string, err error) {
 	op := "ScanSource"
 	logger.Debug("starting scan", "operation", op, "dir", rootDir)
 
+	walk, err := newSourceWalk(rootDir, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	entries = make(map[string]EntryInfo)
+	var jobs []hashJob
+	err = walk.walk(entries, reuse, func(entry EntryInfo, path string, hash bool) error {
+		entries[entry.RelativePath] = entry
+		if hash {
+			// Checksums are computed after the walk by the hashing pool
+			jobs = append(jobs, hashJob{relPath: entry.RelativePath, path: path, size: entry.Size})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := addPrefixDirs(entries, walk.absRoot, walk.prefix); err != nil {
+		return nil, nil, nil, err
+	}
+
+	for i, result := range hashFiles(walk.root, jobs, cfg, walk.progress) {
+		relPath := jobs[i].relPath
+		if result.skip {
+			delete(entries, relPath)
+			if result.failed {
+				walk.markUnreadable(relPath)
+			}
+			continue
+		}
+		entries[relPath] = withChecksum(entries[relPath], result, cfg)
+		walk.cacheChecksum(entries[relPath])
+	}
+	walk.saveCache()
+
+	logger.Info("scan finished successfully", "operation", op, "dir", walk.root, "entries_found", len(entries))
+	return entries, walk.unreadable, walk.outsideWindow, nil
+}
+
+// withChecksum returns entry with the checksum from a hashing result.
+func withChecksum(entry EntryInfo, result hashResult, cfg *config.Config) EntryInfo {
+	entry.Checksum, entry.QuickHash = result.checksum, result.quick
+	if len(result.blocks) > 0 {
+		entry.BlockChecksums, entry.BlockSize = result.blocks, cfg.ChecksumBlockSize
+	}
+	return entry
+}
+
+// reusableChecksum reports whether prev, the recorded entry of a file, still matches the
+// scanned entry on size and mtime, so its checksum can stand in for hashing the file again.
+func reusableChecksum(entry, prev EntryInfo, cfg *config.Config) bool {
+	return prev.Checksum != "" && !prev.IsDir && prev.Size == entry.Size &&
+		sameMtime(entry.Mtime, prev.Mtime, cfg.MtimeThreshold)
+}
+
+// sourceWalk is a prepared walk over a local source directory, shared by scanSource and
+// the streaming pipeline (see streamSource) so both map entries the same way.
+type sourceWalk struct {
+	cfg           *config.Config
+	root          string // Resolved directory that is walked
+	absRoot       string
+	prefix        string // Prefix added by cfg.RelativeTo
+	progress      *scanProgress
+	start         time.Time
+	skipMounts    map[string]bool
+	oneFileSystem bool
+	rootDevice    uint64
+	manifest      map[string]ManifestEntry
+	cache         *ChecksumCache // Shared checksums with cfg.ChecksumCache, if usable (see cacheable)
+	mu            sync.Mutex     // Guards unreadable against the streaming hashers
+	unreadable    []string       // Entry paths skipped because they could not be read
+	outsideWindow []string       // File entry paths skipped for falling outside the mtime window
+	readErrs      []error
+}
+
+// walkDir walks the source tree; tests swap it to inject read errors.
+var walkDir = filepath.WalkDir
+
+// newSourceWalk resolves and checks rootDir and loads what the walk needs from cfg.
+func newSourceWalk(rootDir string, cfg *config.Config) (*sourceWalk, error) {
 	if rootDir == "" {
 		return nil, ErrEmptySrcDir
 	}
-	rootDir = filepath.Clean(rootDir)
+	rootDir, err := resolveRoot(filepath.Clean(rootDir), cfg.DereferenceRoot)
+	if err != nil {
+		return nil, err
+	}
 
 	fileInfo, err := retryableOpWithResult("exists", rootDir, func() (os.FileInfo, error) {
 		return exists(rootDir)
@@ -77,16 +257,116 @@ func ScanSource(rootDir string) (map[string]EntryInfo, error) {
 		return nil, ErrEmptySrcNotADir
 	}
 
-	entries := make(map[string]EntryInfo)
+	absRoot, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyncerFaultyRelPath, err)
+	}
+	w := &sourceWalk{
+		cfg:           cfg,
+		root:          rootDir,
+		absRoot:       absRoot,
+		skipMounts:    excludedMounts(cfg.ExcludeFSTypes, cfg.ExcludeMounts),
+		progress:      newScanProgress(rootDir, cfg),
+		start:         clock.Now(),
+		oneFileSystem: cfg.OneFileSystem,
+	}
+	if w.prefix, err = relativePrefix(absRoot, cfg.RelativeTo, cfg.Flatten); err != nil {
+		return nil, err
+	}
+
+	var ok bool
+	w.rootDevice, ok = deviceOf(fileInfo)
+	if w.oneFileSystem && !ok {
+		logger.Warn("device ids are not available on this platform, -one-file-system has no effect")
+		w.oneFileSystem = false
+	}
+
+	if cfg.SourceChecksums != "" {
+		if w.manifest, err = LoadChecksumManifest(cfg.SourceChecksums); err != nil {
+			return nil, err
+		}
+		logger.Info("using checksum manifest", "path", cfg.SourceChecksums, "entries", len(w.manifest))
+	}
+	if cacheable(cfg) {
+		if w.cache, err = LoadChecksumCache(cfg.ChecksumCache); err != nil {
+			return nil, err
+		}
+		logger.Info("using checksum cache", "path", cfg.ChecksumCache, "entries", len(w.cache.entries))
+	} else if cfg.ChecksumCache != "" {
+		logger.Warn("checksum cache holds full checksums only, not using it with -quick-hash or -checksum-block")
+	}
+	return w, nil
+}
+
+// cacheKey is the checksum cache key of a scanned entry: its absolute source path.
+func (w *sourceWalk) cacheKey(entry EntryInfo) string {
+	return filepath.Join(w.absRoot, cmp.Or(entry.SourcePath, entry.RelativePath))
+}
+
+// cachedChecksum returns the checksum the cache holds for entry, if any. A clamped mtime
+// says nothing about the file, so such entries are always hashed.
+func (w *sourceWalk) cachedChecksum(entry EntryInfo) (string, bool) {
+	if w.cache == nil || entry.MtimeClamped {
+		return "", false
+	}
+	return w.cache.Lookup(w.cacheKey(entry), entry.Size, entry.Mtime)
+}
+
+// cacheChecksum records the checksum of a freshly hashed entry in the cache.
+func (w *sourceWalk) cacheChecksum(entry EntryInfo) {
+	if w.cache == nil || entry.MtimeClamped || entry.Checksum == "" {
+		return
+	}
+	w.cache.Store(w.cacheKey(entry), entry.Size, entry.Mtime, entry.Checksum)
+}
+
+// markUnreadable records relPath as skipped because it could not be read, so the
+// delete of its destination copy is held (see dropUnreadableDeletes).
+func (w *sourceWalk) markUnreadable(relPath string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.unreadable = append(w.unreadable, relPath)
+}
+
+// saveCache writes the checksum cache back once the walk and its hashing are done. The
+// cache only saves work, so failing to write it does not fail the scan.
+func (w *sourceWalk) saveCache() {
+	if w.cache == nil {
+		return
+	}
+	if err := w.cache.Save(w.cfg.ChecksumCache, w.absRoot); err != nil {
+		logger.Warn("cannot save checksum cache", "path", w.cfg.ChecksumCache, "error", err)
+	}
+}
 
-	walkErr := filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, walkErrIn error) error {
+// walk visits every entry below the root in walk order, handing visit the entry, its
+// path on disk and whether its checksum still has to be computed. entries holds what
+// was visited so far and is only read, for flattening and for two paths mapping to the
+// same entry. With reuse non-nil no file is left to hash: a file whose recorded entry
+// still matches keeps its checksum and the others get none (see scanSource).
+// Entries that cannot be read are skipped and collected in w.unreadable unless
+// cfg.StrictScan is set; permission-denied ones are skipped either way.
+func (w *sourceWalk) walk(entries, reuse map[string]EntryInfo, visit func(entry EntryInfo, path string, hash bool) error) error {
+	cfg, rootDir := w.cfg, w.root
+	walkErr := walkDir(rootDir, func(path string, d fs.DirEntry, walkErrIn error) error {
 		if walkErrIn != nil {
-			if errors.Is(walkErrIn, fs.ErrPermission) {
+			relPath, _ := filepath.Rel(rootDir, path)
+			denied := errors.Is(walkErrIn, fs.ErrPermission)
+			if cfg.StrictScan && !denied || relPath == "." {
+				logger.Error("access error during scan", "path", path, "error", walkErrIn)
+				return &SyncError{Op: OpScan, Path: relPath, Err: walkErrIn} // Halt the walk
+			}
+			if denied {
 				logger.Warn("permission denied during scan, skipping", "path", path, "error", walkErrIn)
+			} else {
+				logger.Warn("access error during scan, skipping", "path", path, "error", walkErrIn)
+			}
+			w.markUnreadable(filepath.Join(w.prefix, relPath))
+			w.readErrs = append(w.readErrs, &SyncError{Op: OpScan, Path: relPath, Err: walkErrIn})
+			if d != nil && d.IsDir() {
 				return fs.SkipDir
 			}
-			logger.Error("access error during scan", "path", path, "error", walkErrIn)
-			return walkErrIn // Halt the walk for other errors
+			return nil
 		}
 
 		relPath, err := retryableOpWithResult("rel_path", rootDir, func() (string, error) {
@@ -97,12 +377,42 @@ func ScanSource(rootDir string) (map[string]EntryInfo, error) {
 		}
 		relPath = filepath.Clean(relPath)
 
-		// TODO: Later pass config exclude path here
-		if relPath == "." || shouldExclude(relPath, []string{".DS_Store"}) {
+		if relPath == "." || shouldExclude(relPath, cfg.ExcludePatterns) {
 			logger.Debug("skipping entry", "path", relPath)
 			return nil // Continue walking
 		}
 
+		if d.IsDir() && w.skipMounts[filepath.Join(w.absRoot, relPath)] {
+			logger.Info("skipping excluded mount", "path", relPath)
+			return fs.SkipDir
+		}
+
+		entryPath, skip, err := windowsEntryPath(relPath, cfg)
+		if err != nil {
+			return &SyncError{Op: OpScan, Path: relPath, Err: err} // Halt the walk
+		}
+		if skip {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if cfg.Flatten {
+			if d.IsDir() {
+				return nil // Only the files below are synced
+			}
+			if entryPath, err = flatEntryPath(entryPath, entries, cfg.FlattenRename); err != nil {
+				return &SyncError{Op: OpScan, Path: relPath, Err: err} // Halt the walk
+			}
+		}
+		if w.prefix != "" {
+			entryPath = filepath.Join(w.prefix, entryPath)
+		}
+		if _, taken := entries[entryPath]; taken {
+			return &SyncError{Op: OpScan, Path: relPath,
+				Err: fmt.Errorf("%w: maps to %s, which another source entry already uses", ErrSyncerWindowsName, entryPath)}
+		}
+
 		info, err := retryableOpWithResult("file_info", rootDir, func() (fs.FileInfo, error) {
 			return d.Info()
 		})
@@ -114,47 +424,133 @@ func ScanSource(rootDir string) (map[string]EntryInfo, error) {
 			logger.Error("cannot get file info, skipping entry", "path", path, "error", err)
 			return nil
 		}
+		var linkTarget string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if linkTarget, info, err = scanSymlink(path, relPath, info, cfg); err != nil {
+				logger.Error("cannot read symlink, skipping entry", "path", path, "error", err)
+				return nil
+			}
+			if info == nil {
+				return nil
+			}
+		}
 
 		isDir := d.IsDir()
+		if isDir && w.oneFileSystem {
+			if device, ok := deviceOf(info); ok && device != w.rootDevice {
+				logger.Info("skipping directory on another file system", "path", relPath)
+				return fs.SkipDir
+			}
+		}
+		if !isDir && cfg.MaxFileSize > 0 && info.Size() > cfg.MaxFileSize {
+			logger.Warn("file exceeds max file size, skipping entry", "path", relPath, "size", info.Size(), "max_size", cfg.MaxFileSize)
+			return nil
+		}
+		if !isDir && excludedBySize(relPath, info.Size(), cfg.SizeExcludeRules) {
+			logger.Debug("file matches a size exclude rule, skipping entry", "path", relPath, "size", info.Size())
+			return nil
+		}
+		mtime, clamped := info.ModTime(), false
+		if !isDir && mtime.After(w.start.Add(cfg.FutureThreshold)) {
+			switch cfg.FutureMtimes {
+			case config.FutureMtimesSkip:
+				logger.Warn("file modified in the future, skipping entry", "path", relPath, "mtime", mtime)
+				return nil
+			case config.FutureMtimesClamp:
+				logger.Warn("file modified in the future, using the scan time", "path", relPath, "mtime", mtime)
+				mtime, clamped = w.start, true
+			default:
+				logger.Warn("file modified in the future", "path", relPath, "mtime", mtime)
+			}
+		}
+		if !isDir && !withinMtimeWindow(mtime, cfg.NewerThan, cfg.OlderThan) {
+			logger.Debug("file outside mtime window, skipping entry", "path", relPath, "mtime", mtime)
+			w.outsideWindow = append(w.outsideWindow, entryPath)
+			return nil
+		}
+
 		entry := EntryInfo{
-			RelativePath: relPath,
-			Mtime:        info.ModTime(),
-			Size:         info.Size(), // Size is 0 or irrelevant for dirs, but store anyway
-			IsDir:        isDir,
-			Permissions:  info.Mode(), // Store the full FileMode
-			Checksum:     "",
+			RelativePath:  entryPath,
+			Mtime:         mtime,
+			MtimeClamped:  clamped,
+			Size:          info.Size(), // Size is 0 or irrelevant for dirs, but store anyway
+			IsDir:         isDir,
+			Permissions:   info.Mode(), // Store the full FileMode
+			Checksum:      "",
+			SymlinkTarget: linkTarget,
+		}
+		if entryPath != relPath {
+			entry.SourcePath = relPath
 		}
 
-		if !isDir {
-			checksumBytes, csErr := retryableOpWithResult("checksum", rootDir, func() ([]byte, error) {
-				return generateChecksum(path)
-			})
-			if csErr != nil {
-				if errors.Is(csErr, ErrSyncerNotExist) {
-					logger.Warn("file disappeared before checksum, skipping entry", "path", path)
-					return nil
+		hash := false
+		if isLink(entry, cfg) {
+			entry.Checksum = linkChecksum(linkTarget)
+		} else if !isDir {
+			if checksum, ok := manifestChecksum(w.manifest, relPath, info.Size()); ok {
+				entry.Checksum = checksum
+			} else if checksumBand(cfg) && !inChecksumBand(info.Size(), cfg) {
+				logger.Debug("file outside checksum band, not hashing", "path", relPath, "size", info.Size())
+			} else if checksum, ok := w.cachedChecksum(entry); ok {
+				entry.Checksum = checksum
+			} else if reuse != nil {
+				if prev, ok := reuse[entryPath]; ok && reusableChecksum(entry, prev, cfg) {
+					entry.Checksum, entry.QuickHash = prev.Checksum, prev.QuickHash
 				}
-				logger.Warn("checksum failed, skipping file", "path", path, "error", csErr)
+			} else {
+				hash = true
 			}
-			entry.Checksum = hex.EncodeToString(checksumBytes)
 		}
 
-		entries[relPath] = entry
+		if err := visit(entry, path, hash); err != nil {
+			return err
+		}
+		w.progress.addFile()
 		logger.Debug("scanned entry", "path", relPath, "isDir", isDir)
 		return nil
 	})
 
 	if walkErr != nil {
-		return nil, fmt.Errorf("%w: %v", ErrSyncerDirWalk, walkErr)
+		return fmt.Errorf("%w: %w", ErrSyncerDirWalk, walkErr)
+	}
+	if len(w.readErrs) > 0 {
+		logger.Warn("scan skipped unreadable entries, their destination copies are kept",
+			"count", len(w.readErrs), "errors", errors.Join(w.readErrs...))
 	}
+	return nil
+}
 
-	logger.Info("scan finished successfully", "operation", op, "dir", rootDir, "entries_found", len(entries))
-	return entries, nil
+// resolveRoot returns the directory to walk for rootDir. The walk does not descend into
+// a symlinked root, so such a root is resolved to its target with dereference and
+// rejected without it, rather than silently scanning nothing.
+func resolveRoot(rootDir string, dereference bool) (string, error) {
+	info, err := os.Lstat(rootDir)
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		return rootDir, nil // Missing roots are reported by the existence check
+	}
+	if !dereference {
+		return "", fmt.Errorf("%w: %s (use -dereference-root to follow it)", ErrSyncerRootSymlink, rootDir)
+	}
+	resolved, err := filepath.EvalSymlinks(rootDir)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrSyncerSrcNotExists, err)
+	}
+	logger.Info("following symlinked root", "root", rootDir, "target", resolved)
+	return resolved, nil
 }
 
+// deviceOf resolves the device id of a scanned entry; tests swap it to fake mount points.
+var deviceOf = fileDevice
+
+// freeSpace reports available destination space; tests swap it to simulate a full disk.
+var freeSpace = fileops.FreeSpace
+
+// statFile is the stat call used by exists; tests swap it to count syscalls.
+var statFile = os.Stat
+
 // exists checks if a path exists and returns its FileInfo.
 func exists(path string) (os.FileInfo, error) {
-	fileInfo, err := os.Stat(path)
+	fileInfo, err := statFile(path)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return nil, ErrSyncerNotExist
@@ -164,23 +560,25 @@ func exists(path string) (os.FileInfo, error) {
 	return fileInfo, nil
 }
 
+// shouldExclude reports whether relPath matches any of the exclude patterns. Braces
+// expand to alternatives ("{tmp,cache}/") and "**" spans any number of directories.
+//   - "dir/" (trailing slash) excludes the path relative to the root and everything under it
+//   - a pattern without a slash matches the base name at any depth ("*.log")
+//   - any other pattern matches the whole relative path ("src/**/*.test.js")
 func shouldExclude(relPath string, matchers []string) bool {
-	baseName := filepath.Base(relPath)
+	slashPath := filepath.ToSlash(relPath)
+	baseName := path.Base(slashPath)
 	for _, pattern := range matchers {
-		if strings.HasSuffix(pattern, "/") { // Treat as directory prefix/exact match
-			dirPattern := strings.TrimSuffix(pattern, "/")
-			// Check if path is exactly this directory or inside it
-			if relPath == dirPattern || strings.HasPrefix(relPath, dirPattern+"/") {
-				return true
-			}
-		} else {
-			// Use filepath.Match for glob patterns against the base name
-			matched, _ := filepath.Match(pattern, baseName)
-			if matched {
-				return true
-			}
-			// Also handle exact matches for the whole path
-			if pattern == relPath {
+		for _, alt := range expandBraces(pattern) {
+			if dirPattern, ok := strings.CutSuffix(alt, "/"); ok {
+				if matchGlobOrParent(dirPattern, slashPath) {
+					return true
+				}
+			} else if !strings.Contains(alt, "/") {
+				if matchGlob(alt, baseName) {
+					return true
+				}
+			} else if matchGlob(alt, slashPath) {
 				return true
 			}
 		}
@@ -188,20 +586,107 @@ func shouldExclude(relPath string, matchers []string) bool {
 	return false
 }
 
+// excludedBySize reports whether a file of size bytes at relPath matches one of rules.
+func excludedBySize(relPath string, size int64, rules []config.SizeExcludeRule) bool {
+	for _, rule := range rules {
+		if rule.Smaller && size >= rule.Size || !rule.Smaller && size <= rule.Size {
+			continue
+		}
+		if shouldExclude(relPath, []string{rule.Pattern}) {
+			return true
+		}
+	}
+	return false
+}
+
+// withinMtimeWindow reports whether mtime falls in the half-open window [newerThan, olderThan).
+// A zero bound is treated as unbounded.
+func withinMtimeWindow(mtime, newerThan, olderThan time.Time) bool {
+	if !newerThan.IsZero() && mtime.Before(newerThan) {
+		return false
+	}
+	if !olderThan.IsZero() && !mtime.Before(olderThan) {
+		return false
+	}
+	return true
+}
+
 // generateChecksum calculates the xxHash checksum for a given file path.
 // Returns wrapped ErrRead or ErrChecksum on failure.
-func generateChecksum(filePath string) ([]byte, error) {
+// Unless assumeStable is set, the file is re-statted after reading to detect
+// modifications made while it was being hashed.
+func generateChecksum(filePath string, assumeStable bool) ([]byte, error) {
+	checksum, _, err := generateBlockChecksums(filePath, 0, assumeStable)
+	return checksum, err
+}
+
+// quickHashSpan is how many bytes from each end of a file a quick fingerprint hashes.
+const quickHashSpan = 64 << 10
+
+// generateQuickChecksum fingerprints a file by hashing its size and its first and last
+// quickHashSpan bytes, so it misses edits in the middle that keep the size. Files up to
+// twice the span are hashed whole. Failures are reported like generateChecksum.
+func generateQuickChecksum(filePath string, assumeStable bool) ([]byte, error) {
 	initialInfo, err := exists(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrSyncerSrcNotExists, err)
 	}
+	size := initialInfo.Size()
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, ErrSyncerRead
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Printf("error closing file: %v", err)
+		}
+	}()
+
+	hash := xxhash.New()
+	_ = binary.Write(hash, binary.LittleEndian, size)
+	if size <= 2*quickHashSpan {
+		if _, err := io.Copy(hash, file); err != nil {
+			return nil, ErrSyncerChecksum
+		}
+	} else {
+		head := io.NewSectionReader(file, 0, quickHashSpan)
+		tail := io.NewSectionReader(file, size-quickHashSpan, quickHashSpan)
+		if _, err := io.Copy(hash, io.MultiReader(head, tail)); err != nil {
+			return nil, ErrSyncerChecksum
+		}
+	}
+
+	if !assumeStable {
+		currentInfo, err := exists(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSyncerSrcNotExists, err)
+		}
+		if currentInfo.ModTime() != initialInfo.ModTime() || currentInfo.Size() != size {
+			logger.Warn("file modified during checksum calculation",
+				"path", filePath,
+				"initial_mtime", initialInfo.ModTime(),
+				"current_mtime", currentInfo.ModTime())
+			return nil, ErrSyncerChecksum
+		}
+	}
+	return hash.Sum(nil), nil
+}
+
+// generateBlockChecksums calculates the whole-file checksum like generateChecksum and,
+// with a positive blockSize, the checksum of every blockSize byte block in the same pass.
+func generateBlockChecksums(filePath string, blockSize int64, assumeStable bool) ([]byte, [][]byte, error) {
+	initialInfo, err := exists(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrSyncerSrcNotExists, err)
+	}
 
 	initialMtime := initialInfo.ModTime()
 	initialSize := initialInfo.Size()
 
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, ErrSyncerRead
+		return nil, nil, ErrSyncerRead
 	}
 	defer func() {
 		if err := file.Close(); err != nil {
@@ -210,42 +695,82 @@ func generateChecksum(filePath string) ([]byte, error) {
 	}()
 
 	hash := xxhash.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return nil, ErrSyncerChecksum
+	var blocks [][]byte
+	if blockSize > 0 {
+		block := xxhash.New()
+		for {
+			block.Reset()
+			n, err := io.CopyN(io.MultiWriter(hash, block), file, blockSize)
+			if n > 0 {
+				blocks = append(blocks, block.Sum(nil))
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, nil, ErrSyncerChecksum
+			}
+		}
+	} else if _, err := io.Copy(hash, file); err != nil {
+		return nil, nil, ErrSyncerChecksum
+	}
+
+	if assumeStable {
+		return hash.Sum(nil), blocks, nil
 	}
 
 	currentInfo, err := exists(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrSyncerSrcNotExists, err)
+		return nil, nil, fmt.Errorf("%w: %v", ErrSyncerSrcNotExists, err)
 	} else if currentInfo.ModTime() != initialMtime || currentInfo.Size() != initialSize {
 		// File changed during scan
 		logger.Warn("file modified during checksum calculation",
 			"path", filePath,
 			"initial_mtime", initialMtime,
 			"current_mtime", currentInfo.ModTime())
-		return nil, ErrSyncerChecksum
+		return nil, nil, ErrSyncerChecksum
 		//  Mark the file with a special flag in its entry (better approach)
 		// Return the checksum anyway, and handle in the caller with a flag
 	}
 
-	return hash.Sum(nil), nil
+	return hash.Sum(nil), blocks, nil
 }
 
-const maxRetries = 5
+const maxRetries = config.DefaultRetries
+
+// Retry runs op with the same retry policy as the scan; destinations use it to ride out
+// transient failures such as dropped connections.
+func Retry[T any](operation string, path string, op func() (T, error)) (T, error) {
+	return retryableOpWithResult(operation, path, op)
+}
 
 // Generic retryable operation that returns a value and an error
 func retryableOpWithResult[T any](operation string, path string, op func() (T, error)) (T, error) {
+	return retryAttempts(maxRetries, operation, path, op)
+}
+
+// retryAction runs a mutating action with up to cfg.Retries attempts.
+func retryAction(cfg *config.Config, operation string, path string, op func() error) error {
+	_, err := retryAttempts(cfg.Retries, operation, path, func() (struct{}, error) {
+		return struct{}{}, op()
+	})
+	return err
+}
+
+// retryAttempts runs op up to attempts times (at least once), backing off a little
+// longer after each failure. Errors that cannot go away by retrying are returned
+// immediately.
+func retryAttempts[T any](attempts int, operation string, path string, op func() (T, error)) (T, error) {
 	var result T
 	var lastErr error
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
+	for attempt := 0; attempt < max(attempts, 1); attempt++ {
 		r, err := op()
 		if err == nil {
 			return r, nil
 		}
 
-		// If the file disappeared, no point retrying
-		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrSyncerNotExist) {
+		if isPermanent(err) {
 			return result, err
 		}
 
@@ -262,89 +787,799 @@ func retryableOpWithResult[T any](operation string, path string, op func() (T, e
 	return result, lastErr
 }
 
+// isPermanent reports whether retrying err is pointless: the path disappeared, access
+// is denied, the path is too long, the destination has a conflicting entry or no room
+// for a preallocated file, or the run was cancelled.
+func isPermanent(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrSyncerNotExist) ||
+		errors.Is(err, fs.ErrPermission) || isPathTooLong(err) || errors.Is(err, ErrSyncerTypeConflict) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, fileops.ErrNoSpace)
+}
+
 // ------- SYNC ACTIONS -------
 
-func CompareStates(sourceScan, loadedStateEntries map[string]EntryInfo) []SyncAction {
+// sameMtime reports whether a and b are less than threshold apart, or equal when threshold
+// is 0, so mtimes truncated by a coarser file system still count as the same.
+func sameMtime(a, b time.Time, threshold time.Duration) bool {
+	diff := a.Sub(b).Abs()
+	return diff == 0 || diff < threshold
+}
+
+// CompareStates plans the actions needed to bring the recorded state in line with the
+// source scan. Source paths are processed in sorted order, so parents come before their
+// children, followed by deletes, also sorted.
+// Mtimes less than cfg.MtimeThreshold apart count as equal, and a clamped future mtime
+// (see EntryInfo.MtimeClamped) always does.
+// With cfg.VerifyOnEqualMtime, files whose size and mtime match the state are only
+// considered unchanged when their scanned checksum also matches the recorded one.
+// With cfg.NoTimes mtimes are ignored and files of equal size are unchanged; adding
+// cfg.Checksum still updates those whose checksum differs from the recorded one.
+// With a checksum band (see config.ChecksumMin) files sized within it are verified
+// by checksum like cfg.VerifyOnEqualMtime does, and the others by size and mtime only.
+// Symlinks that are not dereferenced are also updated when their target changed.
+// With cfg.SyncPermsAlways an unchanged file or directory whose permissions differ from
+// the recorded ones gets an ActionChmod instead of ActionNone.
+func CompareStates(sourceScan, loadedStateEntries map[string]EntryInfo, cfg *config.Config) []SyncAction {
 	var syncActions []SyncAction
-	const timeDiffThreshold = 1 * time.Second
+
+	// Entries recorded under a differently cased path are renamed rather than recreated
+	var renames map[string]string
+	if cfg.CaseInsensitive {
+		renames = caseRenames(sourceScan, loadedStateEntries)
+	}
+	renamedFrom := make(map[string]bool, len(renames))
 
 	// Process source entries (creates and updates)
-	for path, source := range sourceScan {
+	for _, path := range slices.Sorted(maps.Keys(sourceScan)) {
+		source := sourceScan[path]
 		entry, found := loadedStateEntries[path]
+		var renamed ChangeReason
+		if old, ok := renames[path]; ok {
+			entry, found, renamed = loadedStateEntries[old], true, ReasonCaseRenamed
+			entry.RelativePath = old
+			renamedFrom[old] = true
+		}
+
+		syncActions = append(syncActions, compareEntry(path, source, entry, found, renamed, cfg))
+	}
 
-		if !found {
-			// New file - create action
+	// Process loaded entries (deletes), keeping the last known entry for reporting
+	for _, path := range slices.Sorted(maps.Keys(loadedStateEntries)) {
+		if _, exists := sourceScan[path]; !exists && !renamedFrom[path] {
 			syncActions = append(syncActions, SyncAction{
-				Type: ActionCreate, RelativePath: path, SourceInfo: source,
+				Type: deleteAction(loadedStateEntries[path]), RelativePath: path, SourceInfo: loadedStateEntries[path],
 			})
-			continue
 		}
+	}
+
+	return syncActions
+}
 
-		// Check if file is unchanged
-		timeDiff := source.Mtime.Sub(entry.Mtime)
-		sameTime := timeDiff < timeDiffThreshold && timeDiff > -timeDiffThreshold
-		sameSize := source.Size == entry.Size
+// compareEntry plans the action for one source entry against its recorded entry, found
+// in the state or not, as CompareStates does. renamed is set when the entry was recorded
+// under a differently cased path.
+func compareEntry(path string, source, entry EntryInfo, found bool, renamed ChangeReason, cfg *config.Config) SyncAction {
+	if !found {
+		// New file or directory - create action
+		return SyncAction{Type: createAction(source), RelativePath: path, SourceInfo: source}
+	}
 
-		if sameTime && sameSize {
-			syncActions = append(syncActions, SyncAction{
-				Type: ActionNone, RelativePath: path, SourceInfo: EntryInfo{},
-			})
-		} else {
-			syncActions = append(syncActions, SyncAction{
+	// A directory's mtime changes with its children, which carry their own actions
+	if source.IsDir && entry.IsDir {
+		if renamed != 0 {
+			return SyncAction{
 				Type: ActionUpdate, RelativePath: path, SourceInfo: source,
-			})
+				PreviousInfo: entry, Reason: renamed,
+			}
+		}
+		return unchangedAction(path, source, entry, cfg)
+	}
+	// A directory replacing a recorded file is created once the file is out of the way
+	if source.IsDir {
+		return SyncAction{
+			Type: ActionMkdir, RelativePath: path, SourceInfo: source, PreviousInfo: entry,
 		}
 	}
 
-	// Process loaded entries (deletes)
-	for path := range loadedStateEntries {
-		if _, exists := sourceScan[path]; !exists {
-			syncActions = append(syncActions, SyncAction{
-				Type: ActionDelete, RelativePath: path, SourceInfo: EntryInfo{},
-			})
+	// Check if file is unchanged
+	sameTime := cfg.NoTimes || source.MtimeClamped || sameMtime(source.Mtime, entry.Mtime, cfg.MtimeThreshold)
+	sameSize := source.Size == entry.Size
+	// A kept link's checksum is its target, so retargeting is caught without hashing
+	verify := cfg.VerifyOnEqualMtime || cfg.NoTimes && cfg.Checksum || isLink(source, cfg) ||
+		inChecksumBand(source.Size, cfg)
+
+	switch {
+	case sameTime && sameSize && verify && checksumsDiffer(entry, source):
+		logger.Warn("content changed with identical size and mtime", "path", path)
+		return SyncAction{
+			Type: ActionUpdate, RelativePath: path, SourceInfo: source,
+			PreviousInfo: entry, Reason: renamed | changeReason(entry, source, false),
 		}
+	case sameTime && sameSize && renamed != 0:
+		return SyncAction{
+			Type: ActionUpdate, RelativePath: path, SourceInfo: source,
+			PreviousInfo: entry, Reason: renamed,
+		}
+	case sameTime && sameSize:
+		return unchangedAction(path, source, entry, cfg)
+	}
+	return SyncAction{
+		Type: ActionUpdate, RelativePath: path, SourceInfo: source,
+		PreviousInfo: entry, Reason: renamed | changeReason(entry, source, !sameTime),
 	}
+}
 
-	return syncActions
+// unchangedAction is the action for an entry whose contents match the recorded entry:
+// ActionNone, or ActionChmod when cfg.SyncPermsAlways is set and only the permissions changed.
+func unchangedAction(path string, source, recorded EntryInfo, cfg *config.Config) SyncAction {
+	if cfg.SyncPermsAlways && source.Permissions.Perm() != recorded.Permissions.Perm() {
+		return SyncAction{
+			Type: ActionChmod, RelativePath: path, SourceInfo: source,
+			PreviousInfo: recorded, Reason: ReasonPermsChanged,
+		}
+	}
+	return SyncAction{Type: ActionNone, RelativePath: path, SourceInfo: source}
 }
 
-func ExecuteActions(srcRoot, dstRoot string, actions []SyncAction, cfg *config.Config) error {
+// createAction is the action that creates entry on the destination.
+func createAction(entry EntryInfo) int {
+	if entry.IsDir {
+		return ActionMkdir
+	}
+	return ActionCreate
+}
+
+// deleteAction is the action that removes entry from the destination.
+func deleteAction(entry EntryInfo) int {
+	if entry.IsDir {
+		return ActionRmdir
+	}
+	return ActionDelete
+}
+
+// ExecuteActions applies the actions to the local directory dstRoot. See ExecuteActionsTo.
+func ExecuteActions(srcRoot, dstRoot string, actions []SyncAction, cfg *config.Config) (report.Summary, error) {
+	return ExecuteActionsTo(context.Background(), srcRoot, NewLocalDestination(dstRoot, cfg), actions, cfg, nil)
+}
+
+// ExecuteActionsTo applies the actions to dst, reading from the local directory srcRoot.
+// With cfg.RootMetadata a successful run ends by applying the root's own metadata (see
+// ApplyRootMetadata). See ExecuteActionsFrom.
+func ExecuteActionsTo(ctx context.Context, srcRoot string, dst Destination, actions []SyncAction, cfg *config.Config, checkpoint *Checkpointer) (report.Summary, error) {
+	summary, err := ExecuteActionsFrom(ctx, NewDirSource(srcRoot), dst, actions, cfg, checkpoint)
+	if err == nil && cfg.RootMetadata {
+		ApplyRootMetadata(srcRoot, dst)
+	}
+	return summary, err
+}
+
+// eventsOut receives the -events stream; tests swap it to decode the events.
+var eventsOut io.Writer = os.Stdout
+
+// newReporter picks how a run reports progress: as an event stream with cfg.Events, as a
+// status line on a terminal with cfg.Progress, or not at all.
+func newReporter(cfg *config.Config, plannedFiles int, plannedBytes int64) report.Reporter {
+	switch {
+	case cfg.Events == config.EventsJSONL:
+		return report.NewEventWriter(eventsOut, plannedFiles, plannedBytes)
+	case cfg.Progress && !cfg.Quiet && report.IsTerminal(os.Stderr):
+		return report.NewProgress(os.Stderr, plannedFiles, plannedBytes)
+	}
+	return (*report.Progress)(nil)
+}
+
+// ExecuteActionsFrom applies the actions to dst, reading files from src, and returns a
+// summary of what was actually done. On error the summary covers the actions completed so far.
+// Paths listed in the summary's Deferred, Failed and Kept were not applied, and callers
+// should not record them as synced. Every completed action is recorded in checkpoint,
+// which may be nil, and a pending checkpoint is saved before returning an error.
+// Cancelling ctx stops the run before the next action and returns the context's error.
+// actions is not modified; checksums taken while copying are returned in the summary's
+// Checksums.
+func ExecuteActionsFrom(ctx context.Context, src Source, dst Destination, actions []SyncAction, cfg *config.Config, checkpoint *Checkpointer) (summary report.Summary, err error) {
+	plannedFiles, plannedBytes := plannedWork(actions)
+	if err := checkFreeSpace(dst, plannedBytes, cfg); err != nil {
+		return summary, err
+	}
+
+	start := clock.Now()
+	progressRoot := ""
+	if local, ok := dst.(*LocalDestination); ok && cfg.PersistProgress {
+		progressRoot = local.Root()
+	}
+
+	var prior report.Summary
+	if progressRoot != "" {
+		prior, _ = loadProgress(progressRoot)
+	}
+	stats := report.NewStats(prior)
+	lastFlush := start
+
+	progress := newReporter(cfg, plannedFiles, plannedBytes)
+	doneFiles, doneBytes := 0, int64(0)
+
+	copied := make(map[string]string)
+	defer func() {
+		progress.Finish()
+		summary = stats.Snapshot()
+		summary.Elapsed = prior.Elapsed + since(start)
+		if len(copied) > 0 {
+			summary.Checksums = copied
+		}
+		if err != nil {
+			if saveErr := checkpoint.Flush(); saveErr != nil {
+				logger.Warn("cannot save checkpoint", "error", saveErr)
+			}
+		}
+		if progressRoot == "" {
+			return
+		}
+		// A run that only had failed actions is complete; those are planned again anyway
+		if err != nil && !errors.Is(err, ErrSyncerActionsFailed) {
+			if saveErr := saveProgress(progressRoot, summary); saveErr != nil {
+				logger.Warn("cannot persist progress", "error", saveErr)
+			}
+			return
+		}
+		clearProgress(progressRoot)
+	}()
+
+	if cfg.Phased {
+		actions = planPhases(actions)
+	}
+	// Locality only means something for files on a local disk
+	if dir, ok := src.(*DirSource); ok && cfg.CopyOrder == config.CopyOrderLocality {
+		actions = orderByLocality(dir.Root(), actions)
+	}
+	space, reserveEnabled := dst.(spaceReporter)
+	reserveEnabled = reserveEnabled && cfg.ReserveSpace > 0
+	var failures []error
+	lastSpaceCheck := start
+
+	// finish accounts for a finished action; an error it returns stops the run
+	finish := func(done appliedAction, err error) error {
+		action := done.action
+		if errors.Is(err, ErrSyncerDestinationNewer) {
+			// Not applied, so the state keeps what it recorded for the path
+			progress.Complete(action.RelativePath, done.bytes, nil)
+			stats.AddKept(action.RelativePath)
+			return nil
+		}
+		progress.Complete(action.RelativePath, done.bytes, err)
+		if err != nil {
+			if !cfg.ContinueOnError {
+				return err
+			}
+			logger.Error("action failed, continuing", "path", action.RelativePath, "error", err)
+			stats.AddFailed(action.RelativePath)
+			failures = append(failures, err)
+			return nil
+		}
+		if done.deferredChecksum && action.SourceInfo.Checksum != "" {
+			copied[action.RelativePath] = action.SourceInfo.Checksum
+		}
+		checkpoint.Record(action)
+
+		doneFiles++
+		if isFileCopy(action) {
+			doneBytes += action.SourceInfo.Size
+		}
+		progress.Update(doneFiles, doneBytes)
+
+		if progressRoot != "" && since(lastFlush) >= progressFlushInterval {
+			progress := stats.Snapshot()
+			progress.Elapsed = prior.Elapsed + since(start)
+			if err := saveProgress(progressRoot, progress); err != nil {
+				logger.Warn("cannot persist progress", "error", err)
+			}
+			lastFlush = clock.Now()
+		}
+		return nil
+	}
+	apply := func(action *SyncAction) error {
+		return applyAction(src, dst, action, cfg, stats)
+	}
+	// Copies run on cfg.CopyWorkers goroutines and anything else waits for them to finish.
+	// Archives are read in order and other destinations may not take concurrent writes.
+	var pool *copyPool
+	_, dirSource := src.(*DirSource)
+	_, localDst := dst.(*LocalDestination)
+	if cfg.CopyWorkers > 1 && dirSource && localDst {
+		pool = newCopyPool(cfg.CopyWorkers)
+	}
+
+	var runErr error
 	for _, action := range actions {
-		readPath := filepath.Join(srcRoot, action.RelativePath)
-		writePath := filepath.Join(dstRoot, action.RelativePath)
+		if runErr = ctx.Err(); runErr != nil {
+			break
+		}
+		// Other writers may be filling the destination too
+		if isFileCopy(action) && since(lastSpaceCheck) >= freeSpaceCheckInterval {
+			if runErr = checkFreeSpace(dst, plannedBytes-doneBytes, cfg); runErr != nil {
+				break
+			}
+			lastSpaceCheck = clock.Now()
+		}
 
-		switch action.Type {
-		case ActionNone:
-			continue
-		case ActionCreate:
-			isDir := action.SourceInfo.IsDir
-			if isDir {
-				_, err := fileops.CreateDir(writePath)
-				if err != nil {
-					return err
-				}
-			} else {
-				_, err := fileops.CopyFile(readPath, writePath, cfg.ChunkSize)
-				if err != nil {
-					return err
-				}
+		if reserveEnabled && isFileCopy(action) {
+			available, err := space.FreeSpace()
+			var pending int64 // Copies still running have yet to take their space
+			if pool != nil {
+				pending = pool.inFlight
 			}
-		case ActionDelete:
-			_, err := fileops.DeletePath(writePath)
 			if err != nil {
+				logger.Warn("cannot determine free space, reserve check disabled", "error", err)
+				reserveEnabled = false
+			} else if int64(available)-pending-action.SourceInfo.Size < cfg.ReserveSpace {
+				logger.Warn("deferring copy to keep reserved space free",
+					"path", action.RelativePath,
+					"size", action.SourceInfo.Size,
+					"available", available,
+					"reserve", cfg.ReserveSpace)
+				stats.AddDeferred(action.RelativePath, action.SourceInfo.Size)
+				continue
+			}
+		}
+
+		if action.Type == ActionNone {
+			stats.AddUnchanged()
+			continue
+		}
+		done := appliedAction{action: action, deferredChecksum: action.SourceInfo.Checksum == ""}
+		if isFileCopy(action) {
+			done.bytes = action.SourceInfo.Size
+		}
+		if pool != nil && parallelCopy(action) {
+			if runErr = pool.free(finish); runErr != nil {
+				break
+			}
+			progress.Start(action.RelativePath, done.bytes)
+			pool.start(done, apply)
+			continue
+		}
+		if pool != nil {
+			if runErr = pool.wait(finish); runErr != nil {
+				break
+			}
+		}
+		progress.Start(action.RelativePath, done.bytes)
+		if runErr = finish(done, apply(&done.action)); runErr != nil {
+			break
+		}
+	}
+	if pool != nil {
+		if err := pool.wait(finish); runErr == nil {
+			runErr = err
+		}
+	}
+	if runErr != nil {
+		return summary, runErr
+	}
+
+	// Writing children bumps their parent's mtime, so directories are stamped last
+	if cfg.PreserveDirTimes {
+		ApplyDirTimes(dst, actions)
+	}
+	if len(failures) > 0 {
+		return summary, fmt.Errorf("%w: %w", ErrSyncerActionsFailed, errors.Join(failures...))
+	}
+	return summary, nil
+}
+
+// applyAction performs a single create, update or delete against dst and records it in
+// stats. Failures are returned as a *SyncError naming the operation that failed; a path
+// the destination rejects as too long also matches ErrSyncerPathTooLong. A file copy whose
+// source checksum was left to the copy (see checksumOnCopy) gets it filled in.
+func applyAction(src Source, dst Destination, action *SyncAction, cfg *config.Config, stats *report.Stats) error {
+	fail := func(op string, err error) error {
+		if isPathTooLong(err) {
+			err = fmt.Errorf("%w (%d characters): %w", ErrSyncerPathTooLong, len(action.RelativePath), err)
+		}
+		return &SyncError{Op: op, Path: action.RelativePath, Err: err}
+	}
+
+	switch action.Type {
+	case ActionMkdir:
+		// The directory may replace a file the state recorded at its path
+		if err := resolveTypeConflict(dst, action.RelativePath, true, cfg); err != nil {
+			return fail(OpMkdir, err)
+		}
+		if err := retryAction(cfg, OpMkdir, action.RelativePath, func() error {
+			return dst.Mkdir(action.RelativePath)
+		}); err != nil {
+			return fail(OpMkdir, err)
+		}
+		// Directories are created with default permissions, which lose the special bits
+		if mode, ok := dst.(chmoder); ok && cfg.PreserveSpecialBits {
+			if err := mode.Chmod(action.RelativePath, action.SourceInfo.Permissions); err != nil {
+				return fail(OpChmod, err)
+			}
+		}
+		stats.AddDirCreated()
+	case ActionCreate, ActionUpdate:
+		if action.SourceInfo.SymlinkTarget != "" && cfg.SymlinkPolicy == config.SymlinkSkip {
+			logger.Debug("skipping symlink", "path", action.RelativePath, "target", action.SourceInfo.SymlinkTarget)
+			return nil
+		}
+		if action.Reason.Has(ReasonCaseRenamed) {
+			if err := renameCase(dst, *action); err != nil {
+				return fail(OpRename, err)
+			}
+			stats.AddRenamed()
+			if action.SourceInfo.IsDir || action.Reason == ReasonCaseRenamed {
+				return nil // Nothing but the name changed
+			}
+		}
+		if err := resolveTypeConflict(dst, action.RelativePath, false, cfg); err != nil {
+			return fail(OpCopy, err)
+		}
+		if isLink(action.SourceInfo, cfg) {
+			if err := createSymlink(dst, *action, cfg); err != nil {
+				return fail(OpSymlink, err)
+			}
+		} else {
+			checksum, err := copyOrSkip(src, dst, *action, cfg, stats)
+			if errors.Is(err, ErrSyncerDestinationNewer) {
 				return err
 			}
-		case ActionUpdate:
-			_, err := fileops.CopyFile(readPath, writePath, cfg.ChunkSize)
 			if err != nil {
+				return fail(OpCopy, err)
+			}
+			if action.SourceInfo.Checksum == "" {
+				action.SourceInfo.Checksum = checksum
+			}
+		}
+		if action.Type == ActionCreate {
+			stats.AddCreated(action.SourceInfo.Size)
+		} else {
+			stats.AddUpdated(action.SourceInfo.Size)
+		}
+	case ActionChmod:
+		mode, ok := dst.(chmoder)
+		if !ok {
+			return fail(OpChmod, ErrSyncerChmodUnsupported)
+		}
+		if err := retryAction(cfg, OpChmod, action.RelativePath, func() error {
+			return mode.Chmod(action.RelativePath, action.SourceInfo.Permissions)
+		}); err != nil {
+			return fail(OpChmod, err)
+		}
+		stats.AddPermsUpdated()
+	case ActionDelete, ActionRmdir:
+		if err := retryAction(cfg, OpDelete, action.RelativePath, func() error {
+			return dst.Delete(action.RelativePath)
+		}); err != nil {
+			return fail(OpDelete, err)
+		}
+		if action.Type == ActionRmdir {
+			stats.AddDirDeleted()
+		} else {
+			stats.AddDeleted(action.SourceInfo.Size)
+		}
+	default:
+		logger.Error("unknown action",
+			"action", action.Type)
+	}
+	return nil
+}
+
+// ApplyDirTimes gives every directory the actions leave on dst its source mtime.
+// Failures are logged and skipped; destinations that cannot set times are left as is.
+func ApplyDirTimes(dst Destination, actions []SyncAction) {
+	setter, ok := dst.(timeSetter)
+	if !ok {
+		logger.Warn("destination does not support setting times, directory mtimes not preserved")
+		return
+	}
+	for _, action := range actions {
+		if action.Type == ActionRmdir || !action.SourceInfo.IsDir {
+			continue
+		}
+		if err := setter.Chtimes(action.RelativePath, action.SourceInfo.Mtime); err != nil {
+			logger.Warn("cannot set directory mtime", "path", action.RelativePath, "error", err)
+		}
+	}
+}
+
+// resolveTypeConflict makes room for a directory (wantDir) or file at relPath when dst
+// holds the other type there, by removing the destination entry. With cfg.StrictTypes
+// the conflict is returned as ErrSyncerTypeConflict instead.
+func resolveTypeConflict(dst Destination, relPath string, wantDir bool, cfg *config.Config) error {
+	info, err := dst.Stat(relPath)
+	if err != nil || info.IsDir() == wantDir {
+		return nil // Missing, same type, or unreadable: let the operation itself decide
+	}
+
+	existing, wanted := "file", "directory"
+	if info.IsDir() {
+		existing, wanted = wanted, existing
+	}
+	if cfg.StrictTypes {
+		return fmt.Errorf("%w: %s is a %s on the destination but a %s in the source", ErrSyncerTypeConflict, relPath, existing, wanted)
+	}
+
+	logger.Warn("replacing destination entry of a different type",
+		"path", relPath, "existing", existing, "source", wanted)
+	return dst.Delete(relPath)
+}
+
+// bandwidthLimit returns the KB/s limit for copying relPath: that of the first of
+// cfg.BandwidthRules matching it, or cfg.BandwidthLimit when none does.
+func bandwidthLimit(relPath string, cfg *config.Config) int {
+	for _, rule := range cfg.BandwidthRules {
+		if shouldExclude(relPath, []string{rule.Pattern}) {
+			return rule.LimitKBps
+		}
+	}
+	return cfg.BandwidthLimit
+}
+
+// copyOrSkip copies the action's file from src to dst and records the bytes in stats.
+// In checksum mode a destination that already matches the source content is left
+// untouched and its size is counted as skipped instead of transferred; with cfg.Update
+// so is a destination file newer than the source, and ErrSyncerDestinationNewer is
+// returned since the copy was not applied. With cfg.AppendGrowth a file that grew
+// is appended to when the destination allows it. Copies matching one of
+// cfg.BandwidthRules get its limit when the destination supports it. The returned
+// checksum is set when the source checksum was left to the copy (see checksumOnCopy).
+func copyOrSkip(src Source, dst Destination, action SyncAction, cfg *config.Config, stats *report.Stats) (string, error) {
+	relPath, source := action.RelativePath, action.SourceInfo
+	stats.AddPlanned(source.Size)
+
+	if cfg.Checksum && destinationMatches(dst, relPath, source) {
+		logger.Debug("destination already up to date, skipping copy", "path", relPath)
+		stats.AddSkipped(source.Size)
+		return "", nil
+	}
+	if cfg.Update && destinationNewer(dst, relPath, source, cfg.MtimeThreshold) {
+		logger.Info("destination is newer than the source, skipping copy", "path", relPath)
+		stats.AddSkipped(source.Size)
+		return "", ErrSyncerDestinationNewer
+	}
+
+	readPath, release, err := src.Open(action.sourcePath())
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	deferred := source.Checksum == "" && checksumOnCopy(cfg)
+
+	if cfg.AppendGrowth && action.Type == ActionUpdate && source.Size > action.PreviousInfo.Size {
+		if appended, ok := appendTail(dst, readPath, relPath, action.PreviousInfo.Size); ok {
+			stats.AddTransferred(appended)
+			if deferred {
+				return hashAfterCopy(readPath)
+			}
+			return "", nil
+		}
+	}
+
+	// Copy already keeps to cfg.BandwidthLimit; only a rule's own limit needs CopyLimited
+	limited, ok := dst.(limitedCopier)
+	limit := bandwidthLimit(relPath, cfg)
+	throttle := ok && limit != cfg.BandwidthLimit
+	hasher, canHash := dst.(checksumCopier)
+	teeHash := deferred && canHash && !throttle
+	var written int64
+	var checksum string
+	err = retryAction(cfg, OpCopy, relPath, func() error {
+		if injectFault != nil {
+			if err := injectFault(relPath); err != nil {
 				return err
 			}
+		}
+		var err error
+		switch {
+		case teeHash:
+			written, checksum, err = hasher.CopyChecksum(readPath, relPath, cfg.ChunkSize)
+		case throttle:
+			written, err = limited.CopyLimited(readPath, relPath, cfg.ChunkSize, limit)
 		default:
-			logger.Error("unknown action",
-				"action", action.Type)
+			written, err = dst.Copy(readPath, relPath, cfg.ChunkSize)
+		}
+		return err
+	})
+	stats.AddTransferred(written)
+	if err != nil {
+		return "", err
+	}
+	if deferred && !teeHash {
+		return hashAfterCopy(readPath)
+	}
+	return checksum, nil
+}
 
+// hashAfterCopy checksums a source file the destination could not hash while copying.
+func hashAfterCopy(readPath string) (string, error) {
+	sum, err := checksumFile(readPath, false)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// appendTail appends the part of readPath past fromOffset to relPath on dst. It reports
+// false when dst cannot append or relPath no longer holds exactly the first fromOffset
+// bytes of the source, and the caller copies the whole file instead.
+func appendTail(dst Destination, readPath, relPath string, fromOffset int64) (int64, bool) {
+	tail, ok := dst.(appender)
+	if !ok || fromOffset <= 0 {
+		return 0, false
+	}
+	appended, err := tail.Append(readPath, relPath, fromOffset)
+	if err != nil {
+		if errors.Is(err, fileops.ErrNotPrefix) {
+			logger.Debug("destination is not a prefix of the source, copying the whole file", "path", relPath)
+		} else {
+			logger.Warn("cannot append to destination, copying the whole file", "path", relPath, "error", err)
 		}
+		return 0, false
+	}
+	logger.Debug("appended new tail", "path", relPath, "offset", fromOffset, "size", appended)
+	return appended, true
+}
 
+// destinationNewer reports whether relPath is a file on dst modified more than threshold
+// after source, allowing for the mtime precision of the destination file system.
+func destinationNewer(dst Destination, relPath string, source EntryInfo, threshold time.Duration) bool {
+	info, err := dst.Stat(relPath)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return info.ModTime().Sub(source.Mtime) > threshold
+}
+
+// destinationMatches reports whether relPath on dst has the same size and checksum
+// as the source entry. Destinations that cannot checksum never match, and neither do
+// sources with only a quick fingerprint.
+func destinationMatches(dst Destination, relPath string, source EntryInfo) bool {
+	hasher, ok := dst.(checksummer)
+	if !ok || source.Checksum == "" || source.QuickHash {
+		return false
+	}
+	info, err := dst.Stat(relPath)
+	if err != nil || info.IsDir() || info.Size() != source.Size {
+		return false
+	}
+	checksum, err := hasher.Checksum(relPath)
+	if err != nil {
+		return false
+	}
+	return checksum == source.Checksum
+}
+
+// PruneEmptyDirs removes the directories that the deletes among actions left empty on
+// the destination: the parents of every delete that ran and, as they go, their parents.
+// Directories in the source scan are kept, and so are the paths in notApplied, whose
+// actions did not run this time, and directories matching keepPatterns (see
+// KeepActions). It returns the relative paths of the removed directories.
+func PruneEmptyDirs(dstRoot string, actions []SyncAction, sourceScan map[string]EntryInfo, notApplied, keepPatterns []string) ([]string, error) {
+	keep := make(map[string]bool)
+	for path, entry := range sourceScan {
+		if entry.IsDir {
+			keep[path] = true
+		}
+	}
+	for _, path := range notApplied {
+		keep[path] = true
+	}
+
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, action := range actions {
+		if action.Type != ActionDelete && action.Type != ActionRmdir || keep[action.RelativePath] {
+			continue
+		}
+		// Once a parent is seen, so are all of its own parents
+		for dir := filepath.Dir(action.RelativePath); dir != "." && !seen[dir]; dir = filepath.Dir(dir) {
+			seen[dir] = true
+			if shouldExclude(dir, keepPatterns) {
+				keep[dir] = true
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+
+	removed, err := fileops.PruneEmptyDirs(dstRoot, dirs, keep)
+	if len(removed) > 0 {
+		logger.Info("pruned empty directories", "dir", dstRoot, "count", len(removed))
+	}
+	return removed, err
+}
+
+// plannedWork counts the actions that change the destination and the bytes they copy.
+func plannedWork(actions []SyncAction) (files int, bytes int64) {
+	for _, action := range actions {
+		if action.Type == ActionNone {
+			continue
+		}
+		files++
+		if isFileCopy(action) {
+			bytes += action.SourceInfo.Size
+		}
+	}
+	return files, bytes
+}
+
+// freeSpaceCheckInterval is how often a running sync re-checks cfg.MinFreeSpace; tests shorten it.
+var freeSpaceCheckInterval = 10 * time.Second
+
+// checkFreeSpace returns ErrSyncerInsufficientSpace when writing needed more bytes would
+// leave less than cfg.MinFreeSpace free on dst. It passes when the check is disabled or
+// dst cannot report its free space.
+func checkFreeSpace(dst Destination, needed int64, cfg *config.Config) error {
+	if cfg.MinFreeSpace <= 0 {
+		return nil
+	}
+	space, ok := dst.(spaceReporter)
+	if !ok {
+		logger.Warn("destination cannot report free space, -min-free-space has no effect")
+		return nil
+	}
+	available, err := space.FreeSpace()
+	if err != nil {
+		logger.Warn("cannot determine free space, -min-free-space not checked", "error", err)
+		return nil
+	}
+	if int64(available)-needed < cfg.MinFreeSpace {
+		return fmt.Errorf("%w: %s to copy, %s available, %s must stay free", ErrSyncerInsufficientSpace,
+			report.FormatSize(needed), report.FormatSize(int64(available)), report.FormatSize(cfg.MinFreeSpace))
 	}
 	return nil
 }
+
+// MeasureDestination fills in summary.BytesReplaced with the current destination size
+// of every file the actions update or delete, without changing anything. Paths that
+// are missing or unreadable on dst count as empty.
+func MeasureDestination(dst Destination, actions []SyncAction, summary *report.Summary) {
+	for _, action := range actions {
+		if action.Type != ActionUpdate && action.Type != ActionDelete {
+			continue
+		}
+		info, err := dst.Stat(action.RelativePath)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		summary.BytesReplaced += info.Size()
+	}
+	summary.DestinationMeasured = true
+}
+
+// PlanSummary totals the planned actions without touching the filesystem.
+func PlanSummary(actions []SyncAction) report.Summary {
+	summary := report.Summary{DryRun: true}
+
+	for _, action := range actions {
+		switch action.Type {
+		case ActionNone:
+			summary.Unchanged++
+		case ActionMkdir:
+			summary.DirsCreated++
+		case ActionRmdir:
+			summary.DirsDeleted++
+		case ActionCreate:
+			summary.FilesCreated++
+			summary.BytesCreated += action.SourceInfo.Size
+			summary.BytesPlanned += action.SourceInfo.Size
+		case ActionUpdate:
+			if action.Reason.Has(ReasonCaseRenamed) {
+				summary.Renamed++
+				if action.SourceInfo.IsDir || action.Reason == ReasonCaseRenamed {
+					continue
+				}
+			}
+			summary.FilesUpdated++
+			summary.BytesUpdated += action.SourceInfo.Size
+			summary.BytesPlanned += action.SourceInfo.Size
+		case ActionDelete:
+			summary.FilesDeleted++
+			summary.BytesDeleted += action.SourceInfo.Size
+		case ActionChmod:
+			summary.PermsUpdated++
+		}
+	}
+
+	return summary
+}
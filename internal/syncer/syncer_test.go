@@ -1,11 +1,22 @@
 package syncer
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/ogzhanolguncu/mimic/internal/config"
 	"github.com/stretchr/testify/require"
 )
 
@@ -15,7 +26,7 @@ func TestScanSource(t *testing.T) {
 	defer os.RemoveAll(tempDir)
 
 	t.Run("EmptySourceDir", func(t *testing.T) {
-		entries, err := ScanSource("")
+		entries, err := ScanSource("", config.NewDefaultConfig())
 		require.Error(t, err, "Expected error for empty source directory")
 		require.Equal(t, ErrEmptySrcDir, err, "Expected ErrEmptySrcDir error")
 		require.Nil(t, entries, "Expected nil entries for error case")
@@ -23,7 +34,7 @@ func TestScanSource(t *testing.T) {
 
 	t.Run("NonExistentSourceDir", func(t *testing.T) {
 		nonExistentDir := filepath.Join(tempDir, "non-existent")
-		entries, err := ScanSource(nonExistentDir)
+		entries, err := ScanSource(nonExistentDir, config.NewDefaultConfig())
 		require.Error(t, err, "Expected error for non-existent source directory")
 		require.ErrorIs(t, err, ErrSyncerSrcNotExists, "Expected ErrSyncerSrcNotExists error")
 		require.Nil(t, entries, "Expected nil entries for error case")
@@ -34,7 +45,7 @@ func TestScanSource(t *testing.T) {
 		err := os.WriteFile(testFile, []byte("test content"), 0644)
 		require.NoError(t, err, "Failed to create test file")
 
-		entries, err := ScanSource(testFile)
+		entries, err := ScanSource(testFile, config.NewDefaultConfig())
 		require.Error(t, err, "Expected error when source is a file")
 		require.Equal(t, ErrEmptySrcNotADir, err, "Expected ErrEmptySrcNotADir error")
 		require.Nil(t, entries, "Expected nil entries for error case")
@@ -56,7 +67,7 @@ func TestScanSource(t *testing.T) {
 		require.NoError(t, os.WriteFile(rootFile, []byte("root content"), 0644), "Failed to create root file")
 		require.NoError(t, os.WriteFile(subFile, []byte("sub content"), 0644), "Failed to create sub file")
 
-		entries, err := ScanSource(testDir)
+		entries, err := ScanSource(testDir, config.NewDefaultConfig())
 		require.NoError(t, err, "Expected no error for valid directory scan")
 		require.NotNil(t, entries, "Expected non-nil entries")
 
@@ -80,6 +91,80 @@ func TestScanSource(t *testing.T) {
 		require.Empty(t, subdirEntry.Checksum, "Expected empty checksum for directory")
 	})
 
+	t.Run("MaxFileSizeExcludesLargeFiles", func(t *testing.T) {
+		testDir := filepath.Join(tempDir, "max-size-dir")
+		require.NoError(t, os.Mkdir(testDir, 0755), "Failed to create test directory")
+
+		smallFile := filepath.Join(testDir, "small.txt")
+		largeFile := filepath.Join(testDir, "large.bin")
+		require.NoError(t, os.WriteFile(smallFile, make([]byte, 100), 0644), "Failed to create small file")
+		require.NoError(t, os.WriteFile(largeFile, make([]byte, 2048), 0644), "Failed to create large file")
+
+		cfg := config.NewDefaultConfig()
+		cfg.MaxFileSize = 1024
+
+		entries, err := ScanSource(testDir, cfg)
+		require.NoError(t, err, "Expected no error for scan with max file size")
+		require.Contains(t, entries, "small.txt", "Expected small file to be scanned")
+		require.NotContains(t, entries, "large.bin", "Expected oversize file to be excluded")
+
+		actions := CompareStates(entries, map[string]EntryInfo{}, config.NewDefaultConfig())
+		for _, action := range actions {
+			require.NotEqual(t, "large.bin", action.RelativePath, "Expected no action for oversize file")
+		}
+	})
+
+	t.Run("SizeExcludeRules", func(t *testing.T) {
+		testDir := filepath.Join(tempDir, "size-rule-dir")
+		require.NoError(t, os.MkdirAll(filepath.Join(testDir, "isos"), 0755))
+		files := map[string]int{
+			"small.iso":                        100,
+			"large.iso":                        4096,
+			filepath.Join("isos", "large.iso"): 4096,
+			"large.bin":                        4096,
+			"tiny.log":                         10,
+			"full.log":                         2048,
+		}
+		for name, size := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(testDir, name), make([]byte, size), 0644))
+		}
+
+		testCases := []struct {
+			name     string
+			rules    []config.SizeExcludeRule
+			excluded []string
+		}{
+			{"GreaterThan", []config.SizeExcludeRule{{Pattern: "*.iso", Size: 1024}},
+				[]string{"large.iso", filepath.Join("isos", "large.iso")}},
+			{"LessThan", []config.SizeExcludeRule{{Pattern: "*.log", Smaller: true, Size: 1024}},
+				[]string{"tiny.log"}},
+			{"PathPattern", []config.SizeExcludeRule{{Pattern: "isos/*.iso", Size: 1024}},
+				[]string{filepath.Join("isos", "large.iso")}},
+			{"Combined", []config.SizeExcludeRule{
+				{Pattern: "*.iso", Size: 1024},
+				{Pattern: "*.log", Smaller: true, Size: 1024},
+			}, []string{"large.iso", filepath.Join("isos", "large.iso"), "tiny.log"}},
+			{"SizeIsExclusive", []config.SizeExcludeRule{{Pattern: "*.log", Size: 2048}}, nil},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := config.NewDefaultConfig()
+				cfg.SizeExcludeRules = tc.rules
+
+				entries, err := ScanSource(testDir, cfg)
+				require.NoError(t, err)
+				for name := range files {
+					if slices.Contains(tc.excluded, name) {
+						require.NotContains(t, entries, name, "Expected %s to be excluded", name)
+					} else {
+						require.Contains(t, entries, name, "Expected %s to be kept", name)
+					}
+				}
+			})
+		}
+	})
+
 	if os.Geteuid() == 0 {
 		t.Skip("Skipping permission test when running as root")
 	}
@@ -97,7 +182,7 @@ func TestScanSource(t *testing.T) {
 		require.NoError(t, os.Chmod(nopermDir, 0000), "Failed to change permissions")
 
 		// The scan should succeed but skip the no-permission directory
-		entries, err := ScanSource(noReadDir)
+		entries, err := ScanSource(noReadDir, config.NewDefaultConfig())
 		require.NoError(t, err, "Expected no error for scan with permission denied subdirectory")
 		require.NotNil(t, entries, "Expected non-nil entries")
 
@@ -116,7 +201,7 @@ func TestGenerateChecksum(t *testing.T) {
 
 	t.Run("NonExistentFile", func(t *testing.T) {
 		nonExistentFile := filepath.Join(tempDir, "non-existent.txt")
-		checksum, err := generateChecksum(nonExistentFile)
+		checksum, err := generateChecksum(nonExistentFile, false)
 		require.Error(t, err, "Expected error for non-existent file")
 		require.ErrorIs(t, err, ErrSyncerSrcNotExists, "Expected ErrSyncerSrcNotExists error")
 		require.Nil(t, checksum, "Expected nil checksum for error case")
@@ -128,13 +213,13 @@ func TestGenerateChecksum(t *testing.T) {
 		err := os.WriteFile(testFile, []byte(testContent), 0644)
 		require.NoError(t, err, "Failed to create test file")
 
-		checksum1, err := generateChecksum(testFile)
+		checksum1, err := generateChecksum(testFile, false)
 		require.NoError(t, err, "Expected no error for valid file")
 		require.NotNil(t, checksum1, "Expected non-nil checksum")
 		require.NotEmpty(t, checksum1, "Expected non-empty checksum")
 
 		// Generate checksum again to verify it's consistent
-		checksum2, err := generateChecksum(testFile)
+		checksum2, err := generateChecksum(testFile, false)
 		require.NoError(t, err, "Expected no error for second checksum")
 		require.Equal(t, checksum1, checksum2, "Expected consistent checksums")
 
@@ -143,12 +228,219 @@ func TestGenerateChecksum(t *testing.T) {
 		err = os.WriteFile(testFile, []byte(newContent), 0644)
 		require.NoError(t, err, "Failed to modify test file")
 
-		checksum3, err := generateChecksum(testFile)
+		checksum3, err := generateChecksum(testFile, false)
 		require.NoError(t, err, "Expected no error for modified file")
 		require.NotEqual(t, checksum1, checksum3, "Expected different checksum for modified file")
 	})
 }
 
+func TestScanSourceFutureMtimes(t *testing.T) {
+	srcDir := t.TempDir()
+	future := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	soon := time.Now().Add(30 * time.Minute).Truncate(time.Second)
+	for name, mtime := range map[string]time.Time{"future.txt": future, "soon.txt": soon} {
+		path := filepath.Join(srcDir, name)
+		require.NoError(t, os.WriteFile(path, []byte(name), 0644))
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	testCases := []struct {
+		mode      string
+		threshold time.Duration
+		check     func(t *testing.T, entries map[string]EntryInfo)
+	}{
+		{
+			mode: config.FutureMtimesWarn,
+			check: func(t *testing.T, entries map[string]EntryInfo) {
+				require.True(t, entries["future.txt"].Mtime.Equal(future), "Expected the mtime to be kept")
+				require.False(t, entries["future.txt"].MtimeClamped)
+			},
+		},
+		{
+			mode: config.FutureMtimesClamp,
+			check: func(t *testing.T, entries map[string]EntryInfo) {
+				require.True(t, entries["future.txt"].MtimeClamped)
+				require.WithinDuration(t, time.Now(), entries["future.txt"].Mtime, time.Minute)
+			},
+		},
+		{
+			mode: config.FutureMtimesSkip,
+			check: func(t *testing.T, entries map[string]EntryInfo) {
+				require.NotContains(t, entries, "future.txt")
+			},
+		},
+		{
+			mode:      config.FutureMtimesSkip,
+			threshold: 10 * time.Minute,
+			check: func(t *testing.T, entries map[string]EntryInfo) {
+				require.NotContains(t, entries, "future.txt")
+				require.NotContains(t, entries, "soon.txt", "Expected a lower threshold to catch nearer mtimes")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s/%v", tc.mode, tc.threshold), func(t *testing.T) {
+			cfg := config.NewDefaultConfig()
+			cfg.FutureMtimes = tc.mode
+			if tc.threshold > 0 {
+				cfg.FutureThreshold = tc.threshold
+			}
+
+			entries, err := ScanSource(srcDir, cfg)
+			require.NoError(t, err)
+			if tc.threshold == 0 {
+				require.True(t, entries["soon.txt"].Mtime.Equal(soon), "Expected mtimes within the threshold to be left alone")
+			}
+			tc.check(t, entries)
+		})
+	}
+
+	t.Run("ClampedComparesBySize", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.FutureMtimes = config.FutureMtimesClamp
+		scanned, err := ScanSource(srcDir, cfg)
+		require.NoError(t, err)
+		// The previous run clamped to its own, earlier scan time
+		recorded := maps.Clone(scanned)
+		previous := recorded["future.txt"]
+		previous.Mtime = previous.Mtime.Add(-time.Hour)
+		recorded["future.txt"] = previous
+
+		for _, action := range CompareStates(scanned, recorded, cfg) {
+			require.Equal(t, ActionNone, action.Type, "Expected %s unchanged although the clamp moved", action.RelativePath)
+		}
+	})
+}
+
+func TestGenerateQuickChecksum(t *testing.T) {
+	testDir := t.TempDir()
+	size := 4 * quickHashSpan
+	original := make([]byte, size)
+	for i := range original {
+		original[i] = byte(i % 251)
+	}
+
+	edit := func(offset int) []byte {
+		edited := slices.Clone(original)
+		edited[offset] ^= 0xff
+		return edited
+	}
+
+	testCases := []struct {
+		name         string
+		content      []byte
+		quickChanges bool
+	}{
+		{name: "HeadEdit", content: edit(10), quickChanges: true},
+		{name: "TailEdit", content: edit(size - 10), quickChanges: true},
+		{name: "MiddleEdit", content: edit(size / 2), quickChanges: false},
+		{name: "Truncated", content: original[:size-1], quickChanges: true},
+	}
+
+	path := filepath.Join(testDir, "large.bin")
+	checksums := func(content []byte) (quick, full []byte) {
+		require.NoError(t, os.WriteFile(path, content, 0644))
+		quick, err := generateQuickChecksum(path, false)
+		require.NoError(t, err)
+		full, err = generateChecksum(path, false)
+		require.NoError(t, err)
+		return quick, full
+	}
+	originalQuick, originalFull := checksums(original)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			quick, full := checksums(tc.content)
+			require.NotEqual(t, originalFull, full, "Full hashing should catch every edit")
+			if tc.quickChanges {
+				require.NotEqual(t, originalQuick, quick)
+			} else {
+				require.Equal(t, originalQuick, quick, "Quick fingerprint should miss same-size middle edits")
+			}
+		})
+	}
+
+	t.Run("SmallFileHashedWhole", func(t *testing.T) {
+		small := []byte("small file content")
+		quick, _ := checksums(small)
+		small[len(small)/2] ^= 0xff
+		edited, _ := checksums(small)
+		require.NotEqual(t, quick, edited)
+	})
+}
+
+func TestCompareStatesQuickHashNotMixed(t *testing.T) {
+	fixedTime := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	recorded := EntryInfo{RelativePath: "file.txt", Mtime: fixedTime, Size: 100, Checksum: "aaaa"}
+	cfg := config.NewDefaultConfig()
+	cfg.VerifyOnEqualMtime = true
+
+	scanned := recorded
+	scanned.Checksum, scanned.QuickHash = "bbbb", true
+	result := CompareStates(map[string]EntryInfo{"file.txt": scanned}, map[string]EntryInfo{"file.txt": recorded}, cfg)
+	require.Len(t, result, 1)
+	require.Equal(t, ActionNone, result[0].Type, "A quick fingerprint is not compared with a full checksum")
+
+	recorded.QuickHash = true
+	result = CompareStates(map[string]EntryInfo{"file.txt": scanned}, map[string]EntryInfo{"file.txt": recorded}, cfg)
+	require.Len(t, result, 1)
+	require.Equal(t, ActionUpdate, result[0].Type)
+}
+
+func TestChecksumBand(t *testing.T) {
+	srcDir := t.TempDir()
+	sizes := map[string]int{"small.bin": 10, "low-edge.bin": 50, "middle.bin": 100, "high-edge.bin": 500, "large.bin": 1000}
+	for name, size := range sizes {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), bytes.Repeat([]byte("x"), size), 0644))
+	}
+	cfg := config.NewDefaultConfig()
+	cfg.ChecksumMin, cfg.ChecksumMax = 50, 500
+
+	entries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+	inBand := map[string]bool{"low-edge.bin": true, "middle.bin": true, "high-edge.bin": true}
+	for name := range sizes {
+		require.Equal(t, inBand[name], entries[name].Checksum != "", "checksum of %s", name)
+	}
+
+	// Every file changed content without changing size or mtime
+	recorded := make(map[string]EntryInfo)
+	for name, entry := range entries {
+		entry.Checksum = "stale"
+		recorded[name] = entry
+	}
+	for _, action := range CompareStates(entries, recorded, cfg) {
+		if inBand[action.RelativePath] {
+			require.Equal(t, ActionUpdate, action.Type, "Expected %s to be compared by content", action.RelativePath)
+		} else {
+			require.Equal(t, ActionNone, action.Type, "Expected %s to be compared by size and mtime", action.RelativePath)
+		}
+	}
+
+	t.Run("OpenBounds", func(t *testing.T) {
+		testCases := []struct {
+			name     string
+			min, max int64
+			size     int64
+			expected bool
+		}{
+			{name: "NoBand", size: 100},
+			{name: "MinOnlyAbove", min: 50, size: 100, expected: true},
+			{name: "MinOnlyBelow", min: 50, size: 10},
+			{name: "MaxOnlyBelow", max: 50, size: 10, expected: true},
+			{name: "MaxOnlyAbove", max: 50, size: 100},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := config.NewDefaultConfig()
+				cfg.ChecksumMin, cfg.ChecksumMax = tc.min, tc.max
+				require.Equal(t, tc.expected, inChecksumBand(tc.size, cfg))
+			})
+		}
+	})
+}
+
 func TestShouldExclude(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -198,6 +490,96 @@ func TestShouldExclude(t *testing.T) {
 			matchers:      []string{"node_modules/"},
 			shouldExclude: true,
 		},
+		{
+			name:          "Double star directory at depth",
+			relPath:       "a/b/cache/data.bin",
+			matchers:      []string{"**/cache/"},
+			shouldExclude: true,
+		},
+		{
+			name:          "Double star directory at root",
+			relPath:       "cache",
+			matchers:      []string{"**/cache/"},
+			shouldExclude: true,
+		},
+		{
+			name:          "Double star directory not matching similar name",
+			relPath:       "a/cached/data.bin",
+			matchers:      []string{"**/cache/"},
+			shouldExclude: false,
+		},
+		{
+			name:          "Double star mid path",
+			relPath:       "src/components/button/button.test.js",
+			matchers:      []string{"src/**/*.test.js"},
+			shouldExclude: true,
+		},
+		{
+			name:          "Double star matches zero directories",
+			relPath:       "src/app.test.js",
+			matchers:      []string{"src/**/*.test.js"},
+			shouldExclude: true,
+		},
+		{
+			name:          "Double star anchored to its prefix",
+			relPath:       "lib/app.test.js",
+			matchers:      []string{"src/**/*.test.js"},
+			shouldExclude: false,
+		},
+		{
+			name:          "Double star prefix matches root file",
+			relPath:       "debug.log",
+			matchers:      []string{"**/*.log"},
+			shouldExclude: true,
+		},
+		{
+			name:          "Brace directory alternatives",
+			relPath:       "tmp/session/file",
+			matchers:      []string{"{tmp,cache}/"},
+			shouldExclude: true,
+		},
+		{
+			name:          "Brace directory second alternative",
+			relPath:       "cache",
+			matchers:      []string{"{tmp,cache}/"},
+			shouldExclude: true,
+		},
+		{
+			name:          "Brace directory no match",
+			relPath:       "src/tmp/file",
+			matchers:      []string{"{tmp,cache}/"},
+			shouldExclude: false,
+		},
+		{
+			name:          "Brace extension alternatives",
+			relPath:       "images/photo.png",
+			matchers:      []string{"*.{jpg,png}"},
+			shouldExclude: true,
+		},
+		{
+			name:          "Nested braces",
+			relPath:       "build/app.min.js",
+			matchers:      []string{"build/*.{css,{min,map}.js}"},
+			shouldExclude: true,
+		},
+		{
+			name:          "Unbalanced brace is literal",
+			relPath:       "{weird.txt",
+			matchers:      []string{"{weird.txt"},
+			shouldExclude: true,
+		},
+		{
+			name:          "Single star stays within a segment",
+			relPath:       "docs/v1/draft.md",
+			matchers:      []string{"docs/*/draft.md"},
+			shouldExclude: true,
+		},
+		{
+			name:          "Single star does not cross segments",
+			relPath:       "docs/v1/old/draft.md",
+			matchers:      []string{"docs/*/draft.md"},
+			shouldExclude: false,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -242,6 +624,22 @@ func TestShouldCompareStates(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "DirectoryWithNewerMtimeIsUnchanged",
+			sourceScan: map[string]EntryInfo{
+				"dir": {RelativePath: "dir", Mtime: fixedTime, IsDir: true},
+			},
+			loadedEntries: map[string]EntryInfo{
+				"dir": {RelativePath: "dir", Mtime: fixedTime.Add(-time.Hour), IsDir: true},
+			},
+			expected: []SyncAction{
+				{
+					Type:         ActionNone,
+					RelativePath: "dir",
+					SourceInfo:   EntryInfo{RelativePath: "dir", Mtime: fixedTime, IsDir: true},
+				},
+			},
+		},
 		{
 			name: "UpdateExistingFile",
 			sourceScan: map[string]EntryInfo{
@@ -270,6 +668,13 @@ func TestShouldCompareStates(t *testing.T) {
 						Size:         200,
 						IsDir:        false,
 					},
+					PreviousInfo: EntryInfo{
+						RelativePath: "file1.txt",
+						Mtime:        fixedTime.Add(-10 * time.Minute),
+						Size:         100,
+						IsDir:        false,
+					},
+					Reason: ReasonSizeChanged | ReasonMtimeChanged,
 				},
 			},
 		},
@@ -288,7 +693,43 @@ func TestShouldCompareStates(t *testing.T) {
 				{
 					Type:         ActionDelete,
 					RelativePath: "file1.txt",
-					SourceInfo:   EntryInfo{},
+					SourceInfo: EntryInfo{
+						RelativePath: "file1.txt",
+						Mtime:        fixedTime.Add(-10 * time.Minute),
+						Size:         100,
+						IsDir:        false,
+					},
+				},
+			},
+		},
+		{
+			name:       "DeleteRemovedDirectory",
+			sourceScan: map[string]EntryInfo{},
+			loadedEntries: map[string]EntryInfo{
+				"dir": {RelativePath: "dir", Mtime: fixedTime, IsDir: true},
+			},
+			expected: []SyncAction{
+				{
+					Type:         ActionRmdir,
+					RelativePath: "dir",
+					SourceInfo:   EntryInfo{RelativePath: "dir", Mtime: fixedTime, IsDir: true},
+				},
+			},
+		},
+		{
+			name: "DirectoryReplacesFile",
+			sourceScan: map[string]EntryInfo{
+				"data": {RelativePath: "data", Mtime: fixedTime, IsDir: true},
+			},
+			loadedEntries: map[string]EntryInfo{
+				"data": {RelativePath: "data", Mtime: fixedTime, Size: 100},
+			},
+			expected: []SyncAction{
+				{
+					Type:         ActionMkdir,
+					RelativePath: "data",
+					SourceInfo:   EntryInfo{RelativePath: "data", Mtime: fixedTime, IsDir: true},
+					PreviousInfo: EntryInfo{RelativePath: "data", Mtime: fixedTime, Size: 100},
 				},
 			},
 		},
@@ -314,7 +755,12 @@ func TestShouldCompareStates(t *testing.T) {
 				{
 					Type:         ActionNone,
 					RelativePath: "file1.txt",
-					SourceInfo:   EntryInfo{},
+					SourceInfo: EntryInfo{
+						RelativePath: "file1.txt",
+						Mtime:        fixedTime,
+						Size:         100,
+						IsDir:        false,
+					},
 				},
 			},
 		},
@@ -356,34 +802,44 @@ func TestShouldCompareStates(t *testing.T) {
 			},
 			expected: []SyncAction{
 				{
-					Type:         ActionNone,
-					RelativePath: "file1.txt",
-					SourceInfo:   EntryInfo{},
+					Type:         ActionMkdir,
+					RelativePath: "dir1",
+					SourceInfo: EntryInfo{
+						RelativePath: "dir1",
+						Mtime:        fixedTime,
+						Size:         0,
+						IsDir:        true,
+					},
 				},
 				{
-					Type:         ActionCreate,
-					RelativePath: "file2.txt",
+					Type:         ActionNone,
+					RelativePath: "file1.txt",
 					SourceInfo: EntryInfo{
-						RelativePath: "file2.txt",
+						RelativePath: "file1.txt",
 						Mtime:        fixedTime,
-						Size:         200,
+						Size:         100,
 						IsDir:        false,
 					},
 				},
 				{
 					Type:         ActionCreate,
-					RelativePath: "dir1",
+					RelativePath: "file2.txt",
 					SourceInfo: EntryInfo{
-						RelativePath: "dir1",
+						RelativePath: "file2.txt",
 						Mtime:        fixedTime,
-						Size:         0,
-						IsDir:        true,
+						Size:         200,
+						IsDir:        false,
 					},
 				},
 				{
 					Type:         ActionDelete,
 					RelativePath: "oldfile.txt",
-					SourceInfo:   EntryInfo{},
+					SourceInfo: EntryInfo{
+						RelativePath: "oldfile.txt",
+						Mtime:        fixedTime.Add(-24 * time.Hour),
+						Size:         50,
+						IsDir:        false,
+					},
 				},
 			},
 		},
@@ -409,7 +865,12 @@ func TestShouldCompareStates(t *testing.T) {
 				{
 					Type:         ActionNone, // Should be considered the same due to threshold
 					RelativePath: "file1.txt",
-					SourceInfo:   EntryInfo{},
+					SourceInfo: EntryInfo{
+						RelativePath: "file1.txt",
+						Mtime:        time.Date(2023, 1, 1, 12, 0, 0, 500*1000*1000, time.UTC),
+						Size:         100,
+						IsDir:        false,
+					},
 				},
 			},
 		},
@@ -417,8 +878,1192 @@ func TestShouldCompareStates(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := CompareStates(tc.sourceScan, tc.loadedEntries)
+			result := CompareStates(tc.sourceScan, tc.loadedEntries, config.NewDefaultConfig())
 			require.Equal(t, tc.expected, result)
 		})
 	}
 }
+
+func TestCompareStatesMtimeThreshold(t *testing.T) {
+	fixedTime := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	loaded := map[string]EntryInfo{"file.txt": {RelativePath: "file.txt", Mtime: fixedTime, Size: 100}}
+
+	testCases := []struct {
+		name      string
+		threshold time.Duration
+		offset    time.Duration
+		expected  int
+	}{
+		{name: "ExactEqual", threshold: 0, offset: 0, expected: ActionNone},
+		{name: "ExactSubsecond", threshold: 0, offset: 500 * time.Millisecond, expected: ActionUpdate},
+		{name: "DefaultBorderline", threshold: time.Second, offset: 1500 * time.Millisecond, expected: ActionUpdate},
+		{name: "TwoSecondsBorderline", threshold: 2 * time.Second, offset: 1500 * time.Millisecond, expected: ActionNone},
+		{name: "TwoSecondsBoundary", threshold: 2 * time.Second, offset: -2 * time.Second, expected: ActionUpdate},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.NewDefaultConfig()
+			cfg.MtimeThreshold = tc.threshold
+			source := map[string]EntryInfo{
+				"file.txt": {RelativePath: "file.txt", Mtime: fixedTime.Add(tc.offset), Size: 100},
+			}
+
+			result := CompareStates(source, loaded, cfg)
+			require.Len(t, result, 1)
+			require.Equal(t, tc.expected, result[0].Type)
+		})
+	}
+}
+
+func TestCompareStatesUpdateReason(t *testing.T) {
+	fixedTime := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	previous := EntryInfo{RelativePath: "file.txt", Mtime: fixedTime, Size: 100, Checksum: "aaaa", Permissions: 0644}
+
+	testCases := []struct {
+		name        string
+		modify      func(e *EntryInfo)
+		expected    ChangeReason
+		description string
+	}{
+		{
+			name:        "SizeChanged",
+			modify:      func(e *EntryInfo) { e.Size = 200 },
+			expected:    ReasonSizeChanged,
+			description: "size 100 B→200 B",
+		},
+		{
+			name:        "MtimeOnly",
+			modify:      func(e *EntryInfo) { e.Mtime = fixedTime.Add(time.Hour) },
+			expected:    ReasonMtimeChanged,
+			description: "mtime",
+		},
+		{
+			name: "ChecksumDiffered",
+			modify: func(e *EntryInfo) {
+				e.Mtime = fixedTime.Add(time.Hour)
+				e.Checksum = "bbbb"
+			},
+			expected:    ReasonMtimeChanged | ReasonChecksumDiffered,
+			description: "mtime, checksum",
+		},
+		{
+			name: "PermsChanged",
+			modify: func(e *EntryInfo) {
+				e.Mtime = fixedTime.Add(time.Hour)
+				e.Permissions = 0755
+			},
+			expected:    ReasonMtimeChanged | ReasonPermsChanged,
+			description: "mtime, perms -rw-r--r--→-rwxr-xr-x",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			current := previous
+			tc.modify(&current)
+
+			actions := CompareStates(map[string]EntryInfo{"file.txt": current}, map[string]EntryInfo{"file.txt": previous}, config.NewDefaultConfig())
+			require.Len(t, actions, 1)
+			require.Equal(t, ActionUpdate, actions[0].Type)
+			require.Equal(t, tc.expected, actions[0].Reason)
+			require.Equal(t, tc.description, DescribeReason(actions[0]))
+		})
+	}
+}
+
+func TestWithinMtimeWindow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name      string
+		mtime     time.Time
+		newerThan time.Time
+		olderThan time.Time
+		expected  bool
+	}{
+		{name: "No bounds", mtime: start, expected: true},
+		{name: "Newer than - after bound", mtime: start.Add(time.Second), newerThan: start, expected: true},
+		{name: "Newer than - before bound", mtime: start.Add(-time.Second), newerThan: start, expected: false},
+		{name: "Newer than - exactly at bound is inclusive", mtime: start, newerThan: start, expected: true},
+		{name: "Older than - before bound", mtime: end.Add(-time.Second), olderThan: end, expected: true},
+		{name: "Older than - after bound", mtime: end.Add(time.Second), olderThan: end, expected: false},
+		{name: "Older than - exactly at bound is exclusive", mtime: end, olderThan: end, expected: false},
+		{name: "Window - inside", mtime: start.Add(24 * time.Hour), newerThan: start, olderThan: end, expected: true},
+		{name: "Window - before", mtime: start.Add(-24 * time.Hour), newerThan: start, olderThan: end, expected: false},
+		{name: "Window - after", mtime: end.Add(24 * time.Hour), newerThan: start, olderThan: end, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, withinMtimeWindow(tc.mtime, tc.newerThan, tc.olderThan))
+		})
+	}
+}
+
+func TestScanSourceMtimeWindow(t *testing.T) {
+	testDir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	files := map[string]time.Time{
+		"before.txt":                        start.Add(-24 * time.Hour),
+		"inside.txt":                        start.Add(24 * time.Hour),
+		"after.txt":                         end.Add(24 * time.Hour),
+		filepath.Join("old-dir", "new.txt"): start.Add(48 * time.Hour),
+	}
+	for rel, mtime := range files {
+		path := filepath.Join(testDir, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755), "Failed to create parent directory")
+		require.NoError(t, os.WriteFile(path, []byte(rel), 0644), "Failed to create test file")
+		require.NoError(t, os.Chtimes(path, mtime, mtime), "Failed to set mtime")
+	}
+	// Directory mtime outside the window must not stop traversal
+	oldDir := filepath.Join(testDir, "old-dir")
+	require.NoError(t, os.Chtimes(oldDir, start.Add(-time.Hour), start.Add(-time.Hour)), "Failed to set dir mtime")
+
+	cfg := config.NewDefaultConfig()
+	cfg.NewerThan = start
+	cfg.OlderThan = end
+
+	entries, err := ScanSource(testDir, cfg)
+	require.NoError(t, err, "Expected no error scanning with mtime window")
+	require.Contains(t, entries, "inside.txt", "Expected file inside window")
+	require.Contains(t, entries, filepath.Join("old-dir", "new.txt"), "Expected nested file inside window")
+	require.Contains(t, entries, "old-dir", "Expected directory to still be traversed")
+	require.NotContains(t, entries, "before.txt", "Expected file before window to be excluded")
+	require.NotContains(t, entries, "after.txt", "Expected file after window to be excluded")
+}
+
+func TestSyncKeepsFilesOutsideMtimeWindow(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Streaming=%t", streaming), func(t *testing.T) {
+			srcDir, dstDir := t.TempDir(), t.TempDir()
+			for _, name := range []string{"old.txt", "new.txt"} {
+				require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(name), 0644))
+			}
+			cfg := config.NewDefaultConfig()
+			cfg.Streaming = streaming
+			_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+
+			old := time.Now().Add(-30 * 24 * time.Hour)
+			require.NoError(t, os.Chtimes(filepath.Join(srcDir, "old.txt"), old, old))
+			cfg.NewerThan = time.Now().Add(-7 * 24 * time.Hour)
+			summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+			require.Zero(t, summary.FilesDeleted, "Expected no deletes for files outside the mtime window")
+			require.FileExists(t, filepath.Join(dstDir, "old.txt"))
+
+			state, err := LoadState(dstDir, cfg)
+			require.NoError(t, err)
+			require.Contains(t, state.Entries, "old.txt", "Expected the entry to stay recorded")
+		})
+	}
+}
+
+// injectReadError makes the source walk fail to list directories named name with readErr.
+func injectReadError(t *testing.T, name string, readErr error) {
+	walkDir = func(root string, fn fs.WalkDirFunc) error {
+		return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && d.IsDir() && d.Name() == name {
+				// A failed listing visits the directory, then reports it again with the error
+				if err := fn(path, d, nil); err != nil {
+					return err
+				}
+				return fn(path, d, readErr)
+			}
+			return fn(path, d, err)
+		})
+	}
+	t.Cleanup(func() { walkDir = filepath.WalkDir })
+}
+
+func TestScanSourceReadErrors(t *testing.T) {
+	writeTree := func(t *testing.T) string {
+		srcDir := t.TempDir()
+		for _, name := range []string{"top.txt", filepath.Join("good", "a.txt"), filepath.Join("bad", "b.txt")} {
+			path := filepath.Join(srcDir, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			require.NoError(t, os.WriteFile(path, []byte(name), 0644))
+		}
+		return srcDir
+	}
+	errIO := errors.New("input/output error")
+
+	t.Run("SkipsUnreadable", func(t *testing.T) {
+		srcDir := writeTree(t)
+		injectReadError(t, "bad", errIO)
+
+		entries, err := ScanSource(srcDir, config.NewDefaultConfig())
+		require.NoError(t, err, "Expected an unreadable directory not to halt the scan")
+		require.Contains(t, entries, "top.txt")
+		require.Contains(t, entries, filepath.Join("good", "a.txt"))
+		require.NotContains(t, entries, filepath.Join("bad", "b.txt"))
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		srcDir := writeTree(t)
+		injectReadError(t, "bad", errIO)
+		cfg := config.NewDefaultConfig()
+		cfg.StrictScan = true
+
+		_, err := ScanSource(srcDir, cfg)
+		require.ErrorIs(t, err, ErrSyncerDirWalk)
+		require.ErrorIs(t, err, errIO)
+	})
+
+	for _, tc := range []struct {
+		name    string
+		readErr error
+	}{
+		{name: "IO", readErr: errIO},
+		{name: "Permission", readErr: os.ErrPermission},
+	} {
+		for _, streaming := range []bool{false, true} {
+			t.Run(fmt.Sprintf("SyncKeepsUnreadable/%s/Streaming=%t", tc.name, streaming), func(t *testing.T) {
+				srcDir, dstDir := writeTree(t), t.TempDir()
+				cfg := config.NewDefaultConfig()
+				cfg.Streaming = streaming
+				_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+				require.NoError(t, err)
+
+				injectReadError(t, "bad", tc.readErr)
+				require.NoError(t, os.Remove(filepath.Join(srcDir, "good", "a.txt")))
+				_, err = Sync(context.Background(), srcDir, dstDir, cfg)
+				require.NoError(t, err)
+				require.FileExists(t, filepath.Join(dstDir, "bad", "b.txt"), "Expected the copy of an unreadable entry to be kept")
+				require.NoFileExists(t, filepath.Join(dstDir, "good", "a.txt"), "Expected readable deletions to go ahead")
+
+				state, err := LoadState(dstDir, cfg)
+				require.NoError(t, err)
+				require.Contains(t, state.Entries, filepath.Join("bad", "b.txt"), "Expected the unreadable entry to stay recorded")
+			})
+		}
+	}
+}
+
+func TestExecuteActionsSummary(t *testing.T) {
+	srcDir := t.TempDir()
+	dst := newMemDestination()
+	cfg := config.NewDefaultConfig()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "new.txt"), []byte("brand new"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "dir", "changed.txt"), []byte("changed content"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "same.txt"), []byte("same"), 0644))
+
+	// First run seeds the destination
+	entries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+	_, err = ExecuteActionsTo(context.Background(), srcDir, dst, CompareStates(entries, map[string]EntryInfo{}, cfg), cfg, nil)
+	require.NoError(t, err)
+	require.True(t, dst.dirs["dir"], "Expected directory to be created on the destination")
+	require.Equal(t, "changed content", string(dst.files[filepath.Join("dir", "changed.txt")]))
+	dst.files["stale.txt"] = []byte("stale")
+	delete(dst.files, "new.txt")
+
+	state := entries
+	state["stale.txt"] = EntryInfo{RelativePath: "stale.txt", Size: 5}
+	delete(state, "new.txt")
+	changed := state[filepath.Join("dir", "changed.txt")]
+	changed.Size = 1
+	state[filepath.Join("dir", "changed.txt")] = changed
+
+	// Second run exercises every action type
+	entries, err = ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+	actions := CompareStates(entries, state, cfg)
+	summary, err := ExecuteActionsTo(context.Background(), srcDir, dst, actions, cfg, nil)
+	require.NoError(t, err)
+
+	require.False(t, summary.DryRun)
+	require.Equal(t, 1, summary.FilesCreated, "Expected one created file")
+	require.Equal(t, 1, summary.FilesUpdated, "Expected one updated file")
+	require.Equal(t, 1, summary.FilesDeleted, "Expected one deleted file")
+	require.Equal(t, 2, summary.Unchanged, "Expected unchanged file and directory")
+	require.Equal(t, int64(len("brand new")), summary.BytesCreated)
+	require.Equal(t, int64(len("changed content")), summary.BytesUpdated)
+	require.Equal(t, int64(len("brand new")+len("changed content")), summary.BytesTransferred, "Expected transferred bytes to match written files")
+	require.Positive(t, summary.Elapsed)
+
+	require.Equal(t, "brand new", string(dst.files["new.txt"]))
+	require.NotContains(t, dst.files, "stale.txt", "Expected stale file to be deleted")
+
+	planned := PlanSummary(actions)
+	require.True(t, planned.DryRun)
+	require.Equal(t, summary.FilesCreated, planned.FilesCreated)
+	require.Equal(t, summary.FilesUpdated, planned.FilesUpdated)
+	require.Equal(t, summary.Unchanged, planned.Unchanged)
+}
+
+func TestExecuteActionsSkipsMatchingDestination(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	files := map[string]string{
+		"identical.txt": "identical content",
+		"same-size.txt": "aaaa",
+		"missing.txt":   "not yet copied",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "identical.txt"), []byte("identical content"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "same-size.txt"), []byte("bbbb"), 0644))
+
+	entries, err := ScanSource(srcDir, config.NewDefaultConfig())
+	require.NoError(t, err)
+
+	actions := []SyncAction{
+		{Type: ActionUpdate, RelativePath: "identical.txt", SourceInfo: entries["identical.txt"]},
+		{Type: ActionUpdate, RelativePath: "same-size.txt", SourceInfo: entries["same-size.txt"]},
+		{Type: ActionCreate, RelativePath: "missing.txt", SourceInfo: entries["missing.txt"]},
+	}
+	total := int64(len(files["identical.txt"]) + len(files["same-size.txt"]) + len(files["missing.txt"]))
+
+	t.Run("ChecksumModeSkipsUnchanged", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.Checksum = true
+
+		summary, err := ExecuteActions(srcDir, dstDir, actions, cfg)
+		require.NoError(t, err)
+		require.Equal(t, total, summary.BytesPlanned, "Expected every copy to be planned")
+		require.Equal(t, 1, summary.FilesSkipped, "Expected identical file to be skipped")
+		require.Equal(t, int64(len(files["identical.txt"])), summary.BytesSkipped)
+		require.Equal(t, int64(len(files["same-size.txt"])+len(files["missing.txt"])), summary.BytesTransferred)
+		require.Equal(t, summary.BytesPlanned, summary.BytesSkipped+summary.BytesTransferred)
+
+		for name, content := range files {
+			data, err := os.ReadFile(filepath.Join(dstDir, name))
+			require.NoError(t, err)
+			require.Equal(t, content, string(data), "Expected %s to match source", name)
+		}
+	})
+
+	t.Run("DefaultModeCopiesEverything", func(t *testing.T) {
+		summary, err := ExecuteActions(srcDir, dstDir, actions, config.NewDefaultConfig())
+		require.NoError(t, err)
+		require.Zero(t, summary.FilesSkipped)
+		require.Equal(t, total, summary.BytesTransferred)
+	})
+}
+
+func TestGenerateChecksumAssumeStable(t *testing.T) {
+	testDir := t.TempDir()
+	for i := range 5 {
+		name := filepath.Join(testDir, fmt.Sprintf("file-%d.txt", i))
+		require.NoError(t, os.WriteFile(name, []byte(fmt.Sprintf("content %d", i)), 0644))
+	}
+
+	var statCalls atomic.Int64
+	statFile = func(name string) (os.FileInfo, error) {
+		statCalls.Add(1)
+		return os.Stat(name)
+	}
+	t.Cleanup(func() { statFile = os.Stat })
+
+	scan := func(assumeStable bool) (map[string]EntryInfo, int64) {
+		statCalls.Store(0)
+		cfg := config.NewDefaultConfig()
+		cfg.AssumeStableSource = assumeStable
+		entries, err := ScanSource(testDir, cfg)
+		require.NoError(t, err)
+		return entries, statCalls.Load()
+	}
+
+	verifiedEntries, verifiedStats := scan(false)
+	stableEntries, stableStats := scan(true)
+
+	// One stat for the root, then two per file when verifying versus one when stable
+	require.Equal(t, int64(1+5*2), verifiedStats, "Expected pre and post read stats per file")
+	require.Equal(t, int64(1+5), stableStats, "Expected only the pre read stat per file")
+	require.Equal(t, verifiedEntries, stableEntries, "Expected identical checksums in both modes")
+}
+
+func TestExecuteActionsReserveSpace(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	files := map[string]int{"small.bin": 100, "large.bin": 5000}
+	for name, size := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), make([]byte, size), 0644))
+	}
+
+	entries, err := ScanSource(srcDir, config.NewDefaultConfig())
+	require.NoError(t, err)
+
+	actions := []SyncAction{
+		{Type: ActionCreate, RelativePath: "small.bin", SourceInfo: entries["small.bin"]},
+		{Type: ActionCreate, RelativePath: "large.bin", SourceInfo: entries["large.bin"]},
+	}
+
+	// Simulate a destination with 2000 bytes free
+	originalFreeSpace := freeSpace
+	freeSpace = func(string) (uint64, error) { return 2000, nil }
+	t.Cleanup(func() { freeSpace = originalFreeSpace })
+
+	cfg := config.NewDefaultConfig()
+	cfg.ReserveSpace = 1000
+
+	summary, err := ExecuteActions(srcDir, dstDir, actions, cfg)
+	require.NoError(t, err, "Expected deferred copies not to fail the run")
+	require.Equal(t, 1, summary.FilesCreated, "Expected only the small file to be copied")
+	require.Equal(t, []string{"large.bin"}, summary.Deferred, "Expected the large file to be deferred")
+	require.Equal(t, int64(5000), summary.BytesDeferred)
+
+	_, err = os.Stat(filepath.Join(dstDir, "large.bin"))
+	require.True(t, os.IsNotExist(err), "Expected deferred file not to be written")
+
+	t.Run("DeferredFilesAreNotRecorded", func(t *testing.T) {
+		previous := map[string]EntryInfo{}
+		reconciled := ReconcileEntries(previous, entries, summary.Deferred)
+		require.Contains(t, reconciled, "small.bin")
+		require.NotContains(t, reconciled, "large.bin", "Expected deferred file to be retried next run")
+	})
+}
+
+func TestFilterActions(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "new.txt"), []byte("new"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "changed.txt"), []byte("changed in source"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "changed.txt"), []byte("old"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "stale.txt"), []byte("stale"), 0644))
+
+	entries, err := ScanSource(srcDir, config.NewDefaultConfig())
+	require.NoError(t, err)
+
+	actions := []SyncAction{
+		{Type: ActionCreate, RelativePath: "new.txt", SourceInfo: entries["new.txt"]},
+		{Type: ActionUpdate, RelativePath: "changed.txt", SourceInfo: entries["changed.txt"]},
+		{Type: ActionDelete, RelativePath: "stale.txt"},
+		{Type: ActionNone, RelativePath: "same.txt"},
+	}
+
+	t.Run("SkipDelete", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.SkipActions = []string{config.ActionKindDelete}
+
+		kept, skipped := FilterActions(actions, cfg)
+		require.Len(t, kept, 3)
+		require.Equal(t, []string{"stale.txt"}, skipped)
+	})
+
+	t.Run("OnlyCreate", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.OnlyActions = []string{config.ActionKindCreate}
+
+		kept, skipped := FilterActions(actions, cfg)
+		require.Equal(t, []string{"changed.txt", "stale.txt"}, skipped)
+
+		summary, err := ExecuteActions(srcDir, dstDir, kept, cfg)
+		require.NoError(t, err)
+		require.Equal(t, 1, summary.FilesCreated)
+		require.Zero(t, summary.FilesUpdated, "Expected no updates")
+		require.Zero(t, summary.FilesDeleted, "Expected no deletes")
+
+		content, err := os.ReadFile(filepath.Join(dstDir, "changed.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "old", string(content), "Expected existing destination file to be untouched")
+		require.FileExists(t, filepath.Join(dstDir, "stale.txt"), "Expected stale file to survive")
+		require.FileExists(t, filepath.Join(dstDir, "new.txt"))
+
+		previous := map[string]EntryInfo{
+			"changed.txt": {RelativePath: "changed.txt", Size: 3},
+			"stale.txt":   {RelativePath: "stale.txt", Size: 5},
+		}
+		reconciled := ReconcileEntries(previous, entries, skipped)
+		require.Equal(t, previous["changed.txt"], reconciled["changed.txt"], "Expected skipped update to keep old state")
+		require.Contains(t, reconciled, "stale.txt", "Expected skipped delete to stay recorded")
+		require.Equal(t, entries["new.txt"], reconciled["new.txt"])
+	})
+}
+
+func TestScanSourceChecksumManifest(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "bin", "listed.bin"), []byte("listed"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "unlisted.txt"), []byte("unlisted"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "stale.txt"), []byte("stale"), 0644))
+
+	manifestPath := filepath.Join(t.TempDir(), "checksums.json")
+	manifest := `{
+		"bin/listed.bin": {"size": 6, "checksum": "from-manifest"},
+		"stale.txt": {"size": 999, "checksum": "outdated"}
+	}`
+	require.NoError(t, os.WriteFile(manifestPath, []byte(manifest), 0644))
+
+	var hashed []string
+	originalChecksumFile := checksumFile
+	checksumFile = func(path string, assumeStable bool) ([]byte, error) {
+		hashed = append(hashed, filepath.Base(path))
+		return originalChecksumFile(path, assumeStable)
+	}
+	t.Cleanup(func() { checksumFile = originalChecksumFile })
+
+	cfg := config.NewDefaultConfig()
+	cfg.SourceChecksums = manifestPath
+
+	entries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+
+	require.Equal(t, "from-manifest", entries[filepath.Join("bin", "listed.bin")].Checksum, "Expected manifest checksum to be trusted")
+	require.NotEmpty(t, entries["unlisted.txt"].Checksum, "Expected unlisted file to be hashed")
+	require.NotEqual(t, "outdated", entries["stale.txt"].Checksum, "Expected size mismatch to fall back to hashing")
+	require.ElementsMatch(t, []string{"unlisted.txt", "stale.txt"}, hashed, "Expected only unlisted or stale files to be hashed")
+
+	t.Run("InvalidManifest", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(manifestPath, []byte("not json"), 0644))
+		_, err := ScanSource(srcDir, cfg)
+		require.ErrorIs(t, err, ErrManifestParse)
+	})
+}
+
+func TestSyncChecksumOnCopy(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	cfg := config.NewDefaultConfig()
+	cfg.ChecksumOnCopy = true
+
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "kept.txt"), []byte("kept"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "big.bin"), bytes.Repeat([]byte("mimic"), 1<<18), 0644))
+
+	var hashed []string
+	originalChecksumFile := checksumFile
+	checksumFile = func(path string, assumeStable bool) ([]byte, error) {
+		hashed = append(hashed, filepath.Base(path))
+		return originalChecksumFile(path, assumeStable)
+	}
+	t.Cleanup(func() { checksumFile = originalChecksumFile })
+
+	requireStateChecksums := func() map[string]EntryInfo {
+		t.Helper()
+		state, err := LoadState(dstDir, cfg)
+		require.NoError(t, err)
+		for _, name := range []string{"kept.txt", "big.bin"} {
+			want, err := originalChecksumFile(filepath.Join(srcDir, name), false)
+			require.NoError(t, err)
+			require.Equal(t, hex.EncodeToString(want), state.Entries[name].Checksum,
+				"Expected the recorded checksum of %s to match an independent hash", name)
+		}
+		return state.Entries
+	}
+
+	_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.Empty(t, hashed, "Expected copied files to be hashed by the copy, not the scan")
+	first := requireStateChecksums()
+
+	// Only the changed file is copied and hashed again; the unchanged one keeps its checksum
+	hashed = nil
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "big.bin"), []byte("changed"), 0644))
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(srcDir, "big.bin"), future, future))
+	_, err = Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.Empty(t, hashed)
+	second := requireStateChecksums()
+	require.Equal(t, first["kept.txt"].Checksum, second["kept.txt"].Checksum)
+	require.NotEqual(t, first["big.bin"].Checksum, second["big.bin"].Checksum)
+
+	t.Run("ChecksumModeHashesAtScan", func(t *testing.T) {
+		hashed = nil
+		checksumCfg := *cfg
+		checksumCfg.Checksum = true
+		_, err := Sync(context.Background(), srcDir, dstDir, &checksumCfg)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"kept.txt", "big.bin"}, hashed, "Expected -checksum to keep hashing during the scan")
+	})
+
+	t.Run("ExecuteReturnsChecksums", func(t *testing.T) {
+		entries, err := ScanSource(srcDir, cfg)
+		require.NoError(t, err)
+		// As planned by a run that leaves the hashing of copied files to the copy
+		for path, entry := range entries {
+			entry.Checksum = ""
+			entries[path] = entry
+		}
+		actions := CompareStates(entries, nil, cfg)
+		planned := slices.Clone(actions)
+
+		summary, err := ExecuteActionsTo(context.Background(), srcDir, NewLocalDestination(t.TempDir(), cfg), actions, cfg, nil)
+		require.NoError(t, err)
+		require.Equal(t, planned, actions, "Expected the caller's actions to be left as they were")
+		want, err := originalChecksumFile(filepath.Join(srcDir, "kept.txt"), false)
+		require.NoError(t, err)
+		require.Equal(t, hex.EncodeToString(want), summary.Checksums["kept.txt"])
+		require.Len(t, summary.Checksums, 2)
+	})
+}
+
+func TestPlanSummaryReportsDeletedSizes(t *testing.T) {
+	loaded := map[string]EntryInfo{
+		"old":         {RelativePath: "old", IsDir: true},
+		"old/log.txt": {RelativePath: "old/log.txt", Size: 300},
+		"kept.txt":    {RelativePath: "kept.txt", Size: 10},
+	}
+	source := map[string]EntryInfo{
+		"kept.txt": {RelativePath: "kept.txt", Size: 10},
+	}
+
+	summary := PlanSummary(CompareStates(source, loaded, config.NewDefaultConfig()))
+	require.Equal(t, 1, summary.FilesDeleted)
+	require.Equal(t, 1, summary.DirsDeleted, "Expected deleted directory to be recognised from state")
+	require.Equal(t, int64(300), summary.BytesDeleted, "Expected size to delete from the last known state")
+	require.Equal(t, 1, summary.Unchanged)
+}
+
+func TestCompareStatesVerifyOnEqualMtime(t *testing.T) {
+	srcDir := t.TempDir()
+	path := filepath.Join(srcDir, "config.ini")
+	require.NoError(t, os.WriteFile(path, []byte("value=1"), 0644))
+
+	before, err := ScanSource(srcDir, config.NewDefaultConfig())
+	require.NoError(t, err)
+	recorded := before["config.ini"]
+
+	// Same length edit, then restore the original mtime to simulate the collision
+	require.NoError(t, os.WriteFile(path, []byte("value=2"), 0644))
+	require.NoError(t, os.Chtimes(path, recorded.Mtime, recorded.Mtime))
+
+	after, err := ScanSource(srcDir, config.NewDefaultConfig())
+	require.NoError(t, err)
+	require.Equal(t, recorded.Size, after["config.ini"].Size)
+	require.True(t, recorded.Mtime.Equal(after["config.ini"].Mtime))
+
+	t.Run("DefaultMissesChange", func(t *testing.T) {
+		actions := CompareStates(after, before, config.NewDefaultConfig())
+		require.Equal(t, ActionNone, actions[0].Type)
+	})
+
+	t.Run("VerifyDetectsChange", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.VerifyOnEqualMtime = true
+
+		actions := CompareStates(after, before, cfg)
+		require.Len(t, actions, 1)
+		require.Equal(t, ActionUpdate, actions[0].Type)
+		require.Equal(t, ReasonChecksumDiffered, actions[0].Reason)
+	})
+
+	t.Run("VerifyKeepsUnchanged", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.VerifyOnEqualMtime = true
+
+		actions := CompareStates(after, after, cfg)
+		require.Equal(t, ActionNone, actions[0].Type)
+	})
+}
+
+func TestCompareStatesNoTimes(t *testing.T) {
+	recorded := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	skewed := recorded.Add(-90 * 24 * time.Hour)
+	state := map[string]EntryInfo{
+		"same.txt":    {RelativePath: "same.txt", Mtime: recorded, Size: 10, Checksum: "aaaa"},
+		"edited.txt":  {RelativePath: "edited.txt", Mtime: recorded, Size: 10, Checksum: "bbbb"},
+		"resized.txt": {RelativePath: "resized.txt", Mtime: recorded, Size: 10, Checksum: "cccc"},
+	}
+	source := map[string]EntryInfo{
+		"same.txt":    {RelativePath: "same.txt", Mtime: skewed, Size: 10, Checksum: "aaaa"},
+		"edited.txt":  {RelativePath: "edited.txt", Mtime: recorded.Add(time.Hour), Size: 10, Checksum: "ffff"},
+		"resized.txt": {RelativePath: "resized.txt", Mtime: skewed, Size: 12, Checksum: "cccc"},
+	}
+
+	tests := []struct {
+		name     string
+		noTimes  bool
+		checksum bool
+		want     map[string]int
+	}{
+		{name: "Default", want: map[string]int{"edited.txt": ActionUpdate, "resized.txt": ActionUpdate, "same.txt": ActionUpdate}},
+		{name: "NoTimes", noTimes: true, want: map[string]int{"edited.txt": ActionNone, "resized.txt": ActionUpdate, "same.txt": ActionNone}},
+		{name: "NoTimesWithChecksum", noTimes: true, checksum: true, want: map[string]int{"edited.txt": ActionUpdate, "resized.txt": ActionUpdate, "same.txt": ActionNone}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewDefaultConfig()
+			cfg.NoTimes = tt.noTimes
+			cfg.Checksum = tt.checksum
+
+			got := make(map[string]int)
+			for _, action := range CompareStates(source, state, cfg) {
+				got[action.RelativePath] = action.Type
+				if tt.noTimes {
+					require.False(t, action.Reason.Has(ReasonMtimeChanged), "Expected mtime to never be a reason with -no-times")
+				}
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExecuteActionsAppendGrowth(t *testing.T) {
+	original := strings.Repeat("2024-01-01 request served\n", 500)
+	tests := []struct {
+		name            string
+		source          string
+		wantTransferred int
+	}{
+		{name: "PureAppend", source: original + "2024-01-02 request served\n", wantTransferred: len("2024-01-02 request served\n")},
+		{name: "MidFileChange", source: "2025" + original[4:] + "2024-01-02 request served\n", wantTransferred: len(original) + len("2024-01-02 request served\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcDir, dstDir := t.TempDir(), t.TempDir()
+			cfg := config.NewDefaultConfig()
+			cfg.AppendGrowth = true
+
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "app.log"), []byte(original), 0644))
+			state, err := ScanSource(srcDir, cfg)
+			require.NoError(t, err)
+			_, err = ExecuteActions(srcDir, dstDir, CompareStates(state, map[string]EntryInfo{}, cfg), cfg)
+			require.NoError(t, err)
+
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "app.log"), []byte(tt.source), 0644))
+			scan, err := ScanSource(srcDir, cfg)
+			require.NoError(t, err)
+			actions := CompareStates(scan, state, cfg)
+			require.Equal(t, ActionUpdate, actions[0].Type)
+
+			summary, err := ExecuteActions(srcDir, dstDir, actions, cfg)
+			require.NoError(t, err)
+			require.Equal(t, 1, summary.FilesUpdated)
+			require.Equal(t, int64(tt.wantTransferred), summary.BytesTransferred)
+
+			got, err := os.ReadFile(filepath.Join(dstDir, "app.log"))
+			require.NoError(t, err)
+			require.Equal(t, tt.source, string(got))
+		})
+	}
+}
+
+func TestSyncPermsAlways(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("Enabled=%v", enabled), func(t *testing.T) {
+			srcDir, dstDir := t.TempDir(), t.TempDir()
+			cfg := config.NewDefaultConfig()
+			cfg.SyncPermsAlways = enabled
+
+			srcFile := filepath.Join(srcDir, "config.yml")
+			require.NoError(t, os.WriteFile(srcFile, []byte("port: 80\n"), 0644))
+			state, err := ScanSource(srcDir, cfg)
+			require.NoError(t, err)
+			_, err = ExecuteActions(srcDir, dstDir, CompareStates(state, map[string]EntryInfo{}, cfg), cfg)
+			require.NoError(t, err)
+
+			// Same contents and mtime, only the mode changes
+			require.NoError(t, os.Chmod(srcFile, 0600))
+			scan, err := ScanSource(srcDir, cfg)
+			require.NoError(t, err)
+			actions := CompareStates(scan, state, cfg)
+			require.Len(t, actions, 1)
+
+			summary, err := ExecuteActions(srcDir, dstDir, actions, cfg)
+			require.NoError(t, err)
+			require.Zero(t, summary.FilesUpdated, "Expected no copy for a permission-only change")
+			require.Zero(t, summary.BytesTransferred)
+
+			info, err := os.Stat(filepath.Join(dstDir, "config.yml"))
+			require.NoError(t, err)
+			if enabled {
+				require.Equal(t, ActionChmod, actions[0].Type)
+				require.Equal(t, ReasonPermsChanged, actions[0].Reason)
+				require.Equal(t, 1, summary.PermsUpdated)
+				require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+			} else {
+				require.Equal(t, ActionNone, actions[0].Type)
+				require.Zero(t, summary.PermsUpdated)
+				require.Equal(t, os.FileMode(0644), info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestSyncUpdateSkipsNewerDestination(t *testing.T) {
+	for _, update := range []bool{true, false} {
+		t.Run(fmt.Sprintf("Update=%v", update), func(t *testing.T) {
+			srcDir, dstDir := t.TempDir(), t.TempDir()
+			cfg := config.NewDefaultConfig()
+			cfg.Update = update
+
+			srcFile, dstFile := filepath.Join(srcDir, "notes.txt"), filepath.Join(dstDir, "notes.txt")
+			require.NoError(t, os.WriteFile(srcFile, []byte("v1"), 0644))
+			_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+
+			// The source changes, but the destination was edited more recently still
+			past := time.Now().Add(-time.Hour)
+			require.NoError(t, os.WriteFile(srcFile, []byte("v2 from source"), 0644))
+			require.NoError(t, os.Chtimes(srcFile, past, past))
+			require.NoError(t, os.WriteFile(dstFile, []byte("edited on destination"), 0644))
+
+			summary, err := Sync(context.Background(), srcDir, dstDir, cfg)
+			require.NoError(t, err)
+
+			got, err := os.ReadFile(dstFile)
+			require.NoError(t, err)
+			if update {
+				require.Equal(t, "edited on destination", string(got), "Expected the newer destination file to be preserved")
+				require.Equal(t, 1, summary.FilesSkipped)
+				require.Zero(t, summary.FilesUpdated, "Expected a skipped copy not to count as updated")
+				require.Zero(t, summary.BytesTransferred)
+				require.Equal(t, []string{"notes.txt"}, summary.Kept)
+
+				state, err := LoadState(dstDir, cfg)
+				require.NoError(t, err)
+				require.Equal(t, int64(len("v1")), state.Entries["notes.txt"].Size, "Expected the previous state entry to be kept")
+			} else {
+				require.Equal(t, "v2 from source", string(got))
+				require.Zero(t, summary.FilesSkipped)
+			}
+		})
+	}
+}
+
+func TestBandwidthLimit(t *testing.T) {
+	cfg := config.NewDefaultConfig()
+	cfg.BandwidthLimit = 1000
+	cfg.BandwidthRules = []config.BandwidthRule{
+		{Pattern: "*.mp4", LimitKBps: 5000},
+		{Pattern: "raw/**", LimitKBps: 200},
+		{Pattern: "*.conf", LimitKBps: 0},
+	}
+
+	tests := []struct {
+		relPath string
+		want    int
+	}{
+		{relPath: filepath.Join("videos", "trip.mp4"), want: 5000},
+		{relPath: filepath.Join("raw", "trip.mp4"), want: 5000}, // The first matching rule wins
+		{relPath: filepath.Join("raw", "scan.tiff"), want: 200},
+		{relPath: "app.conf", want: 0},
+		{relPath: "notes.txt", want: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.relPath, func(t *testing.T) {
+			require.Equal(t, tt.want, bandwidthLimit(tt.relPath, cfg))
+		})
+	}
+}
+
+// limitRecordingDestination records the limit each throttled copy was made with.
+type limitRecordingDestination struct {
+	*LocalDestination
+	limits map[string]int
+}
+
+func (d *limitRecordingDestination) CopyLimited(srcPath, relPath string, chunkSize int64, limitKBps int) (int64, error) {
+	d.limits[relPath] = limitKBps
+	return d.LocalDestination.Copy(srcPath, relPath, chunkSize)
+}
+
+func TestExecuteActionsBandwidthRules(t *testing.T) {
+	srcDir := t.TempDir()
+	for _, name := range []string{"movie.mp4", "app.conf", "notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(name), 0644))
+	}
+	cfg := config.NewDefaultConfig()
+	cfg.BandwidthRules = []config.BandwidthRule{{Pattern: "*.mp4", LimitKBps: 5000}}
+	entries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+
+	dst := &limitRecordingDestination{NewLocalDestination(t.TempDir(), cfg), map[string]int{}}
+	_, err = ExecuteActionsTo(context.Background(), srcDir, dst, CompareStates(entries, map[string]EntryInfo{}, cfg), cfg, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"movie.mp4": 5000}, dst.limits, "Expected only files matching a rule to get its limit")
+}
+
+func TestExecuteActionsPreserveDirTimes(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	cfg := config.NewDefaultConfig()
+	cfg.PreserveDirTimes = true
+
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "outer", "inner"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "outer", "inner", "file.txt"), []byte("data"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "outer", "top.txt"), []byte("top"), 0644))
+	dirTimes := map[string]time.Time{
+		"outer":                         time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		filepath.Join("outer", "inner"): time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC),
+	}
+	for dir, mtime := range dirTimes {
+		require.NoError(t, os.Chtimes(filepath.Join(srcDir, dir), mtime, mtime))
+	}
+
+	entries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+	_, err = ExecuteActions(srcDir, dstDir, CompareStates(entries, map[string]EntryInfo{}, cfg), cfg)
+	require.NoError(t, err)
+
+	for dir, mtime := range dirTimes {
+		info, err := os.Stat(filepath.Join(dstDir, dir))
+		require.NoError(t, err)
+		require.True(t, mtime.Equal(info.ModTime()), "Expected %s mtime %v, got %v", dir, mtime, info.ModTime())
+	}
+}
+
+func TestExecuteActionsTypeConflict(t *testing.T) {
+	setup := func(t *testing.T) (srcDir, dstDir string, actions []SyncAction) {
+		srcDir = t.TempDir()
+		dstDir = t.TempDir()
+
+		// Source has a directory where the destination has a file, and vice versa
+		require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "was-file"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, "was-file", "child.txt"), []byte("child"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, "was-dir"), []byte("now a file"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dstDir, "was-file"), []byte("old file"), 0644))
+		require.NoError(t, os.MkdirAll(filepath.Join(dstDir, "was-dir", "nested"), 0755))
+
+		cfg := config.NewDefaultConfig()
+		entries, err := ScanSource(srcDir, cfg)
+		require.NoError(t, err)
+		return srcDir, dstDir, CompareStates(entries, map[string]EntryInfo{}, cfg)
+	}
+
+	t.Run("Replace", func(t *testing.T) {
+		srcDir, dstDir, actions := setup(t)
+
+		_, err := ExecuteActions(srcDir, dstDir, actions, config.NewDefaultConfig())
+		require.NoError(t, err)
+
+		require.DirExists(t, filepath.Join(dstDir, "was-file"), "Expected the file to be replaced by a directory")
+		got, err := os.ReadFile(filepath.Join(dstDir, "was-file", "child.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "child", string(got))
+
+		got, err = os.ReadFile(filepath.Join(dstDir, "was-dir"))
+		require.NoError(t, err, "Expected the directory to be replaced by a file")
+		require.Equal(t, "now a file", string(got))
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		srcDir, dstDir, actions := setup(t)
+		cfg := config.NewDefaultConfig()
+		cfg.StrictTypes = true
+
+		_, err := ExecuteActions(srcDir, dstDir, actions, cfg)
+		require.ErrorIs(t, err, ErrSyncerTypeConflict)
+		require.DirExists(t, filepath.Join(dstDir, "was-dir", "nested"), "Expected strict mode to leave the destination alone")
+	})
+}
+
+// flakyDestination fails each operation a fixed number of times before delegating.
+type flakyDestination struct {
+	*memDestination
+	failures int   // Failures left per operation and path
+	err      error // Error returned while failing
+	attempts map[string]int
+}
+
+func (d *flakyDestination) fail(op, relPath string) error {
+	key := op + ":" + relPath
+	d.attempts[key]++
+	if d.attempts[key] <= d.failures {
+		return d.err
+	}
+	return nil
+}
+
+func (d *flakyDestination) Copy(srcPath, relPath string, chunkSize int64) (int64, error) {
+	if err := d.fail("copy", relPath); err != nil {
+		return 0, err
+	}
+	return d.memDestination.Copy(srcPath, relPath, chunkSize)
+}
+
+func (d *flakyDestination) Mkdir(relPath string) error {
+	if err := d.fail("mkdir", relPath); err != nil {
+		return err
+	}
+	return d.memDestination.Mkdir(relPath)
+}
+
+func (d *flakyDestination) Delete(relPath string) error {
+	if err := d.fail("delete", relPath); err != nil {
+		return err
+	}
+	return d.memDestination.Delete(relPath)
+}
+
+func TestExecuteActionsRetriesTransientErrors(t *testing.T) {
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "dir", "file.txt"), []byte("payload"), 0644))
+	cfg := config.NewDefaultConfig()
+	entries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+	actions := append(CompareStates(entries, map[string]EntryInfo{}, cfg),
+		SyncAction{Type: ActionDelete, RelativePath: "stale.txt", SourceInfo: EntryInfo{Size: 5}})
+
+	t.Run("TransientSucceeds", func(t *testing.T) {
+		dst := &flakyDestination{memDestination: newMemDestination(), failures: 2, err: errors.New("device busy"), attempts: map[string]int{}}
+		dst.files["stale.txt"] = []byte("stale")
+
+		summary, err := ExecuteActionsTo(context.Background(), srcDir, dst, actions, cfg, nil)
+		require.NoError(t, err, "Expected transient failures to be retried")
+		require.Equal(t, "payload", string(dst.files[filepath.Join("dir", "file.txt")]))
+		require.NotContains(t, dst.files, "stale.txt")
+		require.Equal(t, int64(len("payload")), summary.BytesTransferred, "Expected only the successful attempt to count")
+		require.Equal(t, map[string]int{
+			"mkdir:dir": 3,
+			"copy:" + filepath.Join("dir", "file.txt"): 3,
+			"delete:stale.txt":                         3,
+		}, dst.attempts)
+	})
+
+	t.Run("ExhaustsRetries", func(t *testing.T) {
+		dst := &flakyDestination{memDestination: newMemDestination(), failures: 100, err: errors.New("device busy"), attempts: map[string]int{}}
+		cfg := config.NewDefaultConfig()
+		cfg.Retries = 2
+
+		_, err := ExecuteActionsTo(context.Background(), srcDir, dst, actions, cfg, nil)
+		require.ErrorContains(t, err, "device busy")
+		require.Equal(t, 2, dst.attempts["mkdir:dir"])
+	})
+
+	t.Run("PermissionIsPermanent", func(t *testing.T) {
+		dst := &flakyDestination{memDestination: newMemDestination(), failures: 100, err: fmt.Errorf("wrapped: %w", fs.ErrPermission), attempts: map[string]int{}}
+
+		_, err := ExecuteActionsTo(context.Background(), srcDir, dst, actions, cfg, nil)
+		require.ErrorIs(t, err, fs.ErrPermission)
+		require.Equal(t, 1, dst.attempts["mkdir:dir"], "Expected permission errors not to be retried")
+	})
+}
+
+func TestScanSourceSymlinkRoot(t *testing.T) {
+	realDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(realDir, "dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(realDir, "dir", "file.txt"), []byte("content"), 0644))
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(realDir, link); err != nil {
+		t.Skipf("cannot create symlinks: %v", err)
+	}
+
+	t.Run("Rejected", func(t *testing.T) {
+		_, err := ScanSource(link, config.NewDefaultConfig())
+		require.ErrorIs(t, err, ErrSyncerRootSymlink)
+		require.ErrorContains(t, err, "-dereference-root")
+	})
+
+	t.Run("Dereferenced", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.DereferenceRoot = true
+
+		entries, err := ScanSource(link, cfg)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Contains(t, entries, filepath.Join("dir", "file.txt"), "Expected paths relative to the link target")
+	})
+
+	t.Run("SymlinkedParentIsFine", func(t *testing.T) {
+		entries, err := ScanSource(filepath.Join(link, "dir"), config.NewDefaultConfig())
+		require.NoError(t, err, "Expected only the root itself to be checked")
+		require.Contains(t, entries, "file.txt")
+	})
+}
+
+func TestExecuteActionsMinFreeSpace(t *testing.T) {
+	srcDir := t.TempDir()
+	for name, size := range map[string]int{"a.bin": 3000, "b.bin": 4000} {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), make([]byte, size), 0644))
+	}
+	cfg := config.NewDefaultConfig()
+	entries, err := ScanSource(srcDir, cfg)
+	require.NoError(t, err)
+	actions := CompareStates(entries, map[string]EntryInfo{}, cfg)
+
+	available := uint64(10000)
+	originalFreeSpace := freeSpace
+	freeSpace = func(string) (uint64, error) { return available, nil }
+	t.Cleanup(func() { freeSpace = originalFreeSpace })
+
+	testCases := []struct {
+		name         string
+		minFreeSpace int64
+		wantErr      bool
+	}{
+		{name: "Disabled", minFreeSpace: 0},
+		{name: "Fits", minFreeSpace: 3000},
+		{name: "TooSmall", minFreeSpace: 3001, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dstDir := t.TempDir()
+			cfg := config.NewDefaultConfig()
+			cfg.MinFreeSpace = tc.minFreeSpace
+
+			summary, err := ExecuteActions(srcDir, dstDir, actions, cfg)
+			if !tc.wantErr {
+				require.NoError(t, err)
+				require.Equal(t, 2, summary.FilesCreated)
+				return
+			}
+			require.ErrorIs(t, err, ErrSyncerInsufficientSpace)
+			require.Zero(t, summary.FilesCreated, "Expected the run to be refused before any copy")
+			require.NoFileExists(t, filepath.Join(dstDir, "a.bin"))
+		})
+	}
+
+	t.Run("RecheckedWhileRunning", func(t *testing.T) {
+		originalInterval := freeSpaceCheckInterval
+		freeSpaceCheckInterval = 0
+		t.Cleanup(func() { freeSpaceCheckInterval = originalInterval })
+
+		dst := &spaceDestination{memDestination: newMemDestination(), free: []uint64{10000, 10000, 4500}}
+		cfg := config.NewDefaultConfig()
+		cfg.MinFreeSpace = 1000
+
+		summary, err := ExecuteActionsTo(context.Background(), srcDir, dst, actions, cfg, nil)
+		require.ErrorIs(t, err, ErrSyncerInsufficientSpace, "Expected the run to stop once space ran low")
+		require.Equal(t, 1, summary.FilesCreated)
+	})
+}
+
+// spaceDestination reports the next of a sequence of free space readings on each call.
+type spaceDestination struct {
+	*memDestination
+	free []uint64
+}
+
+func (d *spaceDestination) FreeSpace() (uint64, error) {
+	next := d.free[0]
+	if len(d.free) > 1 {
+		d.free = d.free[1:]
+	}
+	return next, nil
+}
+
+func TestPruneEmptyDirs(t *testing.T) {
+	dstRoot := t.TempDir()
+	for _, dir := range []string{
+		filepath.Join("a", "b"), filepath.Join("a", "src-empty"), filepath.Join("deep", "x"),
+		"pending", "failed", "untracked", "spool",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dstRoot, dir), 0755))
+	}
+
+	actions := []SyncAction{
+		{Type: ActionDelete, RelativePath: filepath.Join("a", "b", "file.txt")},
+		{Type: ActionDelete, RelativePath: filepath.Join("deep", "x", "y", "z.txt")},
+		{Type: ActionRmdir, RelativePath: filepath.Join("deep", "x", "y")},
+		{Type: ActionDelete, RelativePath: filepath.Join("pending", "file.txt")},
+		{Type: ActionDelete, RelativePath: filepath.Join("failed", "file.txt")},
+		{Type: ActionCreate, RelativePath: filepath.Join("untracked", "new.txt")},
+		{Type: ActionDelete, RelativePath: filepath.Join("spool", "job.txt")},
+	}
+	sourceScan := map[string]EntryInfo{
+		"a":                             {RelativePath: "a", IsDir: true},
+		filepath.Join("a", "src-empty"): {RelativePath: filepath.Join("a", "src-empty"), IsDir: true},
+	}
+	notApplied := []string{"pending", filepath.Join("failed", "file.txt")}
+
+	removed, err := PruneEmptyDirs(dstRoot, actions, sourceScan, notApplied, []string{"spool"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{filepath.Join("a", "b"), filepath.Join("deep", "x"), "deep"}, removed)
+	for _, dir := range []string{filepath.Join("a", "src-empty"), "pending", "failed", "untracked", "spool"} {
+		require.DirExists(t, filepath.Join(dstRoot, dir))
+	}
+}
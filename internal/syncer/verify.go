@@ -0,0 +1,62 @@
+package syncer
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/fileops"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+// VerifyExecution checks that executed actions took effect on the local destination
+// dstRoot: delete targets must be gone and created or updated entries must exist. It
+// returns the discrepancies sorted by path. Symlinks are not checked, since a kept
+// link may dangle and a skipped one is never created.
+func VerifyExecution(dstRoot string, actions []SyncAction) []Mismatch {
+	var mismatches []Mismatch
+	for _, action := range actions {
+		var wantExists bool
+		switch action.Type {
+		case ActionCreate, ActionUpdate, ActionMkdir:
+			if action.SourceInfo.SymlinkTarget != "" {
+				continue
+			}
+			wantExists = true
+		case ActionDelete, ActionRmdir:
+		default:
+			continue
+		}
+
+		exists, err := fileops.PathExists(filepath.Join(dstRoot, action.RelativePath))
+		switch {
+		case err != nil:
+			mismatches = append(mismatches, Mismatch{Path: action.RelativePath, Problem: "cannot check: " + err.Error()})
+		case wantExists && !exists:
+			mismatches = append(mismatches, Mismatch{Path: action.RelativePath, Problem: "missing after " + actionKind(action.Type)})
+		case !wantExists && exists:
+			mismatches = append(mismatches, Mismatch{Path: action.RelativePath, Problem: "still present after delete"})
+		}
+	}
+	slices.SortStableFunc(mismatches, func(a, b Mismatch) int { return strings.Compare(a.Path, b.Path) })
+	return mismatches
+}
+
+// verifyExecution runs VerifyExecution over the actions of a run, leaving out the
+// deferred copies that were never attempted, and logs every discrepancy. They are
+// returned as an ErrSyncerExecutionMismatch error.
+func verifyExecution(dstRoot string, actions []SyncAction, deferred []string) error {
+	attempted := slices.DeleteFunc(slices.Clone(actions), func(a SyncAction) bool {
+		return slices.Contains(deferred, a.RelativePath)
+	})
+	mismatches := VerifyExecution(dstRoot, attempted)
+	for _, m := range mismatches {
+		logger.Warn("Destination does not match the executed action", "path", m.Path, "problem", m.Problem)
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%w: %d paths", ErrSyncerExecutionMismatch, len(mismatches))
+	}
+	logger.Info("Verified executed actions", "count", len(attempted))
+	return nil
+}
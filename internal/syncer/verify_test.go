@@ -0,0 +1,79 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyExecution(t *testing.T) {
+	dstDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dstDir, "kept"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "created.txt"), []byte("new"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "undeleted.txt"), []byte("stale"), 0644))
+
+	testCases := []struct {
+		name     string
+		actions  []SyncAction
+		expected []Mismatch
+	}{
+		{
+			name: "AllApplied",
+			actions: []SyncAction{
+				{Type: ActionCreate, RelativePath: "created.txt"},
+				{Type: ActionMkdir, RelativePath: "kept"},
+				{Type: ActionDelete, RelativePath: "gone.txt"},
+				{Type: ActionRmdir, RelativePath: "gone-dir"},
+			},
+		},
+		{
+			// The destination reported the delete as done but the file is still there
+			name:     "FailedDelete",
+			actions:  []SyncAction{{Type: ActionDelete, RelativePath: "undeleted.txt"}},
+			expected: []Mismatch{{Path: "undeleted.txt", Problem: "still present after delete"}},
+		},
+		{
+			name: "MissingCopies",
+			actions: []SyncAction{
+				{Type: ActionUpdate, RelativePath: "updated.txt"},
+				{Type: ActionCreate, RelativePath: "absent.txt"},
+				{Type: ActionCreate, RelativePath: "link", SourceInfo: EntryInfo{SymlinkTarget: "elsewhere"}},
+			},
+			expected: []Mismatch{
+				{Path: "absent.txt", Problem: "missing after " + config.ActionKindCreate},
+				{Path: "updated.txt", Problem: "missing after " + config.ActionKindUpdate},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, VerifyExecution(dstDir, tc.actions))
+		})
+	}
+}
+
+func TestSyncVerifyExecution(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("alpha"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "b.txt"), []byte("bravo"), 0644))
+	cfg := config.NewDefaultConfig()
+	cfg.VerifyExecution = true
+
+	_, err := Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+
+	// A delete that does not take effect fails the run
+	require.NoError(t, os.Remove(filepath.Join(srcDir, "b.txt")))
+	require.ErrorIs(t, verifyExecution(dstDir, []SyncAction{{Type: ActionDelete, RelativePath: "a.txt"}}, nil), ErrSyncerExecutionMismatch)
+	require.NoError(t, verifyExecution(dstDir, []SyncAction{{Type: ActionDelete, RelativePath: "a.txt"}}, []string{"a.txt"}),
+		"Expected deferred actions to be left out")
+
+	_, err = Sync(context.Background(), srcDir, dstDir, cfg)
+	require.NoError(t, err)
+	require.NoFileExists(t, filepath.Join(dstDir, "b.txt"))
+}
@@ -0,0 +1,100 @@
+package syncer
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/ogzhanolguncu/mimic/internal/logger"
+)
+
+// windowsTarget reports whether scanned names must be storable on Windows; tests set it
+// to exercise the name handling on other platforms.
+var windowsTarget = runtime.GOOS == "windows"
+
+// windowsReserved holds the device names Windows reserves, with or without an extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// windowsNameProblem describes why Windows cannot store a file named name, or returns ""
+// when it can.
+func windowsNameProblem(name string) string {
+	stem, _, _ := strings.Cut(name, ".")
+	switch {
+	case windowsReserved[strings.ToUpper(strings.TrimRight(stem, " "))]:
+		return "reserved device name"
+	case strings.ContainsFunc(name, invalidWindowsRune):
+		return "invalid character"
+	case strings.HasSuffix(name, ".") || strings.HasSuffix(name, " "):
+		return "trailing dot or space"
+	}
+	return ""
+}
+
+func invalidWindowsRune(r rune) bool {
+	return r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r)
+}
+
+// sanitizeWindowsName maps name to one Windows can store: invalid characters and
+// trailing dots or spaces become '_', and reserved device names get a '_' appended to
+// their stem ("con.txt" becomes "con_.txt").
+func sanitizeWindowsName(name string) string {
+	sanitized := []rune(strings.Map(func(r rune) rune {
+		if invalidWindowsRune(r) {
+			return '_'
+		}
+		return r
+	}, name))
+	for i := len(sanitized) - 1; i >= 0 && (sanitized[i] == '.' || sanitized[i] == ' '); i-- {
+		sanitized[i] = '_'
+	}
+	name = string(sanitized)
+
+	stem, ext, hasExt := strings.Cut(name, ".")
+	if windowsReserved[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		name = stem + "_"
+		if hasExt {
+			name += "." + ext
+		}
+	}
+	return name
+}
+
+// sanitizeWindowsPath applies sanitizeWindowsName to every component of relPath.
+func sanitizeWindowsPath(relPath string) string {
+	components := strings.Split(relPath, string(filepath.Separator))
+	for i, component := range components {
+		components[i] = sanitizeWindowsName(component)
+	}
+	return filepath.Join(components...)
+}
+
+// windowsEntryPath decides where the scanned source entry relPath is recorded when
+// entries must be storable on Windows. Parents are scanned before their children, so
+// only the last component needs checking: with cfg.WindowsNames set to skip the entry
+// is dropped (skip is true), with replace the whole path is sanitized, and otherwise a
+// problem is an ErrSyncerWindowsName error.
+func windowsEntryPath(relPath string, cfg *config.Config) (mapped string, skip bool, err error) {
+	if !windowsTarget {
+		return relPath, false, nil
+	}
+	switch cfg.WindowsNames {
+	case config.WindowsNamesReplace:
+		return sanitizeWindowsPath(relPath), false, nil
+	case config.WindowsNamesSkip:
+		if problem := windowsNameProblem(filepath.Base(relPath)); problem != "" {
+			logger.Warn("name cannot be stored on Windows, skipping entry", "path", relPath, "problem", problem)
+			return "", true, nil
+		}
+	default:
+		if problem := windowsNameProblem(filepath.Base(relPath)); problem != "" {
+			return "", false, fmt.Errorf("%w: %s", ErrSyncerWindowsName, problem)
+		}
+	}
+	return relPath, false, nil
+}
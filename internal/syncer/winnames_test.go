@@ -0,0 +1,111 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ogzhanolguncu/mimic/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowsNames(t *testing.T) {
+	testCases := []struct {
+		name      string
+		problem   string
+		sanitized string
+	}{
+		{name: "report.txt", sanitized: "report.txt"},
+		{name: "CON", problem: "reserved device name", sanitized: "CON_"},
+		{name: "aux.tar.gz", problem: "reserved device name", sanitized: "aux_.tar.gz"},
+		{name: "Com7.log", problem: "reserved device name", sanitized: "Com7_.log"},
+		{name: "console.log", sanitized: "console.log"},
+		{name: "a:b?.txt", problem: "invalid character", sanitized: "a_b_.txt"},
+		{name: "notes.", problem: "trailing dot or space", sanitized: "notes_"},
+		{name: "draft ", problem: "trailing dot or space", sanitized: "draft_"},
+		{name: "nul.", problem: "reserved device name", sanitized: "nul_"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.problem, windowsNameProblem(tc.name))
+			require.Equal(t, tc.sanitized, sanitizeWindowsName(tc.name))
+			require.Empty(t, windowsNameProblem(tc.sanitized), "Expected the sanitized name to be storable")
+		})
+	}
+}
+
+func TestScanSourceWindowsNames(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("reserved names cannot be created on Windows")
+	}
+	defer func(prev bool) { windowsTarget = prev }(windowsTarget)
+	windowsTarget = true
+
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "aux", "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "aux", "sub", "data.txt"), []byte("data"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a:b.txt"), []byte("colon"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "fine.txt"), []byte("fine"), 0644))
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := ScanSource(srcDir, config.NewDefaultConfig())
+		require.ErrorIs(t, err, ErrSyncerWindowsName)
+		require.ErrorContains(t, err, "a:b.txt")
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.WindowsNames = config.WindowsNamesSkip
+
+		entries, err := ScanSource(srcDir, cfg)
+		require.NoError(t, err)
+		require.Len(t, entries, 1, "Expected the reserved directory and everything under it to be skipped")
+		require.Contains(t, entries, "fine.txt")
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.WindowsNames = config.WindowsNamesReplace
+
+		entries, err := ScanSource(srcDir, cfg)
+		require.NoError(t, err)
+		mapped := filepath.Join("aux_", "sub", "data.txt")
+		require.Contains(t, entries, mapped)
+		require.Equal(t, filepath.Join("aux", "sub", "data.txt"), entries[mapped].SourcePath)
+		require.Contains(t, entries, "a_b.txt")
+		require.Empty(t, entries["fine.txt"].SourcePath)
+
+		// Copies read the original source name and write the sanitized one
+		dstDir := t.TempDir()
+		actions := CompareStates(entries, map[string]EntryInfo{}, cfg)
+		_, err = ExecuteActionsTo(context.Background(), srcDir, NewLocalDestination(dstDir, cfg), actions, cfg, nil)
+		require.NoError(t, err)
+		got, err := os.ReadFile(filepath.Join(dstDir, mapped))
+		require.NoError(t, err)
+		require.Equal(t, "data", string(got))
+	})
+
+	t.Run("ReplaceCollision", func(t *testing.T) {
+		cfg := config.NewDefaultConfig()
+		cfg.WindowsNames = config.WindowsNamesReplace
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a_b.txt"), []byte("taken"), 0644))
+		defer os.Remove(filepath.Join(srcDir, "a_b.txt"))
+
+		_, err := ScanSource(srcDir, cfg)
+		require.ErrorIs(t, err, ErrSyncerWindowsName, "Expected two source names mapping to one destination name to fail")
+	})
+}
+
+func TestShouldExcludeWindowsSeparators(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("backslash is only a separator on Windows")
+	}
+
+	require.True(t, shouldExclude(`node_modules\pkg\index.js`, []string{"node_modules/"}))
+	require.True(t, shouldExclude(`src\gen\api.pb.go`, []string{"src/**/*.pb.go"}))
+	require.True(t, shouldExclude(`docs\build\out.log`, []string{"*.log"}))
+	require.False(t, shouldExclude(`src\main.go`, []string{"main.go/"}))
+}